@@ -61,6 +61,22 @@ func (e *Expression) resolve(index int, msg Message, escaped bool) []byte {
 	return buf.Bytes()
 }
 
+func (e *Expression) tryResolve(index int, msg Message, escaped bool) ([]byte, error) {
+	if len(e.resolvers) == 1 {
+		return e.resolvers[0].TryResolveBytes(index, msg, escaped)
+	}
+	var buf bytes.Buffer
+	var firstErr error
+	for _, r := range e.resolvers {
+		bs, err := r.TryResolveBytes(index, msg, escaped)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		buf.Write(bs)
+	}
+	return buf.Bytes(), firstErr
+}
+
 // NumDynamicExpressions returns the number of dynamic interpolation functions
 // within the expression.
 func (e *Expression) NumDynamicExpressions() int {
@@ -84,3 +100,26 @@ func (e *Expression) String(index int, msg Message) string {
 	}
 	return string(e.Bytes(index, msg))
 }
+
+// TryBytes returns a byte slice representing the expression resolved for a
+// message of a batch, along with the first error returned by an interpolation
+// function of the expression. When an error is returned the byte slice is the
+// value that Bytes would have returned.
+func (e *Expression) TryBytes(index int, msg Message) ([]byte, error) {
+	if len(e.resolvers) == 0 {
+		return []byte(e.static), nil
+	}
+	return e.tryResolve(index, msg, false)
+}
+
+// TryString returns a string representing the expression resolved for a
+// message of a batch, along with the first error returned by an interpolation
+// function of the expression. When an error is returned the string is the
+// value that String would have returned.
+func (e *Expression) TryString(index int, msg Message) (string, error) {
+	if len(e.resolvers) == 0 {
+		return e.static, nil
+	}
+	bs, err := e.TryBytes(index, msg)
+	return string(bs), err
+}
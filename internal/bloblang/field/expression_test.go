@@ -348,3 +348,71 @@ func TestExpressions(t *testing.T) {
 		})
 	}
 }
+
+func TestExpressionsTry(t *testing.T) {
+	mustFn := func(name string, args ...interface{}) query.Function {
+		fn, err := query.InitFunctionHelper(name, args...)
+		require.NoError(t, err)
+		return fn
+	}
+
+	tests := map[string]struct {
+		expression *Expression
+		content    string
+		output     string
+		err        string
+	}{
+		"static string": {
+			expression: NewExpression(StaticResolver("static string")),
+			output:     "static string",
+		},
+		"successful query": {
+			expression: NewExpression(
+				StaticResolver("foo "),
+				NewQueryResolver(mustFn("json", "foo")),
+			),
+			content: `{"foo":"bar"}`,
+			output:  "foo bar",
+		},
+		"failed query": {
+			expression: NewExpression(
+				StaticResolver("foo "),
+				NewQueryResolver(mustFn("throw", "nope")),
+			),
+			output: "foo ",
+			err:    "nope",
+		},
+		"first error returned": {
+			expression: NewExpression(
+				NewQueryResolver(mustFn("throw", "first")),
+				StaticResolver(" "),
+				NewQueryResolver(mustFn("throw", "second")),
+			),
+			output: " ",
+			err:    "first",
+		},
+		"single failed query": {
+			expression: NewExpression(NewQueryResolver(mustFn("json", "foo"))),
+			content:    `not json`,
+			output:     "null",
+			err:        "invalid character",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			msg := message.QuickBatch([][]byte{[]byte(test.content)})
+
+			res, err := test.expression.TryString(0, msg)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.output, res)
+			assert.Equal(t, test.expression.String(0, msg), res)
+		})
+	}
+}
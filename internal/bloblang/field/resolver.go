@@ -12,6 +12,11 @@ import (
 type Resolver interface {
 	ResolveString(index int, msg Message, escaped bool) string
 	ResolveBytes(index int, msg Message, escaped bool) []byte
+
+	// TryResolveBytes returns a byte slice along with any error that occurred
+	// whilst resolving it, in which case the byte slice is the value that
+	// ResolveBytes would have returned.
+	TryResolveBytes(index int, msg Message, escaped bool) ([]byte, error)
 }
 
 //------------------------------------------------------------------------------
@@ -30,6 +35,11 @@ func (s StaticResolver) ResolveBytes(index int, msg Message, escaped bool) []byt
 	return []byte(s)
 }
 
+// TryResolveBytes returns a byte slice and never errors.
+func (s StaticResolver) TryResolveBytes(index int, msg Message, escaped bool) ([]byte, error) {
+	return []byte(s), nil
+}
+
 //------------------------------------------------------------------------------
 
 // QueryResolver executes a query and returns a string representation of the
@@ -44,12 +54,11 @@ func NewQueryResolver(fn query.Function) *QueryResolver {
 	return &QueryResolver{fn}
 }
 
-// ResolveString returns a string.
-func (q QueryResolver) ResolveString(index int, msg Message, escaped bool) string {
+func (q QueryResolver) ctx(index int, msg Message) query.FunctionContext {
 	if msg == nil {
 		msg = message.QuickBatch(nil)
 	}
-	return query.ExecToString(q.fn, query.FunctionContext{
+	return query.FunctionContext{
 		Index:    index,
 		MsgBatch: msg,
 		NewMeta:  msg.Get(index),
@@ -58,28 +67,39 @@ func (q QueryResolver) ResolveString(index int, msg Message, escaped bool) strin
 			return &jObj
 		}
 		return nil
-	}))
+	})
+}
+
+// ResolveString returns a string.
+func (q QueryResolver) ResolveString(index int, msg Message, escaped bool) string {
+	return query.ExecToString(q.fn, q.ctx(index, msg))
 }
 
 // ResolveBytes returns a byte slice.
 func (q QueryResolver) ResolveBytes(index int, msg Message, escaped bool) []byte {
-	if msg == nil {
-		msg = message.QuickBatch(nil)
+	bs := query.ExecToBytes(q.fn, q.ctx(index, msg))
+	if escaped {
+		bs = escapeBytes(bs)
 	}
-	bs := query.ExecToBytes(q.fn, query.FunctionContext{
-		Index:    index,
-		MsgBatch: msg,
-		NewMeta:  msg.Get(index),
-	}.WithValueFunc(func() *interface{} {
-		if jObj, err := msg.Get(index).JSON(); err == nil {
-			return &jObj
+	return bs
+}
+
+// TryResolveBytes returns a byte slice along with any error returned by the
+// query.
+func (q QueryResolver) TryResolveBytes(index int, msg Message, escaped bool) ([]byte, error) {
+	var bs []byte
+	v, err := q.fn.Exec(q.ctx(index, msg))
+	if err != nil {
+		if rec, ok := err.(*query.ErrRecoverable); ok {
+			bs = query.IToBytes(rec.Recovered)
 		}
-		return nil
-	}))
+	} else {
+		bs = query.IToBytes(v)
+	}
 	if escaped {
 		bs = escapeBytes(bs)
 	}
-	return bs
+	return bs, err
 }
 
 func escapeBytes(in []byte) []byte {
@@ -840,6 +840,84 @@ func TestMethods(t *testing.T) {
 			),
 			output: `<<???>>`,
 		},
+		"check base64_decode_auto standard": {
+			input: methods(
+				literalFn("PDw/Pz8+Pg=="),
+				method("base64_decode_auto"),
+				method("string"),
+			),
+			output: `<<???>>`,
+		},
+		"check base64_decode_auto url": {
+			input: methods(
+				literalFn("PDw_Pz8-Pg=="),
+				method("base64_decode_auto"),
+				method("string"),
+			),
+			output: `<<???>>`,
+		},
+		"check base64_decode_auto unpadded": {
+			input: methods(
+				literalFn("aGVsbG8gd29ybGQ"),
+				method("base64_decode_auto"),
+				method("string"),
+			),
+			output: `hello world`,
+		},
+		"check base64_decode_auto invalid": {
+			input: methods(
+				literalFn("PDw/Pz8-Pg=="),
+				method("base64_decode_auto"),
+			),
+			err: `string literal: input is not valid base64 or base64url: illegal base64 data at input byte 3`,
+		},
+		"check parse_jwt": {
+			input: methods(
+				literalFn("eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28iLCJuIjoxfQ."),
+				method("parse_jwt"),
+			),
+			output: map[string]interface{}{
+				"header":    map[string]interface{}{"alg": "none"},
+				"payload":   map[string]interface{}{"sub": "foo", "n": float64(1)},
+				"signature": "",
+			},
+		},
+		"check parse_jwt padded": {
+			input: methods(
+				literalFn("eyJhbGciOiJub25lIn0=.eyJzdWIiOiJmb28iLCJuIjoxfQ==.c2ln"),
+				method("parse_jwt"),
+				method("get", "signature"),
+			),
+			output: "c2ln",
+		},
+		"check parse_jwt segments": {
+			input: methods(
+				literalFn("eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28iLCJuIjoxfQ"),
+				method("parse_jwt"),
+			),
+			err: `string literal: failed to parse value as JWT: expected 3 segments, got 2`,
+		},
+		"check parse_jwt bad base64": {
+			input: methods(
+				literalFn("eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28i$CJuIjoxfQ.c2ln"),
+				method("parse_jwt"),
+			),
+			err: `string literal: failed to parse value as JWT: failed to decode payload: illegal base64 data at input byte 16`,
+		},
+		"check parse_jwt not an object": {
+			input: methods(
+				literalFn("WyJmb28iXQ.eyJzdWIiOiJmb28iLCJuIjoxfQ.c2ln"),
+				method("parse_jwt"),
+			),
+			err: `string literal: failed to parse value as JWT: header is not a JSON object`,
+		},
+		"check parse_jwt bad signature": {
+			input: methods(
+				literalFn("eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28iLCJuIjoxfQ.c2l+"),
+				method("parse_jwt"),
+			),
+			err: `string literal: failed to parse value as JWT: failed to decode signature: illegal base64 data at input byte 3`,
+		},
 		"check z85 encode": {
 			input: methods(
 				literalFn("hello world!"),
@@ -350,11 +350,10 @@ func (p Params) processNamed(args map[string]interface{}) ([]interface{}, error)
 	for i, param := range p.Definitions {
 		v, exists := args[param.Name]
 		if !exists {
-			if param.IsOptional {
-				continue
-			}
 			if param.DefaultValue == nil {
-				missingParams = append(missingParams, param.Name)
+				if !param.IsOptional {
+					missingParams = append(missingParams, param.Name)
+				}
 				continue
 			}
 			v = *param.DefaultValue
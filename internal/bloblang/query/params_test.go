@@ -272,6 +272,17 @@ func TestParamsNamed(t *testing.T) {
 				"bar", nil, nil,
 			},
 		},
+		{
+			name: "basic fields optional with defaults",
+			params: NewParams().
+				Add(ParamString("first", "")).
+				Add(ParamInt64("second", "").Optional().Default(5)).
+				Add(ParamBool("third", "").Optional()),
+			input: map[string]interface{}{"first": "bar"},
+			output: []interface{}{
+				"bar", int64(5), nil,
+			},
+		},
 		{
 			name: "missing field",
 			params: NewParams().
@@ -725,3 +736,45 @@ func TestParsedParamsOptional(t *testing.T) {
 	require.NoError(t, err)
 	assert.Nil(t, q)
 }
+
+func TestParsedParamsOptionalDefaults(t *testing.T) {
+	params := NewParams().
+		Add(ParamString("first", "")).
+		Add(ParamString("second", "").Optional().Default("two")).
+		Add(ParamInt64("third", "").Optional())
+
+	named, err := params.PopulateNamed(map[string]interface{}{"first": "one"})
+	require.NoError(t, err)
+
+	nameless, err := params.PopulateNameless("one")
+	require.NoError(t, err)
+
+	// Omitted optional params take their default when they have one,
+	// regardless of whether the args are named.
+	for _, parsed := range []*ParsedParams{named, nameless} {
+		s, err := parsed.FieldString("second")
+		require.NoError(t, err)
+		assert.Equal(t, "two", s)
+
+		i, err := parsed.FieldOptionalInt64("third")
+		require.NoError(t, err)
+		assert.Nil(t, i)
+	}
+
+	named, err = params.PopulateNamed(map[string]interface{}{
+		"first": "one", "second": "2", "third": 3,
+	})
+	require.NoError(t, err)
+
+	s, err := named.FieldString("second")
+	require.NoError(t, err)
+	assert.Equal(t, "2", s)
+
+	i, err := named.FieldOptionalInt64("third")
+	require.NoError(t, err)
+	require.NotNil(t, i)
+	assert.Equal(t, int64(3), *i)
+
+	_, err = params.PopulateNamed(map[string]interface{}{"second": "2"})
+	require.EqualError(t, err, "missing parameter: first")
+}
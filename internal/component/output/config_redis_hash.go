@@ -1,27 +1,58 @@
 package output
 
 import (
+	"github.com/benthosdev/benthos/v4/internal/batch/policy/batchconfig"
 	bredis "github.com/benthosdev/benthos/v4/internal/impl/redis/old"
 )
 
 // RedisHashConfig contains configuration fields for the RedisHash output type.
 type RedisHashConfig struct {
-	bredis.Config  `json:",inline" yaml:",inline"`
-	Key            string            `json:"key" yaml:"key"`
-	WalkMetadata   bool              `json:"walk_metadata" yaml:"walk_metadata"`
-	WalkJSONObject bool              `json:"walk_json_object" yaml:"walk_json_object"`
-	Fields         map[string]string `json:"fields" yaml:"fields"`
-	MaxInFlight    int               `json:"max_in_flight" yaml:"max_in_flight"`
+	bredis.Config       `json:",inline" yaml:",inline"`
+	Key                 string             `json:"key" yaml:"key"`
+	WalkMetadata        bool               `json:"walk_metadata" yaml:"walk_metadata"`
+	MetadataExclude     []string           `json:"metadata_exclude" yaml:"metadata_exclude"`
+	WalkJSONObject      bool               `json:"walk_json_object" yaml:"walk_json_object"`
+	Fields              map[string]string  `json:"fields" yaml:"fields"`
+	OnInterpError       string             `json:"on_interp_error" yaml:"on_interp_error"`
+	DeleteEmpty         bool               `json:"delete_empty" yaml:"delete_empty"`
+	Diff                bool               `json:"diff" yaml:"diff"`
+	OnlyIfChanged       bool               `json:"only_if_changed" yaml:"only_if_changed"`
+	SanitizeFieldNames  bool               `json:"sanitize_field_names" yaml:"sanitize_field_names"`
+	SanitizeCharacters  string             `json:"sanitize_characters" yaml:"sanitize_characters"`
+	SanitizeReplacement string             `json:"sanitize_replacement" yaml:"sanitize_replacement"`
+	WaitReplicas        int                `json:"wait_replicas" yaml:"wait_replicas"`
+	WaitTimeout         string             `json:"wait_timeout" yaml:"wait_timeout"`
+	Expiration          string             `json:"expiration" yaml:"expiration"`
+	FieldExpiry         map[string]string  `json:"field_expiry" yaml:"field_expiry"`
+	CountNewFields      bool               `json:"count_new_fields" yaml:"count_new_fields"`
+	SetCommand          string             `json:"set_command" yaml:"set_command"`
+	MaxInFlight         int                `json:"max_in_flight" yaml:"max_in_flight"`
+	Batching            batchconfig.Config `json:"batching" yaml:"batching"`
 }
 
 // NewRedisHashConfig creates a new RedisHashConfig with default values.
 func NewRedisHashConfig() RedisHashConfig {
 	return RedisHashConfig{
-		Config:         bredis.NewConfig(),
-		Key:            "",
-		WalkMetadata:   false,
-		WalkJSONObject: false,
-		Fields:         map[string]string{},
-		MaxInFlight:    64,
+		Config:              bredis.NewConfig(),
+		Key:                 "",
+		WalkMetadata:        false,
+		MetadataExclude:     []string{},
+		WalkJSONObject:      false,
+		Fields:              map[string]string{},
+		OnInterpError:       "fail",
+		DeleteEmpty:         false,
+		Diff:                false,
+		OnlyIfChanged:       false,
+		SanitizeFieldNames:  false,
+		SanitizeCharacters:  " ,.<>{}[]\"':;!@#$%^&*()-+=~|/\\",
+		SanitizeReplacement: "_",
+		WaitReplicas:        0,
+		WaitTimeout:         "1s",
+		Expiration:          "",
+		FieldExpiry:         map[string]string{},
+		CountNewFields:      false,
+		SetCommand:          "hmset",
+		MaxInFlight:         64,
+		Batching:            batchconfig.NewConfig(),
 	}
 }
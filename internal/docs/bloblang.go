@@ -25,11 +25,11 @@ func LintBloblangMapping(ctx LintContext, line, col int, v interface{}) []Lint {
 	}
 	if mErr, ok := err.(*parser.Error); ok {
 		bline, bcol := parser.LineAndColOf([]rune(str), mErr.Input)
-		lint := NewLintError(line+bline-1, mErr.ErrorAtPositionStructured("", []rune(str)))
+		lint := NewLintError(line+bline-1, LintBadBloblang, mErr.ErrorAtPositionStructured("", []rune(str)))
 		lint.Column = col + bcol
 		return []Lint{lint}
 	}
-	return []Lint{NewLintError(line, err.Error())}
+	return []Lint{NewLintError(line, LintBadBloblang, err.Error())}
 }
 
 // LintBloblangField is function for linting a config field expected to be an
@@ -48,11 +48,29 @@ func LintBloblangField(ctx LintContext, line, col int, v interface{}) []Lint {
 	}
 	if mErr, ok := err.(*parser.Error); ok {
 		bline, bcol := parser.LineAndColOf([]rune(str), mErr.Input)
-		lint := NewLintError(line+bline-1, mErr.ErrorAtPositionStructured("", []rune(str)))
+		lint := NewLintError(line+bline-1, LintBadBloblang, mErr.ErrorAtPositionStructured("", []rune(str)))
 		lint.Column = col + bcol
 		return []Lint{lint}
 	}
-	return []Lint{NewLintError(line, err.Error())}
+	return []Lint{NewLintError(line, LintBadBloblang, err.Error())}
+}
+
+// LintRequiredInterpolatedField is a function for linting a required config
+// field expected to be an interpolation string. Values that are empty or only
+// contain whitespace are almost always a mistake and are flagged with a
+// warning, whereas values containing interpolation functions are ignored.
+//
+// This lint is not applied automatically, and is added to fields that are
+// required in practice with LinterFunc.
+func LintRequiredInterpolatedField(ctx LintContext, line, col int, v interface{}) []Lint {
+	str, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	if strings.TrimSpace(str) != "" {
+		return nil
+	}
+	return []Lint{NewLintWarning(line, LintEmptyInterpolation, "required interpolated field is empty, which is likely a mistake")}
 }
 
 type functionCategory struct {
@@ -47,13 +47,13 @@ var labelField = FieldString(
 	}
 	if err := ValidateLabel(l); err != nil {
 		return []Lint{
-			NewLintError(line, fmt.Sprintf("Invalid label '%v': %v", l, err)),
+			NewLintError(line, LintBadLabel, fmt.Sprintf("Invalid label '%v': %v", l, err)),
 		}
 	}
 	prevLine, exists := ctx.LabelsToLine[l]
 	if exists {
 		return []Lint{
-			NewLintError(line, fmt.Sprintf("Label '%v' collides with a previously defined label at line %v", l, prevLine)),
+			NewLintError(line, LintDuplicateLabel, fmt.Sprintf("Label '%v' collides with a previously defined label at line %v", l, prevLine)),
 		}
 	}
 	ctx.LabelsToLine[l] = line
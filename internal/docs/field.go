@@ -1,6 +1,7 @@
 package docs
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/benthosdev/benthos/v4/internal/bloblang"
@@ -294,7 +295,7 @@ func (f FieldSpec) LinterBlobl(blobl string) FieldSpec {
 	m, err := env.NewMapping(blobl)
 	if err != nil {
 		f.customLintFn = func(ctx LintContext, line, col int, value interface{}) (lints []Lint) {
-			return []Lint{NewLintError(line, fmt.Sprintf("Field lint mapping itself failed to parse: %v", err))}
+			return []Lint{NewLintError(line, LintCustom, fmt.Sprintf("Field lint mapping itself failed to parse: %v", err))}
 		}
 		return f
 	}
@@ -307,18 +308,18 @@ func (f FieldSpec) LinterBlobl(blobl string) FieldSpec {
 			MsgBatch: message.QuickBatch(nil),
 		}.WithValue(value))
 		if err != nil {
-			return []Lint{NewLintError(line, err.Error())}
+			return []Lint{NewLintError(line, LintCustom, err.Error())}
 		}
 		switch t := res.(type) {
 		case []interface{}:
 			for _, e := range t {
 				if what, _ := e.(string); len(what) > 0 {
-					lints = append(lints, NewLintError(line, what))
+					lints = append(lints, NewLintError(line, LintCustom, what))
 				}
 			}
 		case string:
 			if len(t) > 0 {
-				lints = append(lints, NewLintError(line, t))
+				lints = append(lints, NewLintError(line, LintCustom, t))
 			}
 		}
 		return
@@ -349,7 +350,7 @@ func (f FieldSpec) lintOptions() FieldSpec {
 				}
 			}
 		}
-		return []Lint{NewLintError(line, fmt.Sprintf("value %v is not a valid option for this field", str))}
+		return []Lint{NewLintError(line, LintInvalidOption, fmt.Sprintf("value %v is not a valid option for this field", str))}
 	}
 	return f
 }
@@ -563,6 +564,11 @@ type LintContext struct {
 
 	// Reject any deprecated components or fields as linting errors.
 	RejectDeprecated bool
+
+	// The least severe level of lints to emit, lints of a lower severity are
+	// discarded. Informational lints are opt-in and therefore not emitted by
+	// default.
+	MinLevel LintLevel
 }
 
 // NewLintContext creates a new linting context.
@@ -572,19 +578,103 @@ func NewLintContext() LintContext {
 		DocsProvider:     DeprecatedProvider,
 		BloblangEnv:      bloblang.GlobalEnvironment().Deactivated(),
 		RejectDeprecated: false,
+		MinLevel:         LintWarning,
+	}
+}
+
+func (ctx LintContext) filterLints(lints []Lint) []Lint {
+	if ctx.MinLevel >= LintInfo {
+		return lints
 	}
+	var filtered []Lint
+	for _, l := range lints {
+		if l.Level <= ctx.MinLevel {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
 }
 
 // LintFunc is a common linting function for field values.
 type LintFunc func(ctx LintContext, line, col int, value interface{}) []Lint
 
-// LintLevel describes the severity level of a linting error.
+// LintLevel describes the severity level of a linting error, where lower
+// levels are more severe.
 type LintLevel int
 
 // Lint levels
 const (
 	LintError   LintLevel = iota
 	LintWarning LintLevel = iota
+	LintInfo    LintLevel = iota
+)
+
+// String returns a human readable name of the lint level.
+func (l LintLevel) String() string {
+	switch l {
+	case LintError:
+		return "error"
+	case LintWarning:
+		return "warning"
+	case LintInfo:
+		return "info"
+	}
+	return "unknown"
+}
+
+// LintType is a stable identifier of the rule that produced a lint, which can
+// be used in order to filter lints by category.
+type LintType string
+
+// Lint types
+const (
+	// A lint produced by a custom linting function of a field or component.
+	LintCustom LintType = "custom"
+
+	// A value is not one of the options of a field.
+	LintInvalidOption LintType = "invalid_option"
+
+	// A label is not valid.
+	LintBadLabel LintType = "bad_label"
+
+	// A label collides with a previously defined label.
+	LintDuplicateLabel LintType = "duplicate_label"
+
+	// A Bloblang mapping or interpolation fails to parse.
+	LintBadBloblang LintType = "bad_bloblang"
+
+	// A required interpolated field is empty.
+	LintEmptyInterpolation LintType = "empty_interpolation"
+
+	// A field should be omitted.
+	LintShouldOmit LintType = "should_omit"
+
+	// The type of a component could not be inferred.
+	LintComponentMissing LintType = "component_missing"
+
+	// The type of a component is not recognised.
+	LintComponentNotFound LintType = "component_not_found"
+
+	// A field is not recognised.
+	LintUnknown LintType = "unknown_field"
+
+	// A plugin object has no effect on its component.
+	LintIneffectivePlugin LintType = "ineffective_plugin"
+
+	// A required field is missing.
+	LintMissing LintType = "missing_field"
+
+	// A field expected an array value.
+	LintExpectedArray LintType = "expected_array"
+
+	// A field expected an object value.
+	LintExpectedObject LintType = "expected_object"
+
+	// A field expected a scalar value.
+	LintExpectedScalar LintType = "expected_scalar"
+
+	// A component or field is deprecated.
+	LintDeprecated LintType = "deprecated"
 )
 
 // Lint describes a single linting issue found with a Benthos config.
@@ -592,17 +682,49 @@ type Lint struct {
 	Line   int
 	Column int // Optional, omitted from lint report unless >= 1
 	Level  LintLevel
+	Type   LintType
 	What   string
 }
 
 // NewLintError returns an error lint.
-func NewLintError(line int, msg string) Lint {
-	return Lint{Line: line, Level: LintError, What: msg}
+func NewLintError(line int, t LintType, msg string) Lint {
+	return Lint{Line: line, Level: LintError, Type: t, What: msg}
 }
 
 // NewLintWarning returns a warning lint.
-func NewLintWarning(line int, msg string) Lint {
-	return Lint{Line: line, Level: LintWarning, What: msg}
+func NewLintWarning(line int, t LintType, msg string) Lint {
+	return Lint{Line: line, Level: LintWarning, Type: t, What: msg}
+}
+
+// NewLintInfo returns an informational lint, which describes a hint rather than
+// a problem.
+func NewLintInfo(line int, t LintType, msg string) Lint {
+	return Lint{Line: line, Level: LintInfo, Type: t, What: msg}
+}
+
+type jsonLint struct {
+	Line     int      `json:"line"`
+	Column   int      `json:"column,omitempty"`
+	Severity string   `json:"severity"`
+	Rule     LintType `json:"rule"`
+	Message  string   `json:"message"`
+}
+
+// LintsToJSON serializes a slice of lints into a JSON array of objects, each
+// containing the severity, line, column and rule of a lint along with its
+// message. The column is omitted unless it is >= 1.
+func LintsToJSON(lints []Lint) ([]byte, error) {
+	jLints := make([]jsonLint, 0, len(lints))
+	for _, l := range lints {
+		jLints = append(jLints, jsonLint{
+			Line:     l.Line,
+			Column:   l.Column,
+			Severity: l.Level.String(),
+			Rule:     l.Type,
+			Message:  l.What,
+		})
+	}
+	return json.Marshal(jLints)
 }
 
 //------------------------------------------------------------------------------
@@ -36,22 +36,22 @@ func TestBloblLinter(t *testing.T) {
 			name:  "Single type lint",
 			input: "",
 			expected: []Lint{
-				{What: "expected non-empty string, got empty string"},
+				{Type: LintCustom, What: "expected non-empty string, got empty string"},
 			},
 		},
 		{
 			name:  "One lint",
 			input: `hello meow world`,
 			expected: []Lint{
-				{What: "no cats allowed"},
+				{Type: LintCustom, What: "no cats allowed"},
 			},
 		},
 		{
 			name:  "Two lints",
 			input: `hello woof world`,
 			expected: []Lint{
-				{What: "no dogs allowed"},
-				{What: "no noise allowed"},
+				{Type: LintCustom, What: "no dogs allowed"},
+				{Type: LintCustom, What: "no noise allowed"},
 			},
 		},
 	}
@@ -68,3 +68,25 @@ func TestBloblLinter(t *testing.T) {
 	}
 
 }
+
+func TestLintsToJSON(t *testing.T) {
+	lints := []Lint{
+		NewLintError(3, LintUnknown, "field foo not recognised"),
+		NewLintWarning(5, LintEmptyInterpolation, "required interpolated field is empty, which is likely a mistake"),
+		{Line: 7, Column: 12, Level: LintError, Type: LintBadBloblang, What: "expected query"},
+		NewLintInfo(9, LintCustom, "this is a hint"),
+	}
+
+	jBytes, err := LintsToJSON(lints)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+  {"line":3,"severity":"error","rule":"unknown_field","message":"field foo not recognised"},
+  {"line":5,"severity":"warning","rule":"empty_interpolation","message":"required interpolated field is empty, which is likely a mistake"},
+  {"line":7,"column":12,"severity":"error","rule":"bad_bloblang","message":"expected query"},
+  {"line":9,"severity":"info","rule":"custom","message":"this is a hint"}
+]`, string(jBytes))
+
+	jBytes, err = LintsToJSON(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(jBytes))
+}
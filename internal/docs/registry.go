@@ -1,6 +1,7 @@
 package docs
 
 import (
+	"sort"
 	"sync"
 )
 
@@ -10,6 +11,13 @@ type Provider interface {
 	GetDocs(name string, ctype Type) (ComponentSpec, bool)
 }
 
+// ListingProvider is a Provider that is also able to list the component spec
+// definitions of all registered implementations of a given type.
+type ListingProvider interface {
+	Provider
+	ListDocs(ctype Type) []ComponentSpec
+}
+
 // DeprecatedProvider is a globally declared docs provider that enables the old
 // config parse style to work with dynamic plugins. Eventually we can eliminate
 // all the UnmarshalYAML methods on config structs and remove this as well.
@@ -140,3 +148,39 @@ func (m *MappedDocsProvider) GetDocs(name string, ctype Type) (ComponentSpec, bo
 
 	return spec, ok
 }
+
+// ListDocs returns the docs of all registered implementations of a component
+// type, sorted by name.
+func (m *MappedDocsProvider) ListDocs(ctype Type) []ComponentSpec {
+	m.componentLock.Lock()
+	defer m.componentLock.Unlock()
+
+	var specMap map[string]ComponentSpec
+	switch ctype {
+	case TypeBuffer:
+		specMap = m.bufferMap
+	case TypeCache:
+		specMap = m.cacheMap
+	case TypeInput:
+		specMap = m.inputMap
+	case TypeMetrics:
+		specMap = m.metricsMap
+	case TypeOutput:
+		specMap = m.outputMap
+	case TypeProcessor:
+		specMap = m.processorMap
+	case TypeRateLimit:
+		specMap = m.rateLimitMap
+	case TypeTracer:
+		specMap = m.tracerMap
+	}
+
+	specs := make([]ComponentSpec, 0, len(specMap))
+	for _, spec := range specMap {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+	return specs
+}
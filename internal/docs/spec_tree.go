@@ -0,0 +1,18 @@
+package docs
+
+import (
+	"encoding/json"
+)
+
+// SpecTreeJSON serializes the specs of all components registered with a
+// provider into a single JSON document, where specs are grouped by component
+// type and each group is sorted by component name. The output is intended for
+// driving config editors and is stable across calls with the same registered
+// components, making it suitable for diffing.
+func SpecTreeJSON(p ListingProvider) ([]byte, error) {
+	tree := make(map[Type][]ComponentSpec, len(Types()))
+	for _, t := range Types() {
+		tree[t] = p.ListDocs(t)
+	}
+	return json.MarshalIndent(tree, "", "  ")
+}
@@ -0,0 +1,98 @@
+package docs_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/docs"
+)
+
+func TestSpecTreeJSON(t *testing.T) {
+	docsProv := docs.NewMappedDocsProvider()
+	docsProv.RegisterDocs(docs.ComponentSpec{
+		Name:       "zed",
+		Type:       docs.TypeProcessor,
+		Status:     docs.StatusBeta,
+		Categories: []string{"Utility"},
+		Summary:    "Does zed things.",
+		Version:    "4.1.0",
+		Config: docs.FieldComponent().WithChildren(
+			docs.FieldInterpolatedString("path", "A path.").HasDefault("/foo"),
+			docs.FieldInt("old", "An old field.").HasDefault(5).Deprecated(),
+		),
+	})
+	docsProv.RegisterDocs(docs.ComponentSpec{
+		Name:   "alpha",
+		Type:   docs.TypeProcessor,
+		Status: docs.StatusStable,
+		Config: docs.FieldComponent().WithChildren(
+			docs.FieldBool("enabled", "Whether it's enabled.").HasDefault(true),
+		),
+	})
+	docsProv.RegisterDocs(docs.ComponentSpec{
+		Name:   "meow",
+		Type:   docs.TypeCache,
+		Status: docs.StatusStable,
+		Config: docs.FieldComponent().WithChildren(
+			docs.FieldString("key", "A key."),
+		),
+	})
+
+	first, err := docs.SpecTreeJSON(docsProv)
+	require.NoError(t, err)
+
+	second, err := docs.SpecTreeJSON(docsProv.Clone())
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+
+	var tree map[string][]struct {
+		Name       string   `json:"name"`
+		Status     string   `json:"status"`
+		Categories []string `json:"categories"`
+		Summary    string   `json:"summary"`
+		Version    string   `json:"version"`
+		Config     struct {
+			Children []struct {
+				Name         string      `json:"name"`
+				Type         string      `json:"type"`
+				Default      interface{} `json:"default"`
+				Interpolated bool        `json:"interpolated"`
+				IsDeprecated bool        `json:"is_deprecated"`
+			} `json:"children"`
+		} `json:"config"`
+	}
+	require.NoError(t, json.Unmarshal(first, &tree))
+
+	for _, ctype := range docs.Types() {
+		assert.Contains(t, tree, string(ctype))
+	}
+	assert.Empty(t, tree["input"])
+
+	require.Len(t, tree["cache"], 1)
+	assert.Equal(t, "meow", tree["cache"][0].Name)
+
+	procs := tree["processor"]
+	require.Len(t, procs, 2)
+	assert.Equal(t, "alpha", procs[0].Name)
+	assert.Equal(t, "zed", procs[1].Name)
+
+	assert.Equal(t, "beta", procs[1].Status)
+	assert.Equal(t, []string{"Utility"}, procs[1].Categories)
+	assert.Equal(t, "Does zed things.", procs[1].Summary)
+	assert.Equal(t, "4.1.0", procs[1].Version)
+
+	fields := procs[1].Config.Children
+	require.Len(t, fields, 2)
+	assert.Equal(t, "path", fields[0].Name)
+	assert.Equal(t, "string", fields[0].Type)
+	assert.Equal(t, "/foo", fields[0].Default)
+	assert.True(t, fields[0].Interpolated)
+	assert.False(t, fields[0].IsDeprecated)
+	assert.Equal(t, "old", fields[1].Name)
+	assert.Equal(t, "int", fields[1].Type)
+	assert.Equal(t, 5.0, fields[1].Default)
+	assert.True(t, fields[1].IsDeprecated)
+}
@@ -391,7 +391,7 @@ func (f FieldSpecs) SanitiseYAML(node *yaml.Node, conf SanitiseConfig) error {
 func lintYAMLFromOmit(parentSpec FieldSpecs, lintTargetSpec FieldSpec, parent, node *yaml.Node) []Lint {
 	why, shouldOmit := lintTargetSpec.shouldOmitYAML(parentSpec, node, parent)
 	if shouldOmit {
-		return []Lint{NewLintError(node.Line, why)}
+		return []Lint{NewLintError(node.Line, LintShouldOmit, why)}
 	}
 	return nil
 }
@@ -422,10 +422,14 @@ func customLintFromYAML(ctx LintContext, spec FieldSpec, node *yaml.Node) []Lint
 // LintYAML takes a yaml.Node and a config spec and returns a list of linting
 // errors found in the config.
 func LintYAML(ctx LintContext, cType Type, node *yaml.Node) []Lint {
+	return ctx.filterLints(lintYAML(ctx, cType, node))
+}
+
+func lintYAML(ctx LintContext, cType Type, node *yaml.Node) []Lint {
 	if cType == "condition" {
 		if ctx.RejectDeprecated {
 			return []Lint{
-				NewLintError(node.Line, "condition components are deprecated, use bloblang mappings instead when `check` fields or other alternatives are available"),
+				NewLintError(node.Line, LintDeprecated, "condition components are deprecated, use bloblang mappings instead when `check` fields or other alternatives are available"),
 			}
 		}
 		return nil
@@ -451,26 +455,26 @@ func LintYAML(ctx LintContext, cType Type, node *yaml.Node) []Lint {
 		}
 		var err error
 		if name, _, err = getInferenceCandidateFromList(ctx.DocsProvider, cType, keys); err != nil {
-			lints = append(lints, NewLintWarning(node.Line, "unable to infer component type"))
+			lints = append(lints, NewLintWarning(node.Line, LintComponentMissing, "unable to infer component type"))
 			return lints
 		}
 	}
 
 	cSpec, exists := ctx.DocsProvider.GetDocs(name, cType)
 	if !exists {
-		lints = append(lints, NewLintWarning(node.Line, fmt.Sprintf("failed to obtain docs for %v type %v", cType, name)))
+		lints = append(lints, NewLintWarning(node.Line, LintComponentNotFound, fmt.Sprintf("failed to obtain docs for %v type %v", cType, name)))
 		return lints
 	}
 
 	if ctx.RejectDeprecated && cSpec.Status == StatusDeprecated {
-		lints = append(lints, NewLintError(node.Line, fmt.Sprintf("component %v is deprecated", cSpec.Name)))
+		lints = append(lints, NewLintError(node.Line, LintDeprecated, fmt.Sprintf("component %v is deprecated", cSpec.Name)))
 	}
 
 	nameFound := false
 	for i := 0; i < len(node.Content)-1; i += 2 {
 		if node.Content[i].Value == name {
 			nameFound = true
-			lints = append(lints, cSpec.Config.LintYAML(ctx, node.Content[i+1])...)
+			lints = append(lints, cSpec.Config.lintYAML(ctx, node.Content[i+1])...)
 			break
 		}
 	}
@@ -482,18 +486,18 @@ func LintYAML(ctx LintContext, cType Type, node *yaml.Node) []Lint {
 		}
 		if node.Content[i].Value == "plugin" {
 			if nameFound || !cSpec.Plugin {
-				lints = append(lints, NewLintError(node.Content[i].Line, "plugin object is ineffective"))
+				lints = append(lints, NewLintError(node.Content[i].Line, LintIneffectivePlugin, "plugin object is ineffective"))
 			} else {
-				lints = append(lints, cSpec.Config.LintYAML(ctx, node.Content[i+1])...)
+				lints = append(lints, cSpec.Config.lintYAML(ctx, node.Content[i+1])...)
 			}
 		}
 		spec, exists := reservedFields[node.Content[i].Value]
 		if exists {
 			lints = append(lints, lintYAMLFromOmit(cSpec.Config.Children, spec, node, node.Content[i+1])...)
-			lints = append(lints, spec.LintYAML(ctx, node.Content[i+1])...)
+			lints = append(lints, spec.lintYAML(ctx, node.Content[i+1])...)
 		} else {
 			lints = append(lints, NewLintError(
-				node.Content[i].Line,
+				node.Content[i].Line, LintUnknown,
 				fmt.Sprintf("field %v is invalid when the component type is %v (%v)", node.Content[i].Value, name, cType),
 			))
 		}
@@ -505,12 +509,16 @@ func LintYAML(ctx LintContext, cType Type, node *yaml.Node) []Lint {
 // LintYAML returns a list of linting errors found by checking a field
 // definition against a yaml node.
 func (f FieldSpec) LintYAML(ctx LintContext, node *yaml.Node) []Lint {
+	return ctx.filterLints(f.lintYAML(ctx, node))
+}
+
+func (f FieldSpec) lintYAML(ctx LintContext, node *yaml.Node) []Lint {
 	node = unwrapDocumentNode(node)
 
 	var lints []Lint
 
 	if ctx.RejectDeprecated && f.IsDeprecated {
-		lints = append(lints, NewLintError(node.Line, fmt.Sprintf("field %v is deprecated", f.Name)))
+		lints = append(lints, NewLintError(node.Line, LintDeprecated, fmt.Sprintf("field %v is deprecated", f.Name)))
 	}
 
 	// Execute custom linters, if the kind is non-scalar this means we execute
@@ -522,41 +530,41 @@ func (f FieldSpec) LintYAML(ctx LintContext, node *yaml.Node) []Lint {
 	switch f.Kind {
 	case Kind2DArray:
 		if node.Kind != yaml.SequenceNode {
-			lints = append(lints, NewLintError(node.Line, "expected array value"))
+			lints = append(lints, NewLintError(node.Line, LintExpectedArray, "expected array value"))
 			return lints
 		}
 		for i := 0; i < len(node.Content); i++ {
-			lints = append(lints, f.Array().LintYAML(ctx, node.Content[i])...)
+			lints = append(lints, f.Array().lintYAML(ctx, node.Content[i])...)
 		}
 		return lints
 	case KindArray:
 		if node.Kind != yaml.SequenceNode {
-			lints = append(lints, NewLintError(node.Line, "expected array value"))
+			lints = append(lints, NewLintError(node.Line, LintExpectedArray, "expected array value"))
 			return lints
 		}
 		for i := 0; i < len(node.Content); i++ {
-			lints = append(lints, f.Scalar().LintYAML(ctx, node.Content[i])...)
+			lints = append(lints, f.Scalar().lintYAML(ctx, node.Content[i])...)
 		}
 		return lints
 	case KindMap:
 		if node.Kind != yaml.MappingNode {
-			lints = append(lints, NewLintError(node.Line, "expected object value"))
+			lints = append(lints, NewLintError(node.Line, LintExpectedObject, "expected object value"))
 			return lints
 		}
 		for i := 0; i < len(node.Content)-1; i += 2 {
-			lints = append(lints, f.Scalar().LintYAML(ctx, node.Content[i+1])...)
+			lints = append(lints, f.Scalar().lintYAML(ctx, node.Content[i+1])...)
 		}
 		return lints
 	}
 
 	// If we're a core type then execute component specific linting
 	if coreType, isCore := f.Type.IsCoreComponent(); isCore {
-		return append(lints, LintYAML(ctx, coreType, node)...)
+		return append(lints, lintYAML(ctx, coreType, node)...)
 	}
 
 	// If the field has children then lint the child fields
 	if len(f.Children) > 0 {
-		return append(lints, f.Children.LintYAML(ctx, node)...)
+		return append(lints, f.Children.lintYAML(ctx, node)...)
 	}
 
 	// Otherwise we're a leaf node, so do basic type checking
@@ -564,11 +572,11 @@ func (f FieldSpec) LintYAML(ctx LintContext, node *yaml.Node) []Lint {
 	// TODO: Do proper checking for bool and number types.
 	case FieldTypeBool, FieldTypeString, FieldTypeInt, FieldTypeFloat:
 		if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
-			lints = append(lints, NewLintError(node.Line, fmt.Sprintf("expected %v value", f.Type)))
+			lints = append(lints, NewLintError(node.Line, LintExpectedScalar, fmt.Sprintf("expected %v value", f.Type)))
 		}
 	case FieldTypeObject:
 		if node.Kind != yaml.MappingNode && node.Kind != yaml.AliasNode {
-			lints = append(lints, NewLintError(node.Line, "expected object value"))
+			lints = append(lints, NewLintError(node.Line, LintExpectedObject, "expected object value"))
 		}
 	}
 	return lints
@@ -576,6 +584,10 @@ func (f FieldSpec) LintYAML(ctx LintContext, node *yaml.Node) []Lint {
 
 // LintYAML walks a yaml node and returns a list of linting errors found.
 func (f FieldSpecs) LintYAML(ctx LintContext, node *yaml.Node) []Lint {
+	return ctx.filterLints(f.lintYAML(ctx, node))
+}
+
+func (f FieldSpecs) lintYAML(ctx LintContext, node *yaml.Node) []Lint {
 	node = unwrapDocumentNode(node)
 
 	var lints []Lint
@@ -584,7 +596,7 @@ func (f FieldSpecs) LintYAML(ctx LintContext, node *yaml.Node) []Lint {
 			// TODO: Actually lint through aliases
 			return nil
 		}
-		lints = append(lints, NewLintError(node.Line, "expected object value"))
+		lints = append(lints, NewLintError(node.Line, LintExpectedObject, "expected object value"))
 		return lints
 	}
 
@@ -597,12 +609,12 @@ func (f FieldSpecs) LintYAML(ctx LintContext, node *yaml.Node) []Lint {
 		spec, exists := specNames[node.Content[i].Value]
 		if !exists {
 			if node.Content[i+1].Kind != yaml.AliasNode {
-				lints = append(lints, NewLintError(node.Content[i].Line, fmt.Sprintf("field %v not recognised", node.Content[i].Value)))
+				lints = append(lints, NewLintError(node.Content[i].Line, LintUnknown, fmt.Sprintf("field %v not recognised", node.Content[i].Value)))
 			}
 			continue
 		}
 		lints = append(lints, lintYAMLFromOmit(f, spec, node, node.Content[i+1])...)
-		lints = append(lints, spec.LintYAML(ctx, node.Content[i+1])...)
+		lints = append(lints, spec.lintYAML(ctx, node.Content[i+1])...)
 		delete(specNames, node.Content[i].Value)
 	}
 
@@ -613,7 +625,7 @@ func (f FieldSpecs) LintYAML(ctx LintContext, node *yaml.Node) []Lint {
 			!isCore &&
 			remaining.Kind == KindScalar &&
 			len(remaining.Children) == 0 {
-			lints = append(lints, NewLintError(node.Line, fmt.Sprintf("field %v is required", name)))
+			lints = append(lints, NewLintError(node.Line, LintMissing, fmt.Sprintf("field %v is required", name)))
 		}
 	}
 	return lints
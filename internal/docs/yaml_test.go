@@ -594,7 +594,7 @@ func TestYAMLComponentLinting(t *testing.T) {
 				docs.FieldString("foo1", "").LinterFunc(func(ctx docs.LintContext, line, col int, v interface{}) []docs.Lint {
 					if v == "lint me please" {
 						return []docs.Lint{
-							docs.NewLintError(line, "this is a custom lint"),
+							docs.NewLintError(line, docs.LintCustom, "this is a custom lint"),
 						}
 					}
 					return nil
@@ -668,7 +668,7 @@ testlintbarinput:
   bar1: hello world`,
 			rejectDeprecated: true,
 			res: []docs.Lint{
-				docs.NewLintError(2, "component testlintbarinput is deprecated"),
+				docs.NewLintError(2, docs.LintDeprecated, "component testlintbarinput is deprecated"),
 			},
 		},
 		{
@@ -680,7 +680,19 @@ testlintfooinput:
   foo6: hello world`,
 			rejectDeprecated: true,
 			res: []docs.Lint{
-				docs.NewLintError(4, "field foo6 is deprecated"),
+				docs.NewLintError(4, docs.LintDeprecated, "field foo6 is deprecated"),
+			},
+		},
+		{
+			name:      "ineffective plugin object",
+			inputType: docs.TypeInput,
+			inputConf: `
+testlintfooinput:
+  foo1: hello world
+plugin:
+  foo1: hello world`,
+			res: []docs.Lint{
+				docs.NewLintError(4, docs.LintIneffectivePlugin, "plugin object is ineffective"),
 			},
 		},
 		{
@@ -702,7 +714,7 @@ testlintfooinput: &test-anchor
 processors:
   - testlintfooprocessor: *test-anchor`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "field nope not recognised"),
+				docs.NewLintError(4, docs.LintUnknown, "field nope not recognised"),
 			},
 		},
 		{
@@ -716,9 +728,9 @@ testlintfooinput:
   also_not_recognised: nah
 definitely_not_recognised: huh`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "field not_recognised not recognised"),
-				docs.NewLintError(6, "field also_not_recognised not recognised"),
-				docs.NewLintError(7, "field definitely_not_recognised is invalid when the component type is testlintfooinput (input)"),
+				docs.NewLintError(4, docs.LintUnknown, "field not_recognised not recognised"),
+				docs.NewLintError(6, docs.LintUnknown, "field also_not_recognised not recognised"),
+				docs.NewLintError(7, docs.LintUnknown, "field definitely_not_recognised is invalid when the component type is testlintfooinput (input)"),
 			},
 		},
 		{
@@ -732,8 +744,8 @@ processors:
   - testlintfooprocessor:
       also_not_recognised: nah`,
 			res: []docs.Lint{
-				docs.NewLintError(3, "field not_recognised not recognised"),
-				docs.NewLintError(7, "field also_not_recognised not recognised"),
+				docs.NewLintError(3, docs.LintUnknown, "field not_recognised not recognised"),
+				docs.NewLintError(7, docs.LintUnknown, "field also_not_recognised not recognised"),
 			},
 		},
 		{
@@ -749,7 +761,7 @@ processors:
   - label: foo
     testlintfooprocessor: {}`,
 			res: []docs.Lint{
-				docs.NewLintError(8, "Label 'foo' collides with a previously defined label at line 2"),
+				docs.NewLintError(8, docs.LintDuplicateLabel, "Label 'foo' collides with a previously defined label at line 2"),
 			},
 		},
 		{
@@ -760,7 +772,7 @@ testlintfooinput:
   foo1: hello world
 processors: []`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "field processors is empty and can be removed"),
+				docs.NewLintError(4, docs.LintShouldOmit, "field processors is empty and can be removed"),
 			},
 		},
 		{
@@ -771,7 +783,7 @@ testlintfooinput:
   foo1: hello world
   foo2: drop me`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "because foo"),
+				docs.NewLintError(4, docs.LintShouldOmit, "because foo"),
 			},
 		},
 		{
@@ -785,7 +797,7 @@ testlintfooinput:
         foo1: somevalue
         not_recognised: nah`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "expected array value"),
+				docs.NewLintError(4, docs.LintExpectedArray, "expected array value"),
 			},
 		},
 		{
@@ -798,7 +810,7 @@ testlintfooinput:
       foo1: somevalue
       not_recognised: nah`,
 			res: []docs.Lint{
-				docs.NewLintError(6, "field not_recognised not recognised"),
+				docs.NewLintError(6, docs.LintUnknown, "field not_recognised not recognised"),
 			},
 		},
 		{
@@ -811,7 +823,7 @@ testlintfooinput:
       foo1: [ somevalue ]
 `,
 			res: []docs.Lint{
-				docs.NewLintError(5, "expected string value"),
+				docs.NewLintError(5, docs.LintExpectedScalar, "expected string value"),
 			},
 		},
 		{
@@ -825,7 +837,7 @@ testlintfooinput:
         foo1: somevalue
         not_recognised: nah`,
 			res: []docs.Lint{
-				docs.NewLintError(7, "field not_recognised not recognised"),
+				docs.NewLintError(7, docs.LintUnknown, "field not_recognised not recognised"),
 			},
 		},
 		{
@@ -838,7 +850,7 @@ testlintfooinput:
         foo1: somevalue
         not_recognised: nah`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "expected object value"),
+				docs.NewLintError(4, docs.LintExpectedObject, "expected object value"),
 			},
 		},
 		{
@@ -857,7 +869,7 @@ testlintfooinput:
   foo7:
    - wat: no`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "field wat not recognised"),
+				docs.NewLintError(4, docs.LintUnknown, "field wat not recognised"),
 			},
 		},
 		{
@@ -869,7 +881,7 @@ testlintfooinput:
     key1:
       wat: no`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "expected array value"),
+				docs.NewLintError(4, docs.LintExpectedArray, "expected array value"),
 			},
 		},
 		{
@@ -890,7 +902,7 @@ testlintfooinput:
     key1:
       wat: nope`,
 			res: []docs.Lint{
-				docs.NewLintError(5, "field wat not recognised"),
+				docs.NewLintError(5, docs.LintUnknown, "field wat not recognised"),
 			},
 		},
 		{
@@ -901,7 +913,7 @@ testlintfooinput:
   foo8:
     - wat: nope`,
 			res: []docs.Lint{
-				docs.NewLintError(4, "expected object value"),
+				docs.NewLintError(4, docs.LintExpectedObject, "expected object value"),
 			},
 		},
 		{
@@ -911,7 +923,7 @@ testlintfooinput:
 testlintfooinput:
   foo1: lint me please`,
 			res: []docs.Lint{
-				docs.NewLintError(3, "this is a custom lint"),
+				docs.NewLintError(3, docs.LintCustom, "this is a custom lint"),
 			},
 		},
 	}
@@ -946,7 +958,7 @@ func TestYAMLLinting(t *testing.T) {
 			inputSpec: docs.FieldString("foo", ""),
 			inputConf: `["foo","bar"]`,
 			res: []docs.Lint{
-				docs.NewLintError(1, "expected string value"),
+				docs.NewLintError(1, docs.LintExpectedScalar, "expected string value"),
 			},
 		},
 		{
@@ -954,7 +966,7 @@ func TestYAMLLinting(t *testing.T) {
 			inputSpec: docs.FieldString("foo", "").Array(),
 			inputConf: `"foo"`,
 			res: []docs.Lint{
-				docs.NewLintError(1, "expected array value"),
+				docs.NewLintError(1, docs.LintExpectedArray, "expected array value"),
 			},
 		},
 		{
@@ -964,7 +976,7 @@ func TestYAMLLinting(t *testing.T) {
 			),
 			inputConf: `"foo"`,
 			res: []docs.Lint{
-				docs.NewLintError(1, "expected object value"),
+				docs.NewLintError(1, docs.LintExpectedObject, "expected object value"),
 			},
 		},
 		{
@@ -974,7 +986,7 @@ func TestYAMLLinting(t *testing.T) {
 			),
 			inputConf: `bar: {}`,
 			res: []docs.Lint{
-				docs.NewLintError(1, "expected string value"),
+				docs.NewLintError(1, docs.LintExpectedScalar, "expected string value"),
 			},
 		},
 		{
@@ -987,7 +999,7 @@ func TestYAMLLinting(t *testing.T) {
 			inputConf: `bar:
   baz: {}`,
 			res: []docs.Lint{
-				docs.NewLintError(2, "expected string value"),
+				docs.NewLintError(2, docs.LintExpectedScalar, "expected string value"),
 			},
 		},
 		{
@@ -1000,9 +1012,40 @@ func TestYAMLLinting(t *testing.T) {
 			),
 			inputConf: `bev: hello world`,
 			res: []docs.Lint{
-				docs.NewLintError(1, "field baz is required"),
+				docs.NewLintError(1, docs.LintMissing, "field baz is required"),
+			},
+		},
+		{
+			name: "empty required interpolated fields",
+			inputSpec: docs.FieldObject("foo", "").WithChildren(
+				docs.FieldInterpolatedString("bar", "").LinterFunc(docs.LintRequiredInterpolatedField),
+				docs.FieldInterpolatedString("baz", "").LinterFunc(docs.LintRequiredInterpolatedField),
+				docs.FieldInterpolatedString("buz", ""),
+				docs.FieldInterpolatedString("bev", "").HasDefault("").LinterFunc(docs.LintRequiredInterpolatedField),
+				docs.FieldInterpolatedString("bim", "").Optional(),
+			),
+			inputConf: `
+bar: ""
+baz: "  "
+buz: ""
+bev: " "
+bim: ""`,
+			res: []docs.Lint{
+				docs.NewLintWarning(2, docs.LintEmptyInterpolation, "required interpolated field is empty, which is likely a mistake"),
+				docs.NewLintWarning(3, docs.LintEmptyInterpolation, "required interpolated field is empty, which is likely a mistake"),
+				docs.NewLintWarning(5, docs.LintEmptyInterpolation, "required interpolated field is empty, which is likely a mistake"),
 			},
 		},
+		{
+			name: "non-empty required interpolated fields",
+			inputSpec: docs.FieldObject("foo", "").WithChildren(
+				docs.FieldInterpolatedString("bar", "").LinterFunc(docs.LintRequiredInterpolatedField),
+				docs.FieldInterpolatedString("baz", "").LinterFunc(docs.LintRequiredInterpolatedField),
+			),
+			inputConf: `
+bar: ${! json("id") }
+baz: " static "`,
+		},
 	}
 
 	for _, test := range tests {
@@ -1017,6 +1060,61 @@ func TestYAMLLinting(t *testing.T) {
 	}
 }
 
+func TestLintMinLevel(t *testing.T) {
+	spec := docs.FieldObject("foo", "").WithChildren(
+		docs.FieldInterpolatedString("bar", "").LinterFunc(docs.LintRequiredInterpolatedField),
+		docs.FieldString("baz", "").LinterFunc(func(ctx docs.LintContext, line, col int, value interface{}) []docs.Lint {
+			return []docs.Lint{docs.NewLintInfo(line, docs.LintCustom, "this is a hint")}
+		}),
+	)
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`
+bar: ""
+baz: hello world
+buz: nope`), &node))
+
+	tests := []struct {
+		name     string
+		minLevel docs.LintLevel
+		res      []docs.Lint
+	}{
+		{
+			name:     "info",
+			minLevel: docs.LintInfo,
+			res: []docs.Lint{
+				docs.NewLintWarning(2, docs.LintEmptyInterpolation, "required interpolated field is empty, which is likely a mistake"),
+				docs.NewLintInfo(3, docs.LintCustom, "this is a hint"),
+				docs.NewLintError(4, docs.LintUnknown, "field buz not recognised"),
+			},
+		},
+		{
+			name:     "warning",
+			minLevel: docs.LintWarning,
+			res: []docs.Lint{
+				docs.NewLintWarning(2, docs.LintEmptyInterpolation, "required interpolated field is empty, which is likely a mistake"),
+				docs.NewLintError(4, docs.LintUnknown, "field buz not recognised"),
+			},
+		},
+		{
+			name:     "error",
+			minLevel: docs.LintError,
+			res: []docs.Lint{
+				docs.NewLintError(4, docs.LintUnknown, "field buz not recognised"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			lintCtx := docs.NewLintContext()
+			lintCtx.MinLevel = test.minLevel
+			assert.Equal(t, test.res, spec.LintYAML(lintCtx, &node))
+		})
+	}
+}
+
 func TestYAMLSanitation(t *testing.T) {
 	prov := docs.NewMappedDocsProvider()
 
@@ -0,0 +1,130 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/benthosdev/benthos/v4/public/bloblang"
+)
+
+func init() {
+	// Note: The examples are run and tested from within
+	// ./internal/bloblang/query/parsed_test.go
+
+	withDefaultsSpec := bloblang.NewPluginSpec().
+		Category("Object & Array Manipulation").
+		Description("Returns a structured value conforming to an [Avro](https://avro.apache.org/) schema, with any fields that are missing from the input populated from the defaults declared by the schema. An error is returned if a field without a declared default is missing.").
+		Param(bloblang.NewStringParam("schema").Description("An Avro schema in JSON format.")).
+		Example("",
+			`root = this.avro_with_defaults("""{"type":"record","name":"foo","fields":[{"name":"id","type":"long"},{"name":"name","type":"string","default":"anon"},{"name":"tags","type":{"type":"array","items":"string"},"default":[]}]}""")`,
+			[2]string{
+				`{"id":12}`,
+				`{"id":12,"name":"anon","tags":[]}`,
+			},
+			[2]string{
+				`{"id":12,"name":"jane"}`,
+				`{"id":12,"name":"jane","tags":[]}`,
+			}).
+		Example("Values are expected in, and returned in, the Avro JSON format, where union values other than null are wrapped in an object keyed by their type.",
+			`root = this.avro_with_defaults("""{"type":"record","name":"foo","fields":[{"name":"id","type":"long"},{"name":"nick","type":["null","string"],"default":null}]}""")`,
+			[2]string{
+				`{"id":12}`,
+				`{"id":12,"nick":null}`,
+			},
+			[2]string{
+				`{"id":12,"nick":{"string":"jj"}}`,
+				`{"id":12,"nick":{"string":"jj"}}`,
+			})
+
+	if err := bloblang.RegisterMethodV2(
+		"avro_with_defaults", withDefaultsSpec,
+		func(args *bloblang.ParsedParams) (bloblang.Method, error) {
+			schema, err := args.GetString("schema")
+			if err != nil {
+				return nil, err
+			}
+			codec, err := goavro.NewCodec(schema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse schema: %w", err)
+			}
+			return func(v interface{}) (interface{}, error) {
+				return normalizeWithCodec(codec, v)
+			}, nil
+		},
+	); err != nil {
+		panic(err)
+	}
+
+	equalSpec := bloblang.NewPluginSpec().
+		Category("Object & Array Manipulation").
+		Description("Checks whether a value is equivalent to another value under an [Avro](https://avro.apache.org/) schema, by normalizing both values according to the schema before comparing them. Unlike a plain comparison, fields that are missing but declared with defaults are considered equal to their defaults, and the ordering of object keys is ignored. An error is returned if either value does not conform to the schema.").
+		Param(bloblang.NewAnyParam("other").Description("A value to compare against.")).
+		Param(bloblang.NewStringParam("schema").Description("An Avro schema in JSON format.")).
+		Example("",
+			`root.equal = this.a.avro_equal(this.b, """{"type":"record","name":"foo","fields":[{"name":"id","type":"long"},{"name":"name","type":"string","default":"anon"}]}""")`,
+			[2]string{
+				`{"a":{"id":12},"b":{"name":"anon","id":12}}`,
+				`{"equal":true}`,
+			},
+			[2]string{
+				`{"a":{"id":12},"b":{"name":"jane","id":12}}`,
+				`{"equal":false}`,
+			})
+
+	if err := bloblang.RegisterMethodV2(
+		"avro_equal", equalSpec,
+		func(args *bloblang.ParsedParams) (bloblang.Method, error) {
+			other, err := args.Get("other")
+			if err != nil {
+				return nil, err
+			}
+			schema, err := args.GetString("schema")
+			if err != nil {
+				return nil, err
+			}
+			codec, err := goavro.NewCodec(schema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse schema: %w", err)
+			}
+			otherNorm, err := normalizeWithCodec(codec, other)
+			if err != nil {
+				return nil, fmt.Errorf("failed to normalize argument: %w", err)
+			}
+			return func(v interface{}) (interface{}, error) {
+				vNorm, err := normalizeWithCodec(codec, v)
+				if err != nil {
+					return nil, err
+				}
+				return reflect.DeepEqual(vNorm, otherNorm), nil
+			}, nil
+		},
+	); err != nil {
+		panic(err)
+	}
+}
+
+// normalizeWithCodec converts a structured value into the Avro native form of a
+// codec and back, resulting in a generic value where missing fields are
+// populated from their defaults and numbers are represented consistently.
+func normalizeWithCodec(codec *goavro.Codec, v interface{}) (interface{}, error) {
+	jBytes, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	native, _, err := codec.NativeFromTextual(jBytes)
+	if err != nil {
+		return nil, err
+	}
+	if jBytes, err = codec.TextualFromNative(nil, native); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(jBytes))
+	dec.UseNumber()
+	var gV interface{}
+	err = dec.Decode(&gV)
+	return gV, err
+}
@@ -0,0 +1,167 @@
+package avro_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/bloblang/query"
+	_ "github.com/benthosdev/benthos/v4/internal/impl/avro"
+)
+
+func TestAvroWithDefaults(t *testing.T) {
+	schema := `{
+  "type": "record",
+  "name": "foo",
+  "fields": [
+    { "name": "id", "type": "long" },
+    { "name": "name", "type": "string", "default": "anon" },
+    { "name": "nested", "type": { "type": "record", "name": "bar", "fields": [ { "name": "count", "type": "int", "default": 5 } ] }, "default": {} }
+  ]
+}`
+
+	testCases := []struct {
+		name        string
+		target      interface{}
+		exp         interface{}
+		errContains string
+	}{
+		{
+			name:   "all defaults",
+			target: map[string]interface{}{"id": int64(12345678901234567)},
+			exp: map[string]interface{}{
+				"id":     json.Number("12345678901234567"),
+				"name":   "anon",
+				"nested": map[string]interface{}{"count": json.Number("5")},
+			},
+		},
+		{
+			name:   "nested defaults",
+			target: map[string]interface{}{"id": 1, "name": "jane", "nested": map[string]interface{}{}},
+			exp: map[string]interface{}{
+				"id":     json.Number("1"),
+				"name":   "jane",
+				"nested": map[string]interface{}{"count": json.Number("5")},
+			},
+		},
+		{
+			name:        "missing required field",
+			target:      map[string]interface{}{"name": "jane"},
+			errContains: "only found 2 of 3 fields",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fn, err := query.InitMethodHelper("avro_with_defaults", query.NewLiteralFunction("", query.IClone(test.target)), schema)
+			require.NoError(t, err)
+
+			res, err := fn.Exec(query.FunctionContext{
+				Maps:     map[string]query.Function{},
+				Index:    0,
+				MsgBatch: nil,
+			})
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.exp, res)
+		})
+	}
+}
+
+func TestAvroWithDefaultsBadSchema(t *testing.T) {
+	_, err := query.InitMethodHelper("avro_with_defaults", query.NewLiteralFunction("", map[string]interface{}{}), `{"type":"nope"}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse schema")
+}
+
+func TestAvroEqual(t *testing.T) {
+	schema := `{
+  "type": "record",
+  "name": "foo",
+  "fields": [
+    { "name": "id", "type": "long" },
+    { "name": "name", "type": "string", "default": "anon" },
+    { "name": "score", "type": "double", "default": 0 },
+    { "name": "attrs", "type": { "type": "map", "values": "string" }, "default": {} }
+  ]
+}`
+
+	testCases := []struct {
+		name        string
+		a, b        interface{}
+		exp         bool
+		errContains string
+	}{
+		{
+			name: "identical",
+			a:    map[string]interface{}{"id": 1, "name": "jane"},
+			b:    map[string]interface{}{"id": 1, "name": "jane"},
+			exp:  true,
+		},
+		{
+			name: "defaults filled",
+			a:    map[string]interface{}{"id": 1},
+			b:    map[string]interface{}{"id": 1, "name": "anon", "score": 0.0, "attrs": map[string]interface{}{}},
+			exp:  true,
+		},
+		{
+			name: "numeric representations",
+			a:    map[string]interface{}{"id": int64(1), "score": 2},
+			b:    map[string]interface{}{"id": 1.0, "score": 2.0},
+			exp:  true,
+		},
+		{
+			name: "map key ordering",
+			a:    map[string]interface{}{"id": 1, "attrs": map[string]interface{}{"a": "1", "b": "2"}},
+			b:    map[string]interface{}{"id": 1, "attrs": map[string]interface{}{"b": "2", "a": "1"}},
+			exp:  true,
+		},
+		{
+			name: "different values",
+			a:    map[string]interface{}{"id": 1},
+			b:    map[string]interface{}{"id": 1, "name": "jane"},
+			exp:  false,
+		},
+		{
+			name:        "nonconforming target",
+			a:           map[string]interface{}{"name": "jane"},
+			b:           map[string]interface{}{"id": 1},
+			errContains: "only found 3 of 4 fields",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fn, err := query.InitMethodHelper("avro_equal", query.NewLiteralFunction("", query.IClone(test.a)), query.IClone(test.b), schema)
+			require.NoError(t, err)
+
+			res, err := fn.Exec(query.FunctionContext{
+				Maps:     map[string]query.Function{},
+				Index:    0,
+				MsgBatch: nil,
+			})
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.exp, res)
+		})
+	}
+}
+
+func TestAvroEqualBadArgument(t *testing.T) {
+	schema := `{"type":"record","name":"foo","fields":[{"name":"id","type":"long"}]}`
+	_, err := query.InitMethodHelper("avro_equal", query.NewLiteralFunction("", map[string]interface{}{}), map[string]interface{}{"nope": true}, schema)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to normalize argument")
+}
@@ -0,0 +1,291 @@
+package confluent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// avroType is a parsed Avro schema, where named types are shared by pointer
+// between their definition and all references to them.
+type avroType struct {
+	kind string
+	name string
+
+	fields       []avroField
+	symbols      []string
+	enumDefault  bool
+	enumFallback string
+	items        *avroType
+	values       *avroType
+	size         int
+	unionMembers []*avroType
+
+	// The logical type of a primitive or fixed type, along with the schema of
+	// the type, which is only retained for logical types.
+	logicalType   string
+	logicalSchema map[string]interface{}
+}
+
+type avroField struct {
+	name       string
+	typ        *avroType
+	hasDefault bool
+}
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// parseAvroSchema parses an Avro schema for the purpose of compatibility
+// checks. The schema is expected to be valid, and therefore only the attributes
+// that affect schema resolution are validated.
+func parseAvroSchema(schema string) (*avroType, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		// A bare primitive type name such as `string` is not quoted.
+		v = schema
+	}
+	p := avroSchemaParser{named: map[string]*avroType{}}
+	return p.parse(v, "")
+}
+
+type avroSchemaParser struct {
+	named map[string]*avroType
+}
+
+func (p *avroSchemaParser) parse(v interface{}, namespace string) (*avroType, error) {
+	switch t := v.(type) {
+	case string:
+		if avroPrimitives[t] {
+			return &avroType{kind: t}, nil
+		}
+		if n, exists := p.named[avroFullName(t, namespace)]; exists {
+			return n, nil
+		}
+		if n, exists := p.named[t]; exists {
+			return n, nil
+		}
+		return nil, fmt.Errorf("unknown type: %v", t)
+	case []interface{}:
+		u := &avroType{kind: "union"}
+		for _, m := range t {
+			mt, err := p.parse(m, namespace)
+			if err != nil {
+				return nil, err
+			}
+			u.unionMembers = append(u.unionMembers, mt)
+		}
+		return u, nil
+	case map[string]interface{}:
+		return p.parseObject(t, namespace)
+	}
+	return nil, fmt.Errorf("unexpected schema value type: %T", v)
+}
+
+func (p *avroSchemaParser) parseObject(obj map[string]interface{}, namespace string) (*avroType, error) {
+	kind, isStr := obj["type"].(string)
+	if !isStr {
+		return p.parse(obj["type"], namespace)
+	}
+
+	switch kind {
+	case "record", "error", "enum", "fixed":
+	case "array":
+		items, err := p.parse(obj["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: kind, items: items}, nil
+	case "map":
+		values, err := p.parse(obj["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: kind, values: values}, nil
+	default:
+		// Primitive types with attributes, such as logical types.
+		t, err := p.parse(kind, namespace)
+		if err != nil || !avroPrimitives[kind] {
+			return t, err
+		}
+		setAvroLogicalType(t, obj)
+		return t, nil
+	}
+
+	name, _ := obj["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("%v type is missing a name", kind)
+	}
+	if ns, isStr := obj["namespace"].(string); isStr && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	t := &avroType{kind: kind, name: avroFullName(name, namespace)}
+	if i := strings.LastIndex(t.name, "."); i >= 0 {
+		namespace = t.name[:i]
+	} else {
+		namespace = ""
+	}
+	if kind == "error" {
+		t.kind = "record"
+	}
+
+	// Named types are registered before their contents are parsed in order to
+	// support recursive types.
+	p.named[t.name] = t
+
+	switch t.kind {
+	case "record":
+		fields, _ := obj["fields"].([]interface{})
+		for _, fv := range fields {
+			fObj, isObj := fv.(map[string]interface{})
+			if !isObj {
+				return nil, fmt.Errorf("record %v contains a field that is not an object", t.name)
+			}
+			fName, _ := fObj["name"].(string)
+			fType, err := p.parse(fObj["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %v of record %v: %w", fName, t.name, err)
+			}
+			_, hasDefault := fObj["default"]
+			t.fields = append(t.fields, avroField{name: fName, typ: fType, hasDefault: hasDefault})
+		}
+	case "enum":
+		symbols, _ := obj["symbols"].([]interface{})
+		for _, s := range symbols {
+			str, _ := s.(string)
+			t.symbols = append(t.symbols, str)
+		}
+		_, t.enumDefault = obj["default"]
+		t.enumFallback, _ = obj["default"].(string)
+	case "fixed":
+		size, _ := obj["size"].(float64)
+		t.size = int(size)
+		setAvroLogicalType(t, obj)
+	}
+	return t, nil
+}
+
+func setAvroLogicalType(t *avroType, obj map[string]interface{}) {
+	if t.logicalType, _ = obj["logicalType"].(string); t.logicalType != "" {
+		t.logicalSchema = obj
+	}
+}
+
+func avroFullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// avroPromotions lists the types that the values of each type can be read as
+// according to the schema resolution rules of Avro.
+var avroPromotions = map[string][]string{
+	"int":    {"long", "float", "double"},
+	"long":   {"float", "double"},
+	"float":  {"double"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+// avroCheckReadable returns an error describing the first incompatibility found
+// when data written with the writer schema is read with the reader schema, or
+// nil if all such data can be read.
+func avroCheckReadable(reader, writer *avroType) error {
+	return avroCheckReadableAt("root", reader, writer, map[[2]*avroType]bool{})
+}
+
+func avroCheckReadableAt(path string, reader, writer *avroType, seen map[[2]*avroType]bool) error {
+	if writer.kind == "union" {
+		for _, m := range writer.unionMembers {
+			if err := avroCheckReadableAt(path, reader, m, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if reader.kind == "union" {
+		for _, m := range reader.unionMembers {
+			if avroCheckReadableAt(path, m, writer, seen) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%v: writer type %v is not present in the reader union", path, avroTypeName(writer))
+	}
+
+	if reader.kind != writer.kind {
+		for _, k := range avroPromotions[writer.kind] {
+			if k == reader.kind {
+				return nil
+			}
+		}
+		return fmt.Errorf("%v: writer type %v cannot be read as %v", path, avroTypeName(writer), avroTypeName(reader))
+	}
+	if reader.name != writer.name {
+		return fmt.Errorf("%v: writer type %v cannot be read as %v", path, writer.name, reader.name)
+	}
+
+	switch reader.kind {
+	case "record":
+		// Recursive types have already been checked, or are currently being
+		// checked, when the same pair is seen again.
+		pair := [2]*avroType{reader, writer}
+		if seen[pair] {
+			return nil
+		}
+		seen[pair] = true
+
+		for _, rf := range reader.fields {
+			var wf *avroField
+			for i := range writer.fields {
+				if writer.fields[i].name == rf.name {
+					wf = &writer.fields[i]
+					break
+				}
+			}
+			if wf == nil {
+				if !rf.hasDefault {
+					return fmt.Errorf("%v.%v: reader field is missing from the writer and has no default", path, rf.name)
+				}
+				continue
+			}
+			if err := avroCheckReadableAt(path+"."+rf.name, rf.typ, wf.typ, seen); err != nil {
+				return err
+			}
+		}
+	case "enum":
+		if reader.enumDefault {
+			return nil
+		}
+		for _, ws := range writer.symbols {
+			found := false
+			for _, rs := range reader.symbols {
+				if rs == ws {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%v: writer symbol %v is missing from the reader enum %v, which has no default", path, ws, reader.name)
+			}
+		}
+	case "fixed":
+		if reader.size != writer.size {
+			return fmt.Errorf("%v: writer fixed size %v does not match reader fixed size %v", path, writer.size, reader.size)
+		}
+	case "array":
+		return avroCheckReadableAt(path+".items", reader.items, writer.items, seen)
+	case "map":
+		return avroCheckReadableAt(path+".values", reader.values, writer.values, seen)
+	}
+	return nil
+}
+
+func avroTypeName(t *avroType) string {
+	if t.name != "" {
+		return t.name
+	}
+	return t.kind
+}
@@ -0,0 +1,148 @@
+package confluent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvroCheckReadable(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader string
+		writer string
+		errStr string
+	}{
+		{
+			name:   "same primitive",
+			reader: `"string"`,
+			writer: `"string"`,
+		},
+		{
+			name:   "promoted primitive",
+			reader: `"double"`,
+			writer: `"int"`,
+		},
+		{
+			name:   "demoted primitive",
+			reader: `"int"`,
+			writer: `"long"`,
+			errStr: "root: writer type long cannot be read as int",
+		},
+		{
+			name:   "logical type",
+			reader: `{"type":"long","logicalType":"timestamp-millis"}`,
+			writer: `"int"`,
+		},
+		{
+			name:   "added field with default",
+			reader: `{"type":"record","name":"foo","fields":[{"name":"a","type":"string"},{"name":"b","type":"int","default":0}]}`,
+			writer: `{"type":"record","name":"foo","fields":[{"name":"a","type":"string"}]}`,
+		},
+		{
+			name:   "added field without default",
+			reader: `{"type":"record","name":"foo","fields":[{"name":"a","type":"string"},{"name":"b","type":"int"}]}`,
+			writer: `{"type":"record","name":"foo","fields":[{"name":"a","type":"string"}]}`,
+			errStr: "root.b: reader field is missing from the writer and has no default",
+		},
+		{
+			name:   "removed field",
+			reader: `{"type":"record","name":"foo","fields":[{"name":"a","type":"string"}]}`,
+			writer: `{"type":"record","name":"foo","fields":[{"name":"a","type":"string"},{"name":"b","type":"int"}]}`,
+		},
+		{
+			name:   "changed field type",
+			reader: `{"type":"record","name":"foo","fields":[{"name":"a","type":{"type":"array","items":"int"}}]}`,
+			writer: `{"type":"record","name":"foo","fields":[{"name":"a","type":{"type":"array","items":"string"}}]}`,
+			errStr: "root.a.items: writer type string cannot be read as int",
+		},
+		{
+			name:   "renamed record",
+			reader: `{"type":"record","name":"bar","namespace":"com.example","fields":[]}`,
+			writer: `{"type":"record","name":"foo","namespace":"com.example","fields":[]}`,
+			errStr: "root: writer type com.example.foo cannot be read as com.example.bar",
+		},
+		{
+			name:   "widened union",
+			reader: `["null","string","int"]`,
+			writer: `["null","string"]`,
+		},
+		{
+			name:   "narrowed union",
+			reader: `["null","string"]`,
+			writer: `["null","string","int"]`,
+			errStr: "root: writer type int is not present in the reader union",
+		},
+		{
+			name:   "added enum symbol",
+			reader: `{"type":"enum","name":"e","symbols":["A","B","C"]}`,
+			writer: `{"type":"enum","name":"e","symbols":["A","B"]}`,
+		},
+		{
+			name:   "removed enum symbol",
+			reader: `{"type":"enum","name":"e","symbols":["A"]}`,
+			writer: `{"type":"enum","name":"e","symbols":["A","B"]}`,
+			errStr: "root: writer symbol B is missing from the reader enum e, which has no default",
+		},
+		{
+			name:   "removed enum symbol with default",
+			reader: `{"type":"enum","name":"e","symbols":["A"],"default":"A"}`,
+			writer: `{"type":"enum","name":"e","symbols":["A","B"]}`,
+		},
+		{
+			name:   "changed fixed size",
+			reader: `{"type":"map","values":{"type":"fixed","name":"f","size":8}}`,
+			writer: `{"type":"map","values":{"type":"fixed","name":"f","size":4}}`,
+			errStr: "root.values: writer fixed size 4 does not match reader fixed size 8",
+		},
+		{
+			name:   "recursive record",
+			reader: `{"type":"record","name":"node","fields":[{"name":"next","type":["null","node"]},{"name":"v","type":"long"}]}`,
+			writer: `{"type":"record","name":"node","fields":[{"name":"next","type":["null","node"]},{"name":"v","type":"int"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := parseAvroSchema(test.reader)
+			require.NoError(t, err)
+
+			writer, err := parseAvroSchema(test.writer)
+			require.NoError(t, err)
+
+			err = avroCheckReadable(reader, writer)
+			if test.errStr != "" {
+				assert.EqualError(t, err, test.errStr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParseAvroSchemaNamespaces(t *testing.T) {
+	schema, err := parseAvroSchema(`{
+	"type": "record",
+	"name": "outer",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "a", "type": {"type": "enum", "name": "inner", "symbols": ["X"]}},
+		{"name": "b", "type": "inner"},
+		{"name": "c", "type": {"type": "fixed", "name": "org.other.f", "size": 2}},
+		{"name": "d", "type": "org.other.f"}
+	]
+}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "com.example.outer", schema.name)
+	require.Len(t, schema.fields, 4)
+	assert.Equal(t, "com.example.inner", schema.fields[0].typ.name)
+	assert.Same(t, schema.fields[0].typ, schema.fields[1].typ)
+	assert.Equal(t, "org.other.f", schema.fields[2].typ.name)
+	assert.Same(t, schema.fields[2].typ, schema.fields[3].typ)
+
+	_, err = parseAvroSchema(`{"type":"record","name":"foo","fields":[{"name":"a","type":"nope"}]}`)
+	assert.EqualError(t, err, "field a of record foo: unknown type: nope")
+}
@@ -0,0 +1,213 @@
+package confluent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// enumSubstituter replaces the symbols of enums within native Avro values that
+// are not members of their enum with a default symbol.
+type enumSubstituter struct {
+	root     *avroType
+	fallback string
+}
+
+// newEnumSubstituter returns a substituter for the enums of a schema, or nil if
+// the schema does not contain any enums. The fallback symbol is used for enums
+// that contain it, and otherwise the default symbol of the enum is used.
+func newEnumSubstituter(schema, fallback string) (*enumSubstituter, error) {
+	root, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	if !avroContainsEnum(root, map[*avroType]bool{}) {
+		return nil, nil
+	}
+	return &enumSubstituter{root: root, fallback: fallback}, nil
+}
+
+func avroContainsEnum(t *avroType, seen map[*avroType]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.kind {
+	case "enum":
+		return true
+	case "record":
+		for _, f := range t.fields {
+			if avroContainsEnum(f.typ, seen) {
+				return true
+			}
+		}
+	case "array":
+		return avroContainsEnum(t.items, seen)
+	case "map":
+		return avroContainsEnum(t.values, seen)
+	case "union":
+		for _, m := range t.unionMembers {
+			if avroContainsEnum(m, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// substitute replaces unknown enum symbols within a structured value, which is
+// modified in place, and returns the resulting value along with the number of
+// symbols that were replaced.
+func (e *enumSubstituter) substitute(v interface{}) (interface{}, int64, error) {
+	var n int64
+	v, err := e.walk("root", e.root, v, &n)
+	return v, n, err
+}
+
+// substituteJSON replaces unknown enum symbols within a raw JSON document, and
+// returns the resulting document along with the number of symbols that were
+// replaced. The document is only serialised again when symbols are replaced.
+func (e *enumSubstituter) substituteJSON(b []byte) ([]byte, int64, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		// Parsing errors are left for the codec to report.
+		return b, 0, nil
+	}
+
+	v, n, err := e.substitute(v)
+	if err != nil || n == 0 {
+		return b, 0, err
+	}
+	if b, err = json.Marshal(v); err != nil {
+		return nil, 0, err
+	}
+	return b, n, nil
+}
+
+func (e *enumSubstituter) walk(path string, t *avroType, v interface{}, n *int64) (interface{}, error) {
+	switch t.kind {
+	case "enum":
+		str, isStr := v.(string)
+		if !isStr || avroHasSymbol(t, str) {
+			return v, nil
+		}
+		symbol := t.enumFallback
+		if e.fallback != "" && avroHasSymbol(t, e.fallback) {
+			symbol = e.fallback
+		}
+		if symbol == "" {
+			return nil, fmt.Errorf("%v: symbol '%v' is not a member of enum %v, which has no default symbol", path, str, t.name)
+		}
+		*n++
+		return symbol, nil
+	case "record":
+		obj, isObj := v.(map[string]interface{})
+		if !isObj {
+			return v, nil
+		}
+		for _, f := range t.fields {
+			fv, exists := obj[f.name]
+			if !exists {
+				continue
+			}
+			nv, err := e.walk(path+"."+f.name, f.typ, fv, n)
+			if err != nil {
+				return nil, err
+			}
+			obj[f.name] = nv
+		}
+	case "array":
+		arr, isArr := v.([]interface{})
+		if !isArr {
+			return v, nil
+		}
+		for i, ev := range arr {
+			nv, err := e.walk(fmt.Sprintf("%v.%v", path, i), t.items, ev, n)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = nv
+		}
+	case "map":
+		obj, isObj := v.(map[string]interface{})
+		if !isObj {
+			return v, nil
+		}
+		for k, ev := range obj {
+			nv, err := e.walk(path+"."+k, t.values, ev, n)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = nv
+		}
+	case "union":
+		return e.walkUnion(path, t, v, n)
+	}
+	return v, nil
+}
+
+// walkUnion replaces unknown enum symbols within the value of a union, which is
+// either wrapped in an object keyed by the name of its type as in Avro JSON, or
+// unwrapped as in raw JSON, in which case its type is inferred when possible.
+func (e *enumSubstituter) walkUnion(path string, t *avroType, v interface{}, n *int64) (interface{}, error) {
+	if obj, isObj := v.(map[string]interface{}); isObj && len(obj) == 1 {
+		for k, inner := range obj {
+			for _, m := range t.unionMembers {
+				if avroTypeName(m) != k {
+					continue
+				}
+				nv, err := e.walk(path, m, inner, n)
+				if err != nil {
+					return nil, err
+				}
+				obj[k] = nv
+				return obj, nil
+			}
+		}
+	}
+
+	var candidate *avroType
+	for _, m := range t.unionMembers {
+		if !avroUnwrappedMatch(m, v) {
+			continue
+		}
+		if candidate != nil {
+			// The type of the value is ambiguous.
+			return v, nil
+		}
+		candidate = m
+	}
+	if candidate == nil {
+		return v, nil
+	}
+	return e.walk(path, candidate, v, n)
+}
+
+// avroUnwrappedMatch returns whether an unwrapped value could be of a type.
+func avroUnwrappedMatch(t *avroType, v interface{}) bool {
+	switch v.(type) {
+	case string:
+		switch t.kind {
+		case "string", "bytes", "fixed", "enum":
+			return true
+		}
+	case map[string]interface{}:
+		return t.kind == "record" || t.kind == "map"
+	case []interface{}:
+		return t.kind == "array"
+	}
+	return false
+}
+
+func avroHasSymbol(t *avroType, symbol string) bool {
+	for _, s := range t.symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
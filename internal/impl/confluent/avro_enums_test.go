@@ -0,0 +1,116 @@
+package confluent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const enumTestSchema = `{
+  "type": "record",
+  "name": "event",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "color", "type": {"type": "enum", "name": "Color", "symbols": ["RED", "GREEN", "UNKNOWN"]}},
+    {"name": "size", "type": ["null", {"type": "enum", "name": "Size", "symbols": ["S", "M"], "default": "M"}]},
+    {"name": "tags", "type": {"type": "array", "items": "Color"}},
+    {"name": "extra", "type": {"type": "map", "values": "Size"}}
+  ]
+}`
+
+func TestEnumSubstituterNoEnums(t *testing.T) {
+	e, err := newEnumSubstituter(testSchema, "")
+	require.NoError(t, err)
+	assert.Nil(t, e)
+}
+
+func TestEnumSubstituterStructured(t *testing.T) {
+	e, err := newEnumSubstituter(enumTestSchema, "UNKNOWN")
+	require.NoError(t, err)
+	require.NotNil(t, e)
+
+	v, n, err := e.substitute(map[string]interface{}{
+		"color": "BLUE",
+		"size":  map[string]interface{}{"com.example.Size": "XL"},
+		"tags":  []interface{}{"RED", "PINK"},
+		"extra": map[string]interface{}{"a": "XS", "b": "S"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+	assert.Equal(t, map[string]interface{}{
+		"color": "UNKNOWN",
+		"size":  map[string]interface{}{"com.example.Size": "M"},
+		"tags":  []interface{}{"RED", "UNKNOWN"},
+		"extra": map[string]interface{}{"a": "M", "b": "S"},
+	}, v)
+
+	v, n, err = e.substitute(map[string]interface{}{
+		"color": "GREEN",
+		"size":  nil,
+		"tags":  []interface{}{},
+		"extra": map[string]interface{}{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, "GREEN", v.(map[string]interface{})["color"])
+}
+
+func TestEnumSubstituterNoDefault(t *testing.T) {
+	e, err := newEnumSubstituter(enumTestSchema, "")
+	require.NoError(t, err)
+
+	_, _, err = e.substitute(map[string]interface{}{
+		"color": "BLUE",
+	})
+	require.EqualError(t, err, "root.color: symbol 'BLUE' is not a member of enum com.example.Color, which has no default symbol")
+
+	// The default symbol of the enum is used when the fallback is not a member.
+	e, err = newEnumSubstituter(enumTestSchema, "NOPE")
+	require.NoError(t, err)
+
+	v, n, err := e.substitute(map[string]interface{}{
+		"size": map[string]interface{}{"com.example.Size": "XL"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, map[string]interface{}{
+		"size": map[string]interface{}{"com.example.Size": "M"},
+	}, v)
+}
+
+func TestEnumSubstituterJSON(t *testing.T) {
+	e, err := newEnumSubstituter(enumTestSchema, "UNKNOWN")
+	require.NoError(t, err)
+
+	input := []byte(`{"color":"RED","size":"S","tags":["GREEN"],"extra":{"a":"M"},"n":12345678901234567890}`)
+	b, n, err := e.substituteJSON(input)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, string(input), string(b))
+
+	b, n, err = e.substituteJSON([]byte(`{"color":"BLUE","size":"XL","tags":["PINK"],"extra":{},"n":12345678901234567890}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.JSONEq(t, `{"color":"UNKNOWN","size":"M","tags":["UNKNOWN"],"extra":{},"n":12345678901234567890}`, string(b))
+
+	b, n, err = e.substituteJSON([]byte(`not json`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, "not json", string(b))
+}
+
+func TestEnumSubstituterAmbiguousUnion(t *testing.T) {
+	e, err := newEnumSubstituter(`["string",{"type":"enum","name":"Color","symbols":["RED"],"default":"RED"}]`, "")
+	require.NoError(t, err)
+
+	v, n, err := e.substitute("BLUE")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, "BLUE", v)
+
+	v, n, err = e.substitute(map[string]interface{}{"Color": "BLUE"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, map[string]interface{}{"Color": "RED"}, v)
+}
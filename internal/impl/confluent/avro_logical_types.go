@@ -0,0 +1,332 @@
+package confluent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// logicalTypeConverter converts the JSON representations of values of Avro
+// logical types, such as decimals within strings and timestamps formatted as
+// RFC 3339 strings, into the native values accepted by the codec.
+type logicalTypeConverter struct {
+	root   *avroType
+	codecs map[*avroType]*goavro.Codec
+}
+
+// newLogicalTypeConverter returns a converter for the logical types of a
+// schema, or nil if the schema does not contain any logical types that values
+// are converted for.
+func newLogicalTypeConverter(schema string) (*logicalTypeConverter, error) {
+	root, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &logicalTypeConverter{root: root, codecs: map[*avroType]*goavro.Codec{}}
+	if err := c.compileCodecs(root, map[*avroType]bool{}); err != nil {
+		return nil, err
+	}
+	if len(c.codecs) == 0 {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// compileCodecs compiles a codec for each logical type of a schema, which is
+// used for obtaining the textual form of converted values.
+func (c *logicalTypeConverter) compileCodecs(t *avroType, seen map[*avroType]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	switch t.kind {
+	case "record":
+		for _, f := range t.fields {
+			if err := c.compileCodecs(f.typ, seen); err != nil {
+				return err
+			}
+		}
+	case "array":
+		return c.compileCodecs(t.items, seen)
+	case "map":
+		return c.compileCodecs(t.values, seen)
+	case "union":
+		for _, m := range t.unionMembers {
+			if err := c.compileCodecs(m, seen); err != nil {
+				return err
+			}
+		}
+	default:
+		if !avroConvertedLogicalType(t) {
+			return nil
+		}
+		schema, err := json.Marshal(t.logicalSchema)
+		if err != nil {
+			return err
+		}
+		codec, err := goavro.NewCodec(string(schema))
+		if err != nil {
+			return fmt.Errorf("failed to compile logical type %v: %w", t.logicalType, err)
+		}
+		c.codecs[t] = codec
+	}
+	return nil
+}
+
+// avroConvertedLogicalType returns whether values of a type are converted.
+func avroConvertedLogicalType(t *avroType) bool {
+	switch t.logicalType {
+	case "decimal":
+		return t.kind == "bytes" || t.kind == "fixed"
+	case "timestamp-millis", "timestamp-micros":
+		return t.kind == "long"
+	case "date":
+		return t.kind == "int"
+	}
+	return false
+}
+
+// convert converts the values of logical types within a structured value into
+// their native values, modifying the value in place. A nil converter returns
+// the value unchanged.
+func (c *logicalTypeConverter) convert(v interface{}) (interface{}, error) {
+	if c == nil {
+		return v, nil
+	}
+	var n int64
+	return c.walk("root", c.root, v, false, &n)
+}
+
+// convertJSON converts the values of logical types within a raw JSON document
+// into their textual Avro representations. The document is only serialised
+// again when values are converted. A nil converter returns the document
+// unchanged.
+func (c *logicalTypeConverter) convertJSON(b []byte) ([]byte, error) {
+	if c == nil {
+		return b, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		// Parsing errors are left for the codec to report.
+		return b, nil
+	}
+
+	var n int64
+	v, err := c.walk("root", c.root, v, true, &n)
+	if err != nil || n == 0 {
+		return b, err
+	}
+	return json.Marshal(v)
+}
+
+// walk converts the values of logical types within a value of a type, where
+// textual determines whether converted values are native or textual, and
+// counts the values that were converted.
+func (c *logicalTypeConverter) walk(path string, t *avroType, v interface{}, textual bool, n *int64) (interface{}, error) {
+	switch t.kind {
+	case "record":
+		obj, isObj := v.(map[string]interface{})
+		if !isObj {
+			return v, nil
+		}
+		for _, f := range t.fields {
+			fv, exists := obj[f.name]
+			if !exists {
+				continue
+			}
+			nv, err := c.walk(path+"."+f.name, f.typ, fv, textual, n)
+			if err != nil {
+				return nil, err
+			}
+			obj[f.name] = nv
+		}
+	case "array":
+		arr, isArr := v.([]interface{})
+		if !isArr {
+			return v, nil
+		}
+		for i, ev := range arr {
+			nv, err := c.walk(fmt.Sprintf("%v.%v", path, i), t.items, ev, textual, n)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = nv
+		}
+	case "map":
+		obj, isObj := v.(map[string]interface{})
+		if !isObj {
+			return v, nil
+		}
+		for k, ev := range obj {
+			nv, err := c.walk(path+"."+k, t.values, ev, textual, n)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = nv
+		}
+	case "union":
+		return c.walkUnion(path, t, v, textual, n)
+	default:
+		codec, exists := c.codecs[t]
+		if !exists {
+			return v, nil
+		}
+		native, err := convertLogicalValue(t, v)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		if native == nil {
+			return v, nil
+		}
+		*n++
+		if !textual {
+			return native, nil
+		}
+		b, err := codec.TextualFromNative(nil, native)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		return json.RawMessage(b), nil
+	}
+	return v, nil
+}
+
+// walkUnion converts the value of a union, which is either wrapped in an
+// object keyed by the name of its type as in Avro JSON, or unwrapped as in raw
+// JSON, in which case it is only converted when a single logical type of the
+// union could hold the value.
+func (c *logicalTypeConverter) walkUnion(path string, t *avroType, v interface{}, textual bool, n *int64) (interface{}, error) {
+	if obj, isObj := v.(map[string]interface{}); isObj && len(obj) == 1 {
+		for k, inner := range obj {
+			for _, m := range t.unionMembers {
+				if avroUnionMemberName(m) != k {
+					continue
+				}
+				nv, err := c.walk(path, m, inner, textual, n)
+				if err != nil {
+					return nil, err
+				}
+				obj[k] = nv
+				return obj, nil
+			}
+		}
+	}
+
+	if !textual {
+		return v, nil
+	}
+
+	var candidate *avroType
+	for _, m := range t.unionMembers {
+		if !avroLogicalUnwrappedMatch(m, v) {
+			continue
+		}
+		if candidate != nil {
+			// The type of the value is ambiguous.
+			return v, nil
+		}
+		candidate = m
+	}
+	if candidate == nil {
+		return v, nil
+	}
+	return c.walk(path, candidate, v, textual, n)
+}
+
+// avroUnionMemberName returns the name of the type of a union member as used
+// for wrapping union values, which for logical types of primitive types
+// includes the logical type.
+func avroUnionMemberName(t *avroType) string {
+	if t.name == "" && t.logicalType != "" {
+		return t.kind + "." + t.logicalType
+	}
+	return avroTypeName(t)
+}
+
+// avroLogicalUnwrappedMatch returns whether an unwrapped value could be of a
+// type, where strings are considered to match converted logical types.
+func avroLogicalUnwrappedMatch(t *avroType, v interface{}) bool {
+	if _, isStr := v.(string); isStr && avroConvertedLogicalType(t) {
+		return true
+	}
+	if _, isNum := v.(json.Number); isNum {
+		switch t.kind {
+		case "int", "long", "float", "double":
+			return true
+		}
+		return t.logicalType == "decimal"
+	}
+	return avroUnwrappedMatch(t, v)
+}
+
+// convertLogicalValue returns the native value of a value of a logical type,
+// or nil if the value is already native or cannot be converted, in which case
+// it is left for the codec to report.
+func convertLogicalValue(t *avroType, v interface{}) (interface{}, error) {
+	switch t.logicalType {
+	case "decimal":
+		return convertDecimal(t, v)
+	case "timestamp-millis", "timestamp-micros":
+		str, isStr := v.(string)
+		if !isStr {
+			return nil, nil
+		}
+		ts, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %v value '%v' as an RFC 3339 timestamp", t.logicalType, str)
+		}
+		return ts, nil
+	case "date":
+		str, isStr := v.(string)
+		if !isStr {
+			return nil, nil
+		}
+		d, err := time.Parse("2006-01-02", str)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date value '%v', expected the format YYYY-MM-DD", str)
+		}
+		return d, nil
+	}
+	return nil, nil
+}
+
+func convertDecimal(t *avroType, v interface{}) (interface{}, error) {
+	var str string
+	switch n := v.(type) {
+	case string:
+		str = n
+	case json.Number:
+		str = n.String()
+	case int64:
+		str = strconv.FormatInt(n, 10)
+	case float64:
+		str = strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		return nil, nil
+	}
+
+	r, ok := new(big.Rat).SetString(str)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse decimal value '%v'", str)
+	}
+
+	// The codec truncates digits beyond the scale of the decimal, which is
+	// rejected instead as it would silently change the value.
+	scale, _ := t.logicalSchema["scale"].(float64)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if !scaled.IsInt() {
+		return nil, fmt.Errorf("decimal value '%v' exceeds the scale of %v", str, int(scale))
+	}
+	return r, nil
+}
@@ -0,0 +1,112 @@
+package confluent
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const logicalTypesTestSchema = `{
+  "type": "record",
+  "name": "payment",
+  "fields": [
+    {"name": "amount", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+    {"name": "fee", "type": ["null", {"type": "fixed", "name": "fee", "size": 8, "logicalType": "decimal", "precision": 10, "scale": 2}]},
+    {"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "day", "type": {"type": "int", "logicalType": "date"}}
+  ]
+}`
+
+func TestLogicalTypeConverterNoLogicalTypes(t *testing.T) {
+	c, err := newLogicalTypeConverter(testSchema)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+}
+
+func TestLogicalTypeConverterStructured(t *testing.T) {
+	c, err := newLogicalTypeConverter(logicalTypesTestSchema)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	v, err := c.convert(map[string]interface{}{
+		"amount":     "12.34",
+		"fee":        map[string]interface{}{"fee": 0.5},
+		"created_at": "2021-01-02T03:04:05.678Z",
+		"day":        "2021-01-02",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"amount":     big.NewRat(1234, 100),
+		"fee":        map[string]interface{}{"fee": big.NewRat(1, 2)},
+		"created_at": time.Date(2021, 1, 2, 3, 4, 5, 678000000, time.UTC),
+		"day":        time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	}, v)
+
+	// Values that are already native are left unchanged.
+	v, err = c.convert(map[string]interface{}{
+		"amount":     big.NewRat(1, 1),
+		"fee":        nil,
+		"created_at": int64(1609556645678),
+		"day":        int64(18629),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"amount":     big.NewRat(1, 1),
+		"fee":        nil,
+		"created_at": int64(1609556645678),
+		"day":        int64(18629),
+	}, v)
+}
+
+func TestLogicalTypeConverterJSON(t *testing.T) {
+	c, err := newLogicalTypeConverter(logicalTypesTestSchema)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	b, err := c.convertJSON([]byte(`{"amount":-1,"fee":null,"created_at":"2021-01-02T03:04:05.678Z","day":"2021-01-02"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"amount":"\u009C","created_at":1609556645678,"day":18629,"fee":null}`, string(b))
+
+	// Documents without values to convert are returned unchanged.
+	in := []byte(`{"fee":null, "created_at":1609556645678, "day":18629}`)
+	b, err = c.convertJSON(in)
+	require.NoError(t, err)
+	assert.Equal(t, string(in), string(b))
+}
+
+func TestLogicalTypeConverterErrors(t *testing.T) {
+	c, err := newLogicalTypeConverter(logicalTypesTestSchema)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	tests := []struct {
+		input       map[string]interface{}
+		errContains string
+	}{
+		{
+			input:       map[string]interface{}{"amount": "nope"},
+			errContains: "root.amount: failed to parse decimal value 'nope'",
+		},
+		{
+			input:       map[string]interface{}{"amount": "1.234"},
+			errContains: "root.amount: decimal value '1.234' exceeds the scale of 2",
+		},
+		{
+			input:       map[string]interface{}{"created_at": "yesterday"},
+			errContains: "root.created_at: failed to parse timestamp-millis value 'yesterday' as an RFC 3339 timestamp",
+		},
+		{
+			input:       map[string]interface{}{"day": "02/01/2021"},
+			errContains: "root.day: failed to parse date value '02/01/2021', expected the format YYYY-MM-DD",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := c.convert(test.input)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), test.errContains)
+	}
+}
@@ -0,0 +1,102 @@
+package confluent
+
+import (
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// sharedCodecCaches are the caches of compiled codecs shared by processors
+// within the same process, by name.
+var (
+	sharedCodecCachesMut sync.Mutex
+	sharedCodecCaches    = map[string]*codecCache{}
+)
+
+// acquireCodecCache returns the shared codec cache of a name, creating it if
+// it does not exist yet. Each call must be paired with a call to
+// releaseCodecCache once the cache is no longer used.
+func acquireCodecCache(name string) *codecCache {
+	sharedCodecCachesMut.Lock()
+	defer sharedCodecCachesMut.Unlock()
+
+	c, exists := sharedCodecCaches[name]
+	if !exists {
+		c = &codecCache{
+			name:   name,
+			codecs: map[codecCacheKey]*cachedCodec{},
+		}
+		sharedCodecCaches[name] = c
+	}
+	c.users++
+	return c
+}
+
+// releaseCodecCache releases a shared codec cache, which is removed once it
+// has no remaining users.
+func releaseCodecCache(c *codecCache) {
+	sharedCodecCachesMut.Lock()
+	defer sharedCodecCachesMut.Unlock()
+
+	if c.users--; c.users <= 0 {
+		delete(sharedCodecCaches, c.name)
+	}
+}
+
+// codecCacheKey identifies a compiled codec by the registry it was obtained
+// from, the ID of its schema, and how it was compiled.
+type codecCacheKey struct {
+	url  string
+	id   int
+	mode string
+}
+
+type cachedCodec struct {
+	codec *goavro.Codec
+	refs  int
+}
+
+// codecCache is a cache of compiled codecs that is shared by processors, where
+// codecs are reference counted and removed once no processor refers to them.
+type codecCache struct {
+	name  string
+	users int
+
+	mut    sync.Mutex
+	codecs map[codecCacheKey]*cachedCodec
+}
+
+// acquire returns the codec of a key, compiling it if it is not cached yet, and
+// adds a reference to it. Each successful call must be paired with a call to
+// release once the codec is no longer used.
+func (c *codecCache) acquire(key codecCacheKey, compile func() (*goavro.Codec, error)) (*goavro.Codec, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if cached, exists := c.codecs[key]; exists {
+		cached.refs++
+		return cached.codec, nil
+	}
+
+	codec, err := compile()
+	if err != nil {
+		return nil, err
+	}
+	c.codecs[key] = &cachedCodec{codec: codec, refs: 1}
+	return codec, nil
+}
+
+// release removes a reference to the codec of a key, removing the codec from
+// the cache once it has no remaining references.
+func (c *codecCache) release(key codecCacheKey) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	cached, exists := c.codecs[key]
+	if !exists {
+		return
+	}
+	if cached.refs--; cached.refs <= 0 {
+		delete(c.codecs, key)
+	}
+}
@@ -0,0 +1,91 @@
+package confluent
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func failureSamplerField() *service.ConfigField {
+	return service.NewObjectField("log_failures",
+		service.NewBoolField("enabled").
+			Description("Whether to log the payloads of messages that fail to encode.").
+			Default(false),
+		service.NewDurationField("interval").
+			Description("The minimum period between logged payloads, where failures within this period are counted rather than logged.").
+			Default("10s"),
+		service.NewIntField("max_bytes").
+			Description("The maximum number of bytes of a payload to log, where longer payloads are truncated.").
+			Default(256),
+	).Description("Log a sample of the payloads of messages that fail to encode, along with their schema subject and error, at the warn level. This is useful for diagnosing bad data, but since payloads may contain sensitive data it is disabled by default.").
+		Advanced().Version("4.2.0")
+}
+
+// failureSampler logs the payloads of messages that failed to encode, limited
+// to one payload per interval and truncated to a maximum size.
+type failureSampler struct {
+	interval time.Duration
+	maxBytes int
+
+	mut        sync.Mutex
+	lastLogged time.Time
+	suppressed int64
+}
+
+func failureSamplerFromParsed(conf *service.ParsedConfig) (*failureSampler, error) {
+	conf = conf.Namespace("log_failures")
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+	f := &failureSampler{}
+	if f.interval, err = conf.FieldDuration("interval"); err != nil {
+		return nil, err
+	}
+	if f.maxBytes, err = conf.FieldInt("max_bytes"); err != nil {
+		return nil, err
+	}
+	if f.maxBytes <= 0 {
+		return nil, fmt.Errorf("log_failures max_bytes must be greater than zero, got %v", f.maxBytes)
+	}
+	return f, nil
+}
+
+// sample returns whether a failure at the given time should be logged, along
+// with the number of failures that were not logged since the last that was.
+func (f *failureSampler) sample(now time.Time) (suppressed int64, ok bool) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if !f.lastLogged.IsZero() && now.Sub(f.lastLogged) < f.interval {
+		f.suppressed++
+		return 0, false
+	}
+	suppressed, f.suppressed = f.suppressed, 0
+	f.lastLogged = now
+	return suppressed, true
+}
+
+// payload returns a quoted copy of a payload truncated to the maximum size.
+func (f *failureSampler) payload(b []byte) string {
+	if len(b) <= f.maxBytes {
+		return fmt.Sprintf("%q", b)
+	}
+	return fmt.Sprintf("%q (truncated from %v bytes)", b[:f.maxBytes], len(b))
+}
+
+// log logs the payload of a message that failed to encode with a subject,
+// unless another payload was logged within the interval.
+func (f *failureSampler) log(logger *service.Logger, now time.Time, msg *service.Message, subject string, encodeErr error) {
+	suppressed, ok := f.sample(now)
+	if !ok {
+		return
+	}
+	b, err := msg.AsBytes()
+	if err != nil {
+		return
+	}
+	logger.Warnf("Failed to encode message with subject '%v': %v, payload: %v, failures since the last sample: %v", subject, encodeErr, f.payload(b), suppressed)
+}
@@ -0,0 +1,63 @@
+package confluent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestFailureSamplerDisabled(t *testing.T) {
+	conf, err := service.NewConfigSpec().Field(failureSamplerField()).ParseYAML(`{}`, nil)
+	require.NoError(t, err)
+
+	f, err := failureSamplerFromParsed(conf)
+	require.NoError(t, err)
+	assert.Nil(t, f)
+}
+
+func TestFailureSamplerSample(t *testing.T) {
+	conf, err := service.NewConfigSpec().Field(failureSamplerField()).ParseYAML(`
+log_failures:
+  enabled: true
+  interval: 10s
+`, nil)
+	require.NoError(t, err)
+
+	f, err := failureSamplerFromParsed(conf)
+	require.NoError(t, err)
+	require.NotNil(t, f)
+
+	start := time.Unix(1000, 0)
+
+	suppressed, ok := f.sample(start)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), suppressed)
+
+	for i := 1; i <= 3; i++ {
+		_, ok = f.sample(start.Add(time.Duration(i) * time.Second))
+		assert.False(t, ok)
+	}
+
+	suppressed, ok = f.sample(start.Add(10 * time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), suppressed)
+
+	_, ok = f.sample(start.Add(15 * time.Second))
+	assert.False(t, ok)
+
+	suppressed, ok = f.sample(start.Add(time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), suppressed)
+}
+
+func TestFailureSamplerPayload(t *testing.T) {
+	f := &failureSampler{maxBytes: 5}
+
+	assert.Equal(t, `"hello"`, f.payload([]byte("hello")))
+	assert.Equal(t, `"hello" (truncated from 11 bytes)`, f.payload([]byte("hello world")))
+	assert.Equal(t, `"\x00\x01"`, f.payload([]byte{0, 1}))
+}
@@ -0,0 +1,77 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	jsonschema "github.com/xeipuuv/gojsonschema"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+// newJSONSchemaEncoder compiles an encoder for a JSON schema returned by the
+// schema registry, which validates JSON documents against the schema and leaves
+// them unchanged, as the Confluent wire format for JSON schemas is the document
+// itself.
+func (s *schemaRegistryEncoder) newJSONSchemaEncoder(ctx context.Context, registryURL string, res *schemaResponse) (schemaEncoder, error) {
+	if err := s.checkAvroOnlyOptions(); err != nil {
+		return nil, err
+	}
+
+	// Referenced schemas are added to the loader by their reference names,
+	// which are the URLs used to refer to them within the schema.
+	refs := map[string]string{}
+	if err := s.fetchSchemaReferences(ctx, registryURL, res.References, refs); err != nil {
+		return nil, err
+	}
+
+	loader := jsonschema.NewSchemaLoader()
+	for name, ref := range refs {
+		if err := loader.AddSchema(name, jsonschema.NewStringLoader(ref)); err != nil {
+			return nil, fmt.Errorf("failed to load JSON schema reference '%v': %w", name, err)
+		}
+	}
+
+	schema, err := loader.Compile(jsonschema.NewStringLoader(res.Schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON schema %v: %w", res.ID, err)
+	}
+
+	return func(m *service.Message) error {
+		b, err := m.AsBytes()
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			return errors.New("empty messages cannot be encoded as null with JSON schemas")
+		}
+
+		result, err := schema.Validate(jsonschema.NewBytesLoader(b))
+		if err != nil {
+			return fmt.Errorf("failed to parse JSON message: %w", err)
+		}
+		if !result.Valid() {
+			return errors.New(jsonSchemaResultError(result))
+		}
+		return nil
+	}, nil
+}
+
+// jsonSchemaResultError describes the errors of an invalid result, one per
+// line, in the same format as the json_schema processor.
+func jsonSchemaResultError(result *jsonschema.Result) string {
+	var errStr string
+	for i, desc := range result.Errors() {
+		if i > 0 {
+			errStr += "\n"
+		}
+		description := strings.ToLower(desc.Description())
+		if property := desc.Details()["property"]; property != nil {
+			description = property.(string) + strings.TrimPrefix(description, strings.ToLower(property.(string)))
+		}
+		errStr += desc.Field() + " " + description
+	}
+	return errStr
+}
@@ -0,0 +1,131 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+const testJSONSchema = `{
+	"type": "object",
+	"properties": {
+		"name": { "type": "string" },
+		"address": { "$ref": "http://example.com/address.json" }
+	},
+	"required": [ "name" ]
+}`
+
+const testJSONReferenceSchema = `{
+	"type": "object",
+	"properties": {
+		"city": { "type": "string" }
+	}
+}`
+
+func runJSONSchemaRegistryServer(t *testing.T) string {
+	t.Helper()
+
+	latest, err := json.Marshal(map[string]interface{}{
+		"schema":     testJSONSchema,
+		"schemaType": "JSON",
+		"id":         7,
+		"references": []schemaReference{
+			{Name: "http://example.com/address.json", Subject: "address", Version: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	reference, err := json.Marshal(map[string]interface{}{
+		"schema":     testJSONReferenceSchema,
+		"schemaType": "JSON",
+		"id":         6,
+	})
+	require.NoError(t, err)
+
+	return runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/subjects/foo/versions/latest":
+			return latest, nil
+		case "/subjects/address/versions/1":
+			return reference, nil
+		}
+		return nil, errors.New("nope")
+	})
+}
+
+func TestSchemaRegistryEncodeJSONSchema(t *testing.T) {
+	urlStr := runJSONSchemaRegistryServer(t)
+
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+`, urlStr), nil)
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, encoder.Close(context.Background()))
+	})
+
+	tests := []struct {
+		name        string
+		input       string
+		output      string
+		errContains string
+	}{
+		{
+			name:   "successful message",
+			input:  `{"name":"foo","address":{"city":"bar"}}`,
+			output: "\x00\x00\x00\x00\x07" + `{"name":"foo","address":{"city":"bar"}}`,
+		},
+		{
+			name:        "missing required field",
+			input:       `{"address":{"city":"bar"}}`,
+			errContains: "(root) name is required",
+		},
+		{
+			name:        "referenced schema mismatch",
+			input:       `{"name":"foo","address":{"city":10}}`,
+			errContains: "address.city invalid type",
+		},
+		{
+			name:        "not JSON",
+			input:       `not json`,
+			errContains: "failed to parse JSON message",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			outBatches, err := encoder.ProcessBatch(
+				context.Background(),
+				service.MessageBatch{service.NewMessage([]byte(test.input))},
+			)
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			b, err := outBatches[0][0].AsBytes()
+			require.NoError(t, err)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				assert.Equal(t, test.input, string(b))
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.output, string(b))
+			}
+		})
+	}
+}
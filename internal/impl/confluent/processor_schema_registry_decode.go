@@ -1,23 +1,30 @@
 package confluent
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/binary"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/linkedin/goavro/v2"
+	"github.com/vmihailenco/msgpack/v5"
 
 	"github.com/benthosdev/benthos/v4/internal/shutdown"
+	"github.com/benthosdev/benthos/v4/public/bloblang"
 	"github.com/benthosdev/benthos/v4/public/service"
 )
 
@@ -29,6 +36,8 @@ func schemaRegistryDecoderConfig() *service.ConfigSpec {
 		Description(`
 Decodes messages automatically from a schema stored within a [Confluent Schema Registry service](https://docs.confluent.io/platform/current/schema-registry/index.html) by extracting a schema ID from the message and obtaining the associated schema from the registry. If a message fails to match against the schema then it will remain unchanged and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).
 
+The schema ID is extracted from the Confluent wire format header at the beginning of each message, which consists of a zero magic byte followed by the four byte big-endian schema ID. The layout of this header can be customised with the field ` + "[`header`](#header)" + ` in order to decode messages of registries that use a different layout.
+
 Currently only Avro schemas are supported.
 
 ### Avro JSON Format
@@ -42,18 +51,61 @@ For example, the union schema ` + "`[\"null\",\"string\",\"Foo\"]`, where `Foo`"
 
 - ` + "`null` as `null`" + `;
 - the string ` + "`\"a\"` as `{\"string\": \"a\"}`" + `; and
-- a ` + "`Foo` instance as `{\"Foo\": {...}}`, where `{...}` indicates the JSON encoding of a `Foo`" + ` instance.`).
+- a ` + "`Foo` instance as `{\"Foo\": {...}}`, where `{...}` indicates the JSON encoding of a `Foo`" + ` instance.
+
+### Metadata
+
+When the field ` + "[`fetch_subjects`](#fetch_subjects)" + ` is set to ` + "`true`" + ` this processor adds the following metadata fields to each decoded message:
+
+` + "```text" + `
+- schema_registry_subject
+- schema_registry_subjects
+` + "```" + `
+
+Where ` + "`schema_registry_subject`" + ` is the first subject associated with the schema ID of the message, and ` + "`schema_registry_subjects`" + ` is a comma separated list of all subjects associated with it.
+
+You can access these metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).
+
+### Correlation Keys
+
+When the field ` + "[`correlation_key`](#correlation_key)" + ` is set the decoded messages of each batch are grouped by the result of the mapping, which is executed on each decoded message, and a batch is emitted for each distinct key in the order in which the keys first appear. The order of messages within each group is preserved, and each message has the metadata field ` + "`schema_registry_correlation_key`" + ` set to its key. Messages that fail to decode, or for which the mapping fails, are emitted unchanged within a separate final batch and are flagged as having failed.
+
+This allows messages to be decoded and joined by a value of their payload in a single step, which can then be aggregated with processors such as ` + "[`archive`](/docs/components/processors/archive)" + `. The mapping is only able to query the contents of messages decoded with the ` + "`json`" + ` format.`).
 		// Field(service.NewBoolField("avro_raw_json").
 		// 	Description("Whether Avro messages should be decoded into raw JSON documents rather than [Avro JSON](https://avro.apache.org/docs/current/spec.html#json_encoding). Avro JSON contains namespaced objects for any typed or non-nil union values, e.g. a union `[\"null\",\"string\"]` field with a string value would be represented as `{\"string\":\"foo\"}`.").
 		// 	Advanced().Default(false)).
 		Field(service.NewStringField("url").Description("The base URL of the schema registry service.")).
-		Field(service.NewTLSField("tls"))
+		Field(service.NewBoolField("fetch_subjects").
+			Description("Whether to obtain the subjects associated with the schema ID of each message from the registry and add them to the message as metadata. Subjects are cached by schema ID, but each new schema ID requires an additional request to the registry.").
+			Advanced().Default(false).Version("4.2.0")).
+		Field(service.NewStringAnnotatedEnumField("format", map[string]string{
+			"json":    "Messages are decoded as [Avro JSON](#avro-json-format) documents.",
+			"msgpack": "Messages are re-serialized in the [MessagePack](https://msgpack.org/) format.",
+			"cbor":    "Messages are re-serialized in the [CBOR](https://cbor.io/) format.",
+		}).Description("The format of decoded messages. The binary formats `msgpack` and `cbor` are more compact than JSON and preserve the types of decoded values that JSON cannot represent, such as `bytes` and `fixed` values, which are serialized as binary strings rather than text, and logical timestamp types, which are serialized as timestamps. Union values are structured in the same way as Avro JSON, and the keys of maps and records are sorted so that equal values always serialize identically.").
+			Advanced().Default("json").Version("4.2.0")).
+		Field(service.NewStringAnnotatedEnumField("bytes_encoding", map[string]string{
+			"avro_json": "Values are encoded as [Avro JSON](#avro-json-format) strings, where each byte is represented by the unicode code point of the same value.",
+			"base64":    "Values are encoded as standard base64 strings.",
+			"hex":       "Values are encoded as lowercase hexadecimal strings.",
+			"array":     "Values are encoded as arrays of integers, one for each byte.",
+		}).Description("How the values of `bytes` and `fixed` types are represented when messages are decoded with the `json` format. When set to anything other than `avro_json` the values of `decimal` logical types are also represented as strings of their exact decimal value, such as `\"12.34\"`, rather than as their encoded bytes. This field has no effect on the binary formats.").
+			Advanced().Default("avro_json").Version("4.2.0")).
+		Field(service.NewBloblangField("correlation_key").
+			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) executed on each decoded message that results in a key by which the messages of a batch are [grouped](#correlation-keys).").
+			Advanced().Version("4.2.0").
+			Example(`root = this.order_id`).
+			Example(`root = "%v-%v".format(this.customer.id, meta("kafka_partition"))`).
+			Optional()).
+		Field(wireHeaderField()).
+		Field(service.NewTLSField("tls")).
+		Field(tlsMinVersionField())
 }
 
 func init() {
-	err := service.RegisterProcessor(
+	err := service.RegisterBatchProcessor(
 		"schema_registry_decode", schemaRegistryDecoderConfig(),
-		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
 			return newSchemaRegistryDecoderFromConfig(conf, mgr.Logger())
 		})
 
@@ -65,12 +117,19 @@ func init() {
 //------------------------------------------------------------------------------
 
 type schemaRegistryDecoder struct {
-	client      *http.Client
-	avroRawJSON bool
+	client          *http.Client
+	avroRawJSON     bool
+	fetchSubjects   bool
+	serializeNative nativeSerializer
+	encodeBytes     bytesEncoder
+	header          wireHeader
+	correlationKey  *bloblang.Executor
 
 	schemaRegistryBaseURL *url.URL
 
 	schemas    map[int]*cachedSchemaDecoder
+	subjects   map[int]*cachedSchemaSubjects
+	infos      map[int]*cachedSchemaInfo
 	cacheMut   sync.RWMutex
 	requestMut sync.Mutex
 	shutSig    *shutdown.Signaller
@@ -83,11 +142,50 @@ func newSchemaRegistryDecoderFromConfig(conf *service.ParsedConfig, logger *serv
 	if err != nil {
 		return nil, err
 	}
-	tlsConf, err := conf.FieldTLS("tls")
+	tlsConf, err := registryTLSFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	fetchSubjects, err := conf.FieldBool("fetch_subjects")
+	if err != nil {
+		return nil, err
+	}
+	format, err := conf.FieldString("format")
+	if err != nil {
+		return nil, err
+	}
+	serializeNative, err := nativeSerializerForFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	bytesEncodingStr, err := conf.FieldString("bytes_encoding")
 	if err != nil {
 		return nil, err
 	}
-	return newSchemaRegistryDecoder(urlStr, tlsConf, true, logger)
+	encodeBytes, err := bytesEncoderForName(bytesEncodingStr)
+	if err != nil {
+		return nil, err
+	}
+	header, err := wireHeaderFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	var correlationKey *bloblang.Executor
+	if conf.Contains("correlation_key") {
+		if correlationKey, err = conf.FieldBloblang("correlation_key"); err != nil {
+			return nil, err
+		}
+	}
+	s, err := newSchemaRegistryDecoder(urlStr, tlsConf, true, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.fetchSubjects = fetchSubjects
+	s.serializeNative = serializeNative
+	s.encodeBytes = encodeBytes
+	s.header = header
+	s.correlationKey = correlationKey
+	return s, nil
 }
 
 func newSchemaRegistryDecoder(urlStr string, tlsConf *tls.Config, avroRawJSON bool, logger *service.Logger) (*schemaRegistryDecoder, error) {
@@ -98,25 +196,16 @@ func newSchemaRegistryDecoder(urlStr string, tlsConf *tls.Config, avroRawJSON bo
 
 	s := &schemaRegistryDecoder{
 		avroRawJSON:           avroRawJSON,
+		header:                confluentHeader,
 		schemaRegistryBaseURL: u,
 		schemas:               map[int]*cachedSchemaDecoder{},
+		subjects:              map[int]*cachedSchemaSubjects{},
+		infos:                 map[int]*cachedSchemaInfo{},
 		shutSig:               shutdown.NewSignaller(),
 		logger:                logger,
 	}
 
-	s.client = http.DefaultClient
-	if tlsConf != nil {
-		s.client = &http.Client{}
-		if c, ok := http.DefaultTransport.(*http.Transport); ok {
-			cloned := c.Clone()
-			cloned.TLSClientConfig = tlsConf
-			s.client.Transport = cloned
-		} else {
-			s.client.Transport = &http.Transport{
-				TLSClientConfig: tlsConf,
-			}
-		}
-	}
+	s.client = newRegistryClient(tlsConf)
 
 	go func() {
 		for {
@@ -137,7 +226,7 @@ func (s *schemaRegistryDecoder) Process(ctx context.Context, msg *service.Messag
 		return nil, errors.New("unable to reference message as bytes")
 	}
 
-	id, remaining, err := extractID(b)
+	id, remaining, err := s.header.extract(b)
 	if err != nil {
 		return nil, err
 	}
@@ -153,9 +242,85 @@ func (s *schemaRegistryDecoder) Process(ctx context.Context, msg *service.Messag
 		return nil, err
 	}
 
+	if s.fetchSubjects {
+		subjects, err := s.getSubjects(id)
+		if err != nil {
+			return nil, err
+		}
+		if len(subjects) > 0 {
+			newMsg.MetaSet("schema_registry_subject", subjects[0])
+			newMsg.MetaSet("schema_registry_subjects", strings.Join(subjects, ","))
+		}
+	}
+
 	return service.MessageBatch{newMsg}, nil
 }
 
+func (s *schemaRegistryDecoder) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	batch = batch.Copy()
+
+	if s.correlationKey == nil {
+		outBatch := make(service.MessageBatch, 0, len(batch))
+		for _, msg := range batch {
+			res, err := s.Process(ctx, msg)
+			if err != nil {
+				msg.SetError(err)
+				outBatch = append(outBatch, msg)
+				continue
+			}
+			outBatch = append(outBatch, res...)
+		}
+		return []service.MessageBatch{outBatch}, nil
+	}
+
+	var keys []string
+	groups := map[string]service.MessageBatch{}
+	var failed service.MessageBatch
+	for _, msg := range batch {
+		res, err := s.Process(ctx, msg)
+		var key string
+		if err == nil {
+			key, err = s.extractCorrelationKey(res[0])
+		}
+		if err != nil {
+			msg.SetError(err)
+			failed = append(failed, msg)
+			continue
+		}
+		res[0].MetaSet("schema_registry_correlation_key", key)
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], res[0])
+	}
+
+	outBatches := make([]service.MessageBatch, 0, len(keys)+1)
+	for _, k := range keys {
+		outBatches = append(outBatches, groups[k])
+	}
+	if len(failed) > 0 {
+		outBatches = append(outBatches, failed)
+	}
+	return outBatches, nil
+}
+
+// extractCorrelationKey returns the result of the correlation_key mapping of a
+// decoded message.
+func (s *schemaRegistryDecoder) extractCorrelationKey(msg *service.Message) (string, error) {
+	res, err := msg.BloblangQuery(s.correlationKey)
+	if err != nil {
+		return "", fmt.Errorf("correlation_key mapping failed: %w", err)
+	}
+	if res == nil {
+		return "", errors.New("correlation_key mapping deleted the message")
+	}
+	b, err := res.AsBytes()
+	if err != nil {
+		return "", fmt.Errorf("correlation_key mapping failed: %w", err)
+	}
+	return string(b), nil
+}
+
 func (s *schemaRegistryDecoder) Close(ctx context.Context) error {
 	s.shutSig.CloseNow()
 	s.cacheMut.Lock()
@@ -166,6 +331,12 @@ func (s *schemaRegistryDecoder) Close(ctx context.Context) error {
 	for k := range s.schemas {
 		delete(s.schemas, k)
 	}
+	for k := range s.subjects {
+		delete(s.subjects, k)
+	}
+	for k := range s.infos {
+		delete(s.infos, k)
+	}
 	return nil
 }
 
@@ -173,23 +344,137 @@ func (s *schemaRegistryDecoder) Close(ctx context.Context) error {
 
 type schemaDecoder func(m *service.Message) error
 
+// nativeSerializer serializes a value decoded by a codec into an alternative
+// binary format.
+type nativeSerializer func(native interface{}) ([]byte, error)
+
+// nativeSerializerForFormat returns the serializer of a decode format, which is
+// nil for the default JSON format.
+func nativeSerializerForFormat(format string) (nativeSerializer, error) {
+	switch format {
+	case "json":
+		return nil, nil
+	case "msgpack":
+		return func(native interface{}) ([]byte, error) {
+			var buf bytes.Buffer
+			enc := msgpack.NewEncoder(&buf)
+			enc.SetSortMapKeys(true)
+			if err := enc.Encode(native); err != nil {
+				return nil, fmt.Errorf("failed to serialize decoded value as msgpack: %w", err)
+			}
+			return buf.Bytes(), nil
+		}, nil
+	case "cbor":
+		encMode, err := cbor.EncOptions{
+			Sort:    cbor.SortCanonical,
+			Time:    cbor.TimeRFC3339Nano,
+			TimeTag: cbor.EncTagRequired,
+		}.EncMode()
+		if err != nil {
+			return nil, err
+		}
+		return func(native interface{}) ([]byte, error) {
+			b, err := encMode.Marshal(native)
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize decoded value as cbor: %w", err)
+			}
+			return b, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("format '%v' not recognised", format)
+}
+
+// bytesEncoder converts the value of a bytes or fixed type into a value that
+// can be represented in JSON.
+type bytesEncoder func(b []byte) interface{}
+
+// bytesEncoderForName returns the encoder of a bytes encoding, which is nil for
+// the default Avro JSON encoding.
+func bytesEncoderForName(name string) (bytesEncoder, error) {
+	switch name {
+	case "avro_json":
+		return nil, nil
+	case "base64":
+		return func(b []byte) interface{} {
+			return base64.StdEncoding.EncodeToString(b)
+		}, nil
+	case "hex":
+		return func(b []byte) interface{} {
+			return hex.EncodeToString(b)
+		}, nil
+	case "array":
+		return func(b []byte) interface{} {
+			arr := make([]interface{}, len(b))
+			for i, c := range b {
+				arr[i] = int64(c)
+			}
+			return arr
+		}, nil
+	}
+	return nil, fmt.Errorf("bytes_encoding option '%v' not recognised", name)
+}
+
+// structuredNative returns a copy of a value decoded by a codec where the
+// values of bytes and fixed types are converted with a bytes encoder, and
+// decimal values are converted into strings of their exact decimal value.
+func structuredNative(v interface{}, encodeBytes bytesEncoder) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[k] = structuredNative(e, encodeBytes)
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(t))
+		for i, e := range t {
+			arr[i] = structuredNative(e, encodeBytes)
+		}
+		return arr
+	case []byte:
+		return encodeBytes(t)
+	case *big.Rat:
+		return decimalString(t)
+	}
+	return v
+}
+
+// decimalString returns the exact decimal representation of a rational number
+// decoded from a decimal logical type, the denominator of which is always a
+// product of twos and fives.
+func decimalString(r *big.Rat) string {
+	denom := new(big.Int).Set(r.Denom())
+	two, five := big.NewInt(2), big.NewInt(5)
+	var twos, fives int
+	mod := new(big.Int)
+	for denom.Cmp(big.NewInt(1)) > 0 {
+		if mod.Mod(denom, two).Sign() == 0 {
+			denom.Div(denom, two)
+			twos++
+		} else if mod.Mod(denom, five).Sign() == 0 {
+			denom.Div(denom, five)
+			fives++
+		} else {
+			// Not a terminating decimal, which a decoded decimal never is.
+			return r.RatString()
+		}
+	}
+	places := twos
+	if fives > places {
+		places = fives
+	}
+	return r.FloatString(places)
+}
+
 type cachedSchemaDecoder struct {
 	lastUsedUnixSeconds int64
 	decoder             schemaDecoder
+	codec               *goavro.Codec
 }
 
-func extractID(b []byte) (id int, remaining []byte, err error) {
-	if len(b) == 0 {
-		err = errors.New("message is empty")
-		return
-	}
-	if b[0] != 0 {
-		err = fmt.Errorf("serialization format version number %v not supported", b[0])
-		return
-	}
-	id = int(binary.BigEndian.Uint32(b[1:5]))
-	remaining = b[5:]
-	return
+type cachedSchemaSubjects struct {
+	lastUsedUnixSeconds int64
+	subjects            []string
 }
 
 const (
@@ -201,33 +486,71 @@ func (s *schemaRegistryDecoder) clearExpired() {
 	// First pass in read only mode to gather candidates
 	s.cacheMut.RLock()
 	targetTime := time.Now().Add(-schemaStaleAfter).Unix()
-	var targets []int
+	var targets, subjectTargets, infoTargets []int
 	for k, v := range s.schemas {
 		if atomic.LoadInt64(&v.lastUsedUnixSeconds) < targetTime {
 			targets = append(targets, k)
 		}
 	}
+	for k, v := range s.subjects {
+		if atomic.LoadInt64(&v.lastUsedUnixSeconds) < targetTime {
+			subjectTargets = append(subjectTargets, k)
+		}
+	}
+	for k, v := range s.infos {
+		if atomic.LoadInt64(&v.lastUsedUnixSeconds) < targetTime {
+			infoTargets = append(infoTargets, k)
+		}
+	}
 	s.cacheMut.RUnlock()
 
 	// Second pass fully locks schemas and removes stale decoders
-	if len(targets) > 0 {
+	if len(targets) > 0 || len(subjectTargets) > 0 || len(infoTargets) > 0 {
 		s.cacheMut.Lock()
 		for _, k := range targets {
 			if s.schemas[k].lastUsedUnixSeconds < targetTime {
 				delete(s.schemas, k)
 			}
 		}
+		for _, k := range subjectTargets {
+			if s.subjects[k].lastUsedUnixSeconds < targetTime {
+				delete(s.subjects, k)
+			}
+		}
+		for _, k := range infoTargets {
+			if s.infos[k].lastUsedUnixSeconds < targetTime {
+				delete(s.infos, k)
+			}
+		}
 		s.cacheMut.Unlock()
 	}
 }
 
 func (s *schemaRegistryDecoder) getDecoder(id int) (schemaDecoder, error) {
+	c, err := s.getCachedDecoder(id)
+	if err != nil {
+		return nil, err
+	}
+	return c.decoder, nil
+}
+
+// getCodec returns the codec of a schema ID, which shares the cache of
+// decoders.
+func (s *schemaRegistryDecoder) getCodec(id int) (*goavro.Codec, error) {
+	c, err := s.getCachedDecoder(id)
+	if err != nil {
+		return nil, err
+	}
+	return c.codec, nil
+}
+
+func (s *schemaRegistryDecoder) getCachedDecoder(id int) (*cachedSchemaDecoder, error) {
 	s.cacheMut.RLock()
 	c, ok := s.schemas[id]
 	s.cacheMut.RUnlock()
 	if ok {
 		atomic.StoreInt64(&c.lastUsedUnixSeconds, time.Now().Unix())
-		return c.decoder, nil
+		return c, nil
 	}
 
 	s.requestMut.Lock()
@@ -240,14 +563,83 @@ func (s *schemaRegistryDecoder) getDecoder(id int) (schemaDecoder, error) {
 	s.cacheMut.RUnlock()
 	if ok {
 		atomic.StoreInt64(&c.lastUsedUnixSeconds, time.Now().Unix())
-		return c.decoder, nil
+		return c, nil
+	}
+
+	resBytes, err := s.doRequest(fmt.Sprintf("/schemas/ids/%v", id), fmt.Sprintf("schema '%v'", id))
+	if err != nil {
+		return nil, err
+	}
+
+	resPayload := struct {
+		Schema string `json:"schema"`
+	}{}
+	if err = json.Unmarshal(resBytes, &resPayload); err != nil {
+		s.logger.Errorf("failed to parse response for schema '%v': %v", id, err)
+		return nil, err
+	}
+
+	var codec *goavro.Codec
+	if codec, err = goavro.NewCodecForStandardJSON(resPayload.Schema); err != nil {
+		s.logger.Errorf("failed to parse response for schema '%v': %v", id, err)
+		return nil, err
 	}
 
+	decoder := func(m *service.Message) error {
+		b, err := m.AsBytes()
+		if err != nil {
+			return err
+		}
+
+		native, _, err := codec.NativeFromBinary(b)
+		if err != nil {
+			return err
+		}
+
+		if s.serializeNative != nil {
+			sb, err := s.serializeNative(native)
+			if err != nil {
+				return err
+			}
+			m.SetBytes(sb)
+		} else if s.encodeBytes != nil {
+			m.SetStructured(structuredNative(native, s.encodeBytes))
+		} else if s.avroRawJSON {
+			// TODO: This still encodes with Avro JSON format, needs
+			// investigation as to whether this is possible.
+			jb, err := codec.TextualFromNative(nil, native)
+			if err != nil {
+				return err
+			}
+			m.SetBytes(jb)
+		} else {
+			m.SetStructured(native)
+		}
+		return nil
+	}
+
+	c = &cachedSchemaDecoder{
+		lastUsedUnixSeconds: time.Now().Unix(),
+		decoder:             decoder,
+		codec:               codec,
+	}
+
+	s.cacheMut.Lock()
+	s.schemas[id] = c
+	s.cacheMut.Unlock()
+
+	return c, nil
+}
+
+// doRequest performs a GET request against the schema registry at the given
+// path, retrying on failure, and returns the response body. The what argument
+// describes the requested resource for logs and errors.
+func (s *schemaRegistryDecoder) doRequest(reqPath, what string) ([]byte, error) {
 	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
 	defer done()
 
 	reqURL := *s.schemaRegistryBaseURL
-	reqURL.Path = path.Join(reqURL.Path, fmt.Sprintf("/schemas/ids/%v", id))
+	reqURL.Path = path.Join(reqURL.Path, reqPath)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), http.NoBody)
 	if err != nil {
@@ -259,33 +651,33 @@ func (s *schemaRegistryDecoder) getDecoder(id int) (schemaDecoder, error) {
 	for i := 0; i < 3; i++ {
 		var res *http.Response
 		if res, err = s.client.Do(req); err != nil {
-			s.logger.Errorf("request failed for schema '%v': %v", id, err)
+			s.logger.Errorf("request failed for %v: %v", what, err)
 			continue
 		}
 
 		if res.StatusCode == http.StatusNotFound {
-			err = fmt.Errorf("schema '%v' not found by registry", id)
+			err = fmt.Errorf("%v not found by registry", what)
 			s.logger.Errorf(err.Error())
 			break
 		}
 
 		if res.StatusCode != http.StatusOK {
-			err = fmt.Errorf("request failed for schema '%v'", id)
+			err = fmt.Errorf("request failed for %v", what)
 			s.logger.Errorf(err.Error())
 			// TODO: Best attempt at parsing out the body
 			continue
 		}
 
 		if res.Body == nil {
-			s.logger.Errorf("request for schema '%v' returned an empty body", id)
-			err = errors.New("schema request returned an empty body")
+			s.logger.Errorf("request for %v returned an empty body", what)
+			err = fmt.Errorf("%v request returned an empty body", what)
 			continue
 		}
 
 		resBytes, err = io.ReadAll(res.Body)
 		res.Body.Close()
 		if err != nil {
-			s.logger.Errorf("failed to read response for schema '%v': %v", id, err)
+			s.logger.Errorf("failed to read response for %v: %v", what, err)
 			continue
 		}
 
@@ -294,52 +686,48 @@ func (s *schemaRegistryDecoder) getDecoder(id int) (schemaDecoder, error) {
 	if err != nil {
 		return nil, err
 	}
+	return resBytes, nil
+}
 
-	resPayload := struct {
-		Schema string `json:"schema"`
-	}{}
-	if err = json.Unmarshal(resBytes, &resPayload); err != nil {
-		s.logger.Errorf("failed to parse response for schema '%v': %v", id, err)
-		return nil, err
+func (s *schemaRegistryDecoder) getSubjects(id int) ([]string, error) {
+	s.cacheMut.RLock()
+	c, ok := s.subjects[id]
+	s.cacheMut.RUnlock()
+	if ok {
+		atomic.StoreInt64(&c.lastUsedUnixSeconds, time.Now().Unix())
+		return c.subjects, nil
 	}
 
-	var codec *goavro.Codec
-	if codec, err = goavro.NewCodecForStandardJSON(resPayload.Schema); err != nil {
-		s.logger.Errorf("failed to parse response for schema '%v': %v", id, err)
-		return nil, err
-	}
+	s.requestMut.Lock()
+	defer s.requestMut.Unlock()
 
-	decoder := func(m *service.Message) error {
-		b, err := m.AsBytes()
-		if err != nil {
-			return err
-		}
+	// We might've been beaten to making the request, so check once more whilst
+	// within the request lock.
+	s.cacheMut.RLock()
+	c, ok = s.subjects[id]
+	s.cacheMut.RUnlock()
+	if ok {
+		atomic.StoreInt64(&c.lastUsedUnixSeconds, time.Now().Unix())
+		return c.subjects, nil
+	}
 
-		native, _, err := codec.NativeFromBinary(b)
-		if err != nil {
-			return err
-		}
+	resBytes, err := s.doRequest(fmt.Sprintf("/schemas/ids/%v/subjects", id), fmt.Sprintf("subjects of schema '%v'", id))
+	if err != nil {
+		return nil, err
+	}
 
-		if s.avroRawJSON {
-			// TODO: This still encodes with Avro JSON format, needs
-			// investigation as to whether this is possible.
-			jb, err := codec.TextualFromNative(nil, native)
-			if err != nil {
-				return err
-			}
-			m.SetBytes(jb)
-		} else {
-			m.SetStructured(native)
-		}
-		return nil
+	var subjects []string
+	if err = json.Unmarshal(resBytes, &subjects); err != nil {
+		s.logger.Errorf("failed to parse response for subjects of schema '%v': %v", id, err)
+		return nil, err
 	}
 
 	s.cacheMut.Lock()
-	s.schemas[id] = &cachedSchemaDecoder{
+	s.subjects[id] = &cachedSchemaSubjects{
 		lastUsedUnixSeconds: time.Now().Unix(),
-		decoder:             decoder,
+		subjects:            subjects,
 	}
 	s.cacheMut.Unlock()
 
-	return decoder, nil
+	return subjects, nil
 }
@@ -4,14 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
+	"github.com/linkedin/goavro/v2"
 	"github.com/nsf/jsondiff"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 
 	"github.com/benthosdev/benthos/v4/public/service"
 )
@@ -37,6 +41,31 @@ url: http://example.com/v1
 `,
 			expectedBaseURL: "http://example.com/v1",
 		},
+		{
+			name: "bad header id width",
+			config: `
+url: http://example.com
+header:
+  id_width: 3
+`,
+			errContains: "header id_width must be 1, 2, 4 or 8, got 3",
+		},
+		{
+			name: "bad format",
+			config: `
+url: http://example.com
+format: nope
+`,
+			errContains: "format 'nope' not recognised",
+		},
+		{
+			name: "bad bytes encoding",
+			config: `
+url: http://example.com
+bytes_encoding: nope
+`,
+			errContains: "bytes_encoding option 'nope' not recognised",
+		},
 	}
 
 	spec := schemaRegistryDecoderConfig()
@@ -216,6 +245,16 @@ func TestSchemaRegistryDecodeAvro(t *testing.T) {
 			input:       "\x06\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar",
 			errContains: "version number 6 not supported",
 		},
+		{
+			name:        "empty message",
+			input:       "",
+			errContains: "message is empty",
+		},
+		{
+			name:        "truncated schema id",
+			input:       "\x00\x00\x00",
+			errContains: "expected at least 5 bytes but got 3",
+		},
 		{
 			name:        "non-existing schema",
 			input:       "\x00\x00\x00\x00\x06\x06foo\x02\x06foo\x06bar",
@@ -255,6 +294,284 @@ func TestSchemaRegistryDecodeAvro(t *testing.T) {
 	decoder.cacheMut.Unlock()
 }
 
+func TestSchemaRegistryDecodeSubjects(t *testing.T) {
+	payload3, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: testSchema,
+	})
+	require.NoError(t, err)
+
+	returnedSubjects3 := false
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/schemas/ids/3", "/schemas/ids/4", "/schemas/ids/5":
+			return payload3, nil
+		case "/schemas/ids/3/subjects":
+			assert.False(t, returnedSubjects3)
+			returnedSubjects3 = true
+			return []byte(`["foo","bar"]`), nil
+		case "/schemas/ids/4/subjects":
+			return []byte(`[]`), nil
+		case "/schemas/ids/5/subjects":
+			return nil, fmt.Errorf("nope")
+		}
+		return nil, nil
+	})
+
+	decoder, err := newSchemaRegistryDecoder(urlStr, nil, true, nil)
+	require.NoError(t, err)
+	decoder.fetchSubjects = true
+
+	tests := []struct {
+		name        string
+		input       string
+		subject     string
+		subjects    string
+		errContains string
+	}{
+		{
+			name:     "subjects found",
+			input:    "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x02\x0edancing",
+			subject:  "foo",
+			subjects: "foo,bar",
+		},
+		{
+			name:     "subjects cached",
+			input:    "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00",
+			subject:  "foo",
+			subjects: "foo,bar",
+		},
+		{
+			name:  "no subjects",
+			input: "\x00\x00\x00\x00\x04\x06foo\x02\x06foo\x06bar\x00",
+		},
+		{
+			name:        "server fails",
+			input:       "\x00\x00\x00\x00\x05\x06foo\x02\x06foo\x06bar\x00",
+			errContains: "request failed for subjects of schema '5'",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			outMsgs, err := decoder.Process(context.Background(), service.NewMessage([]byte(test.input)))
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, outMsgs, 1)
+
+			v, exists := outMsgs[0].MetaGet("schema_registry_subject")
+			assert.Equal(t, test.subject != "", exists)
+			assert.Equal(t, test.subject, v)
+
+			v, exists = outMsgs[0].MetaGet("schema_registry_subjects")
+			assert.Equal(t, test.subjects != "", exists)
+			assert.Equal(t, test.subjects, v)
+		})
+	}
+
+	require.NoError(t, decoder.Close(context.Background()))
+	decoder.cacheMut.Lock()
+	assert.Len(t, decoder.subjects, 0)
+	decoder.cacheMut.Unlock()
+}
+
+func TestSchemaRegistryDecodeBytesEncoding(t *testing.T) {
+	schema := `{"type":"record","name":"foo","fields":[
+	{"name":"data","type":"bytes"},
+	{"name":"hash","type":{"type":"fixed","name":"hash","size":2}},
+	{"name":"price","type":{"type":"bytes","logicalType":"decimal","precision":6,"scale":2}},
+	{"name":"maybe","type":["null","bytes"]}
+]}`
+
+	payload3, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: schema,
+	})
+	require.NoError(t, err)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/schemas/ids/3" {
+			return payload3, nil
+		}
+		return nil, nil
+	})
+
+	codec, err := goavro.NewCodec(schema)
+	require.NoError(t, err)
+
+	record, err := codec.BinaryFromNative([]byte{0, 0, 0, 0, 3}, map[string]interface{}{
+		"data":  []byte{0xff, 0x00, 0x01},
+		"hash":  []byte{0xab, 0xcd},
+		"price": big.NewRat(-1234, 100),
+		"maybe": goavro.Union("bytes", []byte{0x10}),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		encoding string
+		output   string
+	}{
+		{
+			encoding: "avro_json",
+			output:   `{"data":"\u00ff\u0000\u0001","hash":"\u00ab\u00cd","maybe":{"bytes":"\u0010"},"price":"\u00fb."}`,
+		},
+		{
+			encoding: "base64",
+			output:   `{"data":"/wAB","hash":"q80=","maybe":{"bytes":"EA=="},"price":"-12.34"}`,
+		},
+		{
+			encoding: "hex",
+			output:   `{"data":"ff0001","hash":"abcd","maybe":{"bytes":"10"},"price":"-12.34"}`,
+		},
+		{
+			encoding: "array",
+			output:   `{"data":[255,0,1],"hash":[171,205],"maybe":{"bytes":[16]},"price":"-12.34"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.encoding, func(t *testing.T) {
+			conf, err := schemaRegistryDecoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+bytes_encoding: %v
+`, urlStr, test.encoding), nil)
+			require.NoError(t, err)
+
+			decoder, err := newSchemaRegistryDecoderFromConfig(conf, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, decoder.Close(context.Background()))
+			})
+
+			outMsgs, err := decoder.Process(context.Background(), service.NewMessage(record))
+			require.NoError(t, err)
+			require.Len(t, outMsgs, 1)
+
+			b, err := outMsgs[0].AsBytes()
+			require.NoError(t, err)
+			assert.JSONEq(t, test.output, string(b))
+		})
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	tests := []struct {
+		input  *big.Rat
+		output string
+	}{
+		{input: big.NewRat(1234, 100), output: "12.34"},
+		{input: big.NewRat(1230, 100), output: "12.3"},
+		{input: big.NewRat(-5, 1000), output: "-0.005"},
+		{input: big.NewRat(42, 1), output: "42"},
+		{input: big.NewRat(1, 8), output: "0.125"},
+		{input: big.NewRat(1, 3), output: "1/3"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.output, decimalString(test.input), test.input.String())
+	}
+}
+
+func TestSchemaRegistryDecodeFormat(t *testing.T) {
+	schema := `{"type":"record","name":"foo","fields":[{"name":"data","type":"bytes"},{"name":"count","type":"long"},{"name":"name","type":["null","string"]}]}`
+
+	payload3, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: schema,
+	})
+	require.NoError(t, err)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/schemas/ids/3" {
+			return payload3, nil
+		}
+		return nil, nil
+	})
+
+	codec, err := goavro.NewCodec(schema)
+	require.NoError(t, err)
+
+	record, err := codec.BinaryFromNative([]byte{0, 0, 0, 0, 3}, map[string]interface{}{
+		"data":  []byte{0xff, 0x00, 0x01},
+		"count": int64(5),
+		"name":  goavro.Union("string", "foo"),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		format    string
+		unmarshal func(b []byte) (map[string]interface{}, error)
+		union     interface{}
+	}{
+		{
+			format: "msgpack",
+			union:  map[string]interface{}{"string": "foo"},
+			unmarshal: func(b []byte) (map[string]interface{}, error) {
+				var v map[string]interface{}
+				err := msgpack.Unmarshal(b, &v)
+				return v, err
+			},
+		},
+		{
+			format: "cbor",
+			union:  map[interface{}]interface{}{"string": "foo"},
+			unmarshal: func(b []byte) (map[string]interface{}, error) {
+				var v map[string]interface{}
+				err := cbor.Unmarshal(b, &v)
+				return v, err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.format, func(t *testing.T) {
+			conf, err := schemaRegistryDecoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+format: %v
+`, urlStr, test.format), nil)
+			require.NoError(t, err)
+
+			decoder, err := newSchemaRegistryDecoderFromConfig(conf, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, decoder.Close(context.Background()))
+			})
+
+			outMsgs, err := decoder.Process(context.Background(), service.NewMessage(record))
+			require.NoError(t, err)
+			require.Len(t, outMsgs, 1)
+
+			b, err := outMsgs[0].AsBytes()
+			require.NoError(t, err)
+
+			v, err := test.unmarshal(b)
+			require.NoError(t, err)
+
+			assert.Equal(t, []byte{0xff, 0x00, 0x01}, v["data"])
+			assert.EqualValues(t, 5, v["count"])
+
+			assert.Equal(t, test.union, v["name"])
+
+			// Serialization is deterministic.
+			outMsgs2, err := decoder.Process(context.Background(), service.NewMessage(record))
+			require.NoError(t, err)
+			b2, err := outMsgs2[0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, b, b2)
+		})
+	}
+}
+
 func TestSchemaRegistryDecodeClearExpired(t *testing.T) {
 	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
 		return nil, fmt.Errorf("nope")
@@ -274,6 +591,10 @@ func TestSchemaRegistryDecodeClearExpired(t *testing.T) {
 		10: {lastUsedUnixSeconds: tNotStale},
 		15: {lastUsedUnixSeconds: tNearlyStale},
 	}
+	decoder.subjects = map[int]*cachedSchemaSubjects{
+		5:  {lastUsedUnixSeconds: tStale},
+		10: {lastUsedUnixSeconds: tNotStale},
+	}
 	decoder.cacheMut.Unlock()
 
 	decoder.clearExpired()
@@ -283,5 +604,137 @@ func TestSchemaRegistryDecodeClearExpired(t *testing.T) {
 		10: {lastUsedUnixSeconds: tNotStale},
 		15: {lastUsedUnixSeconds: tNearlyStale},
 	}, decoder.schemas)
+	assert.Equal(t, map[int]*cachedSchemaSubjects{
+		10: {lastUsedUnixSeconds: tNotStale},
+	}, decoder.subjects)
 	decoder.cacheMut.Unlock()
 }
+
+func TestSchemaRegistryDecodeCorrelationKey(t *testing.T) {
+	payload3, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: testSchema,
+	})
+	require.NoError(t, err)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/schemas/ids/3" {
+			return payload3, nil
+		}
+		return nil, nil
+	})
+
+	const (
+		fooMsg     = "\x00\x00\x00\x00\x03\x06foo\x00\x02\x0edancing"
+		barMsg     = "\x00\x00\x00\x00\x03\x06bar\x00\x00"
+		fooNullMsg = "\x00\x00\x00\x00\x03\x06foo\x00\x00"
+		badMsg     = "\x00\x00\x00\x00\x06\x06foo\x00\x00"
+	)
+
+	tests := []struct {
+		name     string
+		mapping  string
+		input    []string
+		output   [][]string
+		keys     [][]string
+		failures int
+	}{
+		{
+			name:  "no correlation key",
+			input: []string{fooMsg, badMsg, barMsg},
+			output: [][]string{
+				{
+					`{"Name":"foo","Address":null,"MaybeHobby":{"string":"dancing"}}`,
+					badMsg,
+					`{"Name":"bar","Address":null,"MaybeHobby":null}`,
+				},
+			},
+			keys:     [][]string{{"", "", ""}},
+			failures: 1,
+		},
+		{
+			name:    "grouped by name",
+			mapping: `root = this.Name`,
+			input:   []string{fooMsg, barMsg, badMsg, fooNullMsg},
+			output: [][]string{
+				{
+					`{"Name":"foo","Address":null,"MaybeHobby":{"string":"dancing"}}`,
+					`{"Name":"foo","Address":null,"MaybeHobby":null}`,
+				},
+				{
+					`{"Name":"bar","Address":null,"MaybeHobby":null}`,
+				},
+				{
+					badMsg,
+				},
+			},
+			keys:     [][]string{{"foo", "foo"}, {"bar"}, {""}},
+			failures: 1,
+		},
+		{
+			name:    "failed mapping",
+			mapping: `root = this.MaybeHobby.string.uppercase()`,
+			input:   []string{fooMsg, barMsg},
+			output: [][]string{
+				{
+					`{"Name":"foo","Address":null,"MaybeHobby":{"string":"dancing"}}`,
+				},
+				{
+					barMsg,
+				},
+			},
+			keys:     [][]string{{"DANCING"}, {""}},
+			failures: 1,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			config := fmt.Sprintf("url: %v\n", urlStr)
+			if test.mapping != "" {
+				config += fmt.Sprintf("correlation_key: '%v'\n", test.mapping)
+			}
+			conf, err := schemaRegistryDecoderConfig().ParseYAML(config, nil)
+			require.NoError(t, err)
+
+			decoder, err := newSchemaRegistryDecoderFromConfig(conf, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, decoder.Close(context.Background()))
+			})
+
+			var batch service.MessageBatch
+			for _, in := range test.input {
+				batch = append(batch, service.NewMessage([]byte(in)))
+			}
+
+			outBatches, err := decoder.ProcessBatch(context.Background(), batch)
+			require.NoError(t, err)
+			require.Len(t, outBatches, len(test.output))
+
+			failures := 0
+			for i, outBatch := range outBatches {
+				require.Len(t, outBatch, len(test.output[i]), i)
+				for j, msg := range outBatch {
+					b, err := msg.AsBytes()
+					require.NoError(t, err)
+					if msg.GetError() != nil {
+						assert.Equal(t, test.output[i][j], string(b), "%v %v", i, j)
+					} else {
+						assert.JSONEq(t, test.output[i][j], string(b), "%v %v", i, j)
+					}
+
+					key, _ := msg.MetaGet("schema_registry_correlation_key")
+					assert.Equal(t, test.keys[i][j], key, "%v %v", i, j)
+
+					if msg.GetError() != nil {
+						failures++
+					}
+				}
+			}
+			assert.Equal(t, test.failures, failures)
+		})
+	}
+}
@@ -10,14 +10,21 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
+	"github.com/fsnotify/fsnotify"
 	"github.com/linkedin/goavro/v2"
 
 	"github.com/benthosdev/benthos/v4/internal/shutdown"
+	"github.com/benthosdev/benthos/v4/public/bloblang"
 	"github.com/benthosdev/benthos/v4/public/service"
 )
 
@@ -31,7 +38,7 @@ Encodes messages automatically from schemas obtains from a [Confluent Schema Reg
 
 If a message fails to encode under the schema then it will remain unchanged and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).
 
-Currently only Avro schemas are supported.
+Avro, Protobuf and JSON schemas are supported, where the type of each schema is determined by the registry.
 
 ### Avro JSON Format
 
@@ -46,20 +53,246 @@ For example, the union schema ` + "`[\"null\",\"string\",\"Foo\"]`, where `Foo`"
 - the string ` + "`\"a\"` as `{\"string\": \"a\"}`" + `; and
 - a ` + "`Foo` instance as `{\"Foo\": {...}}`, where `{...}` indicates the JSON encoding of a `Foo`" + ` instance.
 
-However, it is possible to instead consume documents in raw JSON format (that match the schema) by setting the field ` + "[`avro_raw_json`](#avro_raw_json) to `true`" + `.`).
-		Field(service.NewStringField("url").Description("The base URL of the schema registry service.")).
+However, it is possible to instead consume documents in raw JSON format (that match the schema) by setting the field ` + "[`avro_raw_json`](#avro_raw_json) to `true`" + `.
+
+### Avro Logical Types
+
+By default values of Avro logical types must be provided in the form of their underlying type, such as the number of milliseconds since the Unix epoch for ` + "`timestamp-millis`" + ` and the bytes of the unscaled value for ` + "`decimal`" + `. When ` + "[`convert_logical_types`](#convert_logical_types)" + ` is ` + "`true`" + ` the following JSON values are converted before encoding, both within structured messages and within raw JSON documents:
+
+- ` + "`decimal`" + ` values of ` + "`bytes` and `fixed`" + ` types from strings and numbers, such as ` + "`\"12.34\"`" + `, rather than from the bytes of their unscaled value, where values with more digits after the decimal point than the scale of the decimal fail to encode rather than being truncated;
+- ` + "`timestamp-millis` and `timestamp-micros`" + ` values from RFC 3339 strings, such as ` + "`\"2021-01-02T03:04:05.678Z\"`" + `; and
+- ` + "`date`" + ` values from strings of the form ` + "`\"2021-01-02\"`" + `.
+
+Numbers are accepted for timestamp and date values regardless of this field.
+
+### Protobuf
+
+Messages encoded with Protobuf schemas are parsed from JSON documents following the [Protobuf JSON mapping](https://developers.google.com/protocol-buffers/docs/proto3#json), as the first message defined by the schema or the message named by the field ` + "[`protobuf_message`](#protobuf_message)" + `. The indexes of the message within the schema are written ahead of the encoded message as required by the Confluent wire format. Schemas imported by a Protobuf schema are resolved using the references of the schema within the registry.
+
+### JSON Schema
+
+Messages with JSON schemas are validated against the schema and, when valid, prefixed with the schema ID header without otherwise being changed. Messages that fail validation remain unchanged and are flagged as having failed in the same way as messages that fail to encode under Avro schemas. Schemas referenced by a JSON schema are resolved using the references of the schema within the registry, where the name of each reference is the URL used to refer to it.
+
+### Avro Only Features
+
+The fields ` + "`avro_raw_json`, `field_mapping`, `unknown_enum_symbols`, `convert_logical_types`, `array_records` and `batch_records`" + `, the ` + "`encode_null`" + ` option of ` + "`empty_messages`" + ` and the single object encoding are only supported for Avro schemas.
+
+### Single Object Encoding
+
+By default encoded messages are prefixed with the Confluent wire format header, which consists of a zero magic byte followed by the four byte big-endian schema ID. It is possible to instead, or additionally, produce messages using the [Avro single object encoding](https://avro.apache.org/docs/current/spec.html#single_object_encoding) by listing the framings to emit in the field ` + "[`framings`](#framings)" + `.
+
+When more than one framing is listed the processor emits a batch for each framing, in the order in which they are listed, where each message is encoded once and framed individually. In this case each message also has the metadata field ` + "`schema_registry_framing`" + ` set to the name of its framing, which can be used in order to route them to separate outputs.
+
+### Metrics
+
+At the end of each batch this processor emits the following metrics:
+
+- ` + "`schema_registry_encode_messages`" + `: A counter of messages processed.
+- ` + "`schema_registry_encode_success`" + `: A counter of messages successfully encoded.
+- ` + "`schema_registry_encode_error`" + `: A counter of messages that failed to encode.
+- ` + "`schema_registry_encode_batch_subjects`" + `: A gauge of the number of distinct subjects that messages of the last batch were encoded with.
+- ` + "`schema_registry_encode_subject_success`" + `: A counter of messages successfully encoded with the label ` + "`subject`" + `.
+- ` + "`schema_registry_encode_enum_substitutions`" + `: A counter of unknown enum symbols replaced with a default symbol when ` + "[`unknown_enum_symbols`](#unknown_enum_symbols)" + ` is ` + "`default_symbol`" + `.
+- ` + "`schema_registry_encode_schema_drift`" + `: A counter of the times that the proportion of messages failing to encode with the schema of a subject exceeded the threshold of ` + "[`schema_drift`](#schema_drift)" + `, with the label ` + "`subject`" + `.
+
+The following metrics describe the cache of schemas, which can be used in order to tune ` + "[`refresh_period`](#refresh_period)" + `:
+
+- ` + "`schema_registry_encode_cache_hit`" + `: A counter of schema lookups served by the cache.
+- ` + "`schema_registry_encode_cache_miss`" + `: A counter of schema lookups that required the schema of a subject to be fetched from the schema registry, including those of ` + "`warmup_subjects`" + ` and ` + "`preload_all`" + `.
+- ` + "`schema_registry_encode_cache_refresh`" + `: A counter of cached schemas successfully refreshed according to ` + "`refresh_period`" + `.
+- ` + "`schema_registry_encode_cache_purge`" + `: A counter of cached schemas purged after not being used.
+- ` + "`schema_registry_encode_cached_subjects`" + `: A gauge of the number of subjects with a cached schema.
+
+In order to protect metrics backends from high cardinality the number of distinct subjects labelled individually is limited by the field ` + "[`max_metric_subjects`](#max_metric_subjects)" + `. Once the limit is reached any further subjects are counted under the label ` + "`other`" + `, and a warning is logged.
+
+When ` + "[`warmup_subjects`](#warmup_subjects)" + ` are configured the following metric is also emitted:
+
+- ` + "`schema_registry_encode_warmup_error`" + `: A counter of subjects that failed to be fetched during warmup.
+
+When ` + "[`preload_all`](#preload_all)" + ` is ` + "`true`" + ` the following metric is also emitted:
+
+- ` + "`schema_registry_encode_preload_error`" + `: A counter of subjects that failed to be fetched during preloading.`).
+		Field(service.NewInterpolatedStringField("url").
+			Description("The base URL of the schema registry service. Either this or `schema_path` must be set. When interpolated the registry of each message is resolved individually, which allows messages of multiple tenants to be encoded with the schemas of their own registries. Schemas are cached separately for each registry, and an interpolated URL cannot be combined with `warmup_subjects` or `preload_all`.").
+			Default("").
+			Example("http://localhost:8081").
+			Example(`https://${! meta("tenant") }.registry.example.com`)).
 		Field(service.NewInterpolatedStringField("subject").Description("The schema subject to derive schemas from.").
 			Example("foo").
 			Example(`${! meta("kafka_topic") }`)).
+		Field(service.NewInterpolatedStringListField("fallback_subjects").
+			Description("An optional list of subjects to attempt in order when encoding with the schema of `subject` fails, either because the subject could not be obtained or because the message does not match its schema. The first subject that succeeds is used, and when all subjects fail the last error is reported. Subjects are resolved for each message, which allows fallbacks to be derived from metadata such as the topic of a message, and subjects that resolve to an empty string are not attempted, and therefore a message without any fallback fails with the error of `subject`.").
+			Advanced().Default([]string{}).Version("4.2.0").
+			Example([]string{"foo-v1", `${! meta("kafka_topic") }-legacy`}).
+			Example([]string{`${! meta("fallback_subject").or("") }`})).
+		Field(service.NewStringMapField("subject_map").
+			Description("An optional map of keys to subjects. When set the key of each message is resolved from `subject_key` and the subject mapped to that key is used instead of `subject`.").
+			Advanced().Default(map[string]string{}).Version("4.2.0").
+			Example(map[string]string{
+				"user_created": "com.example.users-value",
+				"user_deleted": "com.example.users.deleted-value",
+			})).
+		Field(service.NewInterpolatedStringField("subject_key").
+			Description("The key of messages to look up in `subject_map`.").
+			Advanced().Default("").Version("4.2.0").
+			Example(`${! meta("event_type") }`)).
+		Field(service.NewBoolField("subject_map_fallback").
+			Description("Whether messages with a key that is not found in `subject_map` should be encoded with the schema of `subject`. When `false` such messages fail to encode instead.").
+			Advanced().Default(true).Version("4.2.0")).
+		Field(service.NewStringAnnotatedEnumField("subject_suffix", map[string]string{
+			"none":  "Subjects are used as they are.",
+			"key":   "The suffix `-key` is appended to subjects, following the Confluent convention for schemas of message keys.",
+			"value": "The suffix `-value` is appended to subjects, following the Confluent convention for schemas of message values.",
+		}).Description("A conventional suffix to append to subjects before their schemas are fetched, which avoids concatenating the suffix within interpolations. The suffix is appended to subjects resolved from `subject`, `subject_map` and `fallback_subjects`, as well as to `warmup_subjects`, and metrics are labelled with the suffixed subjects. When subjects are derived with the [`schema_registry_subject`](/docs/components/processors/schema_registry_subject) processor this should only be combined with strategies that do not already append a suffix, such as `record_name`.").
+			Advanced().Default("none").Version("4.2.0")).
+		Field(service.NewStringListField("warmup_subjects").
+			Description("A list of subjects whose latest schemas are fetched when the processor is created, so that the first messages of those subjects are not delayed by requests to the schema registry. Subjects that fail to be fetched do not prevent the processor from starting, instead each failure is logged and counted, and the schema of the subject is fetched again when a message first requires it.").
+			Advanced().Default([]string{}).Version("4.2.0").
+			Example([]string{"foo", "bar"})).
+		Field(service.NewBoolField("preload_all").
+			Description("Whether to list all subjects of the schema registry when the processor is created and cache the latest schema of each of them. Combined with a `refresh_period` of zero, which disables refreshing and purging, the processor then never makes requests to the registry while processing messages, unless a message requires a subject that did not exist at the time. This is only suitable for registries with a small and stable number of subjects, and subject suffixes are not appended to listed subjects.").
+			Advanced().Default(false).Version("4.2.0")).
+		Field(service.NewStringAnnotatedEnumField("preload_failures", map[string]string{
+			"fatal": "The processor fails to be created when the subjects cannot be listed, or when the schema of any subject cannot be fetched or compiled.",
+			"warn":  "Failures are logged and counted, and the schemas of subjects that failed are fetched again when a message first requires them.",
+		}).Description("How to handle failures to preload schemas when `preload_all` is `true`.").
+			Advanced().Default("fatal").Version("4.2.0")).
+		Field(service.NewStringField("version").
+			Description("The version of the schema of each subject to encode messages with, which is either `latest` or a specific version number. Pinning a version disables refreshing and revalidating schemas, as the schema of a version never changes, and cannot be combined with `preload_all`.").
+			Advanced().Default("latest").Version("4.2.0").
+			Example("3")).
+		Field(service.NewStringField("schema_path").
+			Description("A path to a local file containing an Avro schema, which is used to encode all messages instead of schemas obtained from a schema registry service. The schema is loaded when the processor is created, in which case no requests are made to a registry and schemas are never refreshed.").
+			Advanced().Default("").Version("4.2.0").
+			Example("./schemas/foo.avsc")).
+		Field(service.NewIntField("schema_id").
+			Description("The schema ID written to the Confluent wire format header of messages encoded with the schema of `schema_path`.").
+			Advanced().Default(0).Version("4.2.0")).
+		Field(service.NewBoolField("watch_schema_path").
+			Description("Whether to watch the file of `schema_path` for changes and reload the schema when it is modified. If the modified schema fails to parse then the error is logged and the previous schema continues to be used.").
+			Advanced().Default(false).Version("4.2.0")).
 		Field(service.NewStringField("refresh_period").
-			Description("The period after which a schema is refreshed for each subject, this is done by polling the schema registry service.").
+			Description("The period after which a schema is refreshed for each subject, this is done by polling the schema registry service. Schemas of subjects that have not been used for ten minutes are purged from the cache, and therefore a period greater than this only takes effect for subjects that are used more often. Setting this to zero disables refreshing and purging entirely, and schemas are cached for the lifetime of the processor.").
 			Default("10m").
 			Example("60s").
 			Example("1h")).
+		Field(service.NewStringField("revalidate_after").
+			Description("The age after which a cached schema is revalidated in the background when a message uses it. The cached schema continues to be used while the latest schema is fetched, and therefore messages are never delayed by requests to the schema registry once the schema of their subject is cached. If a revalidation fails then the error is logged and the cached schema is used until the next attempt, which is made no sooner than this period later. Unlike `refresh_period` this only revalidates schemas that are in use, and the two can be combined. Setting this to zero disables revalidation.").
+			Advanced().Default("0s").Version("4.2.0").
+			Example("1m")).
+		Field(service.NewStringAnnotatedEnumField("schema_type_change", map[string]string{
+			"adopt":  "Log a warning and use the latest schema.",
+			"retain": "Log a warning and continue to use the cached schema.",
+			"error":  "Log an error and fail messages of the subject until the type of its latest schema matches the cached schema again.",
+		}).Description("How to handle the type of the latest schema of a subject differing from the type of its cached schema when the schema is refreshed or revalidated, which can happen when a schema of a different type is accidentally registered under the subject. Since only Avro, Protobuf and JSON schemas can be used for encoding, adopting a schema of any other type fails in the same way as any other refresh, where the error is logged and the cached schema continues to be used.").
+			Advanced().Default("adopt").Version("4.2.0")).
 		Field(service.NewBoolField("avro_raw_json").
 			Description("Whether messages encoded in Avro format should be parsed as raw JSON documents rather than [Avro JSON](https://avro.apache.org/docs/current/spec.html#json_encoding).").
 			Advanced().Default(false).Version("3.59.0")).
+		Field(service.NewStringField("protobuf_message").
+			Description("The fully qualified name of the message to encode messages as when the schema of their subject is a Protobuf schema. When empty the first message defined by the schema is used.").
+			Advanced().Default("").Version("4.2.0").
+			Example("com.example.Order")).
+		Field(service.NewInterpolatedStringField("avro_raw_json_override").
+			Description("An optional expression resolved for each message that overrides `avro_raw_json` for that message, which allows messages of mixed formats to be encoded by a single processor. The expression must resolve to either `true` or `false`, or an empty string in which case `avro_raw_json` applies, and messages for which it resolves to anything else fail to encode.").
+			Advanced().Default("").Version("4.2.0").
+			Example(`${! meta("content_format") == "raw_json" }`).
+			Example(`${! meta("raw_json").or("") }`)).
+		Field(service.NewStringAnnotatedEnumField("codec_json_mode", map[string]string{
+			"standard": "Union values are parsed from standard JSON, where the type of a union value is inferred from the value itself.",
+			"plain":    "Union values are parsed from Avro JSON, where union values other than null are wrapped in an object keyed by their type.",
+		}).
+			Description("The mode of the Avro codec used for parsing messages as JSON documents when `avro_raw_json` is `true`. The `plain` mode is compatible with producers that serialize documents with plain Avro codecs.").
+			Advanced().Default("standard").Version("4.2.0")).
+		Field(service.NewStringMapField("field_mapping").
+			Description("An optional map of field names to rename within structured messages before they are encoded, where each key is the name of a field of the message and each value is the name of the field within the schema. Fields of nested objects are renamed by specifying the path of the field with dot separated keys, where dots within keys can be escaped with `~1` and tildes with `~0`, and paths that reach an array apply to each of its elements. Fields that are not mapped are left unchanged. This cannot be combined with `avro_raw_json`, and is not applied to messages parsed as raw JSON documents due to `avro_raw_json_override`.").
+			Advanced().Default(map[string]string{}).Version("4.2.0").
+			Example(map[string]string{
+				"firstName":       "first_name",
+				"address.zipCode": "zip_code",
+			})).
+		Field(service.NewBloblangField("pre_encode").
+			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) applied to each message immediately before it is encoded, the result of which is encoded instead of the message. This is useful for coercing values into the types expected by the schema in one place. Within the mapping the metadata fields `schema_registry_subject` and `schema_registry_id` are set to the subject and ID of the schema that the message is being encoded with, which differ for each fallback subject attempted, and these fields are not added to the encoded message. The mapping is applied before `field_mapping`, and messages for which it fails or deletes the root are flagged as having failed.").
+			Advanced().Version("4.2.0").
+			Example(`root = this
+root.active = this.active.bool()
+root.count = this.count.number()`).
+			Example(`root = this
+root.subject = meta("schema_registry_subject")`).
+			Optional()).
+		Field(service.NewStringAnnotatedEnumField("array_records", map[string]string{
+			"disabled":               "Messages are encoded as a single record.",
+			"concatenated":           "Messages must be arrays, and each element is encoded as a record and concatenated without any delimiter.",
+			"varint_length_prefixed": "Messages must be arrays, and each element is encoded as a record prefixed with its length as an Avro (zig-zag varint) long.",
+			"uint32_length_prefixed": "Messages must be arrays, and each element is encoded as a record prefixed with its length as a four byte big-endian unsigned integer.",
+		}).
+			Description("Whether messages containing an array should be encoded as multiple concatenated records, where each element of the array is encoded with the schema of the subject rather than the array as a whole.").
+			Advanced().Default("disabled").Version("4.2.0")).
+		Field(service.NewStringAnnotatedEnumField("batch_records", map[string]string{
+			"disabled":               "Each message is framed individually.",
+			"concatenated":           "The records of a batch are concatenated without any delimiter.",
+			"varint_length_prefixed": "Each record of a batch is prefixed with its length as an Avro (zig-zag varint) long.",
+			"uint32_length_prefixed": "Each record of a batch is prefixed with its length as a four byte big-endian unsigned integer.",
+		}).
+			Description("Whether the encoded messages of a batch should be combined into a single message consisting of one framing header followed by the records of the batch, which avoids repeating the header of each record for downstream consumers that understand this framing. All messages of a batch must be encoded with the same schema, otherwise they are framed individually and flagged as having failed. The combined message retains the metadata of the first message of the batch, and messages that fail to encode are not combined and remain in the batch individually. This cannot be combined with `array_records`.").
+			Advanced().Default("disabled").Version("4.2.0")).
+		Field(service.NewStringListField("framings").
+			Description("A list of framings to apply to encoded messages, where a batch is emitted for each framing. Options are `confluent` for the Confluent wire format, the layout of which can be customised with `header`, and `single_object` for the Avro single object encoding.").
+			Advanced().Default([]string{"confluent"}).Version("4.2.0").
+			Example([]string{"confluent", "single_object"})).
+		Field(service.NewBoolField("atomic").
+			Description("Whether a batch should fail as a whole when any of its messages fails to encode. When `false` only the messages that fail to encode are flagged as having failed. When `true` the first failure stops the encoding of the batch and all of its messages are left unchanged and flagged as having failed, which prevents partial batches from being delivered.").
+			Advanced().Default(false).Version("4.2.0")).
+		Field(service.NewStringAnnotatedEnumField("on_error", map[string]string{
+			"set_error": "Messages that fail to encode pass through unchanged and are flagged as having failed.",
+			"drop":      "Messages that fail to encode are removed from the batch.",
+			"reject":    "Messages that fail to encode are removed from the batch and emitted unchanged within a separate batch that follows the encoded messages, where they are flagged as having failed.",
+		}).Description("How to handle messages that fail to encode when `atomic` is `false`. Dropped messages are acknowledged without being delivered, and rejected messages can be routed to a dead-letter output without being interleaved with encoded messages, such as with a `switch` output that checks `errored()`. Failures are counted and sampled by `log_failures` regardless of this option.").
+			Advanced().Default("set_error").Version("4.2.0")).
+		Field(service.NewStringAnnotatedEnumField("empty_messages", map[string]string{
+			"error":       "Empty messages are flagged as having failed.",
+			"skip":        "Empty messages pass through unchanged, without being encoded or framed.",
+			"encode_null": "Empty messages are encoded as a null value, which requires the schema to be a union that includes `null`.",
+		}).Description("How to handle messages with an empty payload, such as tombstone records of Kafka topics. Messages that are skipped are not flagged as having failed and count as successes within metrics. When the schema of an empty message is not a nullable union `encode_null` fails the message.").
+			Advanced().Default("error").Version("4.2.0")).
+		Field(service.NewStringAnnotatedEnumField("unknown_enum_symbols", map[string]string{
+			"error":          "Messages containing unknown enum symbols fail to encode.",
+			"default_symbol": "Unknown enum symbols are replaced with a default symbol before encoding.",
+		}).Description("How to handle values of Avro enums that are not members of the symbols of their enum, such as when producers emit symbols ahead of schema updates. The default symbol of an enum is `default_enum_symbol` when the enum contains it, and otherwise the `default` declared by the enum within the schema. Messages containing an unknown symbol of an enum without a default symbol fail to encode.").
+			Advanced().Default("error").Version("4.2.0")).
+		Field(service.NewStringField("default_enum_symbol").
+			Description("The symbol to replace unknown enum symbols with when `unknown_enum_symbols` is `default_symbol`, for enums that contain it. When empty the `default` declared by each enum within the schema is used.").
+			Advanced().Default("").Version("4.2.0").
+			Example("UNKNOWN")).
+		Field(service.NewBoolField("convert_logical_types").
+			Description("Whether values of Avro logical types are converted from their JSON representations before encoding, as described in [Avro Logical Types](#avro-logical-types).").
+			Advanced().Default(false).Version("4.2.0")).
+		Field(wireHeaderField()).
+		Field(service.NewIntField("max_message_size").
+			Description("The maximum size in bytes of a message to encode, where messages exceeding it are flagged as having failed without attempting to encode them. This protects against excessive memory usage when encoding very large messages. Zero disables the limit.").
+			Advanced().Default(0).Version("4.2.0").
+			Example(1048576)).
+		Field(service.NewIntField("max_metric_subjects").
+			Description("The maximum number of distinct subjects that metrics are labelled with individually, where messages of any further subjects are counted under the label `other`.").
+			Advanced().Default(100).Version("4.2.0")).
+		Field(service.NewBoolField("debug_responses").
+			Description("Whether to log the raw response of the schema registry service at the debug level when a request fails, or when the schema of a subject it returns cannot be parsed or compiled. This is useful for troubleshooting schema mismatches between environments, but can produce large logs. Successful responses are not logged.").
+			Advanced().Default(false).Version("4.2.0")).
+		Field(failureSamplerField()).
+		Field(schemaDriftField()).
+		Field(service.NewStringField("shared_codec_cache").
+			Description("The name of a cache of compiled codecs to share with other `schema_registry_encode` processors of the same process, where empty disables sharing. Processors that share a cache and a `url` compile the schema of each ID once and share the compiled codec, which reduces memory usage when multiple pipelines encode messages with the same schemas. Codecs are removed from the cache once no processor refers to them. Cannot be combined with `schema_path`.").
+			Advanced().Default("").Version("4.2.0").
+			Example("registry_a")).
 		Field(service.NewTLSField("tls")).
+		Field(tlsMinVersionField()).
+		Field(basicAuthField()).
+		Field(service.NewDurationField("request_timeout").
+			Description("The maximum period to wait for each attempt of a request to the schema registry.").
+			Advanced().Default("5s").Version("4.2.0")).
+		Field(service.NewIntField("max_retries").
+			Description("The maximum number of times a failed request to the schema registry is retried, with an exponential backoff between attempts of up to one second. Requests for schemas that are not found are not retried.").
+			Advanced().Default(2).Version("4.2.0")).
 		Version("3.58.0")
 }
 
@@ -67,7 +300,7 @@ func init() {
 	err := service.RegisterBatchProcessor(
 		"schema_registry_encode", schemaRegistryEncoderConfig(),
 		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
-			return newSchemaRegistryEncoderFromConfig(conf, mgr.Logger())
+			return newSchemaRegistryEncoderFromConfig(conf, mgr.Logger(), mgr.Metrics())
 		})
 
 	if err != nil {
@@ -78,35 +311,194 @@ func init() {
 //------------------------------------------------------------------------------
 
 type schemaRegistryEncoder struct {
-	client             *http.Client
-	subject            *service.InterpolatedString
-	avroRawJSON        bool
-	schemaRefreshAfter time.Duration
+	client              *http.Client
+	basicAuth           *registryBasicAuth
+	requestTimeout      time.Duration
+	maxRetries          int
+	subject             *service.InterpolatedString
+	fallbackSubjects    []*service.InterpolatedString
+	subjectMap          map[string]string
+	subjectKey          *service.InterpolatedString
+	subjectMapFallback  bool
+	subjectSuffix       string
+	avroRawJSON         bool
+	avroRawJSONOverride *service.InterpolatedString
+	protobufMessage     string
+	schemaVersion       string
+	newCodec            func(string) (*goavro.Codec, error)
+	codecMode           string
+	codecs              *codecCache
+	releaseCodecsOnce   sync.Once
+	schemaRefreshAfter  time.Duration
+	revalidateAfter     time.Duration
+	schemaTypeChange    string
+	fieldRenames        fieldRenames
+	preEncode           *bloblang.Executor
+	arrayRecordsPrefix  arrayRecordPrefixFn
+	batchRecordsPrefix  arrayRecordPrefixFn
+	atomicBatches       bool
+	onError             string
+	emptyMessages       string
+	unknownEnumSymbols  string
+	defaultEnumSymbol   string
+	convertLogicalTypes bool
+	maxMessageSize      int
+	debugResponses      bool
+	failureSampler      *failureSampler
+	schemaDrift         *schemaDrift
+	framings            []schemaFraming
 
 	schemaRegistryBaseURL *url.URL
 
-	schemas    map[string]*cachedSchemaEncoder
-	cacheMut   sync.RWMutex
-	requestMut sync.Mutex
-	shutSig    *shutdown.Signaller
+	// When the url is interpolated the registry of each message is resolved
+	// individually, and the parsed URLs of registries are cached.
+	registryURL  *service.InterpolatedString
+	registryURLs *registryURLCache
+
+	schemas     map[schemaKey]*cachedSchemaEncoder
+	localSchema *cachedSchemaEncoder
+	cacheMut    sync.RWMutex
+	requestMut  sync.Mutex
+	shutSig     *shutdown.Signaller
 
 	logger *service.Logger
 	nowFn  func() time.Time
+
+	mMessages      *service.MetricCounter
+	mSuccess       *service.MetricCounter
+	mError         *service.MetricCounter
+	mBatchSubjects *service.MetricGauge
+
+	mEnumSubstitutions *service.MetricCounter
+	mSchemaDrift       *service.MetricCounter
+
+	mCacheHit       *service.MetricCounter
+	mCacheMiss      *service.MetricCounter
+	mCacheRefresh   *service.MetricCounter
+	mCachePurge     *service.MetricCounter
+	mCachedSubjects *service.MetricGauge
+
+	mSubjectSuccess *service.MetricCounter
+	subjectLabels   *subjectMetricLabels
 }
 
-func newSchemaRegistryEncoderFromConfig(conf *service.ParsedConfig, logger *service.Logger) (*schemaRegistryEncoder, error) {
+func newSchemaRegistryEncoderFromConfig(conf *service.ParsedConfig, logger *service.Logger, metrics *service.Metrics) (*schemaRegistryEncoder, error) {
 	urlStr, err := conf.FieldString("url")
 	if err != nil {
 		return nil, err
 	}
+	schemaPath, err := conf.FieldString("schema_path")
+	if err != nil {
+		return nil, err
+	}
+	if urlStr == "" && schemaPath == "" {
+		return nil, errors.New("either a url or a schema_path must be specified")
+	}
+	if urlStr != "" && schemaPath != "" {
+		return nil, errors.New("cannot specify both a url and a schema_path")
+	}
+	urlField, err := conf.FieldInterpolatedString("url")
+	if err != nil {
+		return nil, err
+	}
+	var registryURL *service.InterpolatedString
+	if static, isStatic := urlField.Static(); isStatic {
+		urlStr = static
+	} else {
+		registryURL = urlField
+		urlStr = ""
+	}
+	schemaID, err := conf.FieldInt("schema_id")
+	if err != nil {
+		return nil, err
+	}
+	watchSchemaPath, err := conf.FieldBool("watch_schema_path")
+	if err != nil {
+		return nil, err
+	}
 	subject, err := conf.FieldInterpolatedString("subject")
 	if err != nil {
 		return nil, err
 	}
+	fallbackSubjects, err := conf.FieldInterpolatedStringList("fallback_subjects")
+	if err != nil {
+		return nil, err
+	}
+	subjectMap, err := conf.FieldStringMap("subject_map")
+	if err != nil {
+		return nil, err
+	}
+	subjectKey, err := conf.FieldInterpolatedString("subject_key")
+	if err != nil {
+		return nil, err
+	}
+	subjectMapFallback, err := conf.FieldBool("subject_map_fallback")
+	if err != nil {
+		return nil, err
+	}
+	subjectSuffixStr, err := conf.FieldString("subject_suffix")
+	if err != nil {
+		return nil, err
+	}
+	subjectSuffix, err := parseSubjectSuffix(subjectSuffixStr)
+	if err != nil {
+		return nil, err
+	}
+	warmupSubjects, err := conf.FieldStringList("warmup_subjects")
+	if err != nil {
+		return nil, err
+	}
+	for i, subject := range warmupSubjects {
+		if subject == "" {
+			return nil, errors.New("warmup_subjects must not contain an empty subject")
+		}
+		warmupSubjects[i] = subject + subjectSuffix
+	}
+	preloadAll, err := conf.FieldBool("preload_all")
+	if err != nil {
+		return nil, err
+	}
+	if registryURL != nil && (len(warmupSubjects) > 0 || preloadAll) {
+		return nil, errors.New("an interpolated url cannot be combined with warmup_subjects or preload_all")
+	}
+	preloadFailures, err := conf.FieldString("preload_failures")
+	if err != nil {
+		return nil, err
+	}
+	switch preloadFailures {
+	case "fatal", "warn":
+	default:
+		return nil, fmt.Errorf("preload_failures option '%v' not recognised", preloadFailures)
+	}
+	if preloadAll && schemaPath != "" {
+		return nil, errors.New("preload_all cannot be combined with a schema_path")
+	}
 	avroRawJSON, err := conf.FieldBool("avro_raw_json")
 	if err != nil {
 		return nil, err
 	}
+	var avroRawJSONOverride *service.InterpolatedString
+	if overrideStr, _ := conf.FieldString("avro_raw_json_override"); overrideStr != "" {
+		if avroRawJSONOverride, err = conf.FieldInterpolatedString("avro_raw_json_override"); err != nil {
+			return nil, err
+		}
+	}
+	protobufMessage, err := conf.FieldString("protobuf_message")
+	if err != nil {
+		return nil, err
+	}
+	schemaVersion, err := conf.FieldString("version")
+	if err != nil {
+		return nil, err
+	}
+	if schemaVersion != "latest" {
+		if v, err := strconv.Atoi(schemaVersion); err != nil || v < 1 {
+			return nil, fmt.Errorf("version must be either latest or a positive integer, got '%v'", schemaVersion)
+		}
+		if preloadAll {
+			return nil, errors.New("preload_all cannot be combined with a pinned version")
+		}
+	}
 	refreshPeriodStr, err := conf.FieldString("refresh_period")
 	if err != nil {
 		return nil, err
@@ -115,15 +507,271 @@ func newSchemaRegistryEncoderFromConfig(conf *service.ParsedConfig, logger *serv
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse refresh period: %v", err)
 	}
-	refreshTicker := refreshPeriod / 10
-	if refreshTicker < time.Second {
-		refreshTicker = time.Second
+	if schemaPath != "" || schemaVersion != "latest" {
+		refreshPeriod = 0
+	}
+	if refreshPeriod > schemaStaleAfter {
+		logger.Warnf(
+			"The refresh_period of %v is greater than the period of %v after which unused schemas are purged, schemas of subjects that are used less often than this will be purged rather than refreshed",
+			refreshPeriod, schemaStaleAfter,
+		)
+	}
+	schemaTypeChange, err := conf.FieldString("schema_type_change")
+	if err != nil {
+		return nil, err
+	}
+	revalidateAfterStr, err := conf.FieldString("revalidate_after")
+	if err != nil {
+		return nil, err
+	}
+	revalidateAfter, err := time.ParseDuration(revalidateAfterStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse revalidate_after: %v", err)
+	}
+	if schemaPath != "" || schemaVersion != "latest" {
+		revalidateAfter = 0
+	}
+	var refreshTicker time.Duration
+	if refreshPeriod > 0 {
+		if refreshTicker = refreshPeriod / 10; refreshTicker < time.Second {
+			refreshTicker = time.Second
+		}
+	}
+	tlsConf, err := registryTLSFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	codecJSONMode, err := conf.FieldString("codec_json_mode")
+	if err != nil {
+		return nil, err
+	}
+	newCodec, err := codecConstructorForMode(codecJSONMode)
+	if err != nil {
+		return nil, err
+	}
+	sharedCodecCache, err := conf.FieldString("shared_codec_cache")
+	if err != nil {
+		return nil, err
+	}
+	if sharedCodecCache != "" && schemaPath != "" {
+		return nil, errors.New("shared_codec_cache cannot be combined with a schema_path")
+	}
+	fieldMapping, err := conf.FieldStringMap("field_mapping")
+	if err != nil {
+		return nil, err
+	}
+	fieldRenames, err := parseFieldRenames(fieldMapping)
+	if err != nil {
+		return nil, err
+	}
+	if fieldRenames != nil && avroRawJSON {
+		return nil, errors.New("field_mapping cannot be used along with avro_raw_json")
+	}
+	arrayRecordsStr, err := conf.FieldString("array_records")
+	if err != nil {
+		return nil, err
+	}
+	arrayRecordsPrefix, err := parseRecordPrefix("array_records", arrayRecordsStr)
+	if err != nil {
+		return nil, err
+	}
+	batchRecordsStr, err := conf.FieldString("batch_records")
+	if err != nil {
+		return nil, err
+	}
+	batchRecordsPrefix, err := parseRecordPrefix("batch_records", batchRecordsStr)
+	if err != nil {
+		return nil, err
+	}
+	if arrayRecordsPrefix != nil && batchRecordsPrefix != nil {
+		return nil, errors.New("batch_records cannot be enabled along with array_records")
+	}
+	framingStrs, err := conf.FieldStringList("framings")
+	if err != nil {
+		return nil, err
+	}
+	header, err := wireHeaderFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	framings, err := parseSchemaFramings(framingStrs, header)
+	if err != nil {
+		return nil, err
+	}
+	atomicBatches, err := conf.FieldBool("atomic")
+	if err != nil {
+		return nil, err
+	}
+	var preEncode *bloblang.Executor
+	if conf.Contains("pre_encode") {
+		if preEncode, err = conf.FieldBloblang("pre_encode"); err != nil {
+			return nil, err
+		}
+	}
+	onError, err := conf.FieldString("on_error")
+	if err != nil {
+		return nil, err
+	}
+	switch onError {
+	case "set_error", "drop", "reject":
+	default:
+		return nil, fmt.Errorf("on_error option '%v' not recognised", onError)
+	}
+	emptyMessages, err := conf.FieldString("empty_messages")
+	if err != nil {
+		return nil, err
+	}
+	switch emptyMessages {
+	case "error", "skip", "encode_null":
+	default:
+		return nil, fmt.Errorf("empty_messages option '%v' not recognised", emptyMessages)
+	}
+	unknownEnumSymbols, err := conf.FieldString("unknown_enum_symbols")
+	if err != nil {
+		return nil, err
+	}
+	switch unknownEnumSymbols {
+	case "error", "default_symbol":
+	default:
+		return nil, fmt.Errorf("unknown_enum_symbols option '%v' not recognised", unknownEnumSymbols)
+	}
+	defaultEnumSymbol, err := conf.FieldString("default_enum_symbol")
+	if err != nil {
+		return nil, err
 	}
-	tlsConf, err := conf.FieldTLS("tls")
+	convertLogicalTypes, err := conf.FieldBool("convert_logical_types")
 	if err != nil {
 		return nil, err
 	}
-	return newSchemaRegistryEncoder(urlStr, tlsConf, subject, avroRawJSON, refreshPeriod, refreshTicker, logger)
+	maxMessageSize, err := conf.FieldInt("max_message_size")
+	if err != nil {
+		return nil, err
+	}
+	if maxMessageSize < 0 {
+		return nil, fmt.Errorf("max_message_size must not be negative, got %v", maxMessageSize)
+	}
+	maxMetricSubjects, err := conf.FieldInt("max_metric_subjects")
+	if err != nil {
+		return nil, err
+	}
+	if maxMetricSubjects < 0 {
+		return nil, fmt.Errorf("max_metric_subjects must not be negative, got %v", maxMetricSubjects)
+	}
+	debugResponses, err := conf.FieldBool("debug_responses")
+	if err != nil {
+		return nil, err
+	}
+	failureSampler, err := failureSamplerFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	schemaDrift, err := schemaDriftFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	basicAuth, err := registryBasicAuthFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	requestTimeout, err := conf.FieldDuration("request_timeout")
+	if err != nil {
+		return nil, err
+	}
+	if requestTimeout <= 0 {
+		return nil, errors.New("request_timeout must be greater than zero")
+	}
+	maxRetries, err := conf.FieldInt("max_retries")
+	if err != nil {
+		return nil, err
+	}
+	if maxRetries < 0 {
+		return nil, errors.New("max_retries must not be negative")
+	}
+	s, err := newSchemaRegistryEncoder(urlStr, tlsConf, subject, avroRawJSON, refreshPeriod, refreshTicker, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.fallbackSubjects = fallbackSubjects
+	if len(subjectMap) > 0 {
+		s.subjectMap = subjectMap
+		s.subjectKey = subjectKey
+	}
+	s.subjectMapFallback = subjectMapFallback
+	s.subjectSuffix = subjectSuffix
+	s.revalidateAfter = revalidateAfter
+	s.avroRawJSONOverride = avroRawJSONOverride
+	s.protobufMessage = protobufMessage
+	s.schemaVersion = schemaVersion
+	s.schemaTypeChange = schemaTypeChange
+	s.newCodec = newCodec
+	s.codecMode = codecJSONMode
+	s.fieldRenames = fieldRenames
+	s.preEncode = preEncode
+	s.arrayRecordsPrefix = arrayRecordsPrefix
+	s.batchRecordsPrefix = batchRecordsPrefix
+	s.framings = framings
+	s.atomicBatches = atomicBatches
+	s.onError = onError
+	s.emptyMessages = emptyMessages
+	s.unknownEnumSymbols = unknownEnumSymbols
+	s.defaultEnumSymbol = defaultEnumSymbol
+	s.convertLogicalTypes = convertLogicalTypes
+	s.maxMessageSize = maxMessageSize
+	s.debugResponses = debugResponses
+	s.failureSampler = failureSampler
+	s.schemaDrift = schemaDrift
+	s.basicAuth = basicAuth
+	if registryURL != nil {
+		s.registryURL = registryURL
+		s.registryURLs = newRegistryURLCache(registryURLCacheSize)
+	}
+	s.requestTimeout = requestTimeout
+	s.maxRetries = maxRetries
+	if sharedCodecCache != "" {
+		s.codecs = acquireCodecCache(sharedCodecCache)
+	}
+	if schemaPath != "" {
+		if err := s.loadLocalSchema(schemaPath, schemaID); err != nil {
+			_ = s.Close(context.Background())
+			return nil, err
+		}
+		if watchSchemaPath {
+			if err := s.watchLocalSchema(schemaPath, schemaID); err != nil {
+				_ = s.Close(context.Background())
+				return nil, err
+			}
+		}
+	}
+	s.mMessages = metrics.NewCounter("schema_registry_encode_messages")
+	s.mSuccess = metrics.NewCounter("schema_registry_encode_success")
+	s.mError = metrics.NewCounter("schema_registry_encode_error")
+	s.mBatchSubjects = metrics.NewGauge("schema_registry_encode_batch_subjects")
+	s.mEnumSubstitutions = metrics.NewCounter("schema_registry_encode_enum_substitutions")
+	s.mSchemaDrift = metrics.NewCounter("schema_registry_encode_schema_drift", "subject")
+	s.mCacheHit = metrics.NewCounter("schema_registry_encode_cache_hit")
+	s.mCacheMiss = metrics.NewCounter("schema_registry_encode_cache_miss")
+	s.mCacheRefresh = metrics.NewCounter("schema_registry_encode_cache_refresh")
+	s.mCachePurge = metrics.NewCounter("schema_registry_encode_cache_purge")
+	s.mCachedSubjects = metrics.NewGauge("schema_registry_encode_cached_subjects")
+	s.mSubjectSuccess = metrics.NewCounter("schema_registry_encode_subject_success", "subject")
+	s.subjectLabels = newSubjectMetricLabels(maxMetricSubjects, logger)
+	if len(warmupSubjects) > 0 && schemaPath == "" {
+		mWarmupError := metrics.NewCounter("schema_registry_encode_warmup_error")
+		failures := s.warmupEncoders(context.Background(), warmupSubjects)
+		for _, f := range failures {
+			s.logger.Warnf("Failed to warm up schema subject '%v', it will be fetched when first required: %v", f.subject, f.err)
+		}
+		mWarmupError.Incr(int64(len(failures)))
+		s.logger.Infof("Warmed up %v of %v schema subjects", len(warmupSubjects)-len(failures), len(warmupSubjects))
+	}
+	if preloadAll {
+		mPreloadError := metrics.NewCounter("schema_registry_encode_preload_error")
+		if err := s.preloadEncoders(context.Background(), preloadFailures == "fatal", mPreloadError); err != nil {
+			_ = s.Close(context.Background())
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 func newSchemaRegistryEncoder(
@@ -143,25 +791,26 @@ func newSchemaRegistryEncoder(
 		schemaRegistryBaseURL: u,
 		subject:               subject,
 		avroRawJSON:           avroRawJSON,
+		schemaVersion:         "latest",
+		requestTimeout:        time.Second * 5,
+		maxRetries:            2,
+		newCodec:              goavro.NewCodecForStandardJSON,
 		schemaRefreshAfter:    schemaRefreshAfter,
-		schemas:               map[string]*cachedSchemaEncoder{},
+		framings:              []schemaFraming{confluentFraming},
+		onError:               "set_error",
+		emptyMessages:         "error",
+		unknownEnumSymbols:    "error",
+		schemas:               map[schemaKey]*cachedSchemaEncoder{},
 		shutSig:               shutdown.NewSignaller(),
 		logger:                logger,
 		nowFn:                 time.Now,
+		subjectLabels:         newSubjectMetricLabels(100, logger),
 	}
 
-	s.client = http.DefaultClient
-	if tlsConf != nil {
-		s.client = &http.Client{}
-		if c, ok := http.DefaultTransport.(*http.Transport); ok {
-			cloned := c.Clone()
-			cloned.TLSClientConfig = tlsConf
-			s.client.Transport = cloned
-		} else {
-			s.client.Transport = &http.Transport{
-				TLSClientConfig: tlsConf,
-			}
-		}
+	s.client = newRegistryClient(tlsConf)
+
+	if schemaRefreshTicker <= 0 {
+		return s, nil
 	}
 
 	go func() {
@@ -179,73 +828,733 @@ func newSchemaRegistryEncoder(
 
 func (s *schemaRegistryEncoder) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
 	batch = batch.Copy()
+
+	// The schema used to encode each message, or nil if encoding failed.
+	encodedWith := make([]*cachedSchemaEncoder, len(batch))
+	// The number of messages successfully encoded with each subject.
+	subjects := map[string]int64{}
+	// Whether each message failed to encode.
+	failures := make([]bool, len(batch))
+	var failed int64
 	for i, msg := range batch {
-		encoder, id, err := s.getEncoder(batch.InterpolatedString(i, s.subject))
-		if err != nil {
+		var subject string
+		var err error
+		if encodedWith[i], subject, err = s.encodeMessage(ctx, batch, i); err != nil {
+			if errors.Is(err, errEmptyMessageSkipped) {
+				continue
+			}
+			if s.failureSampler != nil {
+				s.failureSampler.log(s.logger, s.nowFn(), msg, subject, err)
+			}
+			if s.atomicBatches {
+				s.mMessages.Incr(int64(len(batch)))
+				s.mError.Incr(int64(len(batch)))
+				return nil, fmt.Errorf("failed to encode message %v of batch: %w", i, err)
+			}
 			msg.SetError(err)
+			failures[i] = true
+			failed++
 			continue
 		}
+		subjects[subject]++
+	}
 
-		if err := encoder(msg); err != nil {
-			msg.SetError(err)
-			continue
+	s.mMessages.Incr(int64(len(batch)))
+	s.mSuccess.Incr(int64(len(batch)) - failed)
+	s.mError.Incr(failed)
+	s.mBatchSubjects.Set(int64(len(subjects)))
+	for subject, n := range subjects {
+		s.mSubjectSuccess.Incr(n, s.subjectLabels.label(subject))
+	}
+
+	var rejected service.MessageBatch
+	if failed > 0 && s.onError != "set_error" {
+		batch, encodedWith, rejected = splitFailedMessages(batch, encodedWith, failures)
+		if s.onError == "drop" {
+			rejected = nil
 		}
+	}
 
-		rawBytes, err := msg.AsBytes()
-		if err != nil {
-			msg.SetError(errors.New("unable to reference encoded message as bytes"))
-			continue
+	if s.batchRecordsPrefix != nil {
+		batch, encodedWith = s.combineBatchRecords(batch, encodedWith)
+	}
+
+	outBatches := make([]service.MessageBatch, 0, len(s.framings)+1)
+	for j, framing := range s.framings {
+		if len(batch) == 0 {
+			break
+		}
+		framedBatch := batch
+		if j < len(s.framings)-1 {
+			framedBatch = batch.Copy()
 		}
+		for i, msg := range framedBatch {
+			if len(s.framings) > 1 {
+				msg.MetaSet("schema_registry_framing", framing.name)
+			}
+			if encodedWith[i] == nil {
+				continue
+			}
 
-		if rawBytes, err = insertID(id, rawBytes); err != nil {
-			msg.SetError(err)
+			rawBytes, err := msg.AsBytes()
+			if err != nil {
+				msg.SetError(errors.New("unable to reference encoded message as bytes"))
+				continue
+			}
+
+			if rawBytes, err = framing.frame(encodedWith[i].id, encodedWith[i].fingerprint, rawBytes); err != nil {
+				msg.SetError(err)
+				continue
+			}
+			msg.SetBytes(rawBytes)
+		}
+		outBatches = append(outBatches, framedBatch)
+	}
+	if len(rejected) > 0 {
+		outBatches = append(outBatches, rejected)
+	}
+	return outBatches, nil
+}
+
+// splitFailedMessages removes the messages that failed to encode from a batch,
+// and returns them separately in their original order.
+func splitFailedMessages(batch service.MessageBatch, encodedWith []*cachedSchemaEncoder, failures []bool) (service.MessageBatch, []*cachedSchemaEncoder, service.MessageBatch) {
+	var failed service.MessageBatch
+	newBatch := make(service.MessageBatch, 0, len(batch))
+	newEncodedWith := make([]*cachedSchemaEncoder, 0, len(encodedWith))
+	for i, msg := range batch {
+		if failures[i] {
+			failed = append(failed, msg)
 			continue
 		}
-		msg.SetBytes(rawBytes)
+		newBatch = append(newBatch, msg)
+		newEncodedWith = append(newEncodedWith, encodedWith[i])
 	}
-	return []service.MessageBatch{batch}, nil
+	return newBatch, newEncodedWith, failed
 }
 
-func (s *schemaRegistryEncoder) Close(ctx context.Context) error {
-	s.shutSig.CloseNow()
-	s.cacheMut.Lock()
-	defer s.cacheMut.Unlock()
-	if ctx.Err() != nil {
-		return ctx.Err()
+// combineBatchRecords combines the successfully encoded messages of a batch into
+// a single message, which is placed ahead of the messages that failed. If the
+// messages were encoded with different schemas they are flagged as having
+// failed and the batch is returned unchanged.
+func (s *schemaRegistryEncoder) combineBatchRecords(batch service.MessageBatch, encodedWith []*cachedSchemaEncoder) (service.MessageBatch, []*cachedSchemaEncoder) {
+	var encoded []int
+	for i, e := range encodedWith {
+		if e != nil {
+			encoded = append(encoded, i)
+		}
 	}
-	for k := range s.schemas {
-		delete(s.schemas, k)
+	if len(encoded) == 0 {
+		return batch, encodedWith
 	}
-	return nil
-}
 
-//------------------------------------------------------------------------------
+	first := encodedWith[encoded[0]]
+	for _, i := range encoded[1:] {
+		if e := encodedWith[i]; e.id != first.id || e.fingerprint != first.fingerprint {
+			err := fmt.Errorf("unable to combine the records of a batch encoded with different schemas, found IDs %v and %v", first.id, e.id)
+			for _, j := range encoded {
+				batch[j].SetError(err)
+			}
+			return batch, encodedWith
+		}
+	}
 
-type schemaEncoder func(m *service.Message) error
+	var buf []byte
+	for _, i := range encoded {
+		record, err := batch[i].AsBytes()
+		if err != nil {
+			err = errors.New("unable to reference encoded message as bytes")
+			for _, j := range encoded {
+				batch[j].SetError(err)
+			}
+			return batch, encodedWith
+		}
+		buf = s.batchRecordsPrefix(buf, len(record))
+		buf = append(buf, record...)
+	}
+
+	combined := batch[encoded[0]]
+	combined.SetBytes(buf)
+
+	newBatch := service.MessageBatch{combined}
+	newEncodedWith := []*cachedSchemaEncoder{first}
+	for i, msg := range batch {
+		if encodedWith[i] == nil {
+			newBatch = append(newBatch, msg)
+			newEncodedWith = append(newEncodedWith, nil)
+		}
+	}
+	return newBatch, newEncodedWith
+}
+
+// encodeMessage encodes a message of a batch with the schema of the first
+// subject that succeeds, and returns the ID and fingerprint of that schema
+// along with the subject.
+func (s *schemaRegistryEncoder) encodeMessage(ctx context.Context, batch service.MessageBatch, i int) (*cachedSchemaEncoder, string, error) {
+	if err := s.checkEmptyMessage(batch[i]); err != nil {
+		return nil, "", err
+	}
+	if err := s.checkMessageSize(batch[i]); err != nil {
+		return nil, "", err
+	}
+
+	registryURL, err := s.resolveRegistryURL(batch, i)
+	if err != nil {
+		return nil, "", err
+	}
+
+	subject, err := s.resolveSubject(batch, i)
+	var res *cachedSchemaEncoder
+	if err == nil {
+		res, err = s.encodeMessageWithSubject(ctx, batch, i, schemaKey{url: registryURL, subject: subject})
+	}
+	for j := 0; err != nil && j < len(s.fallbackSubjects); j++ {
+		fallback := batch.InterpolatedString(i, s.fallbackSubjects[j])
+		if fallback == "" {
+			continue
+		}
+		subject = fallback + s.subjectSuffix
+		res, err = s.encodeMessageWithSubject(ctx, batch, i, schemaKey{url: registryURL, subject: subject})
+	}
+	return res, subject, err
+}
+
+// applyPreEncode returns the result of the pre_encode mapping of a message,
+// which is executed with metadata describing the schema the message is being
+// encoded with. The message itself is not modified.
+func (s *schemaRegistryEncoder) applyPreEncode(msg *service.Message, subject string, id int) (*service.Message, error) {
+	in := msg.Copy()
+	in.MetaSet("schema_registry_subject", subject)
+	in.MetaSet("schema_registry_id", strconv.Itoa(id))
+
+	out, err := in.BloblangQuery(s.preEncode)
+	if err != nil {
+		return nil, fmt.Errorf("pre_encode mapping failed: %w", err)
+	}
+	if out == nil {
+		return nil, errors.New("pre_encode mapping deleted the message")
+	}
+	return out, nil
+}
+
+// errEmptyMessageSkipped is returned when encoding an empty message that
+// should pass through unchanged.
+var errEmptyMessageSkipped = errors.New("empty message skipped")
+
+// checkEmptyMessage returns an error if a message is empty and should not be
+// encoded, which is errEmptyMessageSkipped when it should be skipped. Empty
+// messages that should be encoded as null are handled by the encoder of their
+// schema.
+func (s *schemaRegistryEncoder) checkEmptyMessage(msg *service.Message) error {
+	if s.emptyMessages == "encode_null" {
+		return nil
+	}
+	b, err := msg.AsBytes()
+	if err != nil {
+		return err
+	}
+	if len(b) > 0 {
+		return nil
+	}
+	if s.emptyMessages == "skip" {
+		return errEmptyMessageSkipped
+	}
+	return errors.New("message is empty")
+}
+
+// checkMessageSize returns an error if a message exceeds the maximum size of
+// messages to encode.
+func (s *schemaRegistryEncoder) checkMessageSize(msg *service.Message) error {
+	if s.maxMessageSize <= 0 {
+		return nil
+	}
+	b, err := msg.AsBytes()
+	if err != nil {
+		return err
+	}
+	if len(b) > s.maxMessageSize {
+		return fmt.Errorf("message size of %v bytes exceeds max_message_size of %v bytes", len(b), s.maxMessageSize)
+	}
+	return nil
+}
+
+// resolveRegistryURL returns the URL of the schema registry of a message when
+// the url is interpolated, and otherwise an empty string, which refers to the
+// static url.
+func (s *schemaRegistryEncoder) resolveRegistryURL(batch service.MessageBatch, i int) (string, error) {
+	if s.registryURL == nil {
+		return "", nil
+	}
+	registryURL := batch.InterpolatedString(i, s.registryURL)
+	if registryURL == "" {
+		return "", errors.New("schema registry url resolved to an empty string")
+	}
+	if _, err := s.registryURLs.get(registryURL); err != nil {
+		return "", err
+	}
+	return registryURL, nil
+}
+
+// registryBaseURL returns the parsed base URL of a schema registry, where an
+// empty URL refers to the static url.
+func (s *schemaRegistryEncoder) registryBaseURL(registryURL string) (*url.URL, error) {
+	if registryURL == "" {
+		return s.schemaRegistryBaseURL, nil
+	}
+	return s.registryURLs.get(registryURL)
+}
+
+// resolveSubject returns the primary subject of a message, which is obtained
+// from the subject map when one is configured, with the subject suffix
+// appended. A subject that resolves to an empty string is rejected rather than
+// reduced to the suffix alone.
+func (s *schemaRegistryEncoder) resolveSubject(batch service.MessageBatch, i int) (string, error) {
+	var subject string
+	if s.subjectMap == nil {
+		subject = batch.InterpolatedString(i, s.subject)
+	} else {
+		key := batch.InterpolatedString(i, s.subjectKey)
+		mapped, exists := s.subjectMap[key]
+		switch {
+		case exists:
+			subject = mapped
+		case !s.subjectMapFallback:
+			return "", fmt.Errorf("subject key '%v' not found in subject_map", key)
+		default:
+			subject = batch.InterpolatedString(i, s.subject)
+		}
+	}
+	if subject == "" {
+		return "", errors.New("schema subject resolved to an empty string")
+	}
+	return subject + s.subjectSuffix, nil
+}
+
+// parseSubjectSuffix returns the suffix to append to subjects for a
+// subject_suffix option.
+func parseSubjectSuffix(str string) (string, error) {
+	switch str {
+	case "none":
+		return "", nil
+	case "key":
+		return "-key", nil
+	case "value":
+		return "-value", nil
+	}
+	return "", fmt.Errorf("subject_suffix option '%v' not recognised", str)
+}
+
+func (s *schemaRegistryEncoder) encodeMessageWithSubject(ctx context.Context, batch service.MessageBatch, i int, key schemaKey) (*cachedSchemaEncoder, error) {
+	encoder, id, fingerprint, err := s.getEncoder(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if s.preEncode == nil {
+		if err := s.recordDrift(key, encoder(batch[i])); err != nil {
+			return nil, err
+		}
+	} else {
+		mapped, err := s.applyPreEncode(batch[i], key.subject, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.recordDrift(key, encoder(mapped)); err != nil {
+			return nil, err
+		}
+		b, err := mapped.AsBytes()
+		if err != nil {
+			return nil, err
+		}
+		batch[i].SetBytes(b)
+	}
+	return &cachedSchemaEncoder{
+		id:          id,
+		fingerprint: fingerprint,
+	}, nil
+}
+
+func (s *schemaRegistryEncoder) Close(ctx context.Context) error {
+	s.shutSig.CloseNow()
+	s.cacheMut.Lock()
+	defer s.cacheMut.Unlock()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	for k, c := range s.schemas {
+		s.releaseCodec(k.url, c.id)
+		delete(s.schemas, k)
+	}
+	if s.codecs != nil {
+		// The shared cache is reference counted, and therefore must only be
+		// released once regardless of how many times the processor is closed.
+		s.releaseCodecsOnce.Do(func() {
+			releaseCodecCache(s.codecs)
+		})
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+type schemaEncoder func(m *service.Message) error
+
+// schemaKey identifies the cached schema of a subject within a schema registry,
+// where the url is empty for the static url of the processor.
+type schemaKey struct {
+	url     string
+	subject string
+}
+
+// String returns the subject of the key, followed by the URL of its registry
+// when the url of the processor is interpolated.
+func (k schemaKey) String() string {
+	if k.url == "" {
+		return k.subject
+	}
+	return fmt.Sprintf("%v (%v)", k.subject, k.url)
+}
 
 type cachedSchemaEncoder struct {
 	lastUsedUnixSeconds    int64
 	lastUpdatedUnixSeconds int64
 	id                     int
+	fingerprint            uint64
 	encoder                schemaEncoder
+
+	// The type of the schema as returned by the schema registry, which is
+	// empty for Avro schemas.
+	schemaType string
+
+	// The last time a background revalidation was attempted, and whether one
+	// is currently in progress.
+	lastRevalidatedUnixSeconds int64
+	revalidating               int32
 }
 
-func insertID(id int, content []byte) ([]byte, error) {
-	newBytes := make([]byte, len(content)+5)
+// insertSingleObjectHeader prefixes content with the Avro single object
+// encoding header, which is a two byte marker followed by the little-endian
+// Rabin fingerprint of the schema.
+func insertSingleObjectHeader(fingerprint uint64, content []byte) ([]byte, error) {
+	newBytes := make([]byte, len(content)+10)
 
-	binary.BigEndian.PutUint32(newBytes[1:], uint32(id))
-	copy(newBytes[5:], content)
+	newBytes[0], newBytes[1] = 0xC3, 0x01
+	binary.LittleEndian.PutUint64(newBytes[2:], fingerprint)
+	copy(newBytes[10:], content)
 
 	return newBytes, nil
 }
 
+type schemaFraming struct {
+	name  string
+	frame func(id int, fingerprint uint64, content []byte) ([]byte, error)
+}
+
+// confluentFramingWithHeader returns the confluent framing with a custom
+// layout of its header.
+func confluentFramingWithHeader(h wireHeader) schemaFraming {
+	return schemaFraming{
+		name: "confluent",
+		frame: func(id int, _ uint64, content []byte) ([]byte, error) {
+			return h.insert(id, content)
+		},
+	}
+}
+
+var (
+	confluentFraming    = confluentFramingWithHeader(confluentHeader)
+	singleObjectFraming = schemaFraming{
+		name: "single_object",
+		frame: func(_ int, fingerprint uint64, content []byte) ([]byte, error) {
+			return insertSingleObjectHeader(fingerprint, content)
+		},
+	}
+)
+
+func parseSchemaFramings(names []string, header wireHeader) ([]schemaFraming, error) {
+	if len(names) == 0 {
+		return nil, errors.New("at least one framing must be specified")
+	}
+	framings := make([]schemaFraming, 0, len(names))
+	for _, n := range names {
+		switch n {
+		case confluentFraming.name:
+			framings = append(framings, confluentFramingWithHeader(header))
+		case singleObjectFraming.name:
+			framings = append(framings, singleObjectFraming)
+		default:
+			return nil, fmt.Errorf("framing '%v' not recognised", n)
+		}
+	}
+	return framings, nil
+}
+
+// codecConstructorForMode returns the goavro codec constructor of a codec JSON
+// mode, which determines how union values are parsed from JSON.
+func codecConstructorForMode(mode string) (func(string) (*goavro.Codec, error), error) {
+	switch mode {
+	case "standard":
+		return goavro.NewCodecForStandardJSON, nil
+	case "plain":
+		return goavro.NewCodec, nil
+	}
+	return nil, fmt.Errorf("codec_json_mode not recognised: %v", mode)
+}
+
+// arrayRecordPrefixFn appends the prefix of a record with the given length to a
+// buffer of concatenated records.
+type arrayRecordPrefixFn func(buf []byte, length int) []byte
+
+// parseRecordPrefix returns the record prefix of an option of the given field,
+// or nil if the option is disabled.
+func parseRecordPrefix(field, name string) (arrayRecordPrefixFn, error) {
+	switch name {
+	case "disabled":
+		return nil, nil
+	case "concatenated":
+		return func(buf []byte, _ int) []byte {
+			return buf
+		}, nil
+	case "varint_length_prefixed":
+		return func(buf []byte, length int) []byte {
+			var prefix [binary.MaxVarintLen64]byte
+			n := binary.PutVarint(prefix[:], int64(length))
+			return append(buf, prefix[:n]...)
+		}, nil
+	case "uint32_length_prefixed":
+		return func(buf []byte, length int) []byte {
+			var prefix [4]byte
+			binary.BigEndian.PutUint32(prefix[:], uint32(length))
+			return append(buf, prefix[:]...)
+		}, nil
+	}
+	return nil, fmt.Errorf("%v option '%v' not recognised", field, name)
+}
+
+// useRawJSON returns whether a message should be parsed as a raw JSON document
+// rather than Avro JSON.
+func (s *schemaRegistryEncoder) useRawJSON(m *service.Message) (bool, error) {
+	if s.avroRawJSONOverride == nil {
+		return s.avroRawJSON, nil
+	}
+	switch v := s.avroRawJSONOverride.String(m); v {
+	case "":
+		return s.avroRawJSON, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("avro_raw_json_override must resolve to true or false, got '%v'", v)
+	}
+}
+
+// encodeArrayRecords encodes each element of a message containing an array as
+// a record of the codec and concatenates the records.
+func (s *schemaRegistryEncoder) encodeArrayRecords(codec *goavro.Codec, enums *enumSubstituter, logicalTypes *logicalTypeConverter, m *service.Message) error {
+	rawJSON, err := s.useRawJSON(m)
+	if err != nil {
+		return err
+	}
+
+	var elements []interface{}
+	if rawJSON {
+		b, err := m.AsBytes()
+		if err != nil {
+			return err
+		}
+
+		var rawElements []json.RawMessage
+		if err := json.Unmarshal(b, &rawElements); err != nil {
+			return fmt.Errorf("failed to parse message as an array: %w", err)
+		}
+		for i, raw := range rawElements {
+			raw, err := s.substituteEnumsJSON(enums, raw)
+			if err != nil {
+				return fmt.Errorf("record %v: %w", i, err)
+			}
+			if raw, err = logicalTypes.convertJSON(raw); err != nil {
+				return fmt.Errorf("record %v: %w", i, err)
+			}
+			datum, _, err := codec.NativeFromTextual(raw)
+			if err != nil {
+				return fmt.Errorf("record %v: %w", i, err)
+			}
+			elements = append(elements, datum)
+		}
+	} else {
+		v, err := m.AsStructured()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if elements, ok = s.fieldRenames.apply(resolveJSONNumbers(v)).([]interface{}); !ok {
+			return fmt.Errorf("expected message to be an array, got %T", v)
+		}
+		for i, e := range elements {
+			if elements[i], err = s.substituteEnums(enums, e); err != nil {
+				return fmt.Errorf("record %v: %w", i, err)
+			}
+			if elements[i], err = logicalTypes.convert(elements[i]); err != nil {
+				return fmt.Errorf("record %v: %w", i, err)
+			}
+		}
+	}
+
+	var buf []byte
+	for i, e := range elements {
+		record, err := codec.BinaryFromNative(nil, e)
+		if err != nil {
+			return fmt.Errorf("record %v: %w", i, err)
+		}
+		buf = s.arrayRecordsPrefix(buf, len(record))
+		buf = append(buf, record...)
+	}
+
+	m.SetBytes(buf)
+	return nil
+}
+
+// substituteEnums replaces unknown enum symbols within a structured value when
+// a substituter is provided, and counts the symbols that were replaced.
+func (s *schemaRegistryEncoder) substituteEnums(enums *enumSubstituter, v interface{}) (interface{}, error) {
+	if enums == nil {
+		return v, nil
+	}
+	v, n, err := enums.substitute(v)
+	if err != nil {
+		return nil, err
+	}
+	s.mEnumSubstitutions.Incr(n)
+	return v, nil
+}
+
+// substituteEnumsJSON replaces unknown enum symbols within a raw JSON document
+// when a substituter is provided, and counts the symbols that were replaced.
+func (s *schemaRegistryEncoder) substituteEnumsJSON(enums *enumSubstituter, b []byte) ([]byte, error) {
+	if enums == nil {
+		return b, nil
+	}
+	b, n, err := enums.substituteJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	s.mEnumSubstitutions.Incr(n)
+	return b, nil
+}
+
+// fieldRenames is a tree of fields to rename within structured values, keyed
+// by their original names.
+type fieldRenames map[string]*fieldRename
+
+type fieldRename struct {
+	// The new name of the field, or empty if only fields nested within it are
+	// renamed.
+	to     string
+	nested fieldRenames
+}
+
+// parseFieldRenames parses a map of dot separated field paths to their new
+// names, returning nil if the map is empty.
+func parseFieldRenames(mapping map[string]string) (fieldRenames, error) {
+	if len(mapping) == 0 {
+		return nil, nil
+	}
+	renames := fieldRenames{}
+	for fromPath, to := range mapping {
+		if to == "" || strings.Contains(to, ".") {
+			return nil, fmt.Errorf("field_mapping of '%v' must be a non-empty field name without dots, got '%v'", fromPath, to)
+		}
+		current := renames
+		segments := strings.Split(fromPath, ".")
+		for i, seg := range segments {
+			seg = strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "."), "~0", "~")
+			if seg == "" {
+				return nil, fmt.Errorf("field_mapping path '%v' contains an empty segment", fromPath)
+			}
+			r, exists := current[seg]
+			if !exists {
+				r = &fieldRename{}
+				current[seg] = r
+			}
+			if i == len(segments)-1 {
+				r.to = to
+				break
+			}
+			if r.nested == nil {
+				r.nested = fieldRenames{}
+			}
+			current = r.nested
+		}
+	}
+	return renames, nil
+}
+
+// apply returns a structured value with fields renamed, where objects are
+// copied rather than modified and the elements of arrays are renamed
+// individually.
+func (f fieldRenames) apply(v interface{}) interface{} {
+	if len(f) == 0 {
+		return v
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			if r, exists := f[k]; exists {
+				e = r.nested.apply(e)
+				if r.to != "" {
+					k = r.to
+				}
+			}
+			renamed[k] = e
+		}
+		return renamed
+	case []interface{}:
+		renamed := make([]interface{}, len(t))
+		for i, e := range t {
+			renamed[i] = f.apply(e)
+		}
+		return renamed
+	}
+	return v
+}
+
+// resolveJSONNumbers returns a copy of a structured value where json.Number
+// values, which the codec does not accept, are replaced with an int64 when they
+// are integers and a float64 otherwise. Converting integers directly avoids the
+// loss of precision of large longs that would occur when going via a float64.
+func resolveJSONNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			resolved[k] = resolveJSONNumbers(e)
+		}
+		return resolved
+	case []interface{}:
+		resolved := make([]interface{}, len(t))
+		for i, e := range t {
+			resolved[i] = resolveJSONNumbers(e)
+		}
+		return resolved
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
 func (s *schemaRegistryEncoder) refreshEncoders() {
 	// First pass in read only mode to gather purge candidates and refresh
 	// candidates
 	s.cacheMut.RLock()
 	purgeTargetTime := s.nowFn().Add(-schemaStaleAfter).Unix()
 	updateTargetTime := s.nowFn().Add(-s.schemaRefreshAfter).Unix()
-	var purgeTargets, refreshTargets []string
+	var purgeTargets, refreshTargets []schemaKey
 	for k, v := range s.schemas {
 		if atomic.LoadInt64(&v.lastUsedUnixSeconds) < purgeTargetTime {
 			purgeTargets = append(purgeTargets, k)
@@ -259,113 +1568,439 @@ func (s *schemaRegistryEncoder) refreshEncoders() {
 	if len(purgeTargets) > 0 {
 		s.cacheMut.Lock()
 		for _, k := range purgeTargets {
-			if s.schemas[k].lastUsedUnixSeconds < purgeTargetTime {
+			if c := s.schemas[k]; atomic.LoadInt64(&c.lastUsedUnixSeconds) < purgeTargetTime {
+				s.releaseCodec(k.url, c.id)
 				delete(s.schemas, k)
+				if s.schemaDrift != nil {
+					s.schemaDrift.forget(k.String())
+				}
+				s.mCachePurge.Incr(1)
 			}
 		}
+		s.mCachedSubjects.Set(int64(len(s.schemas)))
 		s.cacheMut.Unlock()
 	}
 
 	// Each refresh target gets updated passively
 	if len(refreshTargets) > 0 {
+		ctx, done := s.shutSig.CloseAtLeisureCtx(context.Background())
+		defer done()
+
 		s.requestMut.Lock()
 		for _, k := range refreshTargets {
-			encoder, id, err := s.getLatestEncoder(k)
-			if err != nil {
+			s.cacheMut.RLock()
+			c, exists := s.schemas[k]
+			s.cacheMut.RUnlock()
+			if !exists || atomic.LoadInt64(&c.lastUpdatedUnixSeconds) >= updateTargetTime {
+				// Either purged or revalidated since the first pass.
+				continue
+			}
+			if err := s.refreshEncoder(ctx, k, c); err != nil {
 				s.logger.Errorf("Failed to refresh schema subject '%v': %v", k, err)
-			} else {
-				s.cacheMut.Lock()
-				s.schemas[k].encoder = encoder
-				s.schemas[k].id = id
-				s.schemas[k].lastUpdatedUnixSeconds = s.nowFn().Unix()
-				s.cacheMut.Unlock()
+				continue
 			}
+			s.mCacheRefresh.Incr(1)
 		}
 		s.requestMut.Unlock()
 	}
 }
 
-func (s *schemaRegistryEncoder) getLatestEncoder(subject string) (schemaEncoder, int, error) {
-	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
-	defer done()
+// schemaResponse is a schema returned by the schema registry.
+type schemaResponse struct {
+	Schema     string            `json:"schema"`
+	SchemaType string            `json:"schemaType"`
+	ID         int               `json:"id"`
+	References []schemaReference `json:"references"`
+
+	// The raw response the schema was parsed from.
+	raw []byte
+}
+
+// normalizedSchemaType returns the type of a schema returned by the schema
+// registry, which omits the type of Avro schemas.
+func (r schemaResponse) normalizedSchemaType() string {
+	return normalizeSchemaType(r.SchemaType)
+}
 
-	reqURL := *s.schemaRegistryBaseURL
-	reqURL.Path = path.Join(reqURL.Path, fmt.Sprintf("/subjects/%s/versions/latest", subject))
+func normalizeSchemaType(schemaType string) string {
+	if schemaType == "" {
+		return "AVRO"
+	}
+	return schemaType
+}
+
+// getLatestEncoder fetches and compiles the latest schema of a subject, or the
+// pinned version of the schema when one is configured.
+func (s *schemaRegistryEncoder) getLatestEncoder(ctx context.Context, key schemaKey) (*cachedSchemaEncoder, error) {
+	res, err := s.fetchLatestSchema(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, fingerprint, err := s.newEncoderForSchema(ctx, key, res)
+	if err != nil {
+		s.logger.Errorf("failed to parse response for schema subject '%v': %v", key, err)
+		s.debugResponse(fmt.Sprintf("schema subject '%v'", key), res.raw)
+		return nil, err
+	}
+	return &cachedSchemaEncoder{
+		id:          res.ID,
+		fingerprint: fingerprint,
+		encoder:     encoder,
+		schemaType:  res.SchemaType,
+	}, nil
+}
+
+// refreshEncoder fetches the latest schema of a cached subject and updates the
+// cached schema in place. When the type of the latest schema differs from the
+// cached schema it is handled according to the field schema_type_change.
+func (s *schemaRegistryEncoder) refreshEncoder(ctx context.Context, key schemaKey, c *cachedSchemaEncoder) error {
+	s.checkDrift(key, c)
+
+	res, err := s.fetchLatestSchema(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	s.cacheMut.RLock()
+	prevType := normalizeSchemaType(c.schemaType)
+	s.cacheMut.RUnlock()
+
+	if newType := res.normalizedSchemaType(); newType != prevType {
+		switch s.schemaTypeChange {
+		case "retain":
+			s.logger.Warnf("Schema type of subject '%v' changed from %v to %v, the cached schema will continue to be used", key, prevType, newType)
+			s.cacheMut.Lock()
+			atomic.StoreInt64(&c.lastUpdatedUnixSeconds, s.nowFn().Unix())
+			s.cacheMut.Unlock()
+			return nil
+		case "error":
+			typeErr := fmt.Errorf("schema type of subject '%v' changed from %v to %v", key, prevType, newType)
+			s.logger.Errorf("%v, messages of the subject will fail until it changes back", typeErr)
+			s.cacheMut.Lock()
+			c.encoder = func(m *service.Message) error {
+				return typeErr
+			}
+			atomic.StoreInt64(&c.lastUpdatedUnixSeconds, s.nowFn().Unix())
+			s.cacheMut.Unlock()
+			return nil
+		default:
+			s.logger.Warnf("Schema type of subject '%v' changed from %v to %v, the latest schema will be used", key, prevType, newType)
+		}
+	}
+
+	encoder, fingerprint, err := s.newEncoderForSchema(ctx, key, res)
+	if err != nil {
+		s.debugResponse(fmt.Sprintf("schema subject '%v'", key), res.raw)
+		return err
+	}
+
+	s.cacheMut.Lock()
+	prevID := c.id
+	c.encoder = encoder
+	c.id = res.ID
+	c.fingerprint = fingerprint
+	c.schemaType = res.SchemaType
+	atomic.StoreInt64(&c.lastUpdatedUnixSeconds, s.nowFn().Unix())
+	s.releaseCodec(key.url, prevID)
+	s.cacheMut.Unlock()
+	return nil
+}
+
+// recordDrift counts the result of encoding a message with the schema of a
+// subject when schema drift is detected, and returns the error of the result.
+func (s *schemaRegistryEncoder) recordDrift(key schemaKey, err error) error {
+	if s.schemaDrift != nil {
+		s.schemaDrift.record(key.String(), err != nil)
+	}
+	return err
+}
+
+// checkDrift reports schema drift of a subject when the proportion of messages
+// that failed to encode with its cached schema exceeds the threshold.
+func (s *schemaRegistryEncoder) checkDrift(key schemaKey, c *cachedSchemaEncoder) {
+	if s.schemaDrift == nil {
+		return
+	}
+	counts, drifted := s.schemaDrift.check(key.String())
+	if !drifted {
+		return
+	}
+	s.cacheMut.RLock()
+	id := c.id
+	s.cacheMut.RUnlock()
+
+	s.logger.Warnf(
+		"Schema drift detected for subject '%v', %v of %v messages failed to encode with schema %v, exceeding the threshold of %v%%",
+		key, counts.failed, counts.total, id, s.schemaDrift.threshold*100,
+	)
+	s.mSchemaDrift.Incr(1, s.subjectLabels.label(key.subject))
+}
+
+// fetchLatestSchema requests the latest schema of a subject from the schema
+// registry, or the pinned version of the schema when one is configured.
+func (s *schemaRegistryEncoder) fetchLatestSchema(ctx context.Context, key schemaKey) (*schemaResponse, error) {
+	resBytes, err := s.doRequest(ctx, key.url, fmt.Sprintf("/subjects/%s/versions/%s", key.subject, s.schemaVersion), fmt.Sprintf("schema subject '%v'", key))
+	if err != nil {
+		return nil, err
+	}
+
+	var resPayload schemaResponse
+	if err = json.Unmarshal(resBytes, &resPayload); err != nil {
+		s.logger.Errorf("failed to parse response for schema subject '%v': %v", key, err)
+		s.debugResponse(fmt.Sprintf("schema subject '%v'", key), resBytes)
+		return nil, err
+	}
+	resPayload.raw = resBytes
+	return &resPayload, nil
+}
+
+// debugResponse logs the raw response of a request to the schema registry that
+// failed or could not be used when debug_responses is enabled.
+func (s *schemaRegistryEncoder) debugResponse(what string, resBytes []byte) {
+	if s.debugResponses {
+		s.logger.Debugf("Response for %v: %s", what, resBytes)
+	}
+}
+
+// listSubjects requests the names of all subjects of the schema registry.
+func (s *schemaRegistryEncoder) listSubjects(ctx context.Context) ([]string, error) {
+	resBytes, err := s.doRequest(ctx, "", "/subjects", "schema subjects")
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects []string
+	if err = json.Unmarshal(resBytes, &subjects); err != nil {
+		s.logger.Errorf("failed to parse response for schema subjects: %v", err)
+		return nil, err
+	}
+	return subjects, nil
+}
+
+// doRequest performs a GET request against the given path of a schema
+// registry, where an empty registry URL refers to the static url, retrying on
+// failure, and returns the response body. The what argument describes the
+// requested resource for logs and errors.
+func (s *schemaRegistryEncoder) doRequest(ctx context.Context, registryURL, reqPath, what string) ([]byte, error) {
+	baseURL, err := s.registryBaseURL(registryURL)
+	if err != nil {
+		return nil, err
+	}
+	reqURL := *baseURL
+	reqURL.Path = path.Join(reqURL.Path, reqPath)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), http.NoBody)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	req.Header.Add("Accept", "application/vnd.schemaregistry.v1+json")
+	s.basicAuth.sign(req)
+
+	boff := backoff.NewExponentialBackOff()
+	boff.InitialInterval = time.Millisecond * 100
+	boff.MaxInterval = time.Second
+	boff.MaxElapsedTime = 0
 
 	var resBytes []byte
-	for i := 0; i < 3; i++ {
-		var res *http.Response
-		if res, err = s.client.Do(req); err != nil {
-			s.logger.Errorf("request failed for schema subject '%v': %v", subject, err)
-			continue
+	for i := 0; i <= s.maxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(boff.NextBackOff()):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 
-		if res.StatusCode == http.StatusNotFound {
-			err = fmt.Errorf("schema subject '%v' not found by registry", subject)
-			s.logger.Errorf(err.Error())
+		var retry bool
+		if resBytes, retry, err = s.doRequestAttempt(ctx, req, what); err == nil || !retry {
 			break
 		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resBytes, nil
+}
 
-		if res.StatusCode != http.StatusOK {
-			err = fmt.Errorf("request failed for schema subject '%v'", subject)
-			s.logger.Errorf(err.Error())
-			// TODO: Best attempt at parsing out the body
-			continue
-		}
+// doRequestAttempt makes a single attempt of a request to the schema registry
+// within the request timeout, and returns whether a failed attempt should be
+// retried.
+func (s *schemaRegistryEncoder) doRequestAttempt(ctx context.Context, req *http.Request, what string) ([]byte, bool, error) {
+	ctx, done := context.WithTimeout(ctx, s.requestTimeout)
+	defer done()
 
-		if res.Body == nil {
-			s.logger.Errorf("request for schema subject latest '%v' returned an empty body", subject)
-			err = errors.New("schema request returned an empty body")
-			continue
-		}
+	res, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		s.logger.Errorf("request failed for %v: %v", what, err)
+		return nil, true, err
+	}
+	defer res.Body.Close()
 
-		resBytes, err = io.ReadAll(res.Body)
-		res.Body.Close()
-		if err != nil {
-			s.logger.Errorf("failed to read response for schema subject '%v': %v", subject, err)
-			continue
-		}
+	resBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		s.logger.Errorf("failed to read response for %v: %v", what, err)
+		return nil, true, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		err = fmt.Errorf("%v not found by registry: %w", what, registryResponseError(res.StatusCode, resBytes))
+		s.logger.Errorf(err.Error())
+		s.debugResponse(what, resBytes)
+		return nil, false, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("request failed for %v: %w", what, registryResponseError(res.StatusCode, resBytes))
+		s.logger.Errorf(err.Error())
+		s.debugResponse(what, resBytes)
+		return nil, true, err
+	}
+	return resBytes, false, nil
+}
 
-		break
+// loadLocalSchema reads and compiles the schema of a local file, which is then
+// used to encode all messages regardless of their subject.
+func (s *schemaRegistryEncoder) loadLocalSchema(schemaPath string, id int) error {
+	schemaBytes, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return fmt.Errorf("failed to read schema_path: %w", err)
 	}
+	encoder, fingerprint, err := s.newEncoder("", string(schemaBytes), id)
 	if err != nil {
-		return nil, 0, err
+		return fmt.Errorf("failed to parse schema from schema_path: %w", err)
 	}
+	s.cacheMut.Lock()
+	s.localSchema = &cachedSchemaEncoder{
+		id:          id,
+		fingerprint: fingerprint,
+		encoder:     encoder,
+	}
+	s.cacheMut.Unlock()
+	return nil
+}
 
-	resPayload := struct {
-		Schema string `json:"schema"`
-		ID     int    `json:"id"`
-	}{}
-	if err = json.Unmarshal(resBytes, &resPayload); err != nil {
-		s.logger.Errorf("failed to parse response for schema subject '%v': %v", subject, err)
-		return nil, 0, err
+// watchLocalSchema reloads the schema of a local file each time it is written
+// to until the processor is closed. The parent directory is watched rather
+// than the file itself so that files replaced by a rename are also detected.
+func (s *schemaRegistryEncoder) watchLocalSchema(schemaPath string, id int) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create schema_path watcher: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(schemaPath)); err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("failed to watch schema_path: %w", err)
 	}
 
-	var codec *goavro.Codec
-	if codec, err = goavro.NewCodecForStandardJSON(resPayload.Schema); err != nil {
-		s.logger.Errorf("failed to parse response for schema subject '%v': %v", subject, err)
+	cleanPath := filepath.Clean(schemaPath)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != cleanPath ||
+					event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if err := s.loadLocalSchema(schemaPath, id); err != nil {
+					s.logger.Errorf("Failed to reload schema from '%v', continuing with the previous schema: %v", schemaPath, err)
+				} else {
+					s.logger.Infof("Reloaded schema from '%v'", schemaPath)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				s.logger.Errorf("Schema watcher error: %v", err)
+			case <-s.shutSig.CloseAtLeisureChan():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// newEncoder compiles a schema and returns an encoder for it along with its
+// fingerprint.
+func (s *schemaRegistryEncoder) newEncoder(registryURL, schema string, id int) (schemaEncoder, uint64, error) {
+	codec, err := s.compileCodec(registryURL, schema, id)
+	if err != nil {
 		return nil, 0, err
 	}
 
+	var enums *enumSubstituter
+	if s.unknownEnumSymbols == "default_symbol" {
+		if enums, err = newEnumSubstituter(schema, s.defaultEnumSymbol); err != nil {
+			s.releaseCodec(registryURL, id)
+			return nil, 0, fmt.Errorf("failed to parse schema enums: %w", err)
+		}
+	}
+
+	var logicalTypes *logicalTypeConverter
+	if s.convertLogicalTypes {
+		if logicalTypes, err = newLogicalTypeConverter(schema); err != nil {
+			s.releaseCodec(registryURL, id)
+			return nil, 0, fmt.Errorf("failed to parse schema logical types: %w", err)
+		}
+	}
+
 	return func(m *service.Message) error {
+		if s.emptyMessages == "encode_null" {
+			b, err := m.AsBytes()
+			if err != nil {
+				return err
+			}
+			if len(b) == 0 {
+				binary, err := codec.BinaryFromNative(nil, nil)
+				if err != nil {
+					return fmt.Errorf("unable to encode empty message as null, the schema must be a union that includes null: %w", err)
+				}
+				m.SetBytes(binary)
+				return nil
+			}
+		}
+
+		if s.arrayRecordsPrefix != nil {
+			return s.encodeArrayRecords(codec, enums, logicalTypes, m)
+		}
+
+		rawJSON, err := s.useRawJSON(m)
+		if err != nil {
+			return err
+		}
+
 		var datum interface{}
-		if s.avroRawJSON {
+		if rawJSON {
 			b, err := m.AsBytes()
 			if err != nil {
 				return err
 			}
 
+			if b, err = s.substituteEnumsJSON(enums, b); err != nil {
+				return err
+			}
+			if b, err = logicalTypes.convertJSON(b); err != nil {
+				return err
+			}
 			if datum, _, err = codec.NativeFromTextual(b); err != nil {
 				return err
 			}
-		} else if datum, err = m.AsStructured(); err != nil {
-			return err
+		} else {
+			v, err := m.AsStructured()
+			if err != nil {
+				return err
+			}
+			datum = s.fieldRenames.apply(resolveJSONNumbers(v))
+			if datum, err = s.substituteEnums(enums, datum); err != nil {
+				return err
+			}
+			if datum, err = logicalTypes.convert(datum); err != nil {
+				return err
+			}
 		}
 
 		binary, err := codec.BinaryFromNative(nil, datum)
@@ -375,17 +2010,213 @@ func (s *schemaRegistryEncoder) getLatestEncoder(subject string) (schemaEncoder,
 
 		m.SetBytes(binary)
 		return nil
-	}, resPayload.ID, nil
+	}, codec.Rabin, nil
+}
+
+// newEncoderForSchema compiles an encoder for a schema returned by the schema
+// registry according to the type of the schema.
+func (s *schemaRegistryEncoder) newEncoderForSchema(ctx context.Context, key schemaKey, res *schemaResponse) (schemaEncoder, uint64, error) {
+	switch schemaType := res.normalizedSchemaType(); schemaType {
+	case "AVRO":
+		return s.newEncoder(key.url, res.Schema, res.ID)
+	case "PROTOBUF":
+		encoder, err := s.newProtobufEncoder(ctx, key.url, res)
+		return encoder, 0, err
+	case "JSON":
+		encoder, err := s.newJSONSchemaEncoder(ctx, key.url, res)
+		return encoder, 0, err
+	default:
+		return nil, 0, fmt.Errorf("schema subject '%v' has type %v, which is not supported", key, schemaType)
+	}
+}
+
+// checkAvroOnlyOptions returns an error if the processor is configured with
+// options that can only be used with Avro schemas.
+func (s *schemaRegistryEncoder) checkAvroOnlyOptions() error {
+	for _, f := range s.framings {
+		if f.name != confluentFraming.name {
+			return fmt.Errorf("framing '%v' is only supported for Avro schemas", f.name)
+		}
+	}
+	if s.arrayRecordsPrefix != nil {
+		return errors.New("array_records is only supported for Avro schemas")
+	}
+	if s.batchRecordsPrefix != nil {
+		return errors.New("batch_records is only supported for Avro schemas")
+	}
+	return nil
+}
+
+// fetchSchemaReferences fetches the schemas referenced by a schema, and the
+// schemas they reference in turn, from the same schema registry into a map of
+// reference names to schemas.
+func (s *schemaRegistryEncoder) fetchSchemaReferences(ctx context.Context, registryURL string, refs []schemaReference, schemas map[string]string) error {
+	for _, ref := range refs {
+		if _, exists := schemas[ref.Name]; exists {
+			continue
+		}
+
+		what := fmt.Sprintf("version %v of schema subject '%v'", ref.Version, ref.Subject)
+		resBytes, err := s.doRequest(ctx, registryURL, fmt.Sprintf("/subjects/%s/versions/%v", ref.Subject, ref.Version), what)
+		if err != nil {
+			return fmt.Errorf("failed to fetch schema reference '%v': %w", ref.Name, err)
+		}
+
+		var refRes schemaResponse
+		if err := json.Unmarshal(resBytes, &refRes); err != nil {
+			return fmt.Errorf("failed to parse response for %v: %w", what, err)
+		}
+		schemas[ref.Name] = refRes.Schema
+
+		if err := s.fetchSchemaReferences(ctx, registryURL, refRes.References, schemas); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileCodec compiles the schema of an ID, or obtains its compiled codec
+// from the shared codec cache when one is configured, in which case the codec
+// must be released with releaseCodec once it is no longer used.
+func (s *schemaRegistryEncoder) compileCodec(registryURL, schema string, id int) (*goavro.Codec, error) {
+	if s.codecs == nil {
+		return s.newCodec(schema)
+	}
+	return s.codecs.acquire(s.codecKey(registryURL, id), func() (*goavro.Codec, error) {
+		return s.newCodec(schema)
+	})
+}
+
+// releaseCodec releases the codec of a schema ID obtained from the shared codec
+// cache, if one is configured.
+func (s *schemaRegistryEncoder) releaseCodec(registryURL string, id int) {
+	if s.codecs != nil {
+		s.codecs.release(s.codecKey(registryURL, id))
+	}
+}
+
+// codecKey returns the key of a schema ID within the shared codec cache, where
+// schema IDs are scoped by the registry they were fetched from.
+func (s *schemaRegistryEncoder) codecKey(registryURL string, id int) codecCacheKey {
+	if registryURL == "" {
+		registryURL = s.schemaRegistryBaseURL.String()
+	}
+	return codecCacheKey{
+		url:  registryURL,
+		id:   id,
+		mode: s.codecMode,
+	}
+}
+
+// revalidateEncoder fetches the latest schema of a cached subject in the
+// background when its cached schema is older than the revalidation period,
+// unless a revalidation of the subject is already in progress or was attempted
+// within the period. Revalidations hold the request lock, and are therefore
+// serialized with passive refreshes and cache misses.
+func (s *schemaRegistryEncoder) revalidateEncoder(key schemaKey, c *cachedSchemaEncoder) {
+	if s.revalidateAfter <= 0 {
+		return
+	}
+	targetTime := s.nowFn().Add(-s.revalidateAfter).Unix()
+	if atomic.LoadInt64(&c.lastUpdatedUnixSeconds) >= targetTime ||
+		atomic.LoadInt64(&c.lastRevalidatedUnixSeconds) >= targetTime {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&c.revalidating, 0, 1) {
+		return
+	}
+	atomic.StoreInt64(&c.lastRevalidatedUnixSeconds, s.nowFn().Unix())
+
+	go func() {
+		defer atomic.StoreInt32(&c.revalidating, 0)
+
+		ctx, done := s.shutSig.CloseAtLeisureCtx(context.Background())
+		defer done()
+
+		s.requestMut.Lock()
+		defer s.requestMut.Unlock()
+
+		// A passive refresh might've beaten us to it whilst waiting for the
+		// request lock, or the subject might've since been purged.
+		s.cacheMut.RLock()
+		current := s.schemas[key]
+		s.cacheMut.RUnlock()
+		if current != c || atomic.LoadInt64(&c.lastUpdatedUnixSeconds) >= targetTime {
+			return
+		}
+
+		if err := s.refreshEncoder(ctx, key, c); err != nil {
+			s.logger.Errorf("Failed to revalidate schema subject '%v', the cached schema will continue to be used: %v", key, err)
+		}
+	}()
+}
+
+// subjectFetchError is the failure to fetch the schema of a subject.
+type subjectFetchError struct {
+	subject string
+	err     error
+}
+
+// warmupEncoders fetches and caches the latest schemas of a list of subjects.
+// A subject that fails to be fetched does not prevent the remaining subjects
+// from being fetched, and the failures are returned so that the subjects that
+// were cached can be used regardless.
+func (s *schemaRegistryEncoder) warmupEncoders(ctx context.Context, subjects []string) []subjectFetchError {
+	ctx, done := s.shutSig.CloseAtLeisureCtx(ctx)
+	defer done()
+
+	var failures []subjectFetchError
+	for _, subject := range subjects {
+		if _, _, _, err := s.getEncoder(ctx, schemaKey{subject: subject}); err != nil {
+			failures = append(failures, subjectFetchError{subject: subject, err: err})
+		}
+	}
+	return failures
+}
+
+// preloadEncoders lists all subjects of the schema registry and caches the
+// latest schema of each of them. When fatal is true the first failure is
+// returned, otherwise failures are logged and counted.
+func (s *schemaRegistryEncoder) preloadEncoders(ctx context.Context, fatal bool, mError *service.MetricCounter) error {
+	subjects, err := s.listSubjects(ctx)
+	if err != nil {
+		if fatal {
+			return fmt.Errorf("failed to list schema subjects for preloading: %w", err)
+		}
+		s.logger.Warnf("Failed to list schema subjects for preloading, schemas will be fetched when first required: %v", err)
+		mError.Incr(1)
+		return nil
+	}
+
+	failures := s.warmupEncoders(ctx, subjects)
+	if fatal && len(failures) > 0 {
+		return fmt.Errorf("failed to preload schema subject '%v': %w", failures[0].subject, failures[0].err)
+	}
+	for _, f := range failures {
+		s.logger.Warnf("Failed to preload schema subject '%v', it will be fetched when first required: %v", f.subject, f.err)
+	}
+	mError.Incr(int64(len(failures)))
+	s.logger.Infof("Preloaded %v of %v schema subjects", len(subjects)-len(failures), len(subjects))
+	return nil
 }
 
-func (s *schemaRegistryEncoder) getEncoder(subject string) (schemaEncoder, int, error) {
+func (s *schemaRegistryEncoder) getEncoder(ctx context.Context, key schemaKey) (schemaEncoder, int, uint64, error) {
 	s.cacheMut.RLock()
-	c, ok := s.schemas[subject]
-	s.cacheMut.RUnlock()
-	if ok {
+	if l := s.localSchema; l != nil {
+		s.cacheMut.RUnlock()
+		return l.encoder, l.id, l.fingerprint, nil
+	}
+	if c, ok := s.schemas[key]; ok {
+		// Cached schemas are updated in place by refreshes and revalidations,
+		// and are therefore read whilst within the cache lock.
+		encoder, id, fingerprint := c.encoder, c.id, c.fingerprint
+		s.cacheMut.RUnlock()
 		atomic.StoreInt64(&c.lastUsedUnixSeconds, s.nowFn().Unix())
-		return c.encoder, c.id, nil
+		s.mCacheHit.Incr(1)
+		s.revalidateEncoder(key, c)
+		return encoder, id, fingerprint, nil
 	}
+	s.cacheMut.RUnlock()
 
 	s.requestMut.Lock()
 	defer s.requestMut.Unlock()
@@ -393,26 +2224,27 @@ func (s *schemaRegistryEncoder) getEncoder(subject string) (schemaEncoder, int,
 	// We might've been beaten to making the request, so check once more whilst
 	// within the request lock.
 	s.cacheMut.RLock()
-	c, ok = s.schemas[subject]
-	s.cacheMut.RUnlock()
-	if ok {
+	if c, ok := s.schemas[key]; ok {
+		encoder, id, fingerprint := c.encoder, c.id, c.fingerprint
+		s.cacheMut.RUnlock()
 		atomic.StoreInt64(&c.lastUsedUnixSeconds, s.nowFn().Unix())
-		return c.encoder, c.id, nil
+		s.mCacheHit.Incr(1)
+		return encoder, id, fingerprint, nil
 	}
+	s.cacheMut.RUnlock()
 
-	encoder, id, err := s.getLatestEncoder(subject)
+	s.mCacheMiss.Incr(1)
+	c, err := s.getLatestEncoder(ctx, key)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
+	atomic.StoreInt64(&c.lastUsedUnixSeconds, s.nowFn().Unix())
+	atomic.StoreInt64(&c.lastUpdatedUnixSeconds, s.nowFn().Unix())
 
 	s.cacheMut.Lock()
-	s.schemas[subject] = &cachedSchemaEncoder{
-		lastUsedUnixSeconds:    s.nowFn().Unix(),
-		lastUpdatedUnixSeconds: s.nowFn().Unix(),
-		id:                     id,
-		encoder:                encoder,
-	}
+	s.schemas[key] = c
+	s.mCachedSubjects.Set(int64(len(s.schemas)))
 	s.cacheMut.Unlock()
 
-	return encoder, id, nil
+	return c.encoder, c.id, c.fingerprint, nil
 }
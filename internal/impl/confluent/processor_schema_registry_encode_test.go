@@ -1,17 +1,31 @@
 package confluent
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/linkedin/goavro/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/benthosdev/benthos/v4/internal/component/metrics"
+	"github.com/benthosdev/benthos/v4/internal/component/processor"
+	"github.com/benthosdev/benthos/v4/internal/log"
+	"github.com/benthosdev/benthos/v4/internal/manager/mock"
 	"github.com/benthosdev/benthos/v4/public/service"
 )
 
@@ -55,6 +69,44 @@ refresh_period: not a duration
 `,
 			errContains: "invalid duration",
 		},
+		{
+			name: "bad revalidate after",
+			config: `
+url: http://example.com
+subject: foo
+revalidate_after: not a duration
+`,
+			errContains: "failed to parse revalidate_after",
+		},
+		{
+			name: "field mapping with raw json",
+			config: `
+url: http://example.com
+subject: foo
+avro_raw_json: true
+field_mapping:
+  foo: bar
+`,
+			errContains: "field_mapping cannot be used along with avro_raw_json",
+		},
+		{
+			name: "refresh disabled",
+			config: `
+url: http://example.com
+subject: foo
+refresh_period: 0s
+`,
+			expectedBaseURL: "http://example.com",
+		},
+		{
+			name: "period beyond purge window",
+			config: `
+url: http://example.com
+subject: foo
+refresh_period: 1h
+`,
+			expectedBaseURL: "http://example.com",
+		},
 		{
 			name: "url with base path",
 			config: `
@@ -63,6 +115,295 @@ subject: foo
 `,
 			expectedBaseURL: "http://example.com/v1",
 		},
+		{
+			name: "bad framing",
+			config: `
+url: http://example.com
+subject: foo
+framings: [ confluent, nope ]
+`,
+			errContains: "framing 'nope' not recognised",
+		},
+		{
+			name: "bad fallback subject",
+			config: `
+url: http://example.com
+subject: foo
+fallback_subjects: [ bar, "${! bad interpolation }" ]
+`,
+			errContains: `failed to parse interpolated field`,
+		},
+		{
+			name: "bad subject suffix",
+			config: `
+url: http://example.com
+subject: foo
+subject_suffix: nope
+`,
+			errContains: "subject_suffix option 'nope' not recognised",
+		},
+		{
+			name: "bad pre encode",
+			config: `
+url: http://example.com
+subject: foo
+pre_encode: 'root = this.'
+`,
+			errContains: "failed to parse bloblang mapping 'pre_encode'",
+		},
+		{
+			name: "bad preload failures",
+			config: `
+url: http://example.com
+subject: foo
+preload_failures: nope
+`,
+			errContains: "preload_failures option 'nope' not recognised",
+		},
+		{
+			name: "preload all with schema path",
+			config: `
+schema_path: ./foo.avsc
+subject: foo
+preload_all: true
+`,
+			errContains: "preload_all cannot be combined with a schema_path",
+		},
+		{
+			name: "bad version",
+			config: `
+url: http://example.com
+subject: foo
+version: nope
+`,
+			errContains: "version must be either latest or a positive integer, got 'nope'",
+		},
+		{
+			name: "zero version",
+			config: `
+url: http://example.com
+subject: foo
+version: 0
+`,
+			errContains: "version must be either latest or a positive integer, got '0'",
+		},
+		{
+			name: "preload all with pinned version",
+			config: `
+url: http://example.com
+subject: foo
+version: 3
+preload_all: true
+`,
+			errContains: "preload_all cannot be combined with a pinned version",
+		},
+		{
+			name: "shared codec cache with schema path",
+			config: `
+schema_path: ./foo.avsc
+subject: foo
+shared_codec_cache: foo
+`,
+			errContains: "shared_codec_cache cannot be combined with a schema_path",
+		},
+		{
+			name: "bad unknown enum symbols",
+			config: `
+url: http://example.com
+subject: foo
+unknown_enum_symbols: nope
+`,
+			errContains: "unknown_enum_symbols option 'nope' not recognised",
+		},
+		{
+			name: "bad empty messages",
+			config: `
+url: http://example.com
+subject: foo
+empty_messages: nope
+`,
+			errContains: "empty_messages option 'nope' not recognised",
+		},
+		{
+			name: "bad on_error",
+			config: `
+url: http://example.com
+subject: foo
+on_error: nope
+`,
+			errContains: "on_error option 'nope' not recognised",
+		},
+		{
+			name: "bad array records",
+			config: `
+url: http://example.com
+subject: foo
+array_records: nope
+`,
+			errContains: "array_records option 'nope' not recognised",
+		},
+		{
+			name: "batch_records with array_records",
+			config: `
+url: http://example.com
+subject: foo
+array_records: concatenated
+batch_records: concatenated
+`,
+			errContains: "batch_records cannot be enabled along with array_records",
+		},
+		{
+			name: "no url or schema path",
+			config: `
+subject: foo
+`,
+			errContains: "either a url or a schema_path must be specified",
+		},
+		{
+			name: "url and schema path",
+			config: `
+url: http://example.com
+schema_path: ./foo.avsc
+subject: foo
+`,
+			errContains: "cannot specify both a url and a schema_path",
+		},
+		{
+			name: "missing schema path",
+			config: `
+schema_path: ./does/not/exist.avsc
+subject: foo
+`,
+			errContains: "failed to read schema_path",
+		},
+		{
+			name: "debug responses",
+			config: `
+url: http://example.com
+subject: foo
+debug_responses: true
+`,
+			expectedBaseURL: "http://example.com",
+		},
+		{
+			name: "plain codec json mode",
+			config: `
+url: http://example.com
+subject: foo
+codec_json_mode: plain
+`,
+			expectedBaseURL: "http://example.com",
+		},
+		{
+			name: "negative max message size",
+			config: `
+url: http://example.com
+subject: foo
+max_message_size: -1
+`,
+			errContains: "max_message_size must not be negative",
+		},
+		{
+			name: "log failures",
+			config: `
+url: http://example.com
+subject: foo
+log_failures:
+  enabled: true
+  interval: 1m
+  max_bytes: 64
+`,
+			expectedBaseURL: "http://example.com",
+		},
+		{
+			name: "log failures bad max bytes",
+			config: `
+url: http://example.com
+subject: foo
+log_failures:
+  enabled: true
+  max_bytes: 0
+`,
+			errContains: "log_failures max_bytes must be greater than zero",
+		},
+		{
+			name: "negative max metric subjects",
+			config: `
+url: http://example.com
+subject: foo
+max_metric_subjects: -1
+`,
+			errContains: "max_metric_subjects must not be negative",
+		},
+		{
+			name: "zero request timeout",
+			config: `
+url: http://example.com
+subject: foo
+request_timeout: 0s
+`,
+			errContains: "request_timeout must be greater than zero",
+		},
+		{
+			name: "negative max retries",
+			config: `
+url: http://example.com
+subject: foo
+max_retries: -1
+`,
+			errContains: "max_retries must not be negative",
+		},
+		{
+			name: "no framings",
+			config: `
+url: http://example.com
+subject: foo
+framings: []
+`,
+			errContains: "at least one framing must be specified",
+		},
+		{
+			name: "interpolated url",
+			config: `
+url: ${! meta("registry") }
+subject: foo
+`,
+			expectedBaseURL: "",
+		},
+		{
+			name: "escaped interpolation in url",
+			config: `
+url: http://example.com/${{!foo}}
+subject: foo
+`,
+			expectedBaseURL: "http://example.com/$%7B%21foo%7D",
+		},
+		{
+			name: "bad interpolated url",
+			config: `
+url: ${! bad interpolation }
+subject: foo
+`,
+			errContains: `failed to parse interpolated field`,
+		},
+		{
+			name: "interpolated url with warmup subjects",
+			config: `
+url: ${! meta("registry") }
+subject: foo
+warmup_subjects: [ foo ]
+`,
+			errContains: "an interpolated url cannot be combined with warmup_subjects or preload_all",
+		},
+		{
+			name: "empty warmup subject",
+			config: `
+url: http://example.com
+subject: foo
+warmup_subjects: [ foo, "" ]
+`,
+			errContains: "warmup_subjects must not contain an empty subject",
+		},
 	}
 
 	spec := schemaRegistryEncoderConfig()
@@ -72,7 +413,7 @@ subject: foo
 			conf, err := spec.ParseYAML(test.config, env)
 			require.NoError(t, err)
 
-			e, err := newSchemaRegistryEncoderFromConfig(conf, nil)
+			e, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
 
 			if e != nil {
 				assert.Equal(t, test.expectedBaseURL, e.schemaRegistryBaseURL.String())
@@ -91,62 +432,2642 @@ subject: foo
 	}
 }
 
-func TestSchemaRegistryEncodeAvroRawJSON(t *testing.T) {
-	fooFirst, err := json.Marshal(struct {
+// schemaResponseBody returns the body of a schema registry response for a
+// schema with an ID.
+func schemaResponseBody(t testing.TB, schema string, id int) []byte {
+	t.Helper()
+
+	b, err := json.Marshal(struct {
 		Schema string `json:"schema"`
 		ID     int    `json:"id"`
 	}{
-		Schema: testSchema,
-		ID:     3,
+		Schema: schema,
+		ID:     id,
+	})
+	require.NoError(t, err)
+	return b
+}
+
+var (
+	registerResourcesCapture sync.Once
+	capturedResources        = make(chan *service.Resources, 1)
+)
+
+// mockResourcesFromManager returns resources like service.MockResources, but
+// backed by a mock manager, which allows tests to inspect the metrics and logs
+// of a component. The resources are captured from a processor plugin that is
+// only registered in order to obtain them.
+func mockResourcesFromManager(t testing.TB, mgr *mock.Manager) *service.Resources {
+	t.Helper()
+
+	registerResourcesCapture.Do(func() {
+		require.NoError(t, service.RegisterBatchProcessor(
+			"schema_registry_encode_test_resources", service.NewConfigSpec(),
+			func(conf *service.ParsedConfig, res *service.Resources) (service.BatchProcessor, error) {
+				capturedResources <- res
+				return nil, errors.New("resources captured")
+			},
+		))
+	})
+
+	conf := processor.NewConfig()
+	conf.Type = "schema_registry_encode_test_resources"
+	_, _ = mgr.NewProcessor(conf)
+
+	select {
+	case res := <-capturedResources:
+		return res
+	default:
+		t.Fatal("resources were not captured")
+	}
+	return nil
+}
+
+// mockResourcesWithMetrics returns resources with metrics registered with a
+// local registry that is returned alongside them.
+func mockResourcesWithMetrics(t testing.TB) (*service.Resources, *metrics.Local) {
+	t.Helper()
+
+	stats := metrics.NewLocal()
+	mgr := mock.NewManager()
+	mgr.M = stats
+	return mockResourcesFromManager(t, mgr), stats
+}
+
+func TestSchemaRegistryEncoderRefreshPeriodWarning(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		warns  bool
+	}{
+		{
+			name: "default period",
+			config: `
+url: http://example.com
+subject: foo
+`,
+		},
+		{
+			name: "period beyond purge window",
+			config: `
+url: http://example.com
+subject: foo
+refresh_period: 1h
+`,
+			warns: true,
+		},
+		{
+			name: "period beyond purge window with pinned version",
+			config: `
+url: http://example.com
+subject: foo
+refresh_period: 1h
+version: 3
+`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			lConf := log.NewConfig()
+			lConf.LogLevel = "WARN"
+
+			var buf bytes.Buffer
+			logger, err := log.NewV2(&buf, lConf)
+			require.NoError(t, err)
+
+			mgr := mock.NewManager()
+			mgr.L = logger
+			res := mockResourcesFromManager(t, mgr)
+
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(test.config, nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, res.Logger(), nil)
+			require.NoError(t, err)
+			require.NoError(t, encoder.Close(context.Background()))
+
+			if test.warns {
+				assert.Contains(t, buf.String(), "The refresh_period of 1h0m0s is greater than the period of 10m0s after which unused schemas are purged")
+			} else {
+				assert.NotContains(t, buf.String(), "refresh_period")
+			}
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeAvroRawJSON(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, true, time.Minute*10, time.Minute, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		input       string
+		output      string
+		errContains string
+	}{
+		{
+			name:   "successful message",
+			input:  `{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":"dancing"}`,
+			output: "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x02\x0edancing",
+		},
+		{
+			name:   "successful message null hobby",
+			input:  `{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":null}`,
+			output: "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00",
+		},
+		{
+			name:        "message doesnt match schema",
+			input:       `{"Address":{"City":"foo","State":30},"Name":"foo","MaybeHobby":null}`,
+			errContains: "could not decode any json data in input",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			outBatches, err := encoder.ProcessBatch(
+				context.Background(),
+				service.MessageBatch{service.NewMessage([]byte(test.input))},
+			)
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+			} else {
+				require.NoError(t, err)
+
+				b, err := outBatches[0][0].AsBytes()
+				require.NoError(t, err)
+				assert.Equal(t, test.output, string(b))
+			}
+		})
+	}
+
+	require.NoError(t, encoder.Close(context.Background()))
+	encoder.cacheMut.Lock()
+	assert.Len(t, encoder.schemas, 0)
+	encoder.cacheMut.Unlock()
+}
+
+func TestSchemaRegistryEncodeAvroRawJSONOverride(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, time.Minute, nil)
+	require.NoError(t, err)
+
+	encoder.avroRawJSONOverride, err = service.NewInterpolatedString(`${! meta("raw_json").or("") }`)
+	require.NoError(t, err)
+
+	var batch service.MessageBatch
+	for _, m := range []struct {
+		rawJSON string
+		input   string
+	}{
+		{rawJSON: "true", input: `{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":null}`},
+		{rawJSON: "false", input: `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`},
+		{input: `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`},
+		{rawJSON: "nope", input: `{}`},
+	} {
+		msg := service.NewMessage([]byte(m.input))
+		if m.rawJSON != "" {
+			msg.MetaSet("raw_json", m.rawJSON)
+		}
+		batch = append(batch, msg)
+	}
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 4)
+
+	for i, msg := range outBatches[0][:3] {
+		require.NoError(t, msg.GetError(), i)
+		b, err := msg.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00", string(b), i)
+	}
+	assert.EqualError(t, outBatches[0][3].GetError(), "avro_raw_json_override must resolve to true or false, got 'nope'")
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeCodecJSONMode(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	standardInput := `{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":"dancing"}`
+	plainInput := `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":{"string":"dancing"}}`
+	output := "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x02\x0edancing"
+
+	tests := []struct {
+		name        string
+		mode        string
+		input       string
+		errContains string
+	}{
+		{name: "standard mode standard union", mode: "standard", input: standardInput},
+		{name: "standard mode plain union", mode: "standard", input: plainInput, errContains: "could not decode any json data in input"},
+		{name: "plain mode plain union", mode: "plain", input: plainInput},
+		{name: "plain mode standard union", mode: "plain", input: standardInput, errContains: "cannot decode textual union"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, true, time.Minute*10, 0, nil)
+			require.NoError(t, err)
+			encoder.newCodec, err = codecConstructorForMode(test.mode)
+			require.NoError(t, err)
+
+			outBatches, err := encoder.ProcessBatch(
+				context.Background(),
+				service.MessageBatch{service.NewMessage([]byte(test.input))},
+			)
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+			} else {
+				require.NoError(t, err)
+
+				b, err := outBatches[0][0].AsBytes()
+				require.NoError(t, err)
+				assert.Equal(t, output, string(b))
+			}
+
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeAvro(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, time.Minute, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		input       string
+		output      string
+		errContains string
+	}{
+		{
+			name:   "successful message",
+			input:  `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":{"string":"dancing"}}`,
+			output: "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x02\x0edancing",
+		},
+		{
+			name:   "successful message null hobby",
+			input:  `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`,
+			output: "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00",
+		},
+		{
+			name:        "message doesnt match schema",
+			input:       `{"Address":{"my.namespace.com.address":"not this","Name":"foo"}}`,
+			errContains: "schema does not specify default value",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			outBatches, err := encoder.ProcessBatch(
+				context.Background(),
+				service.MessageBatch{service.NewMessage([]byte(test.input))},
+			)
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+			} else {
+				require.NoError(t, err)
+
+				b, err := outBatches[0][0].AsBytes()
+				require.NoError(t, err)
+				assert.Equal(t, test.output, string(b))
+			}
+		})
+	}
+
+	require.NoError(t, encoder.Close(context.Background()))
+	encoder.cacheMut.Lock()
+	assert.Len(t, encoder.schemas, 0)
+	encoder.cacheMut.Unlock()
+}
+
+func TestSchemaRegistryEncodeFallbackSubjects(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	barFirst := schemaResponseBody(t, `{"type":"record","name":"bar","fields":[{"name":"id","type":"string"}]}`, 4)
+
+	var fooReqs int32
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/subjects/foo/versions/latest":
+			atomic.AddInt32(&fooReqs, 1)
+			return fooFirst, nil
+		case "/subjects/bar/versions/latest":
+			return barFirst, nil
+		}
+		return nil, nil
+	})
+
+	subj, err := service.NewInterpolatedString("missing")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+
+	for _, fallback := range []string{"foo", `${! meta("fallback").or("") }`} {
+		fSubj, err := service.NewInterpolatedString(fallback)
+		require.NoError(t, err)
+		encoder.fallbackSubjects = append(encoder.fallbackSubjects, fSubj)
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		fallback    string
+		output      string
+		errContains string
+	}{
+		{
+			name:     "first fallback",
+			input:    `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`,
+			fallback: "bar",
+			output:   "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00",
+		},
+		{
+			name:     "second fallback",
+			input:    `{"id":"x"}`,
+			fallback: "bar",
+			output:   "\x00\x00\x00\x00\x04\x02x",
+		},
+		{
+			name:        "all subjects fail",
+			input:       `{"nope":true}`,
+			fallback:    "bar",
+			errContains: `record "bar" field "id": schema does not specify default value`,
+		},
+		{
+			name:        "empty fallback skipped",
+			input:       `{"id":"x"}`,
+			errContains: `record "foo.namespace.com.identity" field "Name": schema does not specify default value`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			inMsg := service.NewMessage([]byte(test.input))
+			inMsg.MetaSet("fallback", test.fallback)
+
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{inMsg})
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := outBatches[0][0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, test.output, string(b))
+		})
+	}
+
+	// Each subject is cached individually.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fooReqs))
+	encoder.cacheMut.Lock()
+	assert.Len(t, encoder.schemas, 2)
+	encoder.cacheMut.Unlock()
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeSubjectMap(t *testing.T) {
+	fooFirst := schemaResponseBody(t, `{"type":"record","name":"foo","fields":[{"name":"id","type":"string"}]}`, 3)
+
+	barFirst := schemaResponseBody(t, `{"type":"record","name":"bar","fields":[{"name":"id","type":"string"}]}`, 4)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/subjects/foo/versions/latest":
+			return fooFirst, nil
+		case "/subjects/bar/versions/latest":
+			return barFirst, nil
+		}
+		return nil, nil
+	})
+
+	tests := []struct {
+		name        string
+		fallback    bool
+		key         string
+		output      string
+		errContains string
+	}{
+		{
+			name:   "mapped key",
+			key:    "b",
+			output: "\x00\x00\x00\x00\x04\x02x",
+		},
+		{
+			name:     "unmapped key with fallback",
+			fallback: true,
+			key:      "nope",
+			output:   "\x00\x00\x00\x00\x03\x02x",
+		},
+		{
+			name:        "unmapped key without fallback",
+			key:         "nope",
+			errContains: "subject key 'nope' not found in subject_map",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+subject_map:
+  a: foo
+  b: bar
+subject_key: ${! meta("key") }
+subject_map_fallback: %v
+`, urlStr, test.fallback), nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, encoder.Close(context.Background()))
+			})
+
+			inMsg := service.NewMessage([]byte(`{"id":"x"}`))
+			inMsg.MetaSet("key", test.key)
+
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{inMsg})
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := outBatches[0][0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, test.output, string(b))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeSubjectSuffix(t *testing.T) {
+	valueSchema := schemaResponseBody(t, `{"type":"record","name":"foo","fields":[{"name":"id","type":"string"}]}`, 3)
+
+	keySchema := schemaResponseBody(t, `{"type":"record","name":"foo_key","fields":[{"name":"id","type":"string"}]}`, 4)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/subjects/foo-value/versions/latest":
+			return valueSchema, nil
+		case "/subjects/bar-key/versions/latest":
+			return keySchema, nil
+		case "/subjects/-value/versions/latest", "/subjects/-key/versions/latest":
+			// Only reached when an empty subject is reduced to the suffix.
+			return valueSchema, nil
+		}
+		return nil, nil
+	})
+
+	tests := []struct {
+		name        string
+		config      string
+		output      string
+		errContains string
+	}{
+		{
+			name: "value suffix",
+			config: `
+subject: foo
+subject_suffix: value
+`,
+			output: "\x00\x00\x00\x00\x03\x02x",
+		},
+		{
+			name: "key suffix",
+			config: `
+subject: bar
+subject_suffix: key
+`,
+			output: "\x00\x00\x00\x00\x04\x02x",
+		},
+		{
+			name: "no suffix",
+			config: `
+subject: foo
+`,
+			errContains: "not found",
+		},
+		{
+			name: "mapped subject",
+			config: `
+subject: nope
+subject_map:
+  a: foo
+subject_key: a
+subject_suffix: value
+`,
+			output: "\x00\x00\x00\x00\x03\x02x",
+		},
+		{
+			name: "fallback subject",
+			config: `
+subject: nope
+fallback_subjects: [ bar ]
+subject_suffix: key
+`,
+			output: "\x00\x00\x00\x00\x04\x02x",
+		},
+		{
+			name: "empty subject",
+			config: `
+subject: ${! meta("subject").or("") }
+subject_suffix: value
+`,
+			errContains: "schema subject resolved to an empty string",
+		},
+		{
+			name: "empty subject fallback",
+			config: `
+subject: ${! meta("subject").or("") }
+fallback_subjects: [ bar ]
+subject_suffix: key
+`,
+			output: "\x00\x00\x00\x00\x04\x02x",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf("url: %v\n%v", urlStr, test.config), nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, encoder.Close(context.Background()))
+			})
+
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(`{"id":"x"}`)),
+			})
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := outBatches[0][0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, test.output, string(b))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeArrayRecords(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, nil
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	recordA := "\x06foo\x02\x06foo\x06bar\x02\x0edancing"
+	recordB := "\x06foo\x02\x06foo\x06bar\x00"
+	input := `[
+	{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":{"string":"dancing"}},
+	{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}
+]`
+
+	tests := []struct {
+		name        string
+		prefix      string
+		rawJSON     bool
+		input       string
+		output      string
+		errContains string
+	}{
+		{
+			name:   "concatenated",
+			prefix: "concatenated",
+			input:  input,
+			output: "\x00\x00\x00\x00\x03" + recordA + recordB,
+		},
+		{
+			name:   "varint length prefixed",
+			prefix: "varint_length_prefixed",
+			input:  input,
+			output: "\x00\x00\x00\x00\x03\x2c" + recordA + "\x1c" + recordB,
+		},
+		{
+			name:   "uint32 length prefixed",
+			prefix: "uint32_length_prefixed",
+			input:  input,
+			output: "\x00\x00\x00\x00\x03\x00\x00\x00\x16" + recordA + "\x00\x00\x00\x0e" + recordB,
+		},
+		{
+			name:    "raw json",
+			prefix:  "varint_length_prefixed",
+			rawJSON: true,
+			input: `[
+	{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":"dancing"},
+	{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":null}
+]`,
+			output: "\x00\x00\x00\x00\x03\x2c" + recordA + "\x1c" + recordB,
+		},
+		{
+			name:   "empty array",
+			prefix: "varint_length_prefixed",
+			input:  `[]`,
+			output: "\x00\x00\x00\x00\x03",
+		},
+		{
+			name:        "not an array",
+			prefix:      "concatenated",
+			input:       `{"Name":"foo"}`,
+			errContains: "expected message to be an array",
+		},
+		{
+			name:        "bad element",
+			prefix:      "concatenated",
+			input:       `[{"Address":{"my.namespace.com.address":"not this","Name":"foo"}}]`,
+			errContains: "record 0",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, test.rawJSON, time.Minute*10, 0, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = encoder.Close(context.Background())
+			})
+
+			encoder.arrayRecordsPrefix, err = parseRecordPrefix("array_records", test.prefix)
+			require.NoError(t, err)
+
+			outBatches, err := encoder.ProcessBatch(
+				context.Background(),
+				service.MessageBatch{service.NewMessage([]byte(test.input))},
+			)
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := outBatches[0][0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, test.output, string(b))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeSchemaPath(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "foo.avsc")
+	require.NoError(t, os.WriteFile(schemaPath, []byte(testSchema), 0o644))
+
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+schema_path: %v
+schema_id: 5
+subject: foo
+`, schemaPath), service.NewEnvironment())
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+	require.NoError(t, err)
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 1)
+	require.NoError(t, outBatches[0][0].GetError())
+
+	b, err := outBatches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00\x00\x05\x06foo\x02\x06foo\x06bar\x00", string(b))
+
+	// No schemas are cached from a registry.
+	encoder.cacheMut.Lock()
+	assert.Len(t, encoder.schemas, 0)
+	encoder.cacheMut.Unlock()
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeWatchSchemaPath(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "foo.avsc")
+	require.NoError(t, os.WriteFile(schemaPath, []byte(`{"type":"record","name":"foo","fields":[{"name":"a","type":"string"}]}`), 0o644))
+
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+schema_path: %v
+watch_schema_path: true
+subject: foo
+`, schemaPath), service.NewEnvironment())
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, encoder.Close(context.Background()))
+	})
+
+	encode := func() ([]byte, error) {
+		outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"b":"x"}`)),
+		})
+		require.NoError(t, err)
+		require.Len(t, outBatches, 1)
+		require.Len(t, outBatches[0], 1)
+		if err := outBatches[0][0].GetError(); err != nil {
+			return nil, err
+		}
+		return outBatches[0][0].AsBytes()
+	}
+
+	_, err = encode()
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(schemaPath, []byte(`{"type":"record","name":"foo","fields":[{"name":"b","type":"string"}]}`), 0o644))
+	assert.Eventually(t, func() bool {
+		_, err := encode()
+		return err == nil
+	}, time.Second*5, time.Millisecond*10)
+
+	// A schema that fails to parse does not replace the previous one.
+	require.NoError(t, os.WriteFile(schemaPath, []byte(`not a schema`), 0o644))
+	time.Sleep(time.Millisecond * 100)
+
+	b, err := encode()
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00\x00\x00\x02x", string(b))
+}
+
+func TestSchemaRegistryEncodeAtomic(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, nil
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+	encoder.atomicBatches = true
+
+	goodInput := `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(goodInput)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 1)
+
+	b, err := outBatches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00", string(b))
+
+	inBatch := service.MessageBatch{
+		service.NewMessage([]byte(goodInput)),
+		service.NewMessage([]byte(`{"nope":true}`)),
+		service.NewMessage([]byte(goodInput)),
+	}
+	_, err = encoder.ProcessBatch(context.Background(), inBatch)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to encode message 1 of batch")
+
+	// The input batch is left unchanged.
+	b, err = inBatch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, goodInput, string(b))
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeOnError(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, nil
+	})
+
+	goodInput := `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`
+	goodOutput := "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00"
+
+	tests := []struct {
+		onError string
+		outputs [][]string
+	}{
+		{
+			onError: "set_error",
+			outputs: [][]string{{goodOutput, `{"nope":true}`, goodOutput, `{"nope":false}`}},
+		},
+		{
+			onError: "drop",
+			outputs: [][]string{{goodOutput, goodOutput}},
+		},
+		{
+			onError: "reject",
+			outputs: [][]string{{goodOutput, goodOutput}, {`{"nope":true}`, `{"nope":false}`}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.onError, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+on_error: %v
+`, urlStr, test.onError), nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
+
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(goodInput)),
+				service.NewMessage([]byte(`{"nope":true}`)),
+				service.NewMessage([]byte(goodInput)),
+				service.NewMessage([]byte(`{"nope":false}`)),
+			})
+			require.NoError(t, err)
+			require.Len(t, outBatches, len(test.outputs))
+
+			for i, outputs := range test.outputs {
+				require.Len(t, outBatches[i], len(outputs))
+				for j, output := range outputs {
+					b, err := outBatches[i][j].AsBytes()
+					require.NoError(t, err)
+					assert.Equal(t, output, string(b))
+					if output == goodOutput {
+						assert.NoError(t, outBatches[i][j].GetError())
+					} else {
+						assert.Error(t, outBatches[i][j].GetError())
+					}
+				}
+			}
+
+			// A batch of only failed messages produces no encoded batch.
+			outBatches, err = encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(`{"nope":true}`)),
+			})
+			require.NoError(t, err)
+			if test.onError == "drop" {
+				assert.Empty(t, outBatches)
+			} else {
+				require.Len(t, outBatches, 1)
+				require.Len(t, outBatches[0], 1)
+				assert.Error(t, outBatches[0][0].GetError())
+			}
+
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeMaxMessageSize(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	var requests int32
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		atomic.AddInt32(&requests, 1)
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, nil
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+
+	goodInput := `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`
+	bigInput := `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"` + strings.Repeat("foo", 100) + `","MaybeHobby":null}`
+	encoder.maxMessageSize = len(goodInput)
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(bigInput)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 1)
+	assert.EqualError(t, outBatches[0][0].GetError(), fmt.Sprintf("message size of %v bytes exceeds max_message_size of %v bytes", len(bigInput), len(goodInput)))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+
+	outBatches, err = encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(goodInput)),
+		service.NewMessage([]byte(bigInput)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 2)
+
+	require.NoError(t, outBatches[0][0].GetError())
+	b, err := outBatches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00", string(b))
+
+	require.Error(t, outBatches[0][1].GetError())
+	b, err = outBatches[0][1].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, bigInput, string(b))
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeEmptyMessages(t *testing.T) {
+	nullableSchema := `["null",{"type":"record","name":"foo","fields":[{"name":"Name","type":"string"}]}]`
+
+	var requests int32
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		atomic.AddInt32(&requests, 1)
+		switch path {
+		case "/subjects/foo/versions/latest":
+			return schemaResponseBody(t, testSchema, 3), nil
+		case "/subjects/nullable/versions/latest":
+			return schemaResponseBody(t, nullableSchema, 4), nil
+		}
+		return nil, nil
+	})
+
+	tests := []struct {
+		name          string
+		subject       string
+		emptyMessages string
+		output        string
+		errContains   string
+	}{
+		{
+			name:        "error by default",
+			subject:     "foo",
+			output:      "",
+			errContains: "message is empty",
+		},
+		{
+			name:          "skip",
+			subject:       "foo",
+			emptyMessages: "skip",
+			output:        "",
+		},
+		{
+			name:          "encode null",
+			subject:       "nullable",
+			emptyMessages: "encode_null",
+			output:        "\x00\x00\x00\x00\x04\x00",
+		},
+		{
+			name:          "encode null without nullable union",
+			subject:       "foo",
+			emptyMessages: "encode_null",
+			output:        "",
+			errContains:   "unable to encode empty message as null",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf := fmt.Sprintf(`
+url: %v
+subject: %v
+`, urlStr, test.subject)
+			if test.emptyMessages != "" {
+				conf += fmt.Sprintf("empty_messages: %v\n", test.emptyMessages)
+			}
+			parsed, err := schemaRegistryEncoderConfig().ParseYAML(conf, nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(parsed, nil, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, encoder.Close(context.Background()))
+			})
+
+			atomic.StoreInt32(&requests, 0)
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage(nil),
+			})
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			b, err := outBatches[0][0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, test.output, string(b))
+			if test.errContains != "" {
+				require.Error(t, outBatches[0][0].GetError())
+				assert.Contains(t, outBatches[0][0].GetError().Error(), test.errContains)
+			} else {
+				require.NoError(t, outBatches[0][0].GetError())
+			}
+
+			if test.emptyMessages != "encode_null" {
+				// Empty messages are handled before schemas are fetched.
+				assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+			}
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeWarmup(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	var requests int32
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		atomic.AddInt32(&requests, 1)
+		switch path {
+		case "/subjects/foo/versions/latest":
+			return fooFirst, nil
+		case "/subjects/baz/versions/latest":
+			return []byte(`{"schema":"not a schema","id":4}`), nil
+		}
+		return nil, nil
+	})
+
+	subj, err := service.NewInterpolatedString(`${! meta("subject") }`)
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+
+	failures := encoder.warmupEncoders(context.Background(), []string{"foo", "bar", "baz"})
+	require.Len(t, failures, 2)
+	assert.Equal(t, "bar", failures[0].subject)
+	assert.Contains(t, failures[0].err.Error(), "not found")
+	assert.Equal(t, "baz", failures[1].subject)
+	require.Error(t, failures[1].err)
+
+	encoder.cacheMut.RLock()
+	assert.Contains(t, encoder.schemas, schemaKey{subject: "foo"})
+	assert.NotContains(t, encoder.schemas, schemaKey{subject: "bar"})
+	assert.NotContains(t, encoder.schemas, schemaKey{subject: "baz"})
+	encoder.cacheMut.RUnlock()
+
+	// Messages of the warmed up subject are encoded without further requests.
+	reqsBefore := atomic.LoadInt32(&requests)
+
+	msg := service.NewMessage([]byte(`{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`))
+	msg.MetaSet("subject", "foo")
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{msg})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 1)
+	require.NoError(t, outBatches[0][0].GetError())
+	assert.Equal(t, reqsBefore, atomic.LoadInt32(&requests))
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodePreloadAll(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	barFirst := schemaResponseBody(t, `{"type":"record","name":"bar","fields":[{"name":"id","type":"string"}]}`, 4)
+
+	var requests int32
+	var subjectsRes atomic.Value
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		atomic.AddInt32(&requests, 1)
+		switch path {
+		case "/subjects":
+			res := subjectsRes.Load().(string)
+			if res == "" {
+				return nil, errors.New("nope")
+			}
+			return []byte(res), nil
+		case "/subjects/foo/versions/latest":
+			return fooFirst, nil
+		case "/subjects/bar/versions/latest":
+			return barFirst, nil
+		}
+		return nil, nil
+	})
+
+	newEncoder := func(failures string) (*schemaRegistryEncoder, error) {
+		conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: ${! meta("subject") }
+refresh_period: 0s
+preload_all: true
+preload_failures: %v
+`, urlStr, failures), nil)
+		require.NoError(t, err)
+		return newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+	}
+
+	subjectsRes.Store(`["foo","bar"]`)
+	encoder, err := newEncoder("fatal")
+	require.NoError(t, err)
+
+	encoder.cacheMut.RLock()
+	assert.Contains(t, encoder.schemas, schemaKey{subject: "foo"})
+	assert.Contains(t, encoder.schemas, schemaKey{subject: "bar"})
+	encoder.cacheMut.RUnlock()
+
+	// Messages of preloaded subjects are encoded without further requests.
+	reqsBefore := atomic.LoadInt32(&requests)
+
+	fooMsg := service.NewMessage([]byte(`{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`))
+	fooMsg.MetaSet("subject", "foo")
+	barMsg := service.NewMessage([]byte(`{"id":"x"}`))
+	barMsg.MetaSet("subject", "bar")
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{fooMsg, barMsg})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 2)
+	require.NoError(t, outBatches[0][0].GetError())
+	require.NoError(t, outBatches[0][1].GetError())
+	assert.Equal(t, reqsBefore, atomic.LoadInt32(&requests))
+
+	require.NoError(t, encoder.Close(context.Background()))
+
+	// A subject that fails to be fetched is fatal by default.
+	subjectsRes.Store(`["foo","baz"]`)
+	_, err = newEncoder("fatal")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to preload schema subject 'baz'")
+
+	encoder, err = newEncoder("warn")
+	require.NoError(t, err)
+	encoder.cacheMut.RLock()
+	assert.Contains(t, encoder.schemas, schemaKey{subject: "foo"})
+	assert.NotContains(t, encoder.schemas, schemaKey{subject: "baz"})
+	encoder.cacheMut.RUnlock()
+	require.NoError(t, encoder.Close(context.Background()))
+
+	// As is a failure to list subjects.
+	subjectsRes.Store("")
+	_, err = newEncoder("fatal")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to list schema subjects for preloading")
+
+	encoder, err = newEncoder("warn")
+	require.NoError(t, err)
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeBatchRecords(t *testing.T) {
+	fooFirst, barFirst := schemaResponseBody(t, testSchema, 3), schemaResponseBody(t, testSchema, 4)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/subjects/foo/versions/latest":
+			return fooFirst, nil
+		case "/subjects/bar/versions/latest":
+			return barFirst, nil
+		}
+		return nil, nil
+	})
+
+	subj, err := service.NewInterpolatedString(`${! meta("subject") }`)
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+	encoder.batchRecordsPrefix, err = parseRecordPrefix("batch_records", "varint_length_prefixed")
+	require.NoError(t, err)
+
+	goodInput := `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`
+	record := "\x06foo\x02\x06foo\x06bar\x00"
+
+	newBatch := func(msgs ...[2]string) service.MessageBatch {
+		var batch service.MessageBatch
+		for _, m := range msgs {
+			msg := service.NewMessage([]byte(m[1]))
+			msg.MetaSet("subject", m[0])
+			batch = append(batch, msg)
+		}
+		return batch
+	}
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), newBatch(
+		[2]string{"foo", goodInput},
+		[2]string{"foo", `{"nope":true}`},
+		[2]string{"foo", goodInput},
+	))
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 2)
+
+	require.NoError(t, outBatches[0][0].GetError())
+	b, err := outBatches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00\x00\x03\x1c"+record+"\x1c"+record, string(b))
+
+	require.Error(t, outBatches[0][1].GetError())
+	b, err = outBatches[0][1].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"nope":true}`, string(b))
+
+	outBatches, err = encoder.ProcessBatch(context.Background(), newBatch(
+		[2]string{"foo", goodInput},
+		[2]string{"bar", goodInput},
+	))
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 2)
+	for i, exp := range []string{"\x00\x00\x00\x00\x03" + record, "\x00\x00\x00\x00\x04" + record} {
+		assert.EqualError(t, outBatches[0][i].GetError(), "unable to combine the records of a batch encoded with different schemas, found IDs 3 and 4")
+		b, err = outBatches[0][i].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, exp, string(b))
+	}
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeEmptySubject(t *testing.T) {
+	var requests int32
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		atomic.AddInt32(&requests, 1)
+		return nil, nil
+	})
+
+	subj, err := service.NewInterpolatedString(`${! meta("subject").or("") }`)
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"Name":"foo"}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 1)
+
+	assert.EqualError(t, outBatches[0][0].GetError(), "schema subject resolved to an empty string")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeLargeNumbers(t *testing.T) {
+	schema := `{"type":"record","name":"nums","fields":[{"name":"id","type":"long"},{"name":"count","type":"int"},{"name":"ratio","type":"double"}]}`
+
+	fooFirst := schemaResponseBody(t, schema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, nil
+	})
+
+	codec, err := goavro.NewCodec(schema)
+	require.NoError(t, err)
+
+	// 2^53 + 1 cannot be represented as a float64.
+	record, err := codec.BinaryFromNative(nil, map[string]interface{}{
+		"id":    int64(9007199254740993),
+		"count": 5,
+		"ratio": 0.5,
+	})
+	require.NoError(t, err)
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	for _, prefix := range []string{"disabled", "concatenated"} {
+		prefix := prefix
+		t.Run(prefix, func(t *testing.T) {
+			encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+			require.NoError(t, err)
+
+			encoder.arrayRecordsPrefix, err = parseRecordPrefix("array_records", prefix)
+			require.NoError(t, err)
+
+			input := `{"id":9007199254740993,"count":5,"ratio":0.5}`
+			if prefix != "disabled" {
+				input = "[" + input + "]"
+			}
+
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(input)),
+			})
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+			require.NoError(t, outBatches[0][0].GetError())
+
+			b, err := outBatches[0][0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, "\x00\x00\x00\x00\x03"+string(record), string(b))
+
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeFramings(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, true, time.Minute*10, time.Minute, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = encoder.Close(context.Background())
+	})
+
+	encoder.framings = []schemaFraming{confluentFraming, singleObjectFraming}
+
+	codec, err := goavro.NewCodecForStandardJSON(testSchema)
+	require.NoError(t, err)
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":null}`)),
+		service.NewMessage([]byte(`{"Address":{"City":"foo","State":30},"Name":"foo","MaybeHobby":null}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 2)
+
+	payload := "\x06foo\x02\x06foo\x06bar\x00"
+	singleObjectHeader := make([]byte, 10)
+	singleObjectHeader[0], singleObjectHeader[1] = 0xC3, 0x01
+	binary.LittleEndian.PutUint64(singleObjectHeader[2:], codec.Rabin)
+
+	for i, exp := range []struct {
+		framing string
+		output  string
+	}{
+		{framing: "confluent", output: "\x00\x00\x00\x00\x03" + payload},
+		{framing: "single_object", output: string(singleObjectHeader) + payload},
+	} {
+		require.Len(t, outBatches[i], 2)
+
+		require.NoError(t, outBatches[i][0].GetError())
+		b, err := outBatches[i][0].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, exp.output, string(b))
+
+		v, _ := outBatches[i][0].MetaGet("schema_registry_framing")
+		assert.Equal(t, exp.framing, v)
+
+		require.Error(t, outBatches[i][1].GetError())
+		v, _ = outBatches[i][1].MetaGet("schema_registry_framing")
+		assert.Equal(t, exp.framing, v)
+	}
+
+	native, _, err := codec.NativeFromSingle([]byte(string(singleObjectHeader) + payload))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", native.(map[string]interface{})["Name"])
+}
+
+func TestSchemaRegistryEncodeClearExpired(t *testing.T) {
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		return nil, fmt.Errorf("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, time.Minute, nil)
+	require.NoError(t, err)
+	require.NoError(t, encoder.Close(context.Background()))
+
+	tStale := time.Now().Add(-time.Hour).Unix()
+	tNotStale := time.Now().Unix()
+	tNearlyStale := time.Now().Add(-(schemaStaleAfter / 2)).Unix()
+
+	encoder.cacheMut.Lock()
+	encoder.schemas = map[schemaKey]*cachedSchemaEncoder{
+		{subject: "5"}:  {lastUsedUnixSeconds: tStale, lastUpdatedUnixSeconds: tNotStale},
+		{subject: "10"}: {lastUsedUnixSeconds: tNotStale, lastUpdatedUnixSeconds: tNotStale},
+		{subject: "15"}: {lastUsedUnixSeconds: tNearlyStale, lastUpdatedUnixSeconds: tNotStale},
+	}
+	encoder.cacheMut.Unlock()
+
+	encoder.refreshEncoders()
+
+	encoder.cacheMut.Lock()
+	assert.Equal(t, map[schemaKey]*cachedSchemaEncoder{
+		{subject: "10"}: {lastUsedUnixSeconds: tNotStale, lastUpdatedUnixSeconds: tNotStale},
+		{subject: "15"}: {lastUsedUnixSeconds: tNearlyStale, lastUpdatedUnixSeconds: tNotStale},
+	}, encoder.schemas)
+	encoder.cacheMut.Unlock()
+}
+
+func TestSchemaRegistryEncodeRefresh(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 2)
+
+	barFirst := schemaResponseBody(t, testSchema, 12)
+
+	var fooReqs, barReqs int32
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/subjects/foo/versions/latest":
+			atomic.AddInt32(&fooReqs, 1)
+			return fooFirst, nil
+		case "/subjects/bar/versions/latest":
+			atomic.AddInt32(&barReqs, 1)
+			return barFirst, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+
+	codec, err := goavro.NewCodecForStandardJSON(testSchema)
+	require.NoError(t, err)
+
+	tStale := time.Now().Add(-time.Hour).Unix()
+	tNotStale := time.Now().Unix()
+	tNearlyStale := time.Now().Add(-(schemaStaleAfter / 2)).Unix()
+
+	encoder.nowFn = func() time.Time {
+		return time.Unix(tNotStale, 0)
+	}
+
+	encoder.cacheMut.Lock()
+	encoder.schemas = map[schemaKey]*cachedSchemaEncoder{
+		{subject: "foo"}: {
+			lastUsedUnixSeconds:    tNotStale,
+			lastUpdatedUnixSeconds: tStale,
+			id:                     1,
+		},
+		{subject: "bar"}: {
+			lastUsedUnixSeconds:    tNotStale,
+			lastUpdatedUnixSeconds: tNearlyStale,
+			id:                     11,
+		},
+	}
+	encoder.cacheMut.Unlock()
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fooReqs))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&barReqs))
+
+	encoder.refreshEncoders()
+
+	encoder.cacheMut.Lock()
+	encoder.schemas[schemaKey{subject: "foo"}].encoder = nil
+	assert.Equal(t, map[schemaKey]*cachedSchemaEncoder{
+		{subject: "foo"}: {
+			lastUsedUnixSeconds:    tNotStale,
+			lastUpdatedUnixSeconds: tNotStale,
+			id:                     2,
+			fingerprint:            codec.Rabin,
+		},
+		{subject: "bar"}: {
+			lastUsedUnixSeconds:    tNotStale,
+			lastUpdatedUnixSeconds: tNearlyStale,
+			id:                     11,
+		},
+	}, encoder.schemas)
+	encoder.schemas[schemaKey{subject: "bar"}].lastUpdatedUnixSeconds = tStale
+	encoder.cacheMut.Unlock()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fooReqs))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&barReqs))
+
+	encoder.refreshEncoders()
+
+	encoder.cacheMut.Lock()
+	encoder.schemas[schemaKey{subject: "bar"}].encoder = nil
+	assert.Equal(t, map[schemaKey]*cachedSchemaEncoder{
+		{subject: "foo"}: {
+			lastUsedUnixSeconds:    tNotStale,
+			lastUpdatedUnixSeconds: tNotStale,
+			id:                     2,
+			fingerprint:            codec.Rabin,
+		},
+		{subject: "bar"}: {
+			lastUsedUnixSeconds:    tNotStale,
+			lastUpdatedUnixSeconds: tNotStale,
+			id:                     12,
+			fingerprint:            codec.Rabin,
+		},
+	}, encoder.schemas)
+	encoder.cacheMut.Unlock()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fooReqs))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&barReqs))
+
+	// Once closed refreshes are abandoned and schemas remain unchanged.
+	require.NoError(t, encoder.Close(context.Background()))
+
+	encoder.cacheMut.Lock()
+	encoder.schemas = map[schemaKey]*cachedSchemaEncoder{
+		{subject: "foo"}: {
+			lastUsedUnixSeconds:    tNotStale,
+			lastUpdatedUnixSeconds: tStale,
+			id:                     1,
+		},
+	}
+	encoder.cacheMut.Unlock()
+
+	encoder.refreshEncoders()
+
+	encoder.cacheMut.Lock()
+	assert.Equal(t, map[schemaKey]*cachedSchemaEncoder{
+		{subject: "foo"}: {
+			lastUsedUnixSeconds:    tNotStale,
+			lastUpdatedUnixSeconds: tStale,
+			id:                     1,
+		},
+	}, encoder.schemas)
+	encoder.cacheMut.Unlock()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fooReqs))
+}
+
+func TestSchemaRegistryEncodeRevalidate(t *testing.T) {
+	latest := schemaResponseBody(t, testSchema, 2)
+
+	var reqs int32
+	release := make(chan struct{})
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path != "/subjects/foo/versions/latest" {
+			return nil, errors.New("nope")
+		}
+		atomic.AddInt32(&reqs, 1)
+		<-release
+		return latest, nil
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+	encoder.revalidateAfter = time.Minute
+
+	tNow := time.Now().Unix()
+	encoder.nowFn = func() time.Time {
+		return time.Unix(tNow, 0)
+	}
+
+	encoder.cacheMut.Lock()
+	encoder.schemas = map[schemaKey]*cachedSchemaEncoder{
+		{subject: "foo"}: {
+			lastUsedUnixSeconds:    tNow,
+			lastUpdatedUnixSeconds: tNow - 30,
+			id:                     1,
+		},
+	}
+	encoder.cacheMut.Unlock()
+
+	// Schemas younger than the revalidation period are not revalidated.
+	_, id, _, err := encoder.getEncoder(context.Background(), schemaKey{subject: "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&reqs))
+
+	encoder.cacheMut.Lock()
+	encoder.schemas[schemaKey{subject: "foo"}].lastUpdatedUnixSeconds = tNow - 120
+	encoder.cacheMut.Unlock()
+
+	// The cached schema is returned without waiting for the revalidation, and
+	// only one revalidation is made at a time.
+	for i := 0; i < 3; i++ {
+		_, id, _, err = encoder.getEncoder(context.Background(), schemaKey{subject: "foo"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, id)
+	}
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&reqs) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		_, id, _, err = encoder.getEncoder(context.Background(), schemaKey{subject: "foo"})
+		return err == nil && id == 2
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reqs))
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeRevalidateConcurrent(t *testing.T) {
+	latest := schemaResponseBody(t, testSchema, 2)
+
+	var reqs int32
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path != "/subjects/foo/versions/latest" {
+			return nil, errors.New("nope")
+		}
+		atomic.AddInt32(&reqs, 1)
+		time.Sleep(time.Millisecond * 10)
+		return latest, nil
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+	encoder.revalidateAfter = time.Minute
+
+	tNow := time.Now().Unix()
+	encoder.nowFn = func() time.Time {
+		return time.Unix(tNow, 0)
+	}
+
+	// The cached schema is due both a passive refresh and a revalidation.
+	encoder.cacheMut.Lock()
+	encoder.schemas = map[schemaKey]*cachedSchemaEncoder{
+		{subject: "foo"}: {
+			lastUsedUnixSeconds:    tNow,
+			lastUpdatedUnixSeconds: tNow - 900,
+			id:                     1,
+		},
+	}
+	encoder.cacheMut.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, _, _, err := encoder.getEncoder(context.Background(), schemaKey{subject: "foo"})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 5; j++ {
+			encoder.refreshEncoders()
+		}
+	}()
+	wg.Wait()
+
+	assert.Eventually(t, func() bool {
+		_, id, _, err := encoder.getEncoder(context.Background(), schemaKey{subject: "foo"})
+		return err == nil && id == 2
+	}, time.Second*5, time.Millisecond*10)
+
+	// Revalidations and passive refreshes are serialized, and whichever runs
+	// second finds the schema already up to date.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reqs))
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestParseFieldRenames(t *testing.T) {
+	tests := []struct {
+		name        string
+		mapping     map[string]string
+		input       string
+		output      string
+		errContains string
+	}{
+		{
+			name:    "top level",
+			mapping: map[string]string{"firstName": "first_name"},
+			input:   `{"firstName":"foo","lastName":"bar"}`,
+			output:  `{"first_name":"foo","lastName":"bar"}`,
+		},
+		{
+			name:    "nested",
+			mapping: map[string]string{"user": "account", "user.zipCode": "zip_code", "other.a": "b"},
+			input:   `{"user":{"zipCode":"123","city":"foo"},"other":"nope"}`,
+			output:  `{"account":{"city":"foo","zip_code":"123"},"other":"nope"}`,
+		},
+		{
+			name:    "arrays",
+			mapping: map[string]string{"items.itemId": "item_id"},
+			input:   `{"items":[{"itemId":1},{"itemId":2},"foo"]}`,
+			output:  `{"items":[{"item_id":1},{"item_id":2},"foo"]}`,
+		},
+		{
+			name:    "escaped keys",
+			mapping: map[string]string{"a~1b.c~0d": "e"},
+			input:   `{"a.b":{"c~d":true}}`,
+			output:  `{"a.b":{"e":true}}`,
+		},
+		{
+			name:        "empty segment",
+			mapping:     map[string]string{"a..b": "c"},
+			errContains: "field_mapping path 'a..b' contains an empty segment",
+		},
+		{
+			name:        "dotted target",
+			mapping:     map[string]string{"a": "b.c"},
+			errContains: "field_mapping of 'a' must be a non-empty field name without dots, got 'b.c'",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			renames, err := parseFieldRenames(test.mapping)
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+
+			var input interface{}
+			require.NoError(t, json.Unmarshal([]byte(test.input), &input))
+
+			output, err := json.Marshal(renames.apply(input))
+			require.NoError(t, err)
+			assert.Equal(t, test.output, string(output))
+
+			// The input is left unchanged.
+			inputBytes, err := json.Marshal(input)
+			require.NoError(t, err)
+			assert.JSONEq(t, test.input, string(inputBytes))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeFieldMapping(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, time.Minute, nil)
+	require.NoError(t, err)
+
+	encoder.fieldRenames, err = parseFieldRenames(map[string]string{
+		"name":    "Name",
+		"address": "Address",
+		"address.my~1namespace~1com~1address.city": "City",
+	})
+	require.NoError(t, err)
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"address":{"my.namespace.com.address":{"city":"foo","State":"bar"}},"name":"foo","MaybeHobby":null}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 1)
+	require.NoError(t, outBatches[0][0].GetError())
+
+	b, err := outBatches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00", string(b))
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodePreEncode(t *testing.T) {
+	barFirst := schemaResponseBody(t, `{"type":"record","name":"bar","fields":[{"name":"active","type":"boolean"},{"name":"count","type":"long"},{"name":"subject","type":"string"}]}`, 4)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/bar/versions/latest" {
+			return barFirst, nil
+		}
+		return nil, nil
+	})
+
+	newEncoder := func(mapping string) *schemaRegistryEncoder {
+		conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: bar
+avro_raw_json: true
+pre_encode: |
+  %v
+`, urlStr, strings.ReplaceAll(mapping, "\n", "\n  ")), nil)
+		require.NoError(t, err)
+
+		encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+		return encoder
+	}
+
+	encoder := newEncoder(`root = this
+root.active = this.active == "true"
+root.count = this.count.number()
+root.subject = "%v:%v".format(meta("schema_registry_subject"), meta("schema_registry_id"))`)
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"active":"true","count":"42"}`)),
+		service.NewMessage([]byte(`{"active":"false","count":"nope"}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 2)
+
+	require.NoError(t, outBatches[0][0].GetError())
+	b, err := outBatches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00\x00\x04\x01\x54\x0abar:4", string(b))
+	_, exists := outBatches[0][0].MetaGet("schema_registry_subject")
+	assert.False(t, exists)
+
+	require.Error(t, outBatches[0][1].GetError())
+	assert.Contains(t, outBatches[0][1].GetError().Error(), "pre_encode mapping failed")
+	b, err = outBatches[0][1].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"active":"false","count":"nope"}`, string(b))
+
+	encoder = newEncoder(`root = deleted()`)
+
+	outBatches, err = encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"active":true,"count":1,"subject":"foo"}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 1)
+	require.Error(t, outBatches[0][0].GetError())
+	assert.Contains(t, outBatches[0][0].GetError().Error(), "pre_encode mapping deleted the message")
+}
+
+func TestSchemaRegistryEncodeSchemaTypeChange(t *testing.T) {
+	latest := schemaResponseBody(t, testSchema, 2)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return latest, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	tests := []struct {
+		mode       string
+		id         int
+		schemaType string
+		errStr     string
+	}{
+		{mode: "adopt", id: 2, schemaType: ""},
+		{mode: "retain", id: 1, schemaType: "JSON"},
+		{mode: "error", id: 1, schemaType: "JSON", errStr: "schema type of subject 'foo' changed from JSON to AVRO"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.mode, func(t *testing.T) {
+			encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+			require.NoError(t, err)
+			encoder.schemaTypeChange = test.mode
+
+			tNow := time.Now().Unix()
+			encoder.cacheMut.Lock()
+			encoder.schemas = map[schemaKey]*cachedSchemaEncoder{
+				{subject: "foo"}: {
+					lastUsedUnixSeconds:    tNow,
+					lastUpdatedUnixSeconds: time.Now().Add(-time.Hour).Unix(),
+					id:                     1,
+					schemaType:             "JSON",
+					encoder: func(m *service.Message) error {
+						return nil
+					},
+				},
+			}
+			encoder.cacheMut.Unlock()
+
+			encoder.refreshEncoders()
+
+			encoder.cacheMut.Lock()
+			c := encoder.schemas[schemaKey{subject: "foo"}]
+			assert.Equal(t, test.id, c.id)
+			assert.Equal(t, test.schemaType, c.schemaType)
+			assert.GreaterOrEqual(t, c.lastUpdatedUnixSeconds, tNow)
+			encoder.cacheMut.Unlock()
+
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(`{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`)),
+			})
+			require.NoError(t, err)
+			if test.errStr != "" {
+				assert.EqualError(t, outBatches[0][0].GetError(), test.errStr)
+			} else {
+				assert.NoError(t, outBatches[0][0].GetError())
+			}
+
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeCancelled(t *testing.T) {
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		t.Errorf("unexpected request: %v", path)
+		return nil, errors.New("nope")
+	})
+
+	subj, err := service.NewInterpolatedString("foo")
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, 0, nil)
+	require.NoError(t, err)
+
+	ctx, done := context.WithCancel(context.Background())
+	done()
+
+	outBatches, err := encoder.ProcessBatch(ctx, service.MessageBatch{service.NewMessage([]byte(`{}`))})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 1)
+
+	err = outBatches[0][0].GetError()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "context canceled")
+
+	require.NoError(t, encoder.Close(context.Background()))
+}
+
+func TestSchemaRegistryEncodeDebugResponses(t *testing.T) {
+	badSchema := `{"type":"record","name":"bar","fields":[{"name":"id","type":"nope"}]}`
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/subjects/foo/versions/latest":
+			return schemaResponseBody(t, testSchema, 3), nil
+		case "/subjects/bar/versions/latest":
+			return schemaResponseBody(t, badSchema, 4), nil
+		case "/subjects/baz/versions/latest":
+			return nil, errors.New("registry is having a bad day")
+		}
+		return nil, nil
+	})
+
+	for _, enabled := range []bool{true, false} {
+		enabled := enabled
+		t.Run(fmt.Sprintf("enabled %v", enabled), func(t *testing.T) {
+			lConf := log.NewConfig()
+			lConf.LogLevel = "DEBUG"
+
+			var buf bytes.Buffer
+			logger, err := log.NewV2(&buf, lConf)
+			require.NoError(t, err)
+
+			mgr := mock.NewManager()
+			mgr.L = logger
+			res := mockResourcesFromManager(t, mgr)
+
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: ${! meta("subject") }
+max_retries: 0
+debug_responses: %v
+`, urlStr, enabled), nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, res.Logger(), nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, encoder.Close(context.Background()))
+			})
+
+			encode := func(subject string) error {
+				msg := service.NewMessage([]byte(`{"Address":null,"Name":"foo","MaybeHobby":null}`))
+				msg.MetaSet("subject", subject)
+				outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{msg})
+				require.NoError(t, err)
+				require.Len(t, outBatches, 1)
+				require.Len(t, outBatches[0], 1)
+				return outBatches[0][0].GetError()
+			}
+
+			// Successful responses are never logged.
+			require.NoError(t, encode("foo"))
+			assert.NotContains(t, buf.String(), "Response for")
+
+			// The raw responses of failed requests, and of schemas that cannot
+			// be compiled, are only logged when enabled.
+			require.Error(t, encode("bar"))
+			require.Error(t, encode("baz"))
+
+			logs := buf.String()
+			if enabled {
+				assert.Contains(t, logs, "Response for schema subject 'bar'")
+				assert.Contains(t, logs, `\"id\":4}`)
+				assert.Contains(t, logs, "Response for schema subject 'baz'")
+				assert.Contains(t, logs, "registry is having a bad day")
+			} else {
+				assert.NotContains(t, logs, "Response for")
+			}
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeBatchMetrics(t *testing.T) {
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return schemaResponseBody(t, testSchema, 3), nil
+		}
+		return nil, nil
+	})
+
+	res, stats := mockResourcesWithMetrics(t)
+
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+`, urlStr), nil)
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, res.Logger(), res.Metrics())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, encoder.Close(context.Background()))
+	})
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"Address":null,"Name":"foo","MaybeHobby":null}`)),
+		service.NewMessage([]byte(`not json`)),
+		service.NewMessage([]byte(`{"Address":null,"Name":"bar","MaybeHobby":null}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 3)
+	require.Error(t, outBatches[0][1].GetError())
+
+	counters := stats.GetCounters()
+	assert.Equal(t, int64(3), counters["schema_registry_encode_messages"])
+	assert.Equal(t, int64(2), counters["schema_registry_encode_success"])
+	assert.Equal(t, int64(1), counters["schema_registry_encode_error"])
+	assert.Equal(t, int64(1), counters["schema_registry_encode_batch_subjects"])
+	assert.Equal(t, int64(2), counters[`schema_registry_encode_subject_success{subject="foo"}`])
+}
+
+func TestSchemaRegistryEncodeCacheMetrics(t *testing.T) {
+	var latestID int32 = 3
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return schemaResponseBody(t, testSchema, int(atomic.LoadInt32(&latestID))), nil
+		}
+		return nil, nil
+	})
+
+	res, stats := mockResourcesWithMetrics(t)
+
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+refresh_period: 1m
+`, urlStr), nil)
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, res.Logger(), res.Metrics())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, encoder.Close(context.Background()))
+	})
+
+	process := func() {
+		t.Helper()
+		outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"Address":null,"Name":"foo","MaybeHobby":null}`)),
+		})
+		require.NoError(t, err)
+		require.Len(t, outBatches, 1)
+		require.Len(t, outBatches[0], 1)
+		require.NoError(t, outBatches[0][0].GetError())
+	}
+
+	counters := func() map[string]int64 {
+		c := stats.GetCounters()
+		return map[string]int64{
+			"hit":     c["schema_registry_encode_cache_hit"],
+			"miss":    c["schema_registry_encode_cache_miss"],
+			"refresh": c["schema_registry_encode_cache_refresh"],
+			"purge":   c["schema_registry_encode_cache_purge"],
+		}
+	}
+
+	process()
+	assert.Equal(t, map[string]int64{"hit": 0, "miss": 1, "refresh": 0, "purge": 0}, counters())
+
+	process()
+	assert.Equal(t, map[string]int64{"hit": 1, "miss": 1, "refresh": 0, "purge": 0}, counters())
+
+	// Schemas are refreshed once older than the refresh period, and purged
+	// once unused for longer than the stale period.
+	now := time.Now()
+	atomic.StoreInt32(&latestID, 4)
+	encoder.nowFn = func() time.Time { return now.Add(time.Minute * 2) }
+	encoder.refreshEncoders()
+	assert.Equal(t, map[string]int64{"hit": 1, "miss": 1, "refresh": 1, "purge": 0}, counters())
+
+	encoder.nowFn = func() time.Time { return now.Add(schemaStaleAfter * 2) }
+	encoder.refreshEncoders()
+	assert.Equal(t, map[string]int64{"hit": 1, "miss": 1, "refresh": 1, "purge": 1}, counters())
+}
+
+func TestSchemaRegistryEncodeSharedCodecCache(t *testing.T) {
+	var latestID int32 = 3
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return schemaResponseBody(t, testSchema, int(atomic.LoadInt32(&latestID))), nil
+		}
+		return nil, nil
+	})
+
+	newEncoder := func(cacheName string) *schemaRegistryEncoder {
+		conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+refresh_period: 0s
+shared_codec_cache: %v
+`, urlStr, cacheName), nil)
+		require.NoError(t, err)
+
+		encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+		require.NoError(t, err)
+
+		outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"Address":null,"Name":"foo","MaybeHobby":null}`)),
+		})
+		require.NoError(t, err)
+		require.Len(t, outBatches, 1)
+		require.Len(t, outBatches[0], 1)
+		require.NoError(t, outBatches[0][0].GetError())
+		return encoder
+	}
+
+	refs := func(c *codecCache) map[int]int {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		res := map[int]int{}
+		for k, v := range c.codecs {
+			assert.Equal(t, urlStr, k.url)
+			res[k.id] = v.refs
+		}
+		return res
+	}
+
+	encoderA := newEncoder("shared_test")
+	encoderB := newEncoder("shared_test")
+	encoderC := newEncoder("shared_test_other")
+
+	require.NotNil(t, encoderA.codecs)
+	assert.Same(t, encoderA.codecs, encoderB.codecs)
+	assert.NotSame(t, encoderA.codecs, encoderC.codecs)
+	assert.Equal(t, map[int]int{3: 2}, refs(encoderA.codecs))
+	assert.Equal(t, map[int]int{3: 1}, refs(encoderC.codecs))
+
+	// Refreshing a subject releases the codec of its previous schema.
+	atomic.StoreInt32(&latestID, 4)
+	encoderA.cacheMut.RLock()
+	c := encoderA.schemas[schemaKey{subject: "foo"}]
+	encoderA.cacheMut.RUnlock()
+	require.NoError(t, encoderA.refreshEncoder(context.Background(), schemaKey{subject: "foo"}, c))
+	assert.Equal(t, map[int]int{3: 1, 4: 1}, refs(encoderA.codecs))
+
+	shared := encoderA.codecs
+	require.NoError(t, encoderA.Close(context.Background()))
+	assert.Equal(t, map[int]int{3: 1}, refs(shared))
+
+	// Closing a processor again must not release the shared cache once more,
+	// which would remove it whilst still in use by another processor.
+	require.NoError(t, encoderA.Close(context.Background()))
+	sharedCodecCachesMut.Lock()
+	assert.Contains(t, sharedCodecCaches, "shared_test")
+	sharedCodecCachesMut.Unlock()
+
+	require.NoError(t, encoderB.Close(context.Background()))
+	assert.Empty(t, refs(shared))
+	require.NoError(t, encoderC.Close(context.Background()))
+
+	sharedCodecCachesMut.Lock()
+	assert.NotContains(t, sharedCodecCaches, "shared_test")
+	assert.NotContains(t, sharedCodecCaches, "shared_test_other")
+	sharedCodecCachesMut.Unlock()
+}
+
+func TestSchemaRegistryEncodeUnknownEnumSymbols(t *testing.T) {
+	fooFirst := schemaResponseBody(t, enumTestSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, nil
+	})
+
+	codec, err := goavro.NewCodec(enumTestSchema)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		config      string
+		input       string
+		output      map[string]interface{}
+		errContains string
+	}{
+		{
+			name:        "error by default",
+			input:       `{"color":"BLUE","size":null,"tags":[],"extra":{}}`,
+			errContains: "BLUE",
+		},
+		{
+			name: "default symbol",
+			config: `
+unknown_enum_symbols: default_symbol
+default_enum_symbol: UNKNOWN
+`,
+			input: `{"color":"BLUE","size":{"com.example.Size":"XL"},"tags":["RED","PINK"],"extra":{"a":"XS"}}`,
+			output: map[string]interface{}{
+				"color": "UNKNOWN",
+				"size":  map[string]interface{}{"com.example.Size": "M"},
+				"tags":  []interface{}{"RED", "UNKNOWN"},
+				"extra": map[string]interface{}{"a": "M"},
+			},
+		},
+		{
+			name: "default symbol raw json",
+			config: `
+avro_raw_json: true
+unknown_enum_symbols: default_symbol
+default_enum_symbol: UNKNOWN
+`,
+			input: `{"color":"BLUE","size":"XL","tags":[],"extra":{}}`,
+			output: map[string]interface{}{
+				"color": "UNKNOWN",
+				"size":  map[string]interface{}{"com.example.Size": "M"},
+				"tags":  []interface{}{},
+				"extra": map[string]interface{}{},
+			},
+		},
+		{
+			name: "no default symbol",
+			config: `
+unknown_enum_symbols: default_symbol
+`,
+			input:       `{"color":"BLUE","size":null,"tags":[],"extra":{}}`,
+			errContains: "root.color: symbol 'BLUE' is not a member of enum com.example.Color, which has no default symbol",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+%v
+`, urlStr, test.config), nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, encoder.Close(context.Background()))
+			})
+
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(test.input)),
+			})
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			if test.errContains != "" {
+				err := outBatches[0][0].GetError()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, outBatches[0][0].GetError())
+
+			b, err := outBatches[0][0].AsBytes()
+			require.NoError(t, err)
+			require.Greater(t, len(b), 5)
+
+			native, _, err := codec.NativeFromBinary(b[5:])
+			require.NoError(t, err)
+			assert.Equal(t, test.output, native)
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeSchemaDrift(t *testing.T) {
+	fooFirst := schemaResponseBody(t, testSchema, 3)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/subjects/foo/versions/latest" {
+			return fooFirst, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+schema_drift:
+  enabled: true
+  threshold: 0.25
+  min_messages: 4
+`, urlStr), nil)
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, encoder.Close(context.Background()))
+	})
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"Address":null,"Name":"foo","MaybeHobby":null}`)),
+		service.NewMessage([]byte(`{"Address":null,"Name":"bar","MaybeHobby":null}`)),
+		service.NewMessage([]byte(`{"Address":null,"FullName":"foo","MaybeHobby":null}`)),
+		service.NewMessage([]byte(`{"Address":null,"FullName":"bar","MaybeHobby":null}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 4)
+	assert.NoError(t, outBatches[0][0].GetError())
+	assert.NoError(t, outBatches[0][1].GetError())
+	assert.Error(t, outBatches[0][2].GetError())
+	assert.Error(t, outBatches[0][3].GetError())
+
+	encoder.schemaDrift.mut.Lock()
+	assert.Equal(t, map[string]*schemaDriftCounts{
+		"foo": {total: 4, failed: 2},
+	}, encoder.schemaDrift.subjects)
+	encoder.schemaDrift.mut.Unlock()
+
+	// Drift is checked, and the counts reset, when the schema is refreshed.
+	encoder.cacheMut.Lock()
+	encoder.schemas[schemaKey{subject: "foo"}].lastUpdatedUnixSeconds = time.Now().Add(-time.Hour).Unix()
+	encoder.cacheMut.Unlock()
+
+	encoder.refreshEncoders()
+
+	encoder.schemaDrift.mut.Lock()
+	assert.Empty(t, encoder.schemaDrift.subjects)
+	encoder.schemaDrift.mut.Unlock()
+}
+
+func TestSchemaRegistryEncodeBasicAuth(t *testing.T) {
+	latest := schemaResponseBody(t, testSchema, 3)
+
+	var authedReqs, unauthedReqs int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "foo" || pass != "bar" {
+			atomic.AddInt32(&unauthedReqs, 1)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		atomic.AddInt32(&authedReqs, 1)
+		if r.URL.Path != "/subjects/foo/versions/latest" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(latest)
+	}))
+	t.Cleanup(ts.Close)
+
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+basic_auth:
+  enabled: true
+  username: foo
+  password: bar
+`, ts.URL), nil)
+	require.NoError(t, err)
+
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, encoder.Close(context.Background()))
+	})
+
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`)),
 	})
 	require.NoError(t, err)
+	require.NoError(t, outBatches[0][0].GetError())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&authedReqs))
+
+	// Refreshes are authenticated in the same way.
+	encoder.cacheMut.Lock()
+	encoder.schemas[schemaKey{subject: "foo"}].lastUpdatedUnixSeconds = time.Now().Add(-time.Hour).Unix()
+	encoder.cacheMut.Unlock()
+
+	encoder.refreshEncoders()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&authedReqs))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&unauthedReqs))
+}
+
+func TestSchemaRegistryEncodePinnedVersion(t *testing.T) {
+	pinned := schemaResponseBody(t, testSchema, 3)
 
+	var reqs int32
 	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
-		if path == "/subjects/foo/versions/latest" {
-			return fooFirst, nil
+		atomic.AddInt32(&reqs, 1)
+		if path == "/subjects/foo/versions/2" {
+			return pinned, nil
 		}
 		return nil, errors.New("nope")
 	})
 
-	subj, err := service.NewInterpolatedString("foo")
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+version: 2
+refresh_period: 1s
+revalidate_after: 1s
+`, urlStr), nil)
 	require.NoError(t, err)
 
-	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, true, time.Minute*10, time.Minute, nil)
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
 	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, encoder.Close(context.Background()))
+	})
+
+	assert.Equal(t, time.Duration(0), encoder.schemaRefreshAfter)
+	assert.Equal(t, time.Duration(0), encoder.revalidateAfter)
+
+	for i := 0; i < 3; i++ {
+		outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`)),
+		})
+		require.NoError(t, err)
+		require.NoError(t, outBatches[0][0].GetError())
+
+		b, err := outBatches[0][0].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00", string(b))
+	}
+
+	// The pinned version is only fetched once.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reqs))
+}
+
+func TestSchemaRegistryEncodeRetries(t *testing.T) {
+	latest := schemaResponseBody(t, testSchema, 3)
+
+	var reqs int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&reqs, 1) {
+		case 1:
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		case 2:
+			// Exceeds the request timeout.
+			time.Sleep(time.Millisecond * 500)
+		}
+		_, _ = w.Write(latest)
+	}))
+	t.Cleanup(ts.Close)
 
 	tests := []struct {
 		name        string
-		input       string
-		output      string
+		maxRetries  int
+		reqs        int32
 		errContains string
 	}{
-		{
-			name:   "successful message",
-			input:  `{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":"dancing"}`,
-			output: "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x02\x0edancing",
-		},
-		{
-			name:   "successful message null hobby",
-			input:  `{"Address":{"City":"foo","State":"bar"},"Name":"foo","MaybeHobby":null}`,
-			output: "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00",
-		},
-		{
-			name:        "message doesnt match schema",
-			input:       `{"Address":{"City":"foo","State":30},"Name":"foo","MaybeHobby":null}`,
-			errContains: "could not decode any json data in input",
-		},
+		{name: "retried", maxRetries: 2, reqs: 3},
+		{name: "retries exhausted", maxRetries: 1, reqs: 2, errContains: "context deadline exceeded"},
+		{name: "no retries", maxRetries: 0, reqs: 1, errContains: "request failed for schema subject 'foo'"},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
-			outBatches, err := encoder.ProcessBatch(
-				context.Background(),
-				service.MessageBatch{service.NewMessage([]byte(test.input))},
-			)
+			atomic.StoreInt32(&reqs, 0)
+
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+request_timeout: 100ms
+max_retries: %v
+`, ts.URL, test.maxRetries), nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
+
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(`{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`)),
+			})
 			require.NoError(t, err)
-			require.Len(t, outBatches, 1)
-			require.Len(t, outBatches[0], 1)
 
 			err = outBatches[0][0].GetError()
 			if test.errContains != "" {
@@ -154,236 +3075,222 @@ func TestSchemaRegistryEncodeAvroRawJSON(t *testing.T) {
 				assert.Contains(t, err.Error(), test.errContains)
 			} else {
 				require.NoError(t, err)
-
-				b, err := outBatches[0][0].AsBytes()
-				require.NoError(t, err)
-				assert.Equal(t, test.output, string(b))
 			}
+			assert.Equal(t, test.reqs, atomic.LoadInt32(&reqs))
+
+			require.NoError(t, encoder.Close(context.Background()))
 		})
 	}
-
-	require.NoError(t, encoder.Close(context.Background()))
-	encoder.cacheMut.Lock()
-	assert.Len(t, encoder.schemas, 0)
-	encoder.cacheMut.Unlock()
 }
 
-func TestSchemaRegistryEncodeAvro(t *testing.T) {
-	fooFirst, err := json.Marshal(struct {
-		Schema string `json:"schema"`
-		ID     int    `json:"id"`
-	}{
-		Schema: testSchema,
-		ID:     3,
-	})
-	require.NoError(t, err)
-
-	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
-		if path == "/subjects/foo/versions/latest" {
-			return fooFirst, nil
+func TestSchemaRegistryEncodeErrorResponses(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/subjects/foo/versions/latest":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40401,"message":"Subject 'foo' not found."}`))
+		case "/subjects/bar/versions/latest":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error_code":50001,"message":"Error in the backend data store"}`))
+		default:
+			http.Error(w, "nope", http.StatusBadGateway)
 		}
-		return nil, errors.New("nope")
-	})
-
-	subj, err := service.NewInterpolatedString("foo")
-	require.NoError(t, err)
-
-	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, time.Minute, nil)
-	require.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
 
 	tests := []struct {
-		name        string
-		input       string
-		output      string
-		errContains string
+		subject string
+		err     string
 	}{
 		{
-			name:   "successful message",
-			input:  `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":{"string":"dancing"}}`,
-			output: "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x02\x0edancing",
+			subject: "foo",
+			err:     "schema subject 'foo' not found by registry: registry responded with status 404 and error code 40401: Subject 'foo' not found.",
 		},
 		{
-			name:   "successful message null hobby",
-			input:  `{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`,
-			output: "\x00\x00\x00\x00\x03\x06foo\x02\x06foo\x06bar\x00",
+			subject: "bar",
+			err:     "request failed for schema subject 'bar': registry responded with status 500 and error code 50001: Error in the backend data store",
 		},
 		{
-			name:        "message doesnt match schema",
-			input:       `{"Address":{"my.namespace.com.address":"not this","Name":"foo"}}`,
-			errContains: "schema does not specify default value",
+			subject: "baz",
+			err:     "request failed for schema subject 'baz': registry responded with status 502",
 		},
 	}
 
 	for _, test := range tests {
 		test := test
-		t.Run(test.name, func(t *testing.T) {
-			outBatches, err := encoder.ProcessBatch(
-				context.Background(),
-				service.MessageBatch{service.NewMessage([]byte(test.input))},
-			)
+		t.Run(test.subject, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: %v
+max_retries: 0
+`, ts.URL, test.subject), nil)
 			require.NoError(t, err)
-			require.Len(t, outBatches, 1)
-			require.Len(t, outBatches[0], 1)
 
-			err = outBatches[0][0].GetError()
-			if test.errContains != "" {
-				require.Error(t, err)
-				assert.Contains(t, err.Error(), test.errContains)
-			} else {
-				require.NoError(t, err)
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
 
-				b, err := outBatches[0][0].AsBytes()
-				require.NoError(t, err)
-				assert.Equal(t, test.output, string(b))
-			}
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(`{"Name":"foo"}`)),
+			})
+			require.NoError(t, err)
+			assert.EqualError(t, outBatches[0][0].GetError(), test.err)
+
+			require.NoError(t, encoder.Close(context.Background()))
 		})
 	}
-
-	require.NoError(t, encoder.Close(context.Background()))
-	encoder.cacheMut.Lock()
-	assert.Len(t, encoder.schemas, 0)
-	encoder.cacheMut.Unlock()
 }
 
-func TestSchemaRegistryEncodeClearExpired(t *testing.T) {
-	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
-		return nil, fmt.Errorf("nope")
-	})
+func TestSchemaRegistryEncodeInterpolatedURL(t *testing.T) {
+	runRegistry := func(id int) string {
+		latest := schemaResponseBody(t, testSchema, id)
 
-	subj, err := service.NewInterpolatedString("foo")
-	require.NoError(t, err)
+		return runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+			if path == "/subjects/foo/versions/latest" {
+				return latest, nil
+			}
+			return nil, nil
+		})
+	}
+	urlA, urlB := runRegistry(3), runRegistry(4)
 
-	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, time.Minute, nil)
+	conf, err := schemaRegistryEncoderConfig().ParseYAML(`
+url: ${! meta("registry").or("") }
+subject: foo
+`, nil)
 	require.NoError(t, err)
-	require.NoError(t, encoder.Close(context.Background()))
 
-	tStale := time.Now().Add(-time.Hour).Unix()
-	tNotStale := time.Now().Unix()
-	tNearlyStale := time.Now().Add(-(schemaStaleAfter / 2)).Unix()
+	encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, encoder.Close(context.Background()))
+	})
 
-	encoder.cacheMut.Lock()
-	encoder.schemas = map[string]*cachedSchemaEncoder{
-		"5":  {lastUsedUnixSeconds: tStale, lastUpdatedUnixSeconds: tNotStale},
-		"10": {lastUsedUnixSeconds: tNotStale, lastUpdatedUnixSeconds: tNotStale},
-		"15": {lastUsedUnixSeconds: tNearlyStale, lastUpdatedUnixSeconds: tNotStale},
+	newMsg := func(registry string) *service.Message {
+		msg := service.NewMessage([]byte(`{"Address":{"my.namespace.com.address":{"City":"foo","State":"bar"}},"Name":"foo","MaybeHobby":null}`))
+		if registry != "" {
+			msg.MetaSet("registry", registry)
+		}
+		return msg
 	}
-	encoder.cacheMut.Unlock()
-
-	encoder.refreshEncoders()
-
-	encoder.cacheMut.Lock()
-	assert.Equal(t, map[string]*cachedSchemaEncoder{
-		"10": {lastUsedUnixSeconds: tNotStale, lastUpdatedUnixSeconds: tNotStale},
-		"15": {lastUsedUnixSeconds: tNearlyStale, lastUpdatedUnixSeconds: tNotStale},
-	}, encoder.schemas)
-	encoder.cacheMut.Unlock()
-}
 
-func TestSchemaRegistryEncodeRefresh(t *testing.T) {
-	fooFirst, err := json.Marshal(struct {
-		Schema string `json:"schema"`
-		ID     int    `json:"id"`
-	}{
-		Schema: testSchema,
-		ID:     2,
+	outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+		newMsg(urlA), newMsg(urlB), newMsg(urlA), newMsg(""),
 	})
 	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 4)
 
-	barFirst, err := json.Marshal(struct {
-		Schema string `json:"schema"`
-		ID     int    `json:"id"`
-	}{
-		Schema: testSchema,
-		ID:     12,
-	})
-	require.NoError(t, err)
+	for i, id := range []byte{3, 4, 3} {
+		require.NoError(t, outBatches[0][i].GetError())
+		b, err := outBatches[0][i].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0, 0, 0, 0, id}, b[:5], "%v", i)
+	}
+	assert.EqualError(t, outBatches[0][3].GetError(), "schema registry url resolved to an empty string")
+
+	encoder.cacheMut.RLock()
+	assert.Len(t, encoder.schemas, 2)
+	assert.Contains(t, encoder.schemas, schemaKey{url: urlA, subject: "foo"})
+	assert.Contains(t, encoder.schemas, schemaKey{url: urlB, subject: "foo"})
+	encoder.cacheMut.RUnlock()
+}
+
+func TestSchemaRegistryEncodeLogicalTypes(t *testing.T) {
+	latest := schemaResponseBody(t, logicalTypesTestSchema, 3)
 
-	var fooReqs, barReqs int32
 	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
-		switch path {
-		case "/subjects/foo/versions/latest":
-			atomic.AddInt32(&fooReqs, 1)
-			return fooFirst, nil
-		case "/subjects/bar/versions/latest":
-			atomic.AddInt32(&barReqs, 1)
-			return barFirst, nil
+		if path == "/subjects/foo/versions/latest" {
+			return latest, nil
 		}
-		return nil, errors.New("nope")
+		return nil, nil
 	})
 
-	subj, err := service.NewInterpolatedString("foo")
-	require.NoError(t, err)
-
-	encoder, err := newSchemaRegistryEncoder(urlStr, nil, subj, false, time.Minute*10, time.Minute, nil)
+	codec, err := goavro.NewCodec(logicalTypesTestSchema)
 	require.NoError(t, err)
-	require.NoError(t, encoder.Close(context.Background()))
 
-	tStale := time.Now().Add(-time.Hour).Unix()
-	tNotStale := time.Now().Unix()
-	tNearlyStale := time.Now().Add(-(schemaStaleAfter / 2)).Unix()
-
-	encoder.nowFn = func() time.Time {
-		return time.Unix(tNotStale, 0)
+	expected := map[string]interface{}{
+		"amount":     big.NewRat(-1234, 100),
+		"fee":        map[string]interface{}{"fee": big.NewRat(1, 2)},
+		"created_at": time.Date(2021, 1, 2, 3, 4, 5, 678000000, time.UTC),
+		"day":        time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
 	}
 
-	encoder.cacheMut.Lock()
-	encoder.schemas = map[string]*cachedSchemaEncoder{
-		"foo": {
-			lastUsedUnixSeconds:    tNotStale,
-			lastUpdatedUnixSeconds: tStale,
-			id:                     1,
+	tests := []struct {
+		name        string
+		config      string
+		input       string
+		errContains string
+	}{
+		{
+			name:   "structured",
+			config: `convert_logical_types: true`,
+			input:  `{"amount":"-12.34","fee":{"fee":0.5},"created_at":"2021-01-02T03:04:05.678Z","day":"2021-01-02"}`,
 		},
-		"bar": {
-			lastUsedUnixSeconds:    tNotStale,
-			lastUpdatedUnixSeconds: tNearlyStale,
-			id:                     11,
+		{
+			name: "raw json",
+			config: `
+convert_logical_types: true
+avro_raw_json: true
+`,
+			input: `{"amount":-12.34,"fee":"0.50","created_at":"2021-01-02T03:04:05.678Z","day":"2021-01-02"}`,
+		},
+		{
+			name:   "numeric timestamps",
+			config: `convert_logical_types: true`,
+			input:  `{"amount":"-12.34","fee":{"fee":"0.5"},"created_at":1609556645678,"day":18629}`,
+		},
+		{
+			name:        "not converted",
+			config:      `convert_logical_types: false`,
+			input:       `{"amount":"-12.34","fee":null,"created_at":"2021-01-02T03:04:05.678Z","day":"2021-01-02"}`,
+			errContains: "expected *big.Rat",
+		},
+		{
+			name:        "exceeds scale",
+			config:      `convert_logical_types: true`,
+			input:       `{"amount":"-12.345","fee":null,"created_at":1609556645678,"day":18629}`,
+			errContains: "root.amount: decimal value '-12.345' exceeds the scale of 2",
 		},
 	}
-	encoder.cacheMut.Unlock()
 
-	assert.Equal(t, int32(0), atomic.LoadInt32(&fooReqs))
-	assert.Equal(t, int32(0), atomic.LoadInt32(&barReqs))
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+%v
+`, urlStr, test.config), nil)
+			require.NoError(t, err)
 
-	encoder.refreshEncoders()
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
 
-	encoder.cacheMut.Lock()
-	encoder.schemas["foo"].encoder = nil
-	assert.Equal(t, map[string]*cachedSchemaEncoder{
-		"foo": {
-			lastUsedUnixSeconds:    tNotStale,
-			lastUpdatedUnixSeconds: tNotStale,
-			id:                     2,
-		},
-		"bar": {
-			lastUsedUnixSeconds:    tNotStale,
-			lastUpdatedUnixSeconds: tNearlyStale,
-			id:                     11,
-		},
-	}, encoder.schemas)
-	encoder.schemas["bar"].lastUpdatedUnixSeconds = tStale
-	encoder.cacheMut.Unlock()
+			outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{
+				service.NewMessage([]byte(test.input)),
+			})
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
 
-	assert.Equal(t, int32(1), atomic.LoadInt32(&fooReqs))
-	assert.Equal(t, int32(0), atomic.LoadInt32(&barReqs))
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+			} else {
+				require.NoError(t, err)
 
-	encoder.refreshEncoders()
+				b, err := outBatches[0][0].AsBytes()
+				require.NoError(t, err)
+				require.True(t, len(b) > 5)
 
-	encoder.cacheMut.Lock()
-	encoder.schemas["bar"].encoder = nil
-	assert.Equal(t, map[string]*cachedSchemaEncoder{
-		"foo": {
-			lastUsedUnixSeconds:    tNotStale,
-			lastUpdatedUnixSeconds: tNotStale,
-			id:                     2,
-		},
-		"bar": {
-			lastUsedUnixSeconds:    tNotStale,
-			lastUpdatedUnixSeconds: tNotStale,
-			id:                     12,
-		},
-	}, encoder.schemas)
-	encoder.cacheMut.Unlock()
+				native, remaining, err := codec.NativeFromBinary(b[5:])
+				require.NoError(t, err)
+				assert.Empty(t, remaining)
+				assert.Equal(t, expected, native)
+			}
 
-	assert.Equal(t, int32(1), atomic.LoadInt32(&fooReqs))
-	assert.Equal(t, int32(1), atomic.LoadInt32(&barReqs))
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+	}
 }
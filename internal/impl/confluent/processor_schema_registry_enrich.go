@@ -0,0 +1,205 @@
+package confluent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func schemaRegistryEnrichConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Integration").
+		Summary("Adds metadata describing the schema of messages in the Confluent wire format, obtained from a Confluent Schema Registry service, without decoding them.").
+		Description(`
+The schema ID is extracted from the Confluent wire format header at the beginning of each message, which consists of a zero magic byte followed by the four byte big-endian schema ID unless customised with the field ` + "[`header`](#header)" + `. Information about the schema is then obtained from a [Confluent Schema Registry service](https://docs.confluent.io/platform/current/schema-registry/index.html) and cached by ID, and the payload of the message, including its header, is left unchanged.
+
+This is useful for tracking the lineage of messages and routing them by schema without the cost of decoding them. Schemas of any type are supported, since they are not parsed.
+
+If the header of a message is missing or malformed, or the information of its schema cannot be obtained, then the message is left unchanged and flagged as having failed, and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).
+
+### Metadata
+
+This processor adds the following metadata fields to each message:
+
+` + "```text" + `
+- schema_registry_id
+- schema_registry_subject
+- schema_registry_version
+- schema_registry_type
+- schema_registry_hash
+` + "```" + `
+
+Where ` + "`schema_registry_subject` and `schema_registry_version`" + ` are the first subject and version registered with the schema ID, ` + "`schema_registry_type`" + ` is the type of the schema such as ` + "`AVRO`, `PROTOBUF` or `JSON`" + `, and ` + "`schema_registry_hash`" + ` is the hex encoded SHA-256 hash of the schema as returned by the registry.
+
+The subjects and versions of a schema ID are obtained from the ` + "`/schemas/ids/{id}/versions`" + ` endpoint of the registry, which requires Confluent Schema Registry 5.5 or newer.
+
+You can access these metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).`).
+		Field(service.NewStringField("url").Description("The base URL of the schema registry service.")).
+		Field(wireHeaderField()).
+		Field(service.NewTLSField("tls")).
+		Field(tlsMinVersionField()).
+		Version("4.2.0")
+}
+
+func init() {
+	err := service.RegisterProcessor(
+		"schema_registry_enrich", schemaRegistryEnrichConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newSchemaRegistryEnrichFromConfig(conf, mgr.Logger())
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type schemaRegistryEnrich struct {
+	// The decoder is only used for its cache of schema information by ID.
+	decoder *schemaRegistryDecoder
+}
+
+func newSchemaRegistryEnrichFromConfig(conf *service.ParsedConfig, logger *service.Logger) (*schemaRegistryEnrich, error) {
+	urlStr, err := conf.FieldString("url")
+	if err != nil {
+		return nil, err
+	}
+	tlsConf, err := registryTLSFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	header, err := wireHeaderFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := newSchemaRegistryDecoder(urlStr, tlsConf, false, logger)
+	if err != nil {
+		return nil, err
+	}
+	decoder.header = header
+	return &schemaRegistryEnrich{decoder: decoder}, nil
+}
+
+func (s *schemaRegistryEnrich) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	b, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	id, _, err := s.decoder.header.extract(b)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.decoder.getSchemaInfo(id)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.MetaSet("schema_registry_id", strconv.Itoa(id))
+	msg.MetaSet("schema_registry_subject", info.subject)
+	msg.MetaSet("schema_registry_version", strconv.Itoa(info.version))
+	msg.MetaSet("schema_registry_type", info.schemaType)
+	msg.MetaSet("schema_registry_hash", info.hash)
+	return service.MessageBatch{msg}, nil
+}
+
+func (s *schemaRegistryEnrich) Close(ctx context.Context) error {
+	return s.decoder.Close(ctx)
+}
+
+//------------------------------------------------------------------------------
+
+type cachedSchemaInfo struct {
+	lastUsedUnixSeconds int64
+
+	subject    string
+	version    int
+	schemaType string
+	hash       string
+}
+
+// getSchemaInfo returns information about the schema of an ID, which unlike
+// getCodec does not require the schema to be parsed.
+func (s *schemaRegistryDecoder) getSchemaInfo(id int) (*cachedSchemaInfo, error) {
+	s.cacheMut.RLock()
+	c, ok := s.infos[id]
+	s.cacheMut.RUnlock()
+	if ok {
+		atomic.StoreInt64(&c.lastUsedUnixSeconds, time.Now().Unix())
+		return c, nil
+	}
+
+	s.requestMut.Lock()
+	defer s.requestMut.Unlock()
+
+	// We might've been beaten to making the request, so check once more whilst
+	// within the request lock.
+	s.cacheMut.RLock()
+	c, ok = s.infos[id]
+	s.cacheMut.RUnlock()
+	if ok {
+		atomic.StoreInt64(&c.lastUsedUnixSeconds, time.Now().Unix())
+		return c, nil
+	}
+
+	resBytes, err := s.doRequest(fmt.Sprintf("/schemas/ids/%v", id), fmt.Sprintf("schema '%v'", id))
+	if err != nil {
+		return nil, err
+	}
+
+	schemaPayload := struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}{}
+	if err = json.Unmarshal(resBytes, &schemaPayload); err != nil {
+		s.logger.Errorf("failed to parse response for schema '%v': %v", id, err)
+		return nil, err
+	}
+
+	if resBytes, err = s.doRequest(fmt.Sprintf("/schemas/ids/%v/versions", id), fmt.Sprintf("versions of schema '%v'", id)); err != nil {
+		return nil, err
+	}
+
+	var versionsPayload []struct {
+		Subject string `json:"subject"`
+		Version int    `json:"version"`
+	}
+	if err = json.Unmarshal(resBytes, &versionsPayload); err != nil {
+		s.logger.Errorf("failed to parse response for versions of schema '%v': %v", id, err)
+		return nil, err
+	}
+	if len(versionsPayload) == 0 {
+		return nil, fmt.Errorf("schema '%v' is not registered with any subject", id)
+	}
+
+	// The schema type is omitted by the registry for Avro schemas.
+	schemaType := schemaPayload.SchemaType
+	if schemaType == "" {
+		schemaType = "AVRO"
+	}
+
+	hash := sha256.Sum256([]byte(schemaPayload.Schema))
+	c = &cachedSchemaInfo{
+		lastUsedUnixSeconds: time.Now().Unix(),
+		subject:             versionsPayload[0].Subject,
+		version:             versionsPayload[0].Version,
+		schemaType:          schemaType,
+		hash:                hex.EncodeToString(hash[:]),
+	}
+
+	s.cacheMut.Lock()
+	s.infos[id] = c
+	s.cacheMut.Unlock()
+
+	return c, nil
+}
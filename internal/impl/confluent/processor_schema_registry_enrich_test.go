@@ -0,0 +1,148 @@
+package confluent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestSchemaRegistryEnrich(t *testing.T) {
+	protoSchema := `syntax = "proto3"; message Foo { string bar = 1; }`
+
+	payload3, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: testSchema,
+	})
+	require.NoError(t, err)
+
+	payload4, err := json.Marshal(struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}{
+		Schema:     protoSchema,
+		SchemaType: "PROTOBUF",
+	})
+	require.NoError(t, err)
+
+	var requests int64
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		atomic.AddInt64(&requests, 1)
+		switch path {
+		case "/schemas/ids/3":
+			return payload3, nil
+		case "/schemas/ids/3/versions":
+			return []byte(`[{"subject":"foo-value","version":2},{"subject":"bar-value","version":1}]`), nil
+		case "/schemas/ids/4":
+			return payload4, nil
+		case "/schemas/ids/4/versions":
+			return []byte(`[{"subject":"baz-value","version":7}]`), nil
+		case "/schemas/ids/5":
+			return payload3, nil
+		case "/schemas/ids/5/versions":
+			return []byte(`[]`), nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	conf, err := schemaRegistryEnrichConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+`, urlStr), nil)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryEnrichFromConfig(conf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, proc.Close(context.Background()))
+	})
+
+	hashOf := func(s string) string {
+		h := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(h[:])
+	}
+
+	tests := []struct {
+		name        string
+		input       []byte
+		meta        map[string]string
+		errContains string
+	}{
+		{
+			name:  "avro schema",
+			input: []byte{0, 0, 0, 0, 3, 'f', 'o', 'o'},
+			meta: map[string]string{
+				"schema_registry_id":      "3",
+				"schema_registry_subject": "foo-value",
+				"schema_registry_version": "2",
+				"schema_registry_type":    "AVRO",
+				"schema_registry_hash":    hashOf(testSchema),
+			},
+		},
+		{
+			name:  "protobuf schema",
+			input: []byte{0, 0, 0, 0, 4},
+			meta: map[string]string{
+				"schema_registry_id":      "4",
+				"schema_registry_subject": "baz-value",
+				"schema_registry_version": "7",
+				"schema_registry_type":    "PROTOBUF",
+				"schema_registry_hash":    hashOf(protoSchema),
+			},
+		},
+		{
+			name:        "no subjects",
+			input:       []byte{0, 0, 0, 0, 5},
+			errContains: "schema '5' is not registered with any subject",
+		},
+		{
+			name:        "unknown schema",
+			input:       []byte{0, 0, 0, 0, 6},
+			errContains: "request failed for schema '6'",
+		},
+		{
+			name:        "no header",
+			input:       []byte{0, 2},
+			errContains: "message is too short to contain a schema ID",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			outBatch, err := proc.Process(context.Background(), service.NewMessage(test.input))
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, outBatch, 1)
+
+			b, err := outBatch[0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, test.input, b)
+
+			for k, v := range test.meta {
+				actual, exists := outBatch[0].MetaGet(k)
+				assert.True(t, exists, k)
+				assert.Equal(t, v, actual, k)
+			}
+		})
+	}
+
+	// Schema information is cached by ID.
+	before := atomic.LoadInt64(&requests)
+	_, err = proc.Process(context.Background(), service.NewMessage([]byte{0, 0, 0, 0, 3}))
+	require.NoError(t, err)
+	assert.Equal(t, before, atomic.LoadInt64(&requests))
+}
@@ -0,0 +1,91 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func schemaRegistryFrameConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Integration").
+		Summary("Prefixes messages with the Confluent wire format header, using a schema ID obtained from a metadata field.").
+		Description(`
+This processor does not contact a schema registry service, it only frames messages that are already encoded, such as archived Avro payloads that were stored separately from their schema IDs. The header consists of a zero magic byte followed by the four byte big-endian schema ID, which is the inverse of the header removed by ` + "[`schema_registry_decode`](/docs/components/processors/schema_registry_decode)" + `, and its layout can be customised with the field ` + "[`header`](#header)" + `.
+
+If the metadata field is missing, or is not a number within the range of schema IDs, then the message is left unchanged and flagged as having failed, and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).`).
+		Field(service.NewStringField("id_meta").
+			Description("The metadata key containing the schema ID of messages.").
+			Default("schema_registry_id")).
+		Field(wireHeaderField()).
+		Version("4.2.0")
+}
+
+func init() {
+	err := service.RegisterProcessor(
+		"schema_registry_frame", schemaRegistryFrameConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newSchemaRegistryFrameFromConfig(conf)
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type schemaRegistryFrame struct {
+	idMeta string
+	header wireHeader
+}
+
+func newSchemaRegistryFrameFromConfig(conf *service.ParsedConfig) (*schemaRegistryFrame, error) {
+	idMeta, err := conf.FieldString("id_meta")
+	if err != nil {
+		return nil, err
+	}
+	if idMeta == "" {
+		return nil, errors.New("id_meta must not be empty")
+	}
+	header, err := wireHeaderFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaRegistryFrame{
+		idMeta: idMeta,
+		header: header,
+	}, nil
+}
+
+func (s *schemaRegistryFrame) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	idStr, exists := msg.MetaGet(s.idMeta)
+	if !exists {
+		return nil, fmt.Errorf("metadata field '%v' is missing", s.idMeta)
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema ID from metadata field '%v': %w", s.idMeta, err)
+	}
+	if id < 0 || uint64(id) > s.header.maxID() {
+		return nil, fmt.Errorf("schema ID %v from metadata field '%v' is out of range", id, s.idMeta)
+	}
+
+	b, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+	if b, err = s.header.insert(int(id), b); err != nil {
+		return nil, err
+	}
+	msg.SetBytes(b)
+	return service.MessageBatch{msg}, nil
+}
+
+func (s *schemaRegistryFrame) Close(ctx context.Context) error {
+	return nil
+}
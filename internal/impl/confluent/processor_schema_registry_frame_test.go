@@ -0,0 +1,121 @@
+package confluent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestSchemaRegistryFrame(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		meta        map[string]string
+		output      string
+		id          int
+		errContains string
+	}{
+		{
+			name:   "default meta",
+			config: `{}`,
+			meta:   map[string]string{"schema_registry_id": "3"},
+			output: "\x00\x00\x00\x00\x03foo",
+			id:     3,
+		},
+		{
+			name:   "custom meta",
+			config: `id_meta: id`,
+			meta:   map[string]string{"id": "16909060"},
+			output: "\x00\x01\x02\x03\x04foo",
+			id:     16909060,
+		},
+		{
+			name: "custom header",
+			config: `
+header:
+  magic_byte: false
+  id_width: 2
+  byte_order: little_endian
+`,
+			meta:   map[string]string{"schema_registry_id": "258"},
+			output: "\x02\x01foo",
+			id:     258,
+		},
+		{
+			name: "out of range of custom header",
+			config: `
+header:
+  id_width: 2
+`,
+			meta:        map[string]string{"schema_registry_id": "65536"},
+			errContains: "out of range",
+		},
+		{
+			name:        "missing meta",
+			config:      `id_meta: id`,
+			meta:        map[string]string{"schema_registry_id": "3"},
+			errContains: "metadata field 'id' is missing",
+		},
+		{
+			name:        "not a number",
+			config:      `{}`,
+			meta:        map[string]string{"schema_registry_id": "nope"},
+			errContains: "failed to parse schema ID",
+		},
+		{
+			name:        "out of range",
+			config:      `{}`,
+			meta:        map[string]string{"schema_registry_id": "4294967296"},
+			errContains: "out of range",
+		},
+		{
+			name:        "negative",
+			config:      `{}`,
+			meta:        map[string]string{"schema_registry_id": "-1"},
+			errContains: "out of range",
+		},
+	}
+
+	spec := schemaRegistryFrameConfig()
+	env := service.NewEnvironment()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := spec.ParseYAML(test.config, env)
+			require.NoError(t, err)
+
+			proc, err := newSchemaRegistryFrameFromConfig(conf)
+			require.NoError(t, err)
+
+			inMsg := service.NewMessage([]byte(`foo`))
+			for k, v := range test.meta {
+				inMsg.MetaSet(k, v)
+			}
+
+			outBatch, err := proc.Process(context.Background(), inMsg)
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, outBatch, 1)
+
+			b, err := outBatch[0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, test.output, string(b))
+
+			// The header is the inverse of that removed when decoding.
+			id, remaining, err := proc.header.extract(b)
+			require.NoError(t, err)
+			assert.Equal(t, test.id, id)
+			assert.Equal(t, "foo", string(remaining))
+
+			require.NoError(t, proc.Close(context.Background()))
+		})
+	}
+}
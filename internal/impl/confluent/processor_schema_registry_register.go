@@ -0,0 +1,547 @@
+package confluent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func schemaRegistryRegisterConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Integration").
+		Summary("Registers the schemas of a batch of messages with a Confluent Schema Registry service, in the order of their dependencies.").
+		Description(`
+Each message of a batch must be a JSON object in the format of the request body of the schema registry [register API](https://docs.confluent.io/platform/current/schema-registry/develop/api.html#post--subjects-(string-%20subject)-versions), consisting of the field ` + "`schema`" + `, and the optional fields ` + "`schemaType` and `references`" + `. The subject of each schema is derived from the field ` + "[`subject`](#subject)" + `.
+
+Schemas that reference the subjects of other schemas within the same batch are registered after them, and otherwise schemas are registered in the order of the batch. The version of a reference to a subject registered within the same batch can be omitted, in which case it is set to the version that was registered. Batches containing circular references are rejected without registering any schemas.
+
+### Failures
+
+The schema registry does not support transactions, and therefore schemas that were registered before a failure remain registered. Instead, registration stops at the first schema that fails, where its message and the messages of all schemas that were not attempted are flagged as having failed, and the errors can be caught using error handling methods outlined [here](/docs/configuration/error_handling).
+
+### Metadata
+
+Messages of schemas that are successfully registered have the following metadata fields added:
+
+` + "```" + `
+- schema_registry_subject
+- schema_registry_id
+- schema_registry_version
+` + "```" + `
+
+### Dry Run
+
+When the field ` + "[`dry_run`](#dry_run)" + ` is set to ` + "`true`" + ` schemas are not registered, and instead each schema is checked for compatibility with the latest version of its subject using the compatibility API of the schema registry. Schemas of subjects that do not exist yet are considered compatible, as registering them would create the subject. Since no schemas are registered, references to subjects of the same batch that are not registered yet cannot be resolved by the registry. Messages of schemas that are checked successfully have the following metadata fields added instead:
+
+` + "```" + `
+- schema_registry_subject
+- schema_registry_compatible
+` + "```" + `
+
+The metadata field ` + "`schema_registry_compatible`" + ` is set to either ` + "`true` or `false`" + `. When a check fails for other reasons, such as an invalid schema, the message is flagged as having failed and the remaining schemas are still checked.
+
+When the field ` + "[`compatibility_check`](#compatibility_check)" + ` is set to ` + "`local`" + ` the latest version of each subject is instead fetched from the registry and compared with the schema locally, following the schema resolution rules of Avro. This works even when the compatibility API of the registry is restricted, but only supports Avro schemas without references, and checks against the level set by ` + "[`compatibility_level`](#compatibility_level)" + ` rather than the level configured for the subject within the registry. The latest version of a subject is only fetched once per batch. Messages of schemas that are found to be incompatible also have the metadata field ` + "`schema_registry_incompatibility`" + ` added, describing the first incompatibility found.`).
+		Field(service.NewStringField("url").Description("The base URL of the schema registry service.")).
+		Field(service.NewInterpolatedStringField("subject").Description("The subject to register the schema of each message under.").
+			Example("foo").
+			Example(`${! meta("subject") }`)).
+		Field(service.NewBoolField("dry_run").
+			Description("Whether to check the compatibility of schemas with the latest versions of their subjects rather than registering them.").
+			Advanced().Default(false)).
+		Field(service.NewStringAnnotatedEnumField("compatibility_check", map[string]string{
+			"registry": "Check compatibility using the compatibility API of the schema registry.",
+			"local":    "Fetch the latest version of each subject from the schema registry and check compatibility locally, which only supports Avro schemas.",
+		}).Description("How the compatibility of schemas is checked when `dry_run` is `true`.").Advanced().Default("registry")).
+		Field(service.NewStringAnnotatedEnumField("compatibility_level", map[string]string{
+			"backward": "Data written with the latest version of a subject can be read with the new schema.",
+			"forward":  "Data written with the new schema can be read with the latest version of a subject.",
+			"full":     "Both backward and forward compatible.",
+		}).Description("The compatibility level checked for when `compatibility_check` is `local`.").Advanced().Default("backward")).
+		Field(service.NewTLSField("tls")).
+		Field(tlsMinVersionField()).
+		Version("4.2.0")
+}
+
+func init() {
+	err := service.RegisterBatchProcessor(
+		"schema_registry_register", schemaRegistryRegisterConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+			return newSchemaRegistryRegisterFromConfig(conf, mgr.Logger())
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type schemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type schemaRegisterRequest struct {
+	Schema     string            `json:"schema"`
+	SchemaType string            `json:"schemaType,omitempty"`
+	References []schemaReference `json:"references,omitempty"`
+}
+
+type schemaRegistryRegister struct {
+	client  *http.Client
+	subject *service.InterpolatedString
+	dryRun  bool
+
+	// When set, compatibility is checked locally rather than by the registry.
+	localCheck         bool
+	compatibilityLevel string
+
+	schemaRegistryBaseURL *url.URL
+
+	logger *service.Logger
+}
+
+func newSchemaRegistryRegisterFromConfig(conf *service.ParsedConfig, logger *service.Logger) (*schemaRegistryRegister, error) {
+	urlStr, err := conf.FieldString("url")
+	if err != nil {
+		return nil, err
+	}
+	subject, err := conf.FieldInterpolatedString("subject")
+	if err != nil {
+		return nil, err
+	}
+	dryRun, err := conf.FieldBool("dry_run")
+	if err != nil {
+		return nil, err
+	}
+	compatibilityCheck, err := conf.FieldString("compatibility_check")
+	if err != nil {
+		return nil, err
+	}
+	compatibilityLevel, err := conf.FieldString("compatibility_level")
+	if err != nil {
+		return nil, err
+	}
+	tlsConf, err := registryTLSFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSchemaRegistryRegister(urlStr, tlsConf, subject, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.dryRun = dryRun
+	s.localCheck = compatibilityCheck == "local"
+	s.compatibilityLevel = compatibilityLevel
+	return s, nil
+}
+
+func newSchemaRegistryRegister(urlStr string, tlsConf *tls.Config, subject *service.InterpolatedString, logger *service.Logger) (*schemaRegistryRegister, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	s := &schemaRegistryRegister{
+		schemaRegistryBaseURL: u,
+		subject:               subject,
+		logger:                logger,
+	}
+
+	s.client = newRegistryClient(tlsConf)
+	return s, nil
+}
+
+func (s *schemaRegistryRegister) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	batch = batch.Copy()
+
+	subjects := make([]string, len(batch))
+	reqs := make([]schemaRegisterRequest, len(batch))
+	for i, msg := range batch {
+		subjects[i] = batch.InterpolatedString(i, s.subject)
+
+		b, err := msg.AsBytes()
+		if err == nil {
+			err = json.Unmarshal(b, &reqs[i])
+		}
+		if err == nil && reqs[i].Schema == "" {
+			err = errors.New("field schema is missing or empty")
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to parse schema of message %v: %w", i, err)
+			for _, m := range batch {
+				m.SetError(err)
+			}
+			return []service.MessageBatch{batch}, nil
+		}
+	}
+
+	order, err := registrationOrder(subjects, reqs)
+	if err != nil {
+		for _, m := range batch {
+			m.SetError(err)
+		}
+		return []service.MessageBatch{batch}, nil
+	}
+
+	if s.dryRun {
+		s.checkCompatibility(ctx, batch, order, subjects, reqs)
+		return []service.MessageBatch{batch}, nil
+	}
+
+	// The registered versions of subjects, used for resolving references.
+	versions := map[string]int{}
+	for n, i := range order {
+		for j, ref := range reqs[i].References {
+			if v, exists := versions[ref.Subject]; exists && ref.Version <= 0 {
+				reqs[i].References[j].Version = v
+			}
+		}
+
+		id, version, err := s.register(ctx, subjects[i], reqs[i])
+		if err != nil {
+			batch[i].SetError(fmt.Errorf("failed to register schema for subject '%v': %w", subjects[i], err))
+			for _, j := range order[n+1:] {
+				batch[j].SetError(fmt.Errorf("schema for subject '%v' was not registered due to a previous failure registering subject '%v'", subjects[j], subjects[i]))
+			}
+			s.logger.Errorf("Registered %v of %v schemas before failing to register subject '%v': %v", n, len(order), subjects[i], err)
+			break
+		}
+
+		versions[subjects[i]] = version
+		batch[i].MetaSet("schema_registry_subject", subjects[i])
+		batch[i].MetaSet("schema_registry_id", strconv.Itoa(id))
+		batch[i].MetaSet("schema_registry_version", strconv.Itoa(version))
+	}
+	return []service.MessageBatch{batch}, nil
+}
+
+// registrationOrder returns the indexes of schemas sorted such that each schema
+// comes after the schemas of the subjects it references, and schemas of the
+// same subject remain in the order of the batch. Schemas are otherwise kept in
+// the order of the batch.
+func registrationOrder(subjects []string, reqs []schemaRegisterRequest) ([]int, error) {
+	bySubject := map[string][]int{}
+	for i, subject := range subjects {
+		bySubject[subject] = append(bySubject[subject], i)
+	}
+
+	dependents := make([][]int, len(subjects))
+	remaining := make([]int, len(subjects))
+	addDependency := func(from, to int) {
+		dependents[from] = append(dependents[from], to)
+		remaining[to]++
+	}
+	for _, indexes := range bySubject {
+		for j := 1; j < len(indexes); j++ {
+			addDependency(indexes[j-1], indexes[j])
+		}
+	}
+	for i, req := range reqs {
+		for _, ref := range req.References {
+			for _, j := range bySubject[ref.Subject] {
+				if j != i {
+					addDependency(j, i)
+				}
+			}
+		}
+	}
+
+	var ready, order []int
+	for i := range subjects {
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, j := range dependents[i] {
+			if remaining[j]--; remaining[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+
+	if len(order) < len(subjects) {
+		var cyclic []string
+		for i, r := range remaining {
+			if r > 0 {
+				cyclic = append(cyclic, subjects[i])
+			}
+		}
+		return nil, fmt.Errorf("schemas of subjects %v contain circular references", cyclic)
+	}
+	return order, nil
+}
+
+// register registers a schema under a subject and returns the ID and version
+// assigned to it by the registry.
+func (s *schemaRegistryRegister) register(ctx context.Context, subject string, req schemaRegisterRequest) (id, version int, err error) {
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Registering a schema only returns its ID, and therefore the version is
+	// obtained by looking the schema up afterwards.
+	if _, err = s.doRequest(ctx, "POST", fmt.Sprintf("/subjects/%s/versions", subject), reqBytes); err != nil {
+		return 0, 0, err
+	}
+	return s.lookup(ctx, subject, req)
+}
+
+// lookup returns the ID and version of a schema that is already registered
+// under a subject.
+func (s *schemaRegistryRegister) lookup(ctx context.Context, subject string, req schemaRegisterRequest) (id, version int, err error) {
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	resBytes, err := s.doRequest(ctx, "POST", fmt.Sprintf("/subjects/%s", subject), reqBytes)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	resPayload := struct {
+		ID      int `json:"id"`
+		Version int `json:"version"`
+	}{}
+	if err = json.Unmarshal(resBytes, &resPayload); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return resPayload.ID, resPayload.Version, nil
+}
+
+// checkCompatibility checks the schemas of a batch for compatibility with the
+// latest versions of their subjects, in the order they would be registered.
+func (s *schemaRegistryRegister) checkCompatibility(ctx context.Context, batch service.MessageBatch, order []int, subjects []string, reqs []schemaRegisterRequest) {
+	// The latest schemas of subjects fetched for local checks, where subjects
+	// that do not exist have a nil schema.
+	latest := map[string]*avroType{}
+	for _, i := range order {
+		var compatible bool
+		var incompatibility, err error
+		if s.localCheck {
+			incompatibility, err = s.compatibleLocal(ctx, subjects[i], reqs[i], latest)
+			compatible = incompatibility == nil
+		} else {
+			compatible, err = s.compatible(ctx, subjects[i], reqs[i])
+		}
+		if err != nil {
+			batch[i].SetError(fmt.Errorf("failed to check compatibility of schema for subject '%v': %w", subjects[i], err))
+			continue
+		}
+		batch[i].MetaSet("schema_registry_subject", subjects[i])
+		batch[i].MetaSet("schema_registry_compatible", strconv.FormatBool(compatible))
+		if incompatibility != nil {
+			batch[i].MetaSet("schema_registry_incompatibility", incompatibility.Error())
+		}
+	}
+}
+
+// compatible returns whether a schema is compatible with the latest version of
+// a subject, where schemas of subjects that do not exist are compatible.
+func (s *schemaRegistryRegister) compatible(ctx context.Context, subject string, req schemaRegisterRequest) (bool, error) {
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+
+	resBytes, err := s.doRequest(ctx, "POST", fmt.Sprintf("/compatibility/subjects/%s/versions/latest", subject), reqBytes)
+	if err != nil {
+		var rErr *registryError
+		if errors.As(err, &rErr) && rErr.errorCode == registryErrSubjectNotFound {
+			return true, nil
+		}
+		return false, err
+	}
+
+	resPayload := struct {
+		IsCompatible bool `json:"is_compatible"`
+	}{}
+	if err = json.Unmarshal(resBytes, &resPayload); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return resPayload.IsCompatible, nil
+}
+
+// compatibleLocal checks a schema for compatibility with the latest version of
+// a subject locally, and returns an error describing the first incompatibility
+// found, or nil if the schema is compatible. Schemas of subjects that do not
+// exist are compatible.
+func (s *schemaRegistryRegister) compatibleLocal(ctx context.Context, subject string, req schemaRegisterRequest, latest map[string]*avroType) (incompatibility, err error) {
+	if req.SchemaType != "" && req.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("local compatibility checks only support Avro schemas, got %v", req.SchemaType)
+	}
+	if len(req.References) > 0 {
+		return nil, errors.New("local compatibility checks do not support schema references")
+	}
+	if _, err := goavro.NewCodec(req.Schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	schema, err := parseAvroSchema(req.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	latestSchema, exists := latest[subject]
+	if !exists {
+		if latestSchema, err = s.latestSchema(ctx, subject); err != nil {
+			return nil, err
+		}
+		latest[subject] = latestSchema
+	}
+	if latestSchema == nil {
+		return nil, nil
+	}
+
+	if s.compatibilityLevel != "forward" {
+		if err := avroCheckReadable(schema, latestSchema); err != nil {
+			return fmt.Errorf("data written with the latest schema cannot be read: %w", err), nil
+		}
+	}
+	if s.compatibilityLevel != "backward" {
+		if err := avroCheckReadable(latestSchema, schema); err != nil {
+			return fmt.Errorf("data written with the new schema cannot be read by the latest schema: %w", err), nil
+		}
+	}
+	return nil, nil
+}
+
+// latestSchema fetches and parses the latest Avro schema of a subject, which is
+// nil when the subject does not exist.
+func (s *schemaRegistryRegister) latestSchema(ctx context.Context, subject string) (*avroType, error) {
+	resBytes, err := s.doRequest(ctx, "GET", fmt.Sprintf("/subjects/%s/versions/latest", subject), nil)
+	if err != nil {
+		var rErr *registryError
+		if errors.As(err, &rErr) && rErr.errorCode == registryErrSubjectNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch latest schema: %w", err)
+	}
+
+	var resPayload schemaRegisterRequest
+	if err = json.Unmarshal(resBytes, &resPayload); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resPayload.SchemaType != "" && resPayload.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("local compatibility checks only support Avro schemas, the latest schema is %v", resPayload.SchemaType)
+	}
+	if len(resPayload.References) > 0 {
+		return nil, errors.New("local compatibility checks do not support schema references, which the latest schema contains")
+	}
+	schema, err := parseAvroSchema(resPayload.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse latest schema: %w", err)
+	}
+	return schema, nil
+}
+
+// doRequest performs a request against the schema registry at the given path,
+// retrying on failures other than rejections, and returns the response body.
+func (s *schemaRegistryRegister) doRequest(ctx context.Context, method, reqPath string, body []byte) ([]byte, error) {
+	ctx, done := context.WithTimeout(ctx, time.Second*5)
+	defer done()
+
+	reqURL := *s.schemaRegistryBaseURL
+	reqURL.Path = path.Join(reqURL.Path, reqPath)
+
+	var resBytes []byte
+	var err error
+	for i := 0; i < 3; i++ {
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(ctx, method, reqURL.String(), bytes.NewReader(body)); err != nil {
+			return nil, err
+		}
+		req.Header.Add("Accept", "application/vnd.schemaregistry.v1+json")
+		req.Header.Add("Content-Type", "application/vnd.schemaregistry.v1+json")
+
+		var res *http.Response
+		if res, err = s.client.Do(req); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		resBytes, err = io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		if res.StatusCode >= 400 && res.StatusCode < 500 {
+			// The request was rejected, which is not worth retrying.
+			return nil, registryResponseError(res.StatusCode, resBytes)
+		}
+		if res.StatusCode != http.StatusOK {
+			err = registryResponseError(res.StatusCode, resBytes)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resBytes, nil
+}
+
+// The error code returned by the registry when a subject does not exist.
+const registryErrSubjectNotFound = 40401
+
+// registryError is a failed response of the schema registry.
+type registryError struct {
+	statusCode int
+	errorCode  int
+	message    string
+}
+
+func (e *registryError) Error() string {
+	status := fmt.Sprintf("registry responded with status %v", e.statusCode)
+	if e.errorCode != 0 {
+		status += fmt.Sprintf(" and error code %v", e.errorCode)
+	}
+	if e.message != "" {
+		return status + ": " + e.message
+	}
+	return status
+}
+
+// registryResponseError returns an error from a failed response, including the
+// error code and message of the registry when present.
+func registryResponseError(statusCode int, resBytes []byte) error {
+	rErr := &registryError{statusCode: statusCode}
+	resPayload := struct {
+		ErrorCode int    `json:"error_code"`
+		Message   string `json:"message"`
+	}{}
+	if json.Unmarshal(resBytes, &resPayload) == nil {
+		rErr.errorCode = resPayload.ErrorCode
+		rErr.message = resPayload.Message
+	}
+	return rErr
+}
+
+func (s *schemaRegistryRegister) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,400 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+type testRegistry struct {
+	mut          sync.Mutex
+	registered   []string
+	requests     map[string]schemaRegisterRequest
+	reject       map[string]bool
+	incompatible map[string]bool
+}
+
+func runRegisterSchemaRegistryServer(t *testing.T, reject ...string) (*testRegistry, string) {
+	t.Helper()
+
+	reg := &testRegistry{
+		requests:     map[string]schemaRegisterRequest{},
+		reject:       map[string]bool{},
+		incompatible: map[string]bool{},
+	}
+	for _, r := range reject {
+		reg.reject[r] = true
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/versions/latest") {
+			subject := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/subjects/"), "/versions/latest")
+
+			reg.mut.Lock()
+			defer reg.mut.Unlock()
+
+			if req, exists := reg.requests[subject]; exists {
+				resBytes, err := json.Marshal(req)
+				require.NoError(t, err)
+				_, _ = w.Write(resBytes)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40401,"message":"Subject not found"}`))
+			return
+		}
+		if r.Method != "POST" {
+			http.Error(w, "nope", http.StatusMethodNotAllowed)
+			return
+		}
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var req schemaRegisterRequest
+		require.NoError(t, json.Unmarshal(b, &req))
+
+		reg.mut.Lock()
+		defer reg.mut.Unlock()
+
+		if strings.HasPrefix(r.URL.Path, "/compatibility/subjects/") {
+			subject := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/compatibility/subjects/"), "/versions/latest")
+			if reg.reject[subject] {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"error_code":42201,"message":"Invalid schema"}`))
+				return
+			}
+			for _, s := range reg.registered {
+				if s == subject {
+					_, _ = fmt.Fprintf(w, `{"is_compatible":%v}`, !reg.incompatible[subject])
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40401,"message":"Subject not found"}`))
+			return
+		}
+
+		subject := strings.TrimPrefix(r.URL.Path, "/subjects/")
+		if strings.HasSuffix(subject, "/versions") {
+			subject = strings.TrimSuffix(subject, "/versions")
+			if reg.reject[subject] {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"error_code":42201,"message":"Invalid schema"}`))
+				return
+			}
+			reg.registered = append(reg.registered, subject)
+			reg.requests[subject] = req
+			_, _ = fmt.Fprintf(w, `{"id":%v}`, len(reg.registered)+10)
+			return
+		}
+
+		for i, s := range reg.registered {
+			if s == subject {
+				_, _ = fmt.Fprintf(w, `{"subject":"%v","id":%v,"version":%v,"schema":"{}"}`, subject, i+11, i+1)
+				return
+			}
+		}
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	t.Cleanup(ts.Close)
+
+	return reg, ts.URL
+}
+
+func TestSchemaRegistryRegisterConfigParse(t *testing.T) {
+	spec := schemaRegistryRegisterConfig()
+
+	conf, err := spec.ParseYAML(`
+url: huh#%#@$u*not////::example.com
+subject: foo
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newSchemaRegistryRegisterFromConfig(conf, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse url")
+}
+
+func TestSchemaRegistryRegisterOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		subjects []string
+		refs     [][]string
+		order    []int
+		errStr   string
+	}{
+		{
+			name:     "no references",
+			subjects: []string{"a", "b", "c"},
+			refs:     [][]string{nil, nil, nil},
+			order:    []int{0, 1, 2},
+		},
+		{
+			name:     "references within the batch",
+			subjects: []string{"a", "b", "c"},
+			refs:     [][]string{{"c"}, {"a", "x"}, nil},
+			order:    []int{2, 0, 1},
+		},
+		{
+			name:     "same subject",
+			subjects: []string{"a", "b", "a"},
+			refs:     [][]string{nil, {"a"}, nil},
+			order:    []int{0, 2, 1},
+		},
+		{
+			name:     "circular references",
+			subjects: []string{"a", "b", "c"},
+			refs:     [][]string{{"b"}, {"a"}, nil},
+			errStr:   "schemas of subjects [a b] contain circular references",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			reqs := make([]schemaRegisterRequest, len(test.subjects))
+			for i, refs := range test.refs {
+				for _, r := range refs {
+					reqs[i].References = append(reqs[i].References, schemaReference{Name: r, Subject: r})
+				}
+			}
+
+			order, err := registrationOrder(test.subjects, reqs)
+			if test.errStr != "" {
+				require.EqualError(t, err, test.errStr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.order, order)
+		})
+	}
+}
+
+func TestSchemaRegistryRegister(t *testing.T) {
+	reg, urlStr := runRegisterSchemaRegistryServer(t)
+
+	subj, err := service.NewInterpolatedString(`${! meta("subject") }`)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryRegister(urlStr, nil, subj, nil)
+	require.NoError(t, err)
+
+	var batch service.MessageBatch
+	for _, m := range []struct {
+		subject string
+		content string
+	}{
+		{subject: "user", content: `{"schema":"{\"type\":\"record\",\"name\":\"user\",\"fields\":[{\"name\":\"address\",\"type\":\"address\"}]}","references":[{"name":"address","subject":"address"}]}`},
+		{subject: "address", content: `{"schema":"{\"type\":\"record\",\"name\":\"address\",\"fields\":[]}"}`},
+	} {
+		msg := service.NewMessage([]byte(m.content))
+		msg.MetaSet("subject", m.subject)
+		batch = append(batch, msg)
+	}
+
+	outBatches, err := proc.ProcessBatch(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 2)
+
+	assert.Equal(t, []string{"address", "user"}, reg.registered)
+	assert.Equal(t, []schemaReference{
+		{Name: "address", Subject: "address", Version: 1},
+	}, reg.requests["user"].References)
+
+	for i, exp := range []map[string]string{
+		{"schema_registry_subject": "user", "schema_registry_id": "12", "schema_registry_version": "2"},
+		{"schema_registry_subject": "address", "schema_registry_id": "11", "schema_registry_version": "1"},
+	} {
+		require.NoError(t, outBatches[0][i].GetError())
+		for k, v := range exp {
+			actual, _ := outBatches[0][i].MetaGet(k)
+			assert.Equal(t, v, actual, k)
+		}
+	}
+
+	require.NoError(t, proc.Close(context.Background()))
+}
+
+func TestSchemaRegistryRegisterFailFast(t *testing.T) {
+	reg, urlStr := runRegisterSchemaRegistryServer(t, "b")
+
+	subj, err := service.NewInterpolatedString(`${! meta("subject") }`)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryRegister(urlStr, nil, subj, nil)
+	require.NoError(t, err)
+
+	var batch service.MessageBatch
+	for _, s := range []string{"a", "b", "c"} {
+		msg := service.NewMessage([]byte(`{"schema":"\"string\""}`))
+		msg.MetaSet("subject", s)
+		batch = append(batch, msg)
+	}
+
+	outBatches, err := proc.ProcessBatch(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 3)
+
+	assert.Equal(t, []string{"a"}, reg.registered)
+
+	require.NoError(t, outBatches[0][0].GetError())
+	id, _ := outBatches[0][0].MetaGet("schema_registry_id")
+	assert.Equal(t, "11", id)
+
+	assert.EqualError(t, outBatches[0][1].GetError(), "failed to register schema for subject 'b': registry responded with status 422 and error code 42201: Invalid schema")
+	assert.EqualError(t, outBatches[0][2].GetError(), "schema for subject 'c' was not registered due to a previous failure registering subject 'b'")
+
+	// Messages are not registered at all when any of them is invalid.
+	batch = service.MessageBatch{
+		service.NewMessage([]byte(`{"schema":"\"string\""}`)),
+		service.NewMessage([]byte(`{"nope":true}`)),
+	}
+	outBatches, err = proc.ProcessBatch(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	for _, m := range outBatches[0] {
+		assert.EqualError(t, m.GetError(), "failed to parse schema of message 1: field schema is missing or empty")
+	}
+	assert.Equal(t, []string{"a"}, reg.registered)
+
+	require.NoError(t, proc.Close(context.Background()))
+}
+
+func TestSchemaRegistryRegisterDryRun(t *testing.T) {
+	reg, urlStr := runRegisterSchemaRegistryServer(t, "c")
+	reg.registered = []string{"a", "b"}
+	reg.incompatible["b"] = true
+
+	subj, err := service.NewInterpolatedString(`${! meta("subject") }`)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryRegister(urlStr, nil, subj, nil)
+	require.NoError(t, err)
+	proc.dryRun = true
+
+	var batch service.MessageBatch
+	for _, s := range []string{"a", "b", "c", "d"} {
+		msg := service.NewMessage([]byte(`{"schema":"\"string\""}`))
+		msg.MetaSet("subject", s)
+		batch = append(batch, msg)
+	}
+
+	outBatches, err := proc.ProcessBatch(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 4)
+
+	// Nothing new is registered.
+	assert.Equal(t, []string{"a", "b"}, reg.registered)
+
+	for i, exp := range []string{"true", "false", "", "true"} {
+		msg := outBatches[0][i]
+		if exp == "" {
+			assert.EqualError(t, msg.GetError(), "failed to check compatibility of schema for subject 'c': registry responded with status 422 and error code 42201: Invalid schema")
+			continue
+		}
+		require.NoError(t, msg.GetError())
+		compatible, _ := msg.MetaGet("schema_registry_compatible")
+		assert.Equal(t, exp, compatible, i)
+		_, exists := msg.MetaGet("schema_registry_id")
+		assert.False(t, exists)
+	}
+
+	require.NoError(t, proc.Close(context.Background()))
+}
+
+func TestSchemaRegistryRegisterDryRunLocal(t *testing.T) {
+	reg, urlStr := runRegisterSchemaRegistryServer(t)
+	reg.requests["user"] = schemaRegisterRequest{
+		Schema: `{"type":"record","name":"user","fields":[{"name":"name","type":"string"}]}`,
+	}
+	reg.requests["proto"] = schemaRegisterRequest{
+		Schema:     `syntax = "proto3";`,
+		SchemaType: "PROTOBUF",
+	}
+
+	subj, err := service.NewInterpolatedString(`${! meta("subject") }`)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryRegister(urlStr, nil, subj, nil)
+	require.NoError(t, err)
+	proc.dryRun = true
+	proc.localCheck = true
+	proc.compatibilityLevel = "backward"
+
+	var batch service.MessageBatch
+	for _, m := range []struct {
+		subject string
+		schema  string
+	}{
+		{subject: "user", schema: `{"type":"record","name":"user","fields":[{"name":"name","type":"string"},{"name":"age","type":"int","default":0}]}`},
+		{subject: "user", schema: `{"type":"record","name":"user","fields":[{"name":"name","type":"string"},{"name":"age","type":"int"}]}`},
+		{subject: "new", schema: `"string"`},
+		{subject: "proto", schema: `"string"`},
+		{subject: "user", schema: `{"type":"nope"}`},
+	} {
+		b, err := json.Marshal(schemaRegisterRequest{Schema: m.schema})
+		require.NoError(t, err)
+		msg := service.NewMessage(b)
+		msg.MetaSet("subject", m.subject)
+		batch = append(batch, msg)
+	}
+
+	outBatches, err := proc.ProcessBatch(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, outBatches, 1)
+	require.Len(t, outBatches[0], 5)
+
+	assert.Empty(t, reg.registered)
+
+	for i, exp := range []struct {
+		compatible      string
+		incompatibility string
+		errContains     string
+	}{
+		{compatible: "true"},
+		{compatible: "false", incompatibility: "data written with the latest schema cannot be read: root.age: reader field is missing from the writer and has no default"},
+		{compatible: "true"},
+		{errContains: "the latest schema is PROTOBUF"},
+		{errContains: "failed to parse schema"},
+	} {
+		msg := outBatches[0][i]
+		if exp.errContains != "" {
+			require.Error(t, msg.GetError(), i)
+			assert.Contains(t, msg.GetError().Error(), exp.errContains, i)
+			continue
+		}
+		require.NoError(t, msg.GetError(), i)
+		compatible, _ := msg.MetaGet("schema_registry_compatible")
+		assert.Equal(t, exp.compatible, compatible, i)
+		incompatibility, _ := msg.MetaGet("schema_registry_incompatibility")
+		assert.Equal(t, exp.incompatibility, incompatibility, i)
+	}
+
+	// Forward compatibility checks that the latest schema can read the new
+	// schema instead.
+	proc.compatibilityLevel = "forward"
+	outBatches, err = proc.ProcessBatch(context.Background(), batch[:2])
+	require.NoError(t, err)
+	for _, msg := range outBatches[0] {
+		require.NoError(t, msg.GetError())
+		compatible, _ := msg.MetaGet("schema_registry_compatible")
+		assert.Equal(t, "true", compatible)
+	}
+
+	require.NoError(t, proc.Close(context.Background()))
+}
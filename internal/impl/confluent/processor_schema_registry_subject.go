@@ -0,0 +1,160 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func schemaRegistrySubjectConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Integration").
+		Summary("Sets a metadata field on messages to the schema subject derived from their topic and record name, following the naming strategies of a Confluent Schema Registry service.").
+		Description(`
+This processor does not contact a schema registry service, it only computes subject names. This is useful for routing or logging messages based on their subject before they reach processors such as ` + "[`schema_registry_encode`](/docs/components/processors/schema_registry_encode)" + `.
+
+### Strategies
+
+The naming strategies mirror the [subject name strategies](https://docs.confluent.io/platform/current/schema-registry/serdes-develop/index.html#subject-name-strategy) of Confluent serializers:
+
+- ` + "`topic_name`" + `: The subject is the topic name suffixed with ` + "`-value`, or `-key` when [`key`](#key) is `true`" + `.
+- ` + "`record_name`" + `: The subject is the fully-qualified record name.
+- ` + "`topic_record_name`" + `: The subject is the topic name and the fully-qualified record name joined with a hyphen.
+
+If a value required by the strategy resolves to an empty string then the message is left unchanged and flagged as having failed, and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).`).
+		Field(service.NewStringAnnotatedEnumField("strategy", map[string]string{
+			"topic_name":        "Derive the subject from the topic name.",
+			"record_name":       "Derive the subject from the fully-qualified record name.",
+			"topic_record_name": "Derive the subject from both the topic name and the fully-qualified record name.",
+		}).Description("The naming strategy used to derive subjects.").Default("topic_name")).
+		Field(service.NewInterpolatedStringField("topic").
+			Description("The topic of messages, used by the `topic_name` and `topic_record_name` strategies.").
+			Default(`${! meta("kafka_topic").or("") }`)).
+		Field(service.NewInterpolatedStringField("record_name").
+			Description("The fully-qualified name of the record of messages, used by the `record_name` and `topic_record_name` strategies.").
+			Default("").
+			Example("com.example.User").
+			Example(`${! meta("record_name").or("") }`)).
+		Field(service.NewBoolField("key").
+			Description("Whether the subject is derived for message keys rather than values, this only affects the `topic_name` strategy.").
+			Advanced().Default(false)).
+		Field(service.NewStringField("meta").
+			Description("The metadata key to set the subject to.").
+			Default("schema_registry_subject")).
+		Version("4.2.0")
+}
+
+func init() {
+	err := service.RegisterProcessor(
+		"schema_registry_subject", schemaRegistrySubjectConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newSchemaRegistrySubjectFromConfig(conf)
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type subjectStrategy func(topic, recordName string, key bool) (string, error)
+
+func topicNameStrategy(topic, _ string, key bool) (string, error) {
+	if topic == "" {
+		return "", errors.New("topic is empty")
+	}
+	if key {
+		return topic + "-key", nil
+	}
+	return topic + "-value", nil
+}
+
+func recordNameStrategy(_, recordName string, _ bool) (string, error) {
+	if recordName == "" {
+		return "", errors.New("record name is empty")
+	}
+	return recordName, nil
+}
+
+func topicRecordNameStrategy(topic, recordName string, _ bool) (string, error) {
+	if topic == "" {
+		return "", errors.New("topic is empty")
+	}
+	if recordName == "" {
+		return "", errors.New("record name is empty")
+	}
+	return topic + "-" + recordName, nil
+}
+
+func strToSubjectStrategy(str string) (subjectStrategy, error) {
+	switch str {
+	case "topic_name":
+		return topicNameStrategy, nil
+	case "record_name":
+		return recordNameStrategy, nil
+	case "topic_record_name":
+		return topicRecordNameStrategy, nil
+	}
+	return nil, fmt.Errorf("strategy not recognised: %v", str)
+}
+
+//------------------------------------------------------------------------------
+
+type schemaRegistrySubject struct {
+	strategy   subjectStrategy
+	topic      *service.InterpolatedString
+	recordName *service.InterpolatedString
+	key        bool
+	metaKey    string
+}
+
+func newSchemaRegistrySubjectFromConfig(conf *service.ParsedConfig) (*schemaRegistrySubject, error) {
+	strategyStr, err := conf.FieldString("strategy")
+	if err != nil {
+		return nil, err
+	}
+	strategy, err := strToSubjectStrategy(strategyStr)
+	if err != nil {
+		return nil, err
+	}
+	topic, err := conf.FieldInterpolatedString("topic")
+	if err != nil {
+		return nil, err
+	}
+	recordName, err := conf.FieldInterpolatedString("record_name")
+	if err != nil {
+		return nil, err
+	}
+	key, err := conf.FieldBool("key")
+	if err != nil {
+		return nil, err
+	}
+	metaKey, err := conf.FieldString("meta")
+	if err != nil {
+		return nil, err
+	}
+	return &schemaRegistrySubject{
+		strategy:   strategy,
+		topic:      topic,
+		recordName: recordName,
+		key:        key,
+		metaKey:    metaKey,
+	}, nil
+}
+
+func (s *schemaRegistrySubject) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	subject, err := s.strategy(s.topic.String(msg), s.recordName.String(msg), s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive schema subject: %w", err)
+	}
+	msg.MetaSet(s.metaKey, subject)
+	return service.MessageBatch{msg}, nil
+}
+
+func (s *schemaRegistrySubject) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,109 @@
+package confluent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestSchemaRegistrySubject(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		meta        map[string]string
+		metaKey     string
+		output      string
+		errContains string
+	}{
+		{
+			name:    "topic name",
+			config:  `{}`,
+			meta:    map[string]string{"kafka_topic": "foo"},
+			metaKey: "schema_registry_subject",
+			output:  "foo-value",
+		},
+		{
+			name: "topic name key",
+			config: `
+key: true
+meta: subject
+`,
+			meta:    map[string]string{"kafka_topic": "foo"},
+			metaKey: "subject",
+			output:  "foo-key",
+		},
+		{
+			name: "topic name missing topic",
+			config: `
+strategy: topic_name
+`,
+			errContains: "topic is empty",
+		},
+		{
+			name: "record name",
+			config: `
+strategy: record_name
+record_name: ${! meta("record") }
+`,
+			meta:    map[string]string{"kafka_topic": "foo", "record": "com.example.User"},
+			metaKey: "schema_registry_subject",
+			output:  "com.example.User",
+		},
+		{
+			name: "record name missing record",
+			config: `
+strategy: record_name
+`,
+			meta:        map[string]string{"kafka_topic": "foo"},
+			errContains: "record name is empty",
+		},
+		{
+			name: "topic record name",
+			config: `
+strategy: topic_record_name
+topic: bar
+record_name: com.example.User
+key: true
+`,
+			metaKey: "schema_registry_subject",
+			output:  "bar-com.example.User",
+		},
+	}
+
+	spec := schemaRegistrySubjectConfig()
+	env := service.NewEnvironment()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := spec.ParseYAML(test.config, env)
+			require.NoError(t, err)
+
+			proc, err := newSchemaRegistrySubjectFromConfig(conf)
+			require.NoError(t, err)
+
+			inMsg := service.NewMessage([]byte(`{}`))
+			for k, v := range test.meta {
+				inMsg.MetaSet(k, v)
+			}
+
+			outBatch, err := proc.Process(context.Background(), inMsg)
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, outBatch, 1)
+
+			v, exists := outBatch[0].MetaGet(test.metaKey)
+			require.True(t, exists)
+			assert.Equal(t, test.output, v)
+
+			require.NoError(t, proc.Close(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func schemaRegistryTranslateConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Integration").
+		Summary("Rewrites the schema IDs of messages encoded with the schemas of a source Confluent Schema Registry service to the IDs of the same schemas within a target registry, without decoding the messages.").
+		Description(`
+This processor is intended for migrating data between registries, where the same schemas are assigned different IDs. The schema ID of each message is read from its header, the schema of the ID is fetched from the source registry, and the ID of the same schema is obtained from the target registry, registering it first when ` + "[`register`](#register)" + ` is ` + "`true`" + `. The header of the message is then replaced with one containing the target ID, and the payload is left unchanged, which assumes that the schemas are identical in both registries.
+
+The schema is registered with, or looked up within, the subject set by ` + "[`subject`](#subject)" + `, or when it is not set the first subject of the schema within the source registry. Schemas that reference other schemas are registered with their references unchanged, and therefore the referenced subjects must already exist within the target registry with the same versions.
+
+The target ID of each source ID and subject is cached for the lifetime of the processor, and therefore the registries are only contacted once for each schema.
+
+### Metadata
+
+Messages that are successfully translated have the following metadata fields added:
+
+` + "```text" + `
+- schema_registry_source_id
+- schema_registry_id
+` + "```" + `
+
+Where ` + "`schema_registry_source_id`" + ` is the ID of the schema within the source registry and ` + "`schema_registry_id`" + ` is its ID within the target registry.
+
+Messages that fail to be translated are left unchanged and flagged as having failed, and the errors can be caught using error handling methods outlined [here](/docs/configuration/error_handling).`).
+		Field(service.NewStringField("source_url").Description("The base URL of the schema registry service that messages were encoded with.")).
+		Field(service.NewStringField("target_url").Description("The base URL of the schema registry service to translate schema IDs to.")).
+		Field(service.NewInterpolatedStringField("subject").
+			Description("The subject to register or look up schemas with in the target registry. When not set the first subject of each schema within the source registry is used.").
+			Example(`${! meta("kafka_topic") }-value`).
+			Optional()).
+		Field(service.NewBoolField("register").
+			Description("Whether to register schemas that do not exist within the target registry yet. When `false` messages with schemas that do not exist within the target registry fail.").
+			Default(true)).
+		Field(wireHeaderField()).
+		Field(service.NewTLSField("tls").Description("Custom TLS settings used for both registries.")).
+		Field(tlsMinVersionField()).
+		Version("4.2.0")
+}
+
+func init() {
+	err := service.RegisterProcessor(
+		"schema_registry_translate", schemaRegistryTranslateConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newSchemaRegistryTranslateFromConfig(conf, mgr.Logger())
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// translateKey identifies a target schema ID by the source schema ID and the
+// subject it is registered with.
+type translateKey struct {
+	id      int
+	subject string
+}
+
+type schemaRegistryTranslate struct {
+	subject  *service.InterpolatedString
+	register bool
+	header   wireHeader
+
+	// The decoder and register processor are only used for their requests to
+	// the source and target registries respectively.
+	source *schemaRegistryDecoder
+	target *schemaRegistryRegister
+
+	ids        map[translateKey]int
+	cacheMut   sync.RWMutex
+	requestMut sync.Mutex
+}
+
+func newSchemaRegistryTranslateFromConfig(conf *service.ParsedConfig, logger *service.Logger) (*schemaRegistryTranslate, error) {
+	sourceURL, err := conf.FieldString("source_url")
+	if err != nil {
+		return nil, err
+	}
+	targetURL, err := conf.FieldString("target_url")
+	if err != nil {
+		return nil, err
+	}
+	var subject *service.InterpolatedString
+	if conf.Contains("subject") {
+		if subject, err = conf.FieldInterpolatedString("subject"); err != nil {
+			return nil, err
+		}
+	}
+	register, err := conf.FieldBool("register")
+	if err != nil {
+		return nil, err
+	}
+	header, err := wireHeaderFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf, err := registryTLSFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	target, err := newSchemaRegistryRegister(targetURL, tlsConf, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+	source, err := newSchemaRegistryDecoder(sourceURL, tlsConf, false, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaRegistryTranslate{
+		subject:  subject,
+		register: register,
+		header:   header,
+		source:   source,
+		target:   target,
+		ids:      map[translateKey]int{},
+	}, nil
+}
+
+func (s *schemaRegistryTranslate) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	b, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	id, remaining, err := s.header.extract(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject string
+	if s.subject != nil {
+		if subject = s.subject.String(msg); subject == "" {
+			return nil, errors.New("schema subject resolved to an empty string")
+		}
+	}
+
+	targetID, err := s.getTargetID(ctx, id, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err = s.header.insert(targetID, remaining); err != nil {
+		return nil, err
+	}
+	msg.SetBytes(b)
+	msg.MetaSet("schema_registry_source_id", strconv.Itoa(id))
+	msg.MetaSet("schema_registry_id", strconv.Itoa(targetID))
+	return service.MessageBatch{msg}, nil
+}
+
+// getTargetID returns the ID within the target registry of the schema of a
+// source ID, where an empty subject is resolved to the first subject of the
+// schema within the source registry.
+func (s *schemaRegistryTranslate) getTargetID(ctx context.Context, id int, subject string) (int, error) {
+	key := translateKey{id: id, subject: subject}
+
+	s.cacheMut.RLock()
+	targetID, ok := s.ids[key]
+	s.cacheMut.RUnlock()
+	if ok {
+		return targetID, nil
+	}
+
+	s.requestMut.Lock()
+	defer s.requestMut.Unlock()
+
+	// We might've been beaten to making the requests, so check once more whilst
+	// within the request lock.
+	s.cacheMut.RLock()
+	targetID, ok = s.ids[key]
+	s.cacheMut.RUnlock()
+	if ok {
+		return targetID, nil
+	}
+
+	req, err := s.sourceSchema(id)
+	if err != nil {
+		return 0, err
+	}
+
+	targetSubject := subject
+	if targetSubject == "" {
+		if targetSubject, err = s.sourceSubject(id); err != nil {
+			return 0, err
+		}
+	}
+
+	if s.register {
+		targetID, _, err = s.target.register(ctx, targetSubject, req)
+		if err != nil {
+			return 0, fmt.Errorf("failed to register schema '%v' with subject '%v' in target registry: %w", id, targetSubject, err)
+		}
+	} else {
+		targetID, _, err = s.target.lookup(ctx, targetSubject, req)
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up schema '%v' with subject '%v' in target registry: %w", id, targetSubject, err)
+		}
+	}
+
+	s.cacheMut.Lock()
+	s.ids[key] = targetID
+	s.cacheMut.Unlock()
+	return targetID, nil
+}
+
+// sourceSchema fetches the schema of an ID from the source registry in the form
+// of a request to register it.
+func (s *schemaRegistryTranslate) sourceSchema(id int) (schemaRegisterRequest, error) {
+	var req schemaRegisterRequest
+
+	resBytes, err := s.source.doRequest(fmt.Sprintf("/schemas/ids/%v", id), fmt.Sprintf("schema '%v'", id))
+	if err != nil {
+		return req, err
+	}
+	if err = json.Unmarshal(resBytes, &req); err != nil {
+		return req, fmt.Errorf("failed to parse response for schema '%v': %w", id, err)
+	}
+	if req.Schema == "" {
+		return req, fmt.Errorf("schema '%v' returned by source registry is empty", id)
+	}
+	return req, nil
+}
+
+// sourceSubject fetches the first subject of the schema of an ID from the source
+// registry.
+func (s *schemaRegistryTranslate) sourceSubject(id int) (string, error) {
+	resBytes, err := s.source.doRequest(fmt.Sprintf("/schemas/ids/%v/versions", id), fmt.Sprintf("versions of schema '%v'", id))
+	if err != nil {
+		return "", err
+	}
+
+	var versionsPayload []struct {
+		Subject string `json:"subject"`
+	}
+	if err = json.Unmarshal(resBytes, &versionsPayload); err != nil {
+		return "", fmt.Errorf("failed to parse response for versions of schema '%v': %w", id, err)
+	}
+	if len(versionsPayload) == 0 {
+		return "", fmt.Errorf("schema '%v' is not registered with any subject", id)
+	}
+	return versionsPayload[0].Subject, nil
+}
+
+func (s *schemaRegistryTranslate) Close(ctx context.Context) error {
+	return s.source.Close(ctx)
+}
@@ -0,0 +1,178 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func runTranslateSourceServer(t *testing.T) (*int32, string) {
+	t.Helper()
+
+	schemaBytes, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: testSchema,
+	})
+	require.NoError(t, err)
+
+	var reqs int32
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		atomic.AddInt32(&reqs, 1)
+		switch path {
+		case "/schemas/ids/3":
+			return schemaBytes, nil
+		case "/schemas/ids/3/versions":
+			return []byte(`[{"subject":"foo","version":1}]`), nil
+		}
+		return nil, nil
+	})
+	return &reqs, urlStr
+}
+
+func TestSchemaRegistryTranslate(t *testing.T) {
+	sourceReqs, sourceURL := runTranslateSourceServer(t)
+	reg, targetURL := runRegisterSchemaRegistryServer(t)
+
+	conf, err := schemaRegistryTranslateConfig().ParseYAML(fmt.Sprintf(`
+source_url: %v
+target_url: %v
+`, sourceURL, targetURL), nil)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryTranslateFromConfig(conf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, proc.Close(context.Background()))
+	})
+
+	for i := 0; i < 3; i++ {
+		res, err := proc.Process(context.Background(), service.NewMessage([]byte("\x00\x00\x00\x00\x03\x06foo\x00\x00")))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		b, err := res[0].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, "\x00\x00\x00\x00\x0b\x06foo\x00\x00", string(b))
+
+		v, _ := res[0].MetaGet("schema_registry_source_id")
+		assert.Equal(t, "3", v)
+		v, _ = res[0].MetaGet("schema_registry_id")
+		assert.Equal(t, "11", v)
+	}
+
+	// The schema is only fetched and registered once.
+	assert.Equal(t, int32(2), atomic.LoadInt32(sourceReqs))
+
+	reg.mut.Lock()
+	assert.Equal(t, []string{"foo"}, reg.registered)
+	assert.Equal(t, testSchema, reg.requests["foo"].Schema)
+	reg.mut.Unlock()
+}
+
+func TestSchemaRegistryTranslateSubject(t *testing.T) {
+	_, sourceURL := runTranslateSourceServer(t)
+	reg, targetURL := runRegisterSchemaRegistryServer(t)
+
+	conf, err := schemaRegistryTranslateConfig().ParseYAML(fmt.Sprintf(`
+source_url: %v
+target_url: %v
+subject: ${! meta("topic").or("") }
+`, sourceURL, targetURL), nil)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryTranslateFromConfig(conf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, proc.Close(context.Background()))
+	})
+
+	for _, topic := range []string{"bar", "baz"} {
+		msg := service.NewMessage([]byte("\x00\x00\x00\x00\x03\x06foo\x00\x00"))
+		msg.MetaSet("topic", topic+"-value")
+
+		res, err := proc.Process(context.Background(), msg)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+	}
+
+	_, err = proc.Process(context.Background(), service.NewMessage([]byte("\x00\x00\x00\x00\x03\x06foo\x00\x00")))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "schema subject resolved to an empty string")
+
+	reg.mut.Lock()
+	assert.Equal(t, []string{"bar-value", "baz-value"}, reg.registered)
+	reg.mut.Unlock()
+}
+
+func TestSchemaRegistryTranslateNoRegister(t *testing.T) {
+	_, sourceURL := runTranslateSourceServer(t)
+	reg, targetURL := runRegisterSchemaRegistryServer(t)
+
+	conf, err := schemaRegistryTranslateConfig().ParseYAML(fmt.Sprintf(`
+source_url: %v
+target_url: %v
+register: false
+`, sourceURL, targetURL), nil)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryTranslateFromConfig(conf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, proc.Close(context.Background()))
+	})
+
+	_, err = proc.Process(context.Background(), service.NewMessage([]byte("\x00\x00\x00\x00\x03\x06foo\x00\x00")))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to look up schema '3' with subject 'foo' in target registry")
+
+	// Failures are not cached.
+	reg.mut.Lock()
+	reg.registered = append(reg.registered, "foo")
+	reg.mut.Unlock()
+
+	res, err := proc.Process(context.Background(), service.NewMessage([]byte("\x00\x00\x00\x00\x03\x06foo\x00\x00")))
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	b, err := res[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00\x00\x0b\x06foo\x00\x00", string(b))
+}
+
+func TestSchemaRegistryTranslateErrors(t *testing.T) {
+	_, sourceURL := runTranslateSourceServer(t)
+	_, targetURL := runRegisterSchemaRegistryServer(t)
+
+	conf, err := schemaRegistryTranslateConfig().ParseYAML(fmt.Sprintf(`
+source_url: %v
+target_url: %v
+`, sourceURL, targetURL), nil)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryTranslateFromConfig(conf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, proc.Close(context.Background()))
+	})
+
+	for _, test := range []struct {
+		input       string
+		errContains string
+	}{
+		{input: "", errContains: "message is empty"},
+		{input: "\x00\x00\x00", errContains: "message is too short"},
+		{input: "\x00\x00\x00\x00\x04\x06foo", errContains: "schema '4' not found by registry"},
+	} {
+		_, err := proc.Process(context.Background(), service.NewMessage([]byte(test.input)))
+		require.Error(t, err, test.input)
+		assert.Contains(t, err.Error(), test.errContains)
+	}
+}
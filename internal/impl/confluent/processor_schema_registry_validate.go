@@ -0,0 +1,194 @@
+package confluent
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func schemaRegistryValidateConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Integration").
+		Summary("Validates that messages in the Confluent wire format decode cleanly with the schema of their embedded schema ID, and optionally attempts to repair their framing.").
+		Description(`
+Each message must begin with the Confluent wire format header, consisting of a zero magic byte followed by the four byte big-endian schema ID unless customised with the field ` + "[`header`](#header)" + `, and the remainder of the message must decode as exactly one record of the schema obtained from a [Confluent Schema Registry service](https://docs.confluent.io/platform/current/schema-registry/index.html). Messages that are valid are left unchanged, this processor does not decode them.
+
+A message fails validation when its header is missing or malformed, when its schema cannot be obtained, when its payload is truncated or otherwise does not match the schema, or when it contains trailing bytes after the record. Messages that fail validation are left unchanged and flagged as having failed, and the errors can be caught using error handling methods outlined [here](/docs/configuration/error_handling).
+
+Currently only Avro schemas are supported, and schemas are cached by ID in the same way as ` + "[`schema_registry_decode`](/docs/components/processors/schema_registry_decode)" + `.
+
+### Repairs
+
+When the field ` + "[`repair`](#repair)" + ` is ` + "`true`" + ` a best-effort attempt is made to repair messages that fail validation, and the following framing faults are detected:
+
+- ` + "`double_header`" + `: The header was applied twice, where the message validates once the outer header is removed.
+- ` + "`trailing_padding`" + `: The record is followed by zero bytes, which are removed.
+
+Repaired messages have the metadata field ` + "`schema_registry_repair`" + ` set to the name of the fault that was repaired. Truncated messages cannot be repaired, and messages that cannot be repaired are flagged as having failed with the original validation error.
+
+### Sampling
+
+When validating production traffic it is often sufficient to validate a sample of messages, which bounds the load on the schema registry and the cost of decoding. When the field ` + "[`sample_every`](#sample_every)" + ` is greater than one only the first message of every N messages is validated, and all other messages pass through untouched. Sampled messages that pass validation, or are repaired, have the metadata field ` + "`schema_registry_sampled`" + ` set to ` + "`true`" + `, and sampled messages that fail validation are flagged as having failed.`).
+		Field(service.NewStringField("url").Description("The base URL of the schema registry service.")).
+		Field(service.NewBoolField("repair").
+			Description("Whether to attempt to repair the framing of messages that fail validation.").
+			Default(false)).
+		Field(service.NewIntField("sample_every").
+			Description("Validate only one in every N messages, where other messages pass through without being validated. A value of one validates every message.").
+			Advanced().Default(1).
+			Example(100)).
+		Field(wireHeaderField()).
+		Field(service.NewTLSField("tls")).
+		Field(tlsMinVersionField()).
+		Version("4.2.0")
+}
+
+func init() {
+	err := service.RegisterProcessor(
+		"schema_registry_validate", schemaRegistryValidateConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newSchemaRegistryValidateFromConfig(conf, mgr.Logger())
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type schemaRegistryValidate struct {
+	// The decoder is only used for its cache of codecs by schema ID.
+	decoder *schemaRegistryDecoder
+	repair  bool
+
+	// Messages are sampled when the count of messages seen is a multiple of
+	// sampleEvery.
+	sampleEvery int64
+	seen        int64
+}
+
+func newSchemaRegistryValidateFromConfig(conf *service.ParsedConfig, logger *service.Logger) (*schemaRegistryValidate, error) {
+	urlStr, err := conf.FieldString("url")
+	if err != nil {
+		return nil, err
+	}
+	tlsConf, err := registryTLSFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	repair, err := conf.FieldBool("repair")
+	if err != nil {
+		return nil, err
+	}
+	sampleEvery, err := conf.FieldInt("sample_every")
+	if err != nil {
+		return nil, err
+	}
+	if sampleEvery < 1 {
+		return nil, fmt.Errorf("sample_every must be at least 1, got %v", sampleEvery)
+	}
+	header, err := wireHeaderFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := newSchemaRegistryDecoder(urlStr, tlsConf, false, logger)
+	if err != nil {
+		return nil, err
+	}
+	decoder.header = header
+	return &schemaRegistryValidate{
+		decoder:     decoder,
+		repair:      repair,
+		sampleEvery: int64(sampleEvery),
+	}, nil
+}
+
+func (s *schemaRegistryValidate) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	sampling := s.sampleEvery > 1
+	if sampling && (atomic.AddInt64(&s.seen, 1)-1)%s.sampleEvery != 0 {
+		return service.MessageBatch{msg}, nil
+	}
+
+	b, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	validateErr := s.validate(b)
+	if validateErr != nil {
+		if !s.repair {
+			return nil, validateErr
+		}
+		repaired, fault := s.repairFraming(b)
+		if repaired == nil {
+			return nil, validateErr
+		}
+		msg.SetBytes(repaired)
+		msg.MetaSet("schema_registry_repair", fault)
+	}
+	if sampling {
+		msg.MetaSet("schema_registry_sampled", "true")
+	}
+	return service.MessageBatch{msg}, nil
+}
+
+// validate checks that a message consists of a header followed by exactly one
+// record of the schema of its ID.
+func (s *schemaRegistryValidate) validate(b []byte) error {
+	id, remaining, err := s.decoder.header.extract(b)
+	if err != nil {
+		return err
+	}
+	codec, err := s.decoder.getCodec(id)
+	if err != nil {
+		return err
+	}
+	_, rest, err := codec.NativeFromBinary(remaining)
+	if err != nil {
+		return fmt.Errorf("failed to decode payload with schema %v: %w", id, err)
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("payload contains %v trailing bytes after the record of schema %v", len(rest), id)
+	}
+	return nil
+}
+
+// repairFraming attempts to repair a message that failed validation, and
+// returns the repaired message along with the name of the fault, or nil if the
+// message could not be repaired.
+func (s *schemaRegistryValidate) repairFraming(b []byte) ([]byte, string) {
+	id, remaining, err := s.decoder.header.extract(b)
+	if err != nil {
+		return nil, ""
+	}
+
+	if _, _, err := s.decoder.header.extract(remaining); err == nil && s.validate(remaining) == nil {
+		return remaining, "double_header"
+	}
+
+	codec, err := s.decoder.getCodec(id)
+	if err != nil {
+		return nil, ""
+	}
+	if _, rest, err := codec.NativeFromBinary(remaining); err == nil && len(rest) > 0 && isZeroBytes(rest) {
+		return b[:len(b)-len(rest)], "trailing_padding"
+	}
+	return nil, ""
+}
+
+func isZeroBytes(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *schemaRegistryValidate) Close(ctx context.Context) error {
+	return s.decoder.Close(ctx)
+}
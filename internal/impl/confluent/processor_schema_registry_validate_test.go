@@ -0,0 +1,221 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestSchemaRegistryValidate(t *testing.T) {
+	payload3, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: testSchema,
+	})
+	require.NoError(t, err)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/schemas/ids/3" {
+			return payload3, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	codec, err := goavro.NewCodecForStandardJSON(testSchema)
+	require.NoError(t, err)
+
+	record, err := codec.BinaryFromNative(nil, map[string]interface{}{
+		"Name":       "foo",
+		"Address":    nil,
+		"MaybeHobby": nil,
+	})
+	require.NoError(t, err)
+
+	header := []byte{0, 0, 0, 0, 3}
+	valid := append(append([]byte{}, header...), record...)
+
+	tests := []struct {
+		name        string
+		input       []byte
+		repair      bool
+		output      []byte
+		fault       string
+		errContains string
+	}{
+		{
+			name:   "valid",
+			input:  valid,
+			output: valid,
+		},
+		{
+			name:        "truncated",
+			input:       valid[:len(valid)-2],
+			repair:      true,
+			errContains: "failed to decode payload with schema 3",
+		},
+		{
+			name:        "padded without repair",
+			input:       append(append([]byte{}, valid...), 0, 0, 0),
+			errContains: "payload contains 3 trailing bytes after the record of schema 3",
+		},
+		{
+			name:   "padded",
+			input:  append(append([]byte{}, valid...), 0, 0, 0),
+			repair: true,
+			output: valid,
+			fault:  "trailing_padding",
+		},
+		{
+			name:        "trailing garbage",
+			input:       append(append([]byte{}, valid...), 1, 2),
+			repair:      true,
+			errContains: "payload contains 2 trailing bytes after the record of schema 3",
+		},
+		{
+			name:        "double header without repair",
+			input:       append(append([]byte{}, header...), valid...),
+			errContains: "schema 3",
+		},
+		{
+			name:   "double header",
+			input:  append(append([]byte{}, header...), valid...),
+			repair: true,
+			output: valid,
+			fault:  "double_header",
+		},
+		{
+			name:        "bad magic byte",
+			input:       append([]byte{1}, valid[1:]...),
+			repair:      true,
+			errContains: "serialization format version number 1 not supported",
+		},
+		{
+			name:        "unknown schema",
+			input:       append([]byte{0, 0, 0, 0, 4}, record...),
+			repair:      true,
+			errContains: "request failed for schema '4'",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			decoder, err := newSchemaRegistryDecoder(urlStr, nil, false, nil)
+			require.NoError(t, err)
+
+			proc := &schemaRegistryValidate{decoder: decoder, repair: test.repair}
+			t.Cleanup(func() {
+				require.NoError(t, proc.Close(context.Background()))
+			})
+
+			outBatch, err := proc.Process(context.Background(), service.NewMessage(test.input))
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, outBatch, 1)
+
+			b, err := outBatch[0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, test.output, b)
+
+			fault, exists := outBatch[0].MetaGet("schema_registry_repair")
+			assert.Equal(t, test.fault != "", exists)
+			assert.Equal(t, test.fault, fault)
+		})
+	}
+}
+
+func TestSchemaRegistryValidateSampling(t *testing.T) {
+	payload3, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: testSchema,
+	})
+	require.NoError(t, err)
+
+	urlStr := runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		if path == "/schemas/ids/3" {
+			return payload3, nil
+		}
+		return nil, errors.New("nope")
+	})
+
+	conf, err := schemaRegistryValidateConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+sample_every: 3
+`, urlStr), nil)
+	require.NoError(t, err)
+
+	proc, err := newSchemaRegistryValidateFromConfig(conf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, proc.Close(context.Background()))
+	})
+
+	codec, err := goavro.NewCodecForStandardJSON(testSchema)
+	require.NoError(t, err)
+
+	valid, err := codec.BinaryFromNative([]byte{0, 0, 0, 0, 3}, map[string]interface{}{
+		"Name":       "foo",
+		"Address":    nil,
+		"MaybeHobby": nil,
+	})
+	require.NoError(t, err)
+	invalid := []byte("not a valid payload")
+
+	tests := []struct {
+		input   []byte
+		sampled bool
+		failed  bool
+	}{
+		{input: valid, sampled: true},
+		{input: invalid},
+		{input: invalid},
+		{input: invalid, failed: true},
+		{input: valid},
+		{input: invalid},
+		{input: valid, sampled: true},
+	}
+
+	for i, test := range tests {
+		outBatch, err := proc.Process(context.Background(), service.NewMessage(test.input))
+		if test.failed {
+			require.Error(t, err, i)
+			continue
+		}
+		require.NoError(t, err, i)
+		require.Len(t, outBatch, 1, i)
+
+		b, err := outBatch[0].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, test.input, b, i)
+
+		sampled, exists := outBatch[0].MetaGet("schema_registry_sampled")
+		assert.Equal(t, test.sampled, exists, i)
+		if test.sampled {
+			assert.Equal(t, "true", sampled, i)
+		}
+	}
+}
+
+func TestSchemaRegistryValidateConfigErrors(t *testing.T) {
+	conf, err := schemaRegistryValidateConfig().ParseYAML(`
+url: http://example.com
+sample_every: 0
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newSchemaRegistryValidateFromConfig(conf, nil)
+	require.EqualError(t, err, "sample_every must be at least 1, got 0")
+}
@@ -0,0 +1,137 @@
+package confluent
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	// nolint:staticcheck // Ignore SA1019 deprecation warning until we can switch to "google.golang.org/protobuf/types/dynamicpb"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+// newProtobufEncoder compiles an encoder for a Protobuf schema returned by the
+// schema registry, which encodes JSON documents as the message selected by the
+// field protobuf_message, prefixed with the indexes of the message within the
+// schema as required by the Confluent wire format.
+func (s *schemaRegistryEncoder) newProtobufEncoder(ctx context.Context, registryURL string, res *schemaResponse) (schemaEncoder, error) {
+	if err := s.checkAvroOnlyOptions(); err != nil {
+		return nil, err
+	}
+
+	// The schema is parsed as a file alongside the schemas it references,
+	// which are named by their import paths.
+	name := fmt.Sprintf("schema_registry/%v.proto", res.ID)
+	files := map[string]string{name: res.Schema}
+	if err := s.fetchSchemaReferences(ctx, registryURL, res.References, files); err != nil {
+		return nil, err
+	}
+
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(files)}
+	fds, err := parser.ParseFiles(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse protobuf schema %v: %w", res.ID, err)
+	}
+
+	md, err := protobufMessage(fds[0], s.protobufMessage)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf schema %v: %w", res.ID, err)
+	}
+	prefix := protobufMessageIndexesPrefix(protobufMessageIndexes(md))
+
+	unmarshaler := &jsonpb.Unmarshaler{
+		AnyResolver: dynamic.AnyResolver(dynamic.NewMessageFactoryWithDefaults(), fds...),
+	}
+
+	return func(m *service.Message) error {
+		b, err := m.AsBytes()
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			return errors.New("empty messages cannot be encoded as null with protobuf schemas")
+		}
+
+		msg := dynamic.NewMessage(md)
+		if err := msg.UnmarshalJSONPB(unmarshaler, b); err != nil {
+			return fmt.Errorf("failed to unmarshal JSON message: %w", err)
+		}
+
+		data, err := msg.Marshal()
+		if err != nil {
+			return fmt.Errorf("failed to marshal protobuf message: %w", err)
+		}
+
+		encoded := make([]byte, 0, len(prefix)+len(data))
+		encoded = append(encoded, prefix...)
+		m.SetBytes(append(encoded, data...))
+		return nil
+	}, nil
+}
+
+// protobufMessage returns the message of a file with a fully qualified name,
+// or the first message of the file when the name is empty.
+func protobufMessage(fd *desc.FileDescriptor, name string) (*desc.MessageDescriptor, error) {
+	if name == "" {
+		msgs := fd.GetMessageTypes()
+		if len(msgs) == 0 {
+			return nil, errors.New("schema does not contain any messages")
+		}
+		return msgs[0], nil
+	}
+	if md := fd.FindMessage(name); md != nil {
+		return md, nil
+	}
+	return nil, fmt.Errorf("message '%v' not found within schema", name)
+}
+
+// protobufMessageIndexes returns the path of indexes of a message within its
+// file, starting with the index of its top level message.
+func protobufMessageIndexes(md *desc.MessageDescriptor) []int {
+	var indexes []int
+	for {
+		var siblings []*desc.MessageDescriptor
+		parent := md.GetParent()
+		switch p := parent.(type) {
+		case *desc.MessageDescriptor:
+			siblings = p.GetNestedMessageTypes()
+		case *desc.FileDescriptor:
+			siblings = p.GetMessageTypes()
+		}
+		for i, sibling := range siblings {
+			if sibling == md {
+				indexes = append([]int{i}, indexes...)
+				break
+			}
+		}
+		next, isMsg := parent.(*desc.MessageDescriptor)
+		if !isMsg {
+			return indexes
+		}
+		md = next
+	}
+}
+
+// protobufMessageIndexesPrefix encodes the indexes of a message as they prefix
+// Protobuf messages in the Confluent wire format, which is the number of
+// indexes followed by each index as zig-zag encoded varints, or a single zero
+// byte for the first top level message.
+func protobufMessageIndexesPrefix(indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return []byte{0}
+	}
+	prefix := make([]byte, 0, (len(indexes)+1)*binary.MaxVarintLen64)
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, int64(len(indexes)))
+	prefix = append(prefix, buf[:n]...)
+	for _, i := range indexes {
+		n = binary.PutVarint(buf, int64(i))
+		prefix = append(prefix, buf[:n]...)
+	}
+	return prefix
+}
@@ -0,0 +1,241 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+const testProtobufSchema = `syntax = "proto3";
+package foo;
+
+import "foo/bar.proto";
+
+message Person {
+  string name = 1;
+  int32 age = 2;
+  foo.bar.Address address = 3;
+}
+
+message Wrapper {
+  message Nested {
+    int32 x = 1;
+  }
+  message Other {
+    bool y = 1;
+  }
+}
+`
+
+const testProtobufReferenceSchema = `syntax = "proto3";
+package foo.bar;
+
+message Address {
+  string city = 1;
+}
+`
+
+func runProtobufSchemaRegistryServer(t *testing.T) string {
+	t.Helper()
+
+	latest, err := json.Marshal(map[string]interface{}{
+		"schema":     testProtobufSchema,
+		"schemaType": "PROTOBUF",
+		"id":         5,
+		"references": []schemaReference{
+			{Name: "foo/bar.proto", Subject: "bar", Version: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	reference, err := json.Marshal(map[string]interface{}{
+		"schema":     testProtobufReferenceSchema,
+		"schemaType": "PROTOBUF",
+		"id":         4,
+	})
+	require.NoError(t, err)
+
+	unsupportedLatest, err := json.Marshal(map[string]interface{}{
+		"schema":     `struct Foo {}`,
+		"schemaType": "THRIFT",
+		"id":         6,
+	})
+	require.NoError(t, err)
+
+	return runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch path {
+		case "/subjects/foo/versions/latest":
+			return latest, nil
+		case "/subjects/bar/versions/2":
+			return reference, nil
+		case "/subjects/baz/versions/latest":
+			return unsupportedLatest, nil
+		}
+		return nil, errors.New("nope")
+	})
+}
+
+func TestSchemaRegistryEncodeProtobuf(t *testing.T) {
+	urlStr := runProtobufSchemaRegistryServer(t)
+
+	tests := []struct {
+		name        string
+		subject     string
+		message     string
+		input       string
+		output      string
+		errContains string
+	}{
+		{
+			name:    "first message",
+			subject: "foo",
+			input:   `{"name":"foo","age":30,"address":{"city":"bar"}}`,
+			output:  "\x00\x00\x00\x00\x05\x00\x0a\x03foo\x10\x1e\x1a\x05\x0a\x03bar",
+		},
+		{
+			name:    "nested message",
+			subject: "foo",
+			message: "foo.Wrapper.Other",
+			input:   `{"y":true}`,
+			output:  "\x00\x00\x00\x00\x05\x04\x02\x02\x08\x01",
+		},
+		{
+			name:        "message doesnt match schema",
+			subject:     "foo",
+			input:       `{"name":"foo","nope":"bar"}`,
+			errContains: "failed to unmarshal JSON message",
+		},
+		{
+			name:        "unknown message",
+			subject:     "foo",
+			message:     "foo.Nope",
+			input:       `{"name":"foo"}`,
+			errContains: "message 'foo.Nope' not found within schema",
+		},
+		{
+			name:        "unsupported schema type",
+			subject:     "baz",
+			input:       `{"name":"foo"}`,
+			errContains: "schema subject 'baz' has type THRIFT, which is not supported",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: %v
+protobuf_message: "%v"
+`, urlStr, test.subject, test.message), nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
+
+			outBatches, err := encoder.ProcessBatch(
+				context.Background(),
+				service.MessageBatch{service.NewMessage([]byte(test.input))},
+			)
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+			} else {
+				require.NoError(t, err)
+
+				b, err := outBatches[0][0].AsBytes()
+				require.NoError(t, err)
+				assert.Equal(t, test.output, string(b))
+			}
+
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+	}
+}
+
+func TestSchemaRegistryEncodeProtobufAvroOnly(t *testing.T) {
+	urlStr := runProtobufSchemaRegistryServer(t)
+
+	tests := []struct {
+		name        string
+		config      string
+		input       string
+		errContains string
+	}{
+		{
+			name:        "single object framing",
+			config:      `framings: [ single_object ]`,
+			input:       `{"name":"foo"}`,
+			errContains: "framing 'single_object' is only supported for Avro schemas",
+		},
+		{
+			name:        "batch records",
+			config:      `batch_records: concatenated`,
+			input:       `{"name":"foo"}`,
+			errContains: "batch_records is only supported for Avro schemas",
+		},
+		{
+			name:        "encode null",
+			config:      `empty_messages: encode_null`,
+			input:       ``,
+			errContains: "empty messages cannot be encoded as null with protobuf schemas",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+subject: foo
+%v
+`, urlStr, test.config), nil)
+			require.NoError(t, err)
+
+			encoder, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			require.NoError(t, err)
+
+			outBatches, err := encoder.ProcessBatch(
+				context.Background(),
+				service.MessageBatch{service.NewMessage([]byte(test.input))},
+			)
+			require.NoError(t, err)
+			require.Len(t, outBatches, 1)
+			require.Len(t, outBatches[0], 1)
+
+			err = outBatches[0][0].GetError()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errContains)
+
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+	}
+}
+
+func TestProtobufMessageIndexesPrefix(t *testing.T) {
+	tests := []struct {
+		indexes []int
+		prefix  string
+	}{
+		{indexes: []int{0}, prefix: "\x00"},
+		{indexes: []int{1}, prefix: "\x02\x02"},
+		{indexes: []int{0, 2}, prefix: "\x04\x00\x04"},
+		{indexes: []int{70}, prefix: "\x02\x8c\x01"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.prefix, string(protobufMessageIndexesPrefix(test.indexes)), "%v", test.indexes)
+	}
+}
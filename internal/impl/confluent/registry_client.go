@@ -0,0 +1,111 @@
+package confluent
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+var tlsMinVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func tlsMinVersionField() *service.ConfigField {
+	return service.NewStringEnumField("tls_min_version", "1.2", "1.3").
+		Description("The minimum version of TLS accepted when connecting to the schema registry. This applies regardless of whether custom `tls` settings are configured, and renegotiation requested by the registry is refused unless `tls.enable_renegotiation` is set.").
+		Advanced().Default("1.2").Version("4.2.0")
+}
+
+// registryTLSFromParsed returns the TLS config used to connect to the schema
+// registry, which always enforces a minimum TLS version.
+func registryTLSFromParsed(conf *service.ParsedConfig) (*tls.Config, error) {
+	tlsConf, err := conf.FieldTLS("tls")
+	if err != nil {
+		return nil, err
+	}
+	minVersionStr, err := conf.FieldString("tls_min_version")
+	if err != nil {
+		return nil, err
+	}
+	minVersion, exists := tlsMinVersions[minVersionStr]
+	if !exists {
+		return nil, fmt.Errorf("tls_min_version option '%v' not recognised", minVersionStr)
+	}
+	if tlsConf == nil {
+		tlsConf = &tls.Config{
+			Renegotiation: tls.RenegotiateNever,
+		}
+	}
+	tlsConf.MinVersion = minVersion
+	return tlsConf, nil
+}
+
+func basicAuthField() *service.ConfigField {
+	return service.NewObjectField("basic_auth",
+		service.NewBoolField("enabled").
+			Description("Whether to use basic authentication in requests to the schema registry.").
+			Default(false),
+		service.NewStringField("username").
+			Description("A username to authenticate as.").
+			Default(""),
+		service.NewStringField("password").
+			Description("A password to authenticate with.").
+			Default(""),
+	).Description("Allows you to specify basic authentication for requests to the schema registry.").
+		Advanced().Version("4.2.0")
+}
+
+// registryBasicAuth contains the credentials used to authenticate requests to
+// the schema registry.
+type registryBasicAuth struct {
+	username string
+	password string
+}
+
+// registryBasicAuthFromParsed returns the basic authentication credentials of
+// the schema registry, or nil when basic authentication is disabled.
+func registryBasicAuthFromParsed(conf *service.ParsedConfig) (*registryBasicAuth, error) {
+	conf = conf.Namespace("basic_auth")
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+	b := &registryBasicAuth{}
+	if b.username, err = conf.FieldString("username"); err != nil {
+		return nil, err
+	}
+	if b.password, err = conf.FieldString("password"); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// sign adds the Authorization header to a request, and does nothing when basic
+// authentication is disabled.
+func (b *registryBasicAuth) sign(req *http.Request) {
+	if b != nil {
+		req.SetBasicAuth(b.username, b.password)
+	}
+}
+
+// newRegistryClient returns the HTTP client used to call the schema registry,
+// which uses the default client unless a TLS config is provided.
+func newRegistryClient(tlsConf *tls.Config) *http.Client {
+	if tlsConf == nil {
+		return http.DefaultClient
+	}
+	client := &http.Client{}
+	if c, ok := http.DefaultTransport.(*http.Transport); ok {
+		cloned := c.Clone()
+		cloned.TLSClientConfig = tlsConf
+		client.Transport = cloned
+	} else {
+		client.Transport = &http.Transport{
+			TLSClientConfig: tlsConf,
+		}
+	}
+	return client
+}
@@ -0,0 +1,93 @@
+package confluent
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryClientTLS(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        string
+		minVersion    uint16
+		renegotiation tls.RenegotiationSupport
+		errContains   string
+	}{
+		{
+			name:          "defaults",
+			minVersion:    tls.VersionTLS12,
+			renegotiation: tls.RenegotiateNever,
+		},
+		{
+			name: "explicit min version",
+			config: `
+tls_min_version: "1.3"
+`,
+			minVersion:    tls.VersionTLS13,
+			renegotiation: tls.RenegotiateNever,
+		},
+		{
+			name: "custom tls settings",
+			config: `
+tls:
+  skip_cert_verify: true
+tls_min_version: "1.3"
+`,
+			minVersion:    tls.VersionTLS13,
+			renegotiation: tls.RenegotiateNever,
+		},
+		{
+			name: "renegotiation enabled",
+			config: `
+tls:
+  enable_renegotiation: true
+`,
+			minVersion:    tls.VersionTLS12,
+			renegotiation: tls.RenegotiateFreelyAsClient,
+		},
+		{
+			name: "unsupported min version",
+			config: `
+tls_min_version: "1.0"
+`,
+			errContains: "tls_min_version option '1.0' not recognised",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := schemaRegistryEncoderConfig().ParseYAML(`
+url: http://localhost:8081
+subject: foo
+`+test.config, nil)
+			require.NoError(t, err)
+
+			e, err := newSchemaRegistryEncoderFromConfig(conf, nil, nil)
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, e.Close(context.Background()))
+			})
+
+			transport, ok := e.client.Transport.(*http.Transport)
+			require.True(t, ok)
+			require.NotNil(t, transport.TLSClientConfig)
+			assert.Equal(t, test.minVersion, transport.TLSClientConfig.MinVersion)
+			assert.Equal(t, test.renegotiation, transport.TLSClientConfig.Renegotiation)
+		})
+	}
+}
+
+func TestRegistryClientNoTLS(t *testing.T) {
+	assert.Equal(t, http.DefaultClient, newRegistryClient(nil))
+}
@@ -0,0 +1,62 @@
+package confluent
+
+import (
+	"container/list"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// registryURLCacheSize is the maximum number of parsed registry URLs retained
+// when the url of a processor is interpolated.
+const registryURLCacheSize = 100
+
+// registryURLCache is a least recently used cache of the parsed base URLs of
+// schema registries resolved from an interpolated url, which avoids parsing
+// the URL of every message. The HTTP client of a processor is shared by all of
+// its registries, as it already pools connections by host.
+type registryURLCache struct {
+	max   int
+	mut   sync.Mutex
+	order *list.List
+	urls  map[string]*list.Element
+}
+
+type registryURLCacheEntry struct {
+	raw    string
+	parsed *url.URL
+}
+
+func newRegistryURLCache(max int) *registryURLCache {
+	return &registryURLCache{
+		max:   max,
+		order: list.New(),
+		urls:  map[string]*list.Element{},
+	}
+}
+
+// get returns the parsed form of a registry URL, parsing and caching it when
+// it is not already cached, in which case the least recently used URL is
+// evicted once the cache is full.
+func (c *registryURLCache) get(raw string) (*url.URL, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if e, exists := c.urls[raw]; exists {
+		c.order.MoveToFront(e)
+		return e.Value.(*registryURLCacheEntry).parsed, nil
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	c.urls[raw] = c.order.PushFront(&registryURLCacheEntry{raw: raw, parsed: parsed})
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.urls, oldest.Value.(*registryURLCacheEntry).raw)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,36 @@
+package confluent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryURLCache(t *testing.T) {
+	c := newRegistryURLCache(2)
+
+	a, err := c.get("http://a.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "a.example.com", a.Host)
+
+	_, err = c.get("http://b.example.com")
+	require.NoError(t, err)
+
+	// Using a makes b the least recently used URL, which is evicted by c.
+	aAgain, err := c.get("http://a.example.com")
+	require.NoError(t, err)
+	assert.Same(t, a, aAgain)
+
+	_, err = c.get("http://c.example.com")
+	require.NoError(t, err)
+
+	assert.Contains(t, c.urls, "http://a.example.com")
+	assert.NotContains(t, c.urls, "http://b.example.com")
+	assert.Contains(t, c.urls, "http://c.example.com")
+
+	_, err = c.get("huh#%#@$u*not////::example.com")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse url")
+	assert.Len(t, c.urls, 2)
+}
@@ -0,0 +1,105 @@
+package confluent
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func schemaDriftField() *service.ConfigField {
+	return service.NewObjectField("schema_drift",
+		service.NewBoolField("enabled").
+			Description("Whether to detect schema drift.").
+			Default(false),
+		service.NewFloatField("threshold").
+			Description("The proportion of the messages of a subject that must fail to encode in order for drift to be reported, greater than 0 and at most 1.").
+			Default(0.05),
+		service.NewIntField("min_messages").
+			Description("The minimum number of messages of a subject that must be encoded before drift is checked, which prevents a handful of failures from being reported. Messages continue to be counted across refreshes until this number is reached.").
+			Default(100),
+	).Description("Detect when the data being encoded no longer conforms to the latest schema of its subject, which usually indicates that a schema change is required. The messages encoded with the schema of each subject, and those that fail, are counted, and each time the schema of a subject is refreshed or revalidated the proportion of failures since drift was last checked is compared against a threshold. When the threshold is exceeded a warning is logged and the metric `schema_registry_encode_schema_drift` is incremented with the label `subject`. Drift is only checked when schemas are refreshed, and therefore requires either a `refresh_period` or a `revalidate_after` period.").
+		Advanced().Version("4.2.0")
+}
+
+// schemaDrift counts the messages encoded with the schema of each subject and
+// the messages that failed to encode, in order to detect when the data being
+// encoded has drifted away from the schema.
+type schemaDrift struct {
+	threshold   float64
+	minMessages int64
+
+	mut      sync.Mutex
+	subjects map[string]*schemaDriftCounts
+}
+
+type schemaDriftCounts struct {
+	total  int64
+	failed int64
+}
+
+func schemaDriftFromParsed(conf *service.ParsedConfig) (*schemaDrift, error) {
+	conf = conf.Namespace("schema_drift")
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+	d := &schemaDrift{subjects: map[string]*schemaDriftCounts{}}
+	if d.threshold, err = conf.FieldFloat("threshold"); err != nil {
+		return nil, err
+	}
+	if d.threshold <= 0 || d.threshold > 1 {
+		return nil, fmt.Errorf("schema_drift threshold must be greater than 0 and at most 1, got %v", d.threshold)
+	}
+	minMessages, err := conf.FieldInt("min_messages")
+	if err != nil {
+		return nil, err
+	}
+	if minMessages < 1 {
+		return nil, fmt.Errorf("schema_drift min_messages must be at least 1, got %v", minMessages)
+	}
+	d.minMessages = int64(minMessages)
+	return d, nil
+}
+
+// record counts a message encoded with the schema of a subject.
+func (d *schemaDrift) record(subject string, failed bool) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	c, exists := d.subjects[subject]
+	if !exists {
+		c = &schemaDriftCounts{}
+		d.subjects[subject] = c
+	}
+	c.total++
+	if failed {
+		c.failed++
+	}
+}
+
+// check returns the counts of a subject since it was last checked, and whether
+// the proportion of failures exceeds the threshold. The counts of the subject
+// are reset once they reach the minimum number of messages, and otherwise
+// continue to accumulate until the next check.
+func (d *schemaDrift) check(subject string) (counts schemaDriftCounts, drifted bool) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	c, exists := d.subjects[subject]
+	if !exists || c.total < d.minMessages {
+		return
+	}
+	delete(d.subjects, subject)
+
+	counts = *c
+	return counts, float64(counts.failed)/float64(counts.total) > d.threshold
+}
+
+// forget removes the counts of a subject, which is used when the schema of the
+// subject is purged from the cache.
+func (d *schemaDrift) forget(subject string) {
+	d.mut.Lock()
+	delete(d.subjects, subject)
+	d.mut.Unlock()
+}
@@ -0,0 +1,113 @@
+package confluent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestSchemaDriftDisabled(t *testing.T) {
+	conf, err := service.NewConfigSpec().Field(schemaDriftField()).ParseYAML(`{}`, nil)
+	require.NoError(t, err)
+
+	d, err := schemaDriftFromParsed(conf)
+	require.NoError(t, err)
+	assert.Nil(t, d)
+}
+
+func TestSchemaDriftBadConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		errContains string
+	}{
+		{
+			name: "zero threshold",
+			config: `
+schema_drift:
+  enabled: true
+  threshold: 0
+`,
+			errContains: "schema_drift threshold must be greater than 0 and at most 1",
+		},
+		{
+			name: "threshold above one",
+			config: `
+schema_drift:
+  enabled: true
+  threshold: 1.5
+`,
+			errContains: "schema_drift threshold must be greater than 0 and at most 1",
+		},
+		{
+			name: "zero min messages",
+			config: `
+schema_drift:
+  enabled: true
+  min_messages: 0
+`,
+			errContains: "schema_drift min_messages must be at least 1",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := service.NewConfigSpec().Field(schemaDriftField()).ParseYAML(test.config, nil)
+			require.NoError(t, err)
+
+			_, err = schemaDriftFromParsed(conf)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errContains)
+		})
+	}
+}
+
+func TestSchemaDriftCheck(t *testing.T) {
+	conf, err := service.NewConfigSpec().Field(schemaDriftField()).ParseYAML(`
+schema_drift:
+  enabled: true
+  threshold: 0.25
+  min_messages: 4
+`, nil)
+	require.NoError(t, err)
+
+	d, err := schemaDriftFromParsed(conf)
+	require.NoError(t, err)
+	require.NotNil(t, d)
+
+	// Unknown subjects are never drifted.
+	_, drifted := d.check("foo")
+	assert.False(t, drifted)
+
+	// Counts accumulate until they reach the minimum.
+	d.record("foo", true)
+	d.record("foo", true)
+	d.record("foo", false)
+	counts, drifted := d.check("foo")
+	assert.False(t, drifted)
+	assert.Equal(t, schemaDriftCounts{}, counts)
+
+	d.record("foo", false)
+	d.record("bar", false)
+	counts, drifted = d.check("foo")
+	assert.True(t, drifted)
+	assert.Equal(t, schemaDriftCounts{total: 4, failed: 2}, counts)
+
+	// Counts are reset once checked.
+	for i := 0; i < 4; i++ {
+		d.record("foo", i == 0)
+	}
+	counts, drifted = d.check("foo")
+	assert.False(t, drifted)
+	assert.Equal(t, schemaDriftCounts{total: 4, failed: 1}, counts)
+
+	// Other subjects are counted separately, and forgotten once purged.
+	d.forget("bar")
+	_, drifted = d.check("bar")
+	assert.False(t, drifted)
+	assert.Empty(t, d.subjects)
+}
@@ -0,0 +1,50 @@
+package confluent
+
+import (
+	"sync"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+// otherSubjectsLabel is the label value of metrics for subjects that exceed the
+// maximum number of subjects labelled individually.
+const otherSubjectsLabel = "other"
+
+// subjectMetricLabels limits the cardinality of metrics labelled by subject.
+// The first subjects seen are labelled individually up to a maximum, and all
+// subjects seen afterwards share a single label.
+type subjectMetricLabels struct {
+	max    int
+	warned bool
+	seen   map[string]struct{}
+	mut    sync.Mutex
+
+	logger *service.Logger
+}
+
+func newSubjectMetricLabels(max int, logger *service.Logger) *subjectMetricLabels {
+	return &subjectMetricLabels{
+		max:    max,
+		seen:   map[string]struct{}{},
+		logger: logger,
+	}
+}
+
+// label returns the label value to use for a subject.
+func (l *subjectMetricLabels) label(subject string) string {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if _, exists := l.seen[subject]; exists {
+		return subject
+	}
+	if len(l.seen) < l.max {
+		l.seen[subject] = struct{}{}
+		return subject
+	}
+	if !l.warned {
+		l.warned = true
+		l.logger.Warnf("Metrics exceeded the maximum of %v subjects, subject '%v' and any further subjects are labelled as '%v'", l.max, subject, otherSubjectsLabel)
+	}
+	return otherSubjectsLabel
+}
@@ -0,0 +1,22 @@
+package confluent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubjectMetricLabels(t *testing.T) {
+	l := newSubjectMetricLabels(2, nil)
+
+	assert.Equal(t, "foo", l.label("foo"))
+	assert.Equal(t, "bar", l.label("bar"))
+	assert.Equal(t, "other", l.label("baz"))
+	assert.Equal(t, "foo", l.label("foo"))
+	assert.Equal(t, "other", l.label("qux"))
+	assert.Equal(t, "bar", l.label("bar"))
+	assert.True(t, l.warned)
+
+	l = newSubjectMetricLabels(0, nil)
+	assert.Equal(t, "other", l.label("foo"))
+}
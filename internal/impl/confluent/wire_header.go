@@ -0,0 +1,155 @@
+package confluent
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+// wireHeader describes the layout of the header that prefixes messages with
+// their schema ID, which is an optional zero magic byte followed by the ID as
+// an unsigned integer of a fixed width.
+type wireHeader struct {
+	magicByte bool
+	idWidth   int
+	byteOrder binary.ByteOrder
+}
+
+// confluentHeader is the header of the Confluent wire format, consisting of a
+// zero magic byte followed by the four byte big-endian schema ID.
+var confluentHeader = wireHeader{
+	magicByte: true,
+	idWidth:   4,
+	byteOrder: binary.BigEndian,
+}
+
+func wireHeaderField() *service.ConfigField {
+	return service.NewObjectField("header",
+		service.NewBoolField("magic_byte").
+			Description("Whether the schema ID is preceded by a zero magic byte.").
+			Default(true),
+		service.NewIntField("id_width").
+			Description("The width of the schema ID in bytes, which must be 1, 2, 4 or 8.").
+			Default(4),
+		service.NewStringAnnotatedEnumField("byte_order", map[string]string{
+			"big_endian":    "The schema ID is encoded with the most significant byte first.",
+			"little_endian": "The schema ID is encoded with the least significant byte first.",
+		}).Description("The byte order of the schema ID.").Default("big_endian"),
+	).Description("The layout of the header that prefixes messages with their schema ID, which can be customised in order to interoperate with registries that do not follow the Confluent wire format. The defaults match the Confluent wire format.").
+		Advanced().Version("4.2.0")
+}
+
+func wireHeaderFromParsed(conf *service.ParsedConfig) (h wireHeader, err error) {
+	conf = conf.Namespace("header")
+	if h.magicByte, err = conf.FieldBool("magic_byte"); err != nil {
+		return
+	}
+	if h.idWidth, err = conf.FieldInt("id_width"); err != nil {
+		return
+	}
+	switch h.idWidth {
+	case 1, 2, 4, 8:
+	default:
+		err = fmt.Errorf("header id_width must be 1, 2, 4 or 8, got %v", h.idWidth)
+		return
+	}
+	var byteOrder string
+	if byteOrder, err = conf.FieldString("byte_order"); err != nil {
+		return
+	}
+	switch byteOrder {
+	case "big_endian":
+		h.byteOrder = binary.BigEndian
+	case "little_endian":
+		h.byteOrder = binary.LittleEndian
+	default:
+		err = fmt.Errorf("header byte_order '%v' not recognised", byteOrder)
+	}
+	return
+}
+
+// size returns the number of bytes of the header.
+func (h wireHeader) size() int {
+	if h.magicByte {
+		return h.idWidth + 1
+	}
+	return h.idWidth
+}
+
+// maxID returns the largest schema ID that fits within the header.
+func (h wireHeader) maxID() uint64 {
+	if h.idWidth >= 8 {
+		return 1<<64 - 1
+	}
+	return 1<<(8*uint(h.idWidth)) - 1
+}
+
+// insert prefixes content with the header of a schema ID.
+func (h wireHeader) insert(id int, content []byte) ([]byte, error) {
+	if id < 0 || uint64(id) > h.maxID() {
+		return nil, fmt.Errorf("schema ID %v does not fit within %v bytes", id, h.idWidth)
+	}
+
+	newBytes := make([]byte, len(content)+h.size())
+
+	idBytes := newBytes
+	if h.magicByte {
+		idBytes = newBytes[1:]
+	}
+	switch h.idWidth {
+	case 1:
+		idBytes[0] = byte(id)
+	case 2:
+		h.byteOrder.PutUint16(idBytes, uint16(id))
+	case 4:
+		h.byteOrder.PutUint32(idBytes, uint32(id))
+	case 8:
+		h.byteOrder.PutUint64(idBytes, uint64(id))
+	}
+	copy(newBytes[h.size():], content)
+
+	return newBytes, nil
+}
+
+// extract parses the header of a message, returning the schema ID and the
+// remaining bytes of the message.
+func (h wireHeader) extract(b []byte) (id int, remaining []byte, err error) {
+	if len(b) == 0 {
+		err = errors.New("message is empty")
+		return
+	}
+	if h.magicByte && b[0] != 0 {
+		err = fmt.Errorf("serialization format version number %v not supported", b[0])
+		return
+	}
+	if len(b) < h.size() {
+		err = fmt.Errorf("message is too short to contain a schema ID, expected at least %v bytes but got %v", h.size(), len(b))
+		return
+	}
+
+	idBytes := b
+	if h.magicByte {
+		idBytes = b[1:]
+	}
+	switch h.idWidth {
+	case 1:
+		id = int(idBytes[0])
+	case 2:
+		id = int(h.byteOrder.Uint16(idBytes))
+	case 4:
+		id = int(h.byteOrder.Uint32(idBytes))
+	case 8:
+		id64 := h.byteOrder.Uint64(idBytes)
+		if id64 > uint64(maxInt) {
+			err = fmt.Errorf("schema ID %v is out of range", id64)
+			return
+		}
+		id = int(id64)
+	}
+	remaining = b[h.size():]
+	return
+}
+
+const maxInt = int(^uint(0) >> 1)
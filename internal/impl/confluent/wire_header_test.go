@@ -0,0 +1,85 @@
+package confluent
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header wireHeader
+		id     int
+		framed string
+	}{
+		{
+			name:   "confluent",
+			header: confluentHeader,
+			id:     16909060,
+			framed: "\x00\x01\x02\x03\x04foo",
+		},
+		{
+			name:   "little endian",
+			header: wireHeader{magicByte: true, idWidth: 4, byteOrder: binary.LittleEndian},
+			id:     16909060,
+			framed: "\x00\x04\x03\x02\x01foo",
+		},
+		{
+			name:   "no magic byte",
+			header: wireHeader{idWidth: 2, byteOrder: binary.BigEndian},
+			id:     258,
+			framed: "\x01\x02foo",
+		},
+		{
+			name:   "one byte",
+			header: wireHeader{magicByte: true, idWidth: 1, byteOrder: binary.BigEndian},
+			id:     7,
+			framed: "\x00\x07foo",
+		},
+		{
+			name:   "eight bytes",
+			header: wireHeader{idWidth: 8, byteOrder: binary.BigEndian},
+			id:     4294967296,
+			framed: "\x00\x00\x00\x01\x00\x00\x00\x00foo",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.header.insert(test.id, []byte("foo"))
+			require.NoError(t, err)
+			assert.Equal(t, test.framed, string(b))
+
+			id, remaining, err := test.header.extract(b)
+			require.NoError(t, err)
+			assert.Equal(t, test.id, id)
+			assert.Equal(t, "foo", string(remaining))
+		})
+	}
+}
+
+func TestWireHeaderErrors(t *testing.T) {
+	twoBytes := wireHeader{magicByte: true, idWidth: 2, byteOrder: binary.BigEndian}
+
+	_, err := twoBytes.insert(65536, nil)
+	assert.EqualError(t, err, "schema ID 65536 does not fit within 2 bytes")
+
+	_, err = twoBytes.insert(-1, nil)
+	assert.EqualError(t, err, "schema ID -1 does not fit within 2 bytes")
+
+	_, _, err = twoBytes.extract([]byte{0, 1})
+	assert.EqualError(t, err, "message is too short to contain a schema ID, expected at least 3 bytes but got 2")
+
+	_, _, err = twoBytes.extract([]byte{1, 0, 1})
+	assert.EqualError(t, err, "serialization format version number 1 not supported")
+
+	noMagic := wireHeader{idWidth: 2, byteOrder: binary.BigEndian}
+	id, remaining, err := noMagic.extract([]byte{1, 0, 5})
+	require.NoError(t, err)
+	assert.Equal(t, 256, id)
+	assert.Equal(t, []byte{5}, remaining)
+}
@@ -0,0 +1,85 @@
+package pure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func requireMetadataProcConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Utility").
+		Summary("Checks that messages contain a list of metadata keys, flagging messages that lack any of them as having failed.").
+		Description(`
+Fields that are derived from metadata with [function interpolation](/docs/configuration/interpolation#bloblang-queries), such as `+"`${! meta(\"kafka_topic\") }`"+`, resolve to an unexpected value when the metadata key is missing, which can lead to confusing errors further down a pipeline. This processor catches such messages early by checking that each key listed in `+"[`keys`](#keys)"+` is present within the metadata of a message with a value that is not empty.
+
+Messages that fail the check are left unchanged and flagged as having failed with an error naming each offending key, and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).`).
+		Field(service.NewStringListField("keys").
+			Description("The metadata keys that messages must contain.").
+			Example([]string{"kafka_topic"}).
+			Example([]string{"kafka_topic", "kafka_key"})).
+		Example("Guard Schema Subjects", `
+When the subject of messages encoded with a schema registry is derived from metadata a missing key results in an invalid subject. Checking for the key beforehand results in an error that clearly explains what is wrong with the message.`, `
+pipeline:
+  processors:
+    - require_metadata:
+        keys: [ kafka_topic ]
+    - schema_registry_encode:
+        url: http://localhost:8081
+        subject: ${! meta("kafka_topic") }
+        refresh_period: 1m
+`).
+		Version("4.2.0")
+}
+
+func init() {
+	err := service.RegisterProcessor(
+		"require_metadata", requireMetadataProcConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newRequireMetadataFromParsed(conf)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type requireMetadataProc struct {
+	keys []string
+}
+
+func newRequireMetadataFromParsed(conf *service.ParsedConfig) (*requireMetadataProc, error) {
+	keys, err := conf.FieldStringList("keys")
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("at least one metadata key must be specified")
+	}
+	return &requireMetadataProc{keys: keys}, nil
+}
+
+func (r *requireMetadataProc) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	var missing []string
+	for _, k := range r.keys {
+		// Empty values are reported as missing by MetaGet.
+		if _, exists := msg.MetaGet(k); !exists {
+			missing = append(missing, "'"+k+"'")
+		}
+	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		return nil, fmt.Errorf("required metadata key %v is missing or empty", missing[0])
+	default:
+		return nil, fmt.Errorf("required metadata keys %v are missing or empty", strings.Join(missing, ", "))
+	}
+	return service.MessageBatch{msg}, nil
+}
+
+func (r *requireMetadataProc) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package pure
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestRequireMetadataNoKeys(t *testing.T) {
+	conf, err := requireMetadataProcConfig().ParseYAML(`
+keys: []
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newRequireMetadataFromParsed(conf)
+	require.EqualError(t, err, "at least one metadata key must be specified")
+}
+
+func TestRequireMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		meta   map[string]string
+		errStr string
+	}{
+		{
+			name:   "all present",
+			config: `keys: [ foo, bar ]`,
+			meta:   map[string]string{"foo": "a", "bar": "b", "baz": "c"},
+		},
+		{
+			name:   "one missing",
+			config: `keys: [ foo, bar ]`,
+			meta:   map[string]string{"foo": "a"},
+			errStr: "required metadata key 'bar' is missing or empty",
+		},
+		{
+			name:   "several missing",
+			config: `keys: [ foo, bar, baz ]`,
+			meta:   map[string]string{"bar": "", "baz": "c"},
+			errStr: "required metadata keys 'foo', 'bar' are missing or empty",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := requireMetadataProcConfig().ParseYAML(test.config, nil)
+			require.NoError(t, err)
+
+			proc, err := newRequireMetadataFromParsed(conf)
+			require.NoError(t, err)
+
+			msg := service.NewMessage([]byte("hello world"))
+			for k, v := range test.meta {
+				msg.MetaSet(k, v)
+			}
+
+			batch, err := proc.Process(context.Background(), msg)
+			if test.errStr != "" {
+				require.EqualError(t, err, test.errStr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, batch, 1)
+
+			b, err := batch[0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(b))
+		})
+	}
+}
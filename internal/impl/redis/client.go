@@ -2,9 +2,11 @@ package redis
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v7"
 
@@ -22,7 +24,7 @@ Some cloud hosted instances of Redis (such as Azure Cache) might need some hand
 
 	return []*service.ConfigField{
 		service.NewStringField("url").
-			Description("The URL of the target Redis server. Database is optional and is supplied as the URL path.").
+			Description("The URL of the target Redis server. Database is optional and is supplied as the URL path. TLS is enabled for URLs with the scheme `rediss`.").
 			Example(":6397").
 			Example("localhost:6397").
 			Example("redis://localhost:6379").
@@ -38,16 +40,58 @@ Some cloud hosted instances of Redis (such as Azure Cache) might need some hand
 			Default("").
 			Example("mymaster").
 			Advanced(),
+		service.NewDurationField("dial_timeout").
+			Description("The maximum period to wait for a connection to be established.").
+			Default("5s").
+			Advanced().
+			Version("4.2.0"),
+		service.NewDurationField("read_timeout").
+			Description("The maximum period to wait for a response to a command, after which the command fails. Commands that block, such as those used for consuming lists and streams, are given additional time by the client according to their own timeouts.").
+			Default("3s").
+			Advanced().
+			Version("4.2.0"),
+		service.NewDurationField("write_timeout").
+			Description("The maximum period to wait for a command to be written to a connection.").
+			Default("3s").
+			Advanced().
+			Version("4.2.0"),
 		tlsField,
 	}
 }
 
+// readURLField returns a config field for an optional URL that read
+// operations are routed to.
+func readURLField() *service.ConfigField {
+	return service.NewStringField("read_url").
+		Description("An optional URL of a Redis server, such as a read replica, that read operations are sent to instead of `url`, whilst writes continue to be sent to `url`. The `kind`, `master` and `tls` settings apply to both. Reads from a replica are eventually consistent and may not reflect the most recent writes.").
+		Example("redis://replica:6379").
+		Default("").
+		Advanced().
+		Version("4.2.0")
+}
+
 func getClient(parsedConf *service.ParsedConfig) (redis.UniversalClient, error) {
 	urlStr, err := parsedConf.FieldString("url")
 	if err != nil {
 		return nil, err
 	}
+	return getClientWithURL(parsedConf, urlStr)
+}
+
+// getReadClient returns a client for the `read_url` of a config, or nil if a
+// read URL is not configured.
+func getReadClient(parsedConf *service.ParsedConfig) (redis.UniversalClient, error) {
+	if !parsedConf.Contains("read_url") {
+		return nil, nil
+	}
+	urlStr, err := parsedConf.FieldString("read_url")
+	if err != nil || urlStr == "" {
+		return nil, err
+	}
+	return getClientWithURL(parsedConf, urlStr)
+}
 
+func getClientWithURL(parsedConf *service.ParsedConfig, urlStr string) (redis.UniversalClient, error) {
 	kind, err := parsedConf.FieldString("kind")
 	if err != nil {
 		return nil, err
@@ -58,18 +102,40 @@ func getClient(parsedConf *service.ParsedConfig) (redis.UniversalClient, error)
 		return nil, err
 	}
 
+	var timeouts clientTimeouts
+	if timeouts.dial, err = parsedConf.FieldDuration("dial_timeout"); err != nil {
+		return nil, err
+	}
+	if timeouts.read, err = parsedConf.FieldDuration("read_timeout"); err != nil {
+		return nil, err
+	}
+	if timeouts.write, err = parsedConf.FieldDuration("write_timeout"); err != nil {
+		return nil, err
+	}
+
 	tlsConf, tlsEnabled, err := parsedConf.FieldTLSToggled("tls")
 	if err != nil {
 		return nil, err
 	}
-	if !tlsEnabled {
-		tlsConf = nil
-	}
 
+	return newClient(urlStr, kind, master, timeouts, tlsConf, tlsEnabled)
+}
+
+// clientTimeouts are the network timeouts of a client, where zero values
+// result in the defaults of the client library.
+type clientTimeouts struct {
+	dial  time.Duration
+	read  time.Duration
+	write time.Duration
+}
+
+// newClient creates a client for a comma separated list of URLs.
+func newClient(urlStr, kind, master string, timeouts clientTimeouts, tlsConf *tls.Config, tlsEnabled bool) (redis.UniversalClient, error) {
 	// We default to Redis DB 0 for backward compatibility
 	var redisDB int
 	var pass string
 	var addrs []string
+	var urlTLSConf *tls.Config
 
 	// handle comma-separated urls
 	for _, v := range strings.Split(urlStr, ",") {
@@ -90,14 +156,22 @@ func getClient(parsedConf *service.ParsedConfig) (redis.UniversalClient, error)
 		addrs = append(addrs, rurl.Addr)
 		redisDB = rurl.DB
 		pass = rurl.Password
+		if rurl.TLSConfig != nil {
+			urlTLSConf = rurl.TLSConfig
+		}
 	}
 
 	var client redis.UniversalClient
+	var err error
+
 	opts := &redis.UniversalOptions{
-		Addrs:     addrs,
-		DB:        redisDB,
-		Password:  pass,
-		TLSConfig: tlsConf,
+		Addrs:        addrs,
+		DB:           redisDB,
+		Password:     pass,
+		DialTimeout:  timeouts.dial,
+		ReadTimeout:  timeouts.read,
+		WriteTimeout: timeouts.write,
+		TLSConfig:    resolveTLSConfig(tlsConf, tlsEnabled, urlTLSConf),
 	}
 
 	switch kind {
@@ -106,6 +180,9 @@ func getClient(parsedConf *service.ParsedConfig) (redis.UniversalClient, error)
 	case "cluster":
 		client = redis.NewClusterClient(opts.Cluster())
 	case "failover":
+		if master == "" {
+			return nil, errors.New("master must be set when kind is failover")
+		}
 		opts.MasterName = master
 		client = redis.NewFailoverClient(opts.Failover())
 	default:
@@ -115,62 +192,74 @@ func getClient(parsedConf *service.ParsedConfig) (redis.UniversalClient, error)
 	return client, err
 }
 
-func clientFromConfig(r old.Config) (redis.UniversalClient, error) {
-	// We default to Redis DB 0 for backward compatibility
-	var redisDB int
-	var pass string
-	var addrs []string
-
-	// handle comma-separated urls
-	for _, v := range strings.Split(r.URL, ",") {
-		url, err := url.Parse(v)
-		if err != nil {
-			return nil, err
+// resolveTLSConfig returns the TLS config that a client should use, if any.
+// Explicitly enabled TLS settings take precedence over TLS implied by a URL
+// with the scheme rediss. When TLS is enabled without any custom settings a
+// default config is returned, as otherwise the client would connect without
+// TLS.
+func resolveTLSConfig(tlsConf *tls.Config, tlsEnabled bool, urlTLSConf *tls.Config) *tls.Config {
+	if !tlsEnabled {
+		return urlTLSConf
+	}
+	if tlsConf == nil {
+		tlsConf = &tls.Config{
+			MinVersion: tls.VersionTLS12,
 		}
+	}
+	return tlsConf
+}
 
-		if url.Scheme == "tcp" {
-			url.Scheme = "redis"
-		}
+func clientFromConfig(r old.Config) (redis.UniversalClient, error) {
+	return clientFromConfigWithURL(r, r.URL)
+}
 
-		rurl, err := redis.ParseURL(url.String())
-		if err != nil {
-			return nil, err
-		}
+// readClientFromConfig returns a client for the read URL of a config, or nil if
+// a read URL is not configured.
+func readClientFromConfig(r old.Config) (redis.UniversalClient, error) {
+	if r.ReadURL == "" {
+		return nil, nil
+	}
+	return clientFromConfigWithURL(r, r.ReadURL)
+}
 
-		addrs = append(addrs, rurl.Addr)
-		redisDB = rurl.DB
-		pass = rurl.Password
+func clientFromConfigWithURL(r old.Config, urlStr string) (redis.UniversalClient, error) {
+	var timeouts clientTimeouts
+	var err error
+	if timeouts.dial, err = parseTimeout("dial_timeout", r.DialTimeout); err != nil {
+		return nil, err
+	}
+	if timeouts.read, err = parseTimeout("read_timeout", r.ReadTimeout); err != nil {
+		return nil, err
+	}
+	if timeouts.write, err = parseTimeout("write_timeout", r.WriteTimeout); err != nil {
+		return nil, err
 	}
 
 	var tlsConf *tls.Config
 	if r.TLS.Enabled {
-		var err error
 		if tlsConf, err = r.TLS.Get(); err != nil {
 			return nil, err
 		}
 	}
+	return newClient(urlStr, r.Kind, r.Master, timeouts, tlsConf, r.TLS.Enabled)
+}
 
-	var client redis.UniversalClient
-	var err error
+// isRedisError returns whether an error, or any error it wraps, was returned
+// by the server for a command, as opposed to an error with the connection.
+func isRedisError(err error) bool {
+	var rErr redis.Error
+	return errors.As(err, &rErr)
+}
 
-	opts := &redis.UniversalOptions{
-		Addrs:     addrs,
-		DB:        redisDB,
-		Password:  pass,
-		TLSConfig: tlsConf,
+// parseTimeout parses a timeout of a config, where an empty string results in
+// the default of the client library.
+func parseTimeout(name, str string) (time.Duration, error) {
+	if str == "" {
+		return 0, nil
 	}
-
-	switch r.Kind {
-	case "simple":
-		client = redis.NewClient(opts.Simple())
-	case "cluster":
-		client = redis.NewClusterClient(opts.Cluster())
-	case "failover":
-		opts.MasterName = r.Master
-		client = redis.NewFailoverClient(opts.Failover())
-	default:
-		err = fmt.Errorf("invalid redis kind: %s", r.Kind)
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %v: %w", name, err)
 	}
-
-	return client, err
+	return d, nil
 }
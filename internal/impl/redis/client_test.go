@@ -0,0 +1,170 @@
+package redis
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/impl/redis/old"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestResolveTLSConfig(t *testing.T) {
+	explicitConf := &tls.Config{ServerName: "explicit"}
+	urlConf := &tls.Config{ServerName: "url"}
+
+	assert.Nil(t, resolveTLSConfig(nil, false, nil))
+	assert.Nil(t, resolveTLSConfig(explicitConf, false, nil))
+	assert.Equal(t, urlConf, resolveTLSConfig(nil, false, urlConf))
+	assert.Equal(t, explicitConf, resolveTLSConfig(explicitConf, true, urlConf))
+
+	defaultConf := resolveTLSConfig(nil, true, nil)
+	if assert.NotNil(t, defaultConf) {
+		assert.Equal(t, uint16(tls.VersionTLS12), defaultConf.MinVersion)
+	}
+}
+
+func TestReadClientFromConfig(t *testing.T) {
+	conf := old.NewConfig()
+	conf.URL = "tcp://primary:6379"
+
+	client, err := readClientFromConfig(conf)
+	require.NoError(t, err)
+	assert.Nil(t, client)
+
+	conf.ReadURL = "redis://replica:6380/2"
+	client, err = readClientFromConfig(conf)
+	require.NoError(t, err)
+	require.IsType(t, &redis.Client{}, client)
+	assert.Equal(t, "replica:6380", client.(*redis.Client).Options().Addr)
+	assert.Equal(t, 2, client.(*redis.Client).Options().DB)
+	require.NoError(t, client.Close())
+
+	conf.ReadURL = "nope://replica:6380"
+	_, err = readClientFromConfig(conf)
+	require.Error(t, err)
+}
+
+func TestGetReadClient(t *testing.T) {
+	spec := service.NewConfigSpec()
+	for _, f := range clientFields() {
+		spec = spec.Field(f)
+	}
+	spec = spec.Field(readURLField())
+
+	conf, err := spec.ParseYAML(`url: tcp://primary:6379`, nil)
+	require.NoError(t, err)
+
+	client, err := getReadClient(conf)
+	require.NoError(t, err)
+	assert.Nil(t, client)
+
+	conf, err = spec.ParseYAML(`
+url: tcp://primary:6379
+read_url: tcp://replica:6380
+`, nil)
+	require.NoError(t, err)
+
+	client, err = getReadClient(conf)
+	require.NoError(t, err)
+	require.IsType(t, &redis.Client{}, client)
+	assert.Equal(t, "replica:6380", client.(*redis.Client).Options().Addr)
+	require.NoError(t, client.Close())
+}
+
+func TestClientTimeouts(t *testing.T) {
+	conf := old.NewConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.DialTimeout = "1s"
+	conf.ReadTimeout = "2s"
+	conf.WriteTimeout = "500ms"
+
+	client, err := clientFromConfig(conf)
+	require.NoError(t, err)
+	require.IsType(t, &redis.Client{}, client)
+
+	opts := client.(*redis.Client).Options()
+	assert.Equal(t, time.Second, opts.DialTimeout)
+	assert.Equal(t, time.Second*2, opts.ReadTimeout)
+	assert.Equal(t, time.Millisecond*500, opts.WriteTimeout)
+	require.NoError(t, client.Close())
+
+	conf.ReadTimeout = "nope"
+	_, err = clientFromConfig(conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse read_timeout")
+
+	spec := service.NewConfigSpec()
+	for _, f := range clientFields() {
+		spec = spec.Field(f)
+	}
+
+	parsedConf, err := spec.ParseYAML(`url: tcp://localhost:6379`, nil)
+	require.NoError(t, err)
+
+	client, err = getClient(parsedConf)
+	require.NoError(t, err)
+	require.IsType(t, &redis.Client{}, client)
+
+	opts = client.(*redis.Client).Options()
+	assert.Equal(t, time.Second*5, opts.DialTimeout)
+	assert.Equal(t, time.Second*3, opts.ReadTimeout)
+	assert.Equal(t, time.Second*3, opts.WriteTimeout)
+	require.NoError(t, client.Close())
+}
+
+func TestClientKinds(t *testing.T) {
+	conf := old.NewConfig()
+	conf.URL = "tcp://node1:7000,tcp://node2:7001"
+
+	conf.Kind = "cluster"
+	client, err := clientFromConfig(conf)
+	require.NoError(t, err)
+	require.IsType(t, &redis.ClusterClient{}, client)
+	assert.Equal(t, []string{"node1:7000", "node2:7001"}, client.(*redis.ClusterClient).Options().Addrs)
+	require.NoError(t, client.Close())
+
+	conf.Kind = "failover"
+	_, err = clientFromConfig(conf)
+	require.EqualError(t, err, "master must be set when kind is failover")
+
+	conf.Master = "mymaster"
+	client, err = clientFromConfig(conf)
+	require.NoError(t, err)
+	require.IsType(t, &redis.Client{}, client)
+	require.NoError(t, client.Close())
+
+	conf.Kind = "nope"
+	_, err = clientFromConfig(conf)
+	require.EqualError(t, err, "invalid redis kind: nope")
+}
+
+func TestIsRedisError(t *testing.T) {
+	serverErr := redisError("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "unwrapped", err: serverErr, expected: true},
+		{name: "wrapped", err: fmt.Errorf("failed to set field expiry: %w", serverErr), expected: true},
+		{name: "doubly wrapped", err: fmt.Errorf("foo: %w", fmt.Errorf("bar: %w", serverErr)), expected: true},
+		{name: "connection error", err: errors.New("dial tcp: connection refused"), expected: false},
+		{name: "redis nil", err: redis.Nil, expected: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isRedisError(test.err))
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-redis/redis/v7"
+
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func redisHashInputConfig() *service.ConfigSpec {
+	spec := service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Services").
+		Version("4.2.0").
+		Summary(`Scans the keys of a Redis server using the SCAN command and reads the hash of each key with the HGETALL command.`).
+		Description(`
+Each hash is emitted as a message containing a JSON object of its fields, with the metadata field ` + "`redis_key`" + ` set to its key. Once all keys have been scanned this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a [sequence](/docs/components/inputs/sequence) to execute).
+
+Keys are scanned in pages, and the hashes of each page are read in a single round trip by pipelining their HGETALL commands, where each page is emitted as a batch. If the hash of an individual key cannot be read, for example because the key holds a value that is not a hash, then its message is empty and flagged as having failed, and the error can be caught using error handling methods outlined [here](/docs/configuration/error_handling).
+
+This input does not support a ` + "`kind`" + ` of ` + "`cluster`" + `, as the SCAN command only iterates the keys held by a single node of a cluster.`)
+
+	for _, f := range clientFields() {
+		spec = spec.Field(f)
+	}
+
+	return spec.
+		Field(readURLField()).
+		Field(service.NewStringField("match").
+			Description("A glob-style pattern that scanned keys must match, where an empty pattern matches all keys.").
+			Default("").
+			Example("foo:*")).
+		Field(service.NewIntField("page_size").
+			Description("The number of keys to request per page, which is used as the COUNT hint of SCAN commands and therefore also determines the number of HGETALL commands pipelined per round trip.").
+			Default(100).
+			Advanced())
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"redis_hash", redisHashInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			return newRedisHashInputFromConfig(conf, mgr.Logger())
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type redisHashInput struct {
+	conf     *service.ParsedConfig
+	match    string
+	pageSize int64
+
+	client   redis.UniversalClient
+	cursor   uint64
+	finished bool
+	cMut     sync.Mutex
+
+	log *service.Logger
+}
+
+func newRedisHashInputFromConfig(conf *service.ParsedConfig, log *service.Logger) (*redisHashInput, error) {
+	match, err := conf.FieldString("match")
+	if err != nil {
+		return nil, err
+	}
+	pageSize, err := conf.FieldInt("page_size")
+	if err != nil {
+		return nil, err
+	}
+	if pageSize < 1 {
+		return nil, fmt.Errorf("page_size must be greater than zero, got %v", pageSize)
+	}
+	kind, err := conf.FieldString("kind")
+	if err != nil {
+		return nil, err
+	}
+	if kind == "cluster" {
+		// SCAN only iterates the keys of the node that it is sent to, and
+		// would therefore silently read a partial keyspace.
+		return nil, errors.New("kind cluster is not supported, as SCAN only iterates the keys of a single node")
+	}
+	// Validate the client config early.
+	if _, err := getClient(conf); err != nil {
+		return nil, err
+	}
+	if _, err := getReadClient(conf); err != nil {
+		return nil, err
+	}
+	return &redisHashInput{
+		conf:     conf,
+		match:    match,
+		pageSize: int64(pageSize),
+		log:      log,
+	}, nil
+}
+
+func (r *redisHashInput) Connect(ctx context.Context) error {
+	r.cMut.Lock()
+	defer r.cMut.Unlock()
+
+	if r.client != nil {
+		return nil
+	}
+
+	// This input only performs reads, and therefore connects to the read URL
+	// when one is configured.
+	client, err := getReadClient(r.conf)
+	if err == nil && client == nil {
+		client, err = getClient(r.conf)
+	}
+	if err == nil {
+		_, err = client.Ping().Result()
+	}
+	if err != nil {
+		return err
+	}
+
+	r.log.Infof("Scanning Redis hashes matching: '%v'", r.match)
+
+	r.client = client
+	return nil
+}
+
+func (r *redisHashInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	r.cMut.Lock()
+	defer r.cMut.Unlock()
+
+	if r.client == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	// SCAN can return empty pages before the cursor is exhausted.
+	var keys []string
+	for len(keys) == 0 {
+		if r.finished {
+			return nil, nil, service.ErrEndOfInput
+		}
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+
+		var err error
+		var cursor uint64
+		if keys, cursor, err = r.client.Scan(r.cursor, r.match, r.pageSize).Result(); err != nil {
+			return nil, nil, r.disconnectOnErr(err)
+		}
+		r.cursor = cursor
+		r.finished = cursor == 0
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(keys))
+	for i, k := range keys {
+		cmds[i] = pipe.HGetAll(k)
+	}
+
+	// Errors of individual commands are returned by Exec as well as set on the
+	// commands themselves, and are reported per message below.
+	if _, err := pipe.Exec(); err != nil && !isRedisError(err) {
+		return nil, nil, r.disconnectOnErr(err)
+	}
+
+	batch := make(service.MessageBatch, len(keys))
+	for i, cmd := range cmds {
+		msg := service.NewMessage(nil)
+		msg.MetaSet("redis_key", keys[i])
+
+		fields, err := cmd.Result()
+		if err != nil {
+			msg.SetError(fmt.Errorf("failed to read hash '%v': %w", keys[i], err))
+		} else {
+			obj := make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				obj[k] = v
+			}
+			msg.SetStructured(obj)
+		}
+		batch[i] = msg
+	}
+
+	return batch, func(ctx context.Context, err error) error {
+		return nil
+	}, nil
+}
+
+func (r *redisHashInput) disconnectOnErr(err error) error {
+	r.log.Errorf("Error from redis: %v", err)
+	if r.client != nil {
+		_ = r.client.Close()
+		r.client = nil
+	}
+	return service.ErrNotConnected
+}
+
+func (r *redisHashInput) Close(ctx context.Context) error {
+	r.cMut.Lock()
+	defer r.cMut.Unlock()
+
+	var err error
+	if r.client != nil {
+		err = r.client.Close()
+		r.client = nil
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/benthosdev/benthos/v4/internal/integration"
+	"github.com/benthosdev/benthos/v4/public/service"
+)
+
+func TestIntegrationRedisHashInput(t *testing.T) {
+	integration.CheckSkip(t)
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Could not connect to docker: %s", err)
+	}
+	pool.MaxWait = time.Second * 30
+
+	resource, err := pool.Run("redis", "latest", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, pool.Purge(resource))
+	})
+
+	urlStr := fmt.Sprintf("tcp://localhost:%v", resource.GetPort("6379/tcp"))
+	uri, err := url.Parse(urlStr)
+	require.NoError(t, err)
+
+	client := redis.NewClient(&redis.Options{
+		Addr:    uri.Host,
+		Network: uri.Scheme,
+	})
+	require.NoError(t, pool.Retry(func() error {
+		return client.Ping().Err()
+	}))
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	expected := map[string]interface{}{}
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("foo:%v", i)
+		require.NoError(t, client.HSet(key, "id", fmt.Sprintf("%v", i), "name", "foo").Err())
+		expected[key] = map[string]interface{}{"id": fmt.Sprintf("%v", i), "name": "foo"}
+	}
+	require.NoError(t, client.Set("foo:not_a_hash", "bar", 0).Err())
+	require.NoError(t, client.HSet("bar:0", "id", "0").Err())
+
+	conf, err := redisHashInputConfig().ParseYAML(fmt.Sprintf(`
+url: %v
+match: foo:*
+page_size: 10
+`, urlStr), nil)
+	require.NoError(t, err)
+
+	input, err := newRedisHashInputFromConfig(conf, nil)
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*30)
+	defer done()
+
+	require.NoError(t, input.Connect(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, input.Close(context.Background()))
+	})
+
+	actual := map[string]interface{}{}
+	var failedKeys []string
+	for {
+		batch, ackFn, err := input.ReadBatch(ctx)
+		if err == service.ErrEndOfInput {
+			break
+		}
+		require.NoError(t, err)
+		for _, msg := range batch {
+			key, _ := msg.MetaGet("redis_key")
+			if msg.GetError() != nil {
+				failedKeys = append(failedKeys, key)
+				continue
+			}
+			v, err := msg.AsStructured()
+			require.NoError(t, err)
+			actual[key] = v
+		}
+		require.NoError(t, ackFn(ctx, nil))
+	}
+
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, []string{"foo:not_a_hash"}, failedKeys)
+}
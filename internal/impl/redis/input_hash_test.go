@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashInputConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		errContains string
+	}{
+		{
+			name: "simple",
+			config: `
+url: tcp://localhost:6379
+`,
+		},
+		{
+			name: "bad page size",
+			config: `
+url: tcp://localhost:6379
+page_size: 0
+`,
+			errContains: "page_size must be greater than zero, got 0",
+		},
+		{
+			name: "cluster",
+			config: `
+url: tcp://localhost:6379
+kind: cluster
+`,
+			errContains: "kind cluster is not supported",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := redisHashInputConfig().ParseYAML(test.config, nil)
+			require.NoError(t, err)
+
+			_, err = newRedisHashInputFromConfig(conf, nil)
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -2,18 +2,25 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redis/v7"
 	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	ibatch "github.com/benthosdev/benthos/v4/internal/batch"
 	"github.com/benthosdev/benthos/v4/internal/component/output"
 	"github.com/benthosdev/benthos/v4/internal/integration"
 	"github.com/benthosdev/benthos/v4/internal/log"
+	"github.com/benthosdev/benthos/v4/internal/manager/mock"
+	"github.com/benthosdev/benthos/v4/internal/message"
 )
 
 func TestIntegrationRedis(t *testing.T) {
@@ -220,6 +227,373 @@ output:
 			integration.StreamTestOptSleepAfterOutput(100*time.Millisecond),
 			integration.StreamTestOptPort(resource.GetPort("6379/tcp")),
 		)
+		t.Run("with diff", func(t *testing.T) {
+			t.Parallel()
+			suite.Run(
+				t, template+`
+    diff: true
+`,
+				integration.StreamTestOptSleepAfterInput(100*time.Millisecond),
+				integration.StreamTestOptSleepAfterOutput(100*time.Millisecond),
+				integration.StreamTestOptPort(resource.GetPort("6379/tcp")),
+			)
+		})
+		t.Run("with hset", func(t *testing.T) {
+			t.Parallel()
+			suite.Run(
+				t, template+`
+    set_command: hset
+`,
+				integration.StreamTestOptSleepAfterInput(100*time.Millisecond),
+				integration.StreamTestOptSleepAfterOutput(100*time.Millisecond),
+				integration.StreamTestOptPort(resource.GetPort("6379/tcp")),
+			)
+		})
+		t.Run("with expiration", func(t *testing.T) {
+			t.Parallel()
+			hashGetExpiringFn := func(ctx context.Context, testID string, id string) (string, []string, error) {
+				client := redis.NewClient(&redis.Options{
+					Addr:    fmt.Sprintf("localhost:%v", resource.GetPort("6379/tcp")),
+					Network: "tcp",
+				})
+				ttl, err := client.PTTL(testID + "-" + id).Result()
+				if err != nil {
+					return "", nil, err
+				}
+				if ttl <= 0 {
+					return "", nil, fmt.Errorf("expected key to expire, got ttl %v", ttl)
+				}
+				return hashGetFn(ctx, testID, id)
+			}
+			integration.StreamTests(
+				integration.StreamTestOutputOnlySendSequential(10, hashGetExpiringFn),
+				integration.StreamTestOutputOnlySendBatch(10, hashGetExpiringFn),
+				integration.StreamTestOutputOnlyOverride(hashGetExpiringFn),
+			).Run(
+				t, template+`
+    expiration: 1h
+`,
+				integration.StreamTestOptSleepAfterInput(100*time.Millisecond),
+				integration.StreamTestOptSleepAfterOutput(100*time.Millisecond),
+				integration.StreamTestOptPort(resource.GetPort("6379/tcp")),
+			)
+		})
+		t.Run("batch with wrong type", func(t *testing.T) {
+			t.Parallel()
+			client := redis.NewClient(&redis.Options{
+				Addr:    fmt.Sprintf("localhost:%v", resource.GetPort("6379/tcp")),
+				Network: "tcp",
+			})
+			require.NoError(t, client.Set("wrongtype-b", "not a hash", 0).Err())
+
+			conf := output.NewRedisHashConfig()
+			conf.URL = fmt.Sprintf("tcp://localhost:%v", resource.GetPort("6379/tcp"))
+			conf.Key = `wrongtype-${! json("id") }`
+			conf.WalkJSONObject = true
+
+			w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+			require.NoError(t, err)
+			require.NoError(t, w.ConnectWithContext(context.Background()))
+			t.Cleanup(func() {
+				_ = w.disconnect()
+			})
+
+			err = w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{
+				[]byte(`{"id":"a"}`),
+				[]byte(`{"id":"b"}`),
+				[]byte(`{"id":"c"}`),
+			}))
+			var batchErr *ibatch.Error
+			require.True(t, errors.As(err, &batchErr), err)
+
+			failed := map[int]bool{}
+			batchErr.WalkParts(func(i int, _ *message.Part, err error) bool {
+				failed[i] = err != nil
+				return true
+			})
+			assert.Equal(t, map[int]bool{0: false, 1: true, 2: false}, failed)
+
+			for _, key := range []string{"wrongtype-a", "wrongtype-c"} {
+				id, err := client.HGet(key, "id").Result()
+				require.NoError(t, err)
+				assert.Equal(t, key[len("wrongtype-"):], id)
+			}
+		})
+		t.Run("delete empty", func(t *testing.T) {
+			t.Parallel()
+			client := redis.NewClient(&redis.Options{
+				Addr:    fmt.Sprintf("localhost:%v", resource.GetPort("6379/tcp")),
+				Network: "tcp",
+			})
+
+			conf := output.NewRedisHashConfig()
+			conf.URL = fmt.Sprintf("tcp://localhost:%v", resource.GetPort("6379/tcp"))
+			conf.Key = `deleteempty-${! json("id") }`
+			conf.WalkJSONObject = true
+			conf.DeleteEmpty = true
+			conf.Fields = map[string]string{
+				"status": `${! json("status").or("") }`,
+			}
+
+			w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+			require.NoError(t, err)
+			require.NoError(t, w.ConnectWithContext(context.Background()))
+			t.Cleanup(func() {
+				_ = w.disconnect()
+			})
+
+			require.NoError(t, w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{
+				[]byte(`{"id":"a","name":"foo","status":"active","note":"bar"}`),
+				[]byte(`{"id":"b","name":"baz","status":"active"}`),
+			})))
+
+			require.NoError(t, w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{
+				[]byte(`{"id":"a","name":"foo","note":null}`),
+			})))
+			require.NoError(t, w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{
+				[]byte(`{"id":"b","name":""}`),
+			})))
+
+			res, err := client.HGetAll("deleteempty-a").Result()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"id": "a", "name": "foo"}, res)
+
+			res, err = client.HGetAll("deleteempty-b").Result()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"id": "b"}, res)
+		})
+		t.Run("with only if changed", func(t *testing.T) {
+			t.Parallel()
+			suite.Run(
+				t, template+`
+    only_if_changed: true
+`,
+				integration.StreamTestOptSleepAfterInput(100*time.Millisecond),
+				integration.StreamTestOptSleepAfterOutput(100*time.Millisecond),
+				integration.StreamTestOptPort(resource.GetPort("6379/tcp")),
+			)
+		})
+		t.Run("only if changed keeps other fields", func(t *testing.T) {
+			t.Parallel()
+			client := redis.NewClient(&redis.Options{
+				Addr:    fmt.Sprintf("localhost:%v", resource.GetPort("6379/tcp")),
+				Network: "tcp",
+			})
+
+			conf := output.NewRedisHashConfig()
+			conf.URL = fmt.Sprintf("tcp://localhost:%v", resource.GetPort("6379/tcp"))
+			conf.Key = `onlyifchanged-${! json("id") }`
+			conf.WalkJSONObject = true
+			conf.OnlyIfChanged = true
+
+			w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+			require.NoError(t, err)
+			require.NoError(t, w.ConnectWithContext(context.Background()))
+			t.Cleanup(func() {
+				_ = w.disconnect()
+			})
+
+			require.NoError(t, client.HSet("onlyifchanged-a", "other", "foo").Err())
+
+			for _, batch := range [][][]byte{
+				{[]byte(`{"id":"a","count":1}`), []byte(`{"id":"b","count":2}`)},
+				{[]byte(`{"id":"a","count":1}`), []byte(`{"id":"b","count":3}`)},
+				{[]byte(`{"id":"a","count":1}`)},
+			} {
+				require.NoError(t, w.WriteWithContext(context.Background(), message.QuickBatch(batch)))
+			}
+
+			res, err := client.HGetAll("onlyifchanged-a").Result()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"id": "a", "count": "1", "other": "foo"}, res)
+
+			res, err = client.HGetAll("onlyifchanged-b").Result()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"id": "b", "count": "3"}, res)
+		})
+		t.Run("with auto set command and diff", func(t *testing.T) {
+			t.Parallel()
+			suite.Run(
+				t, template+`
+    set_command: auto
+    diff: true
+`,
+				integration.StreamTestOptSleepAfterInput(100*time.Millisecond),
+				integration.StreamTestOptSleepAfterOutput(100*time.Millisecond),
+				integration.StreamTestOptPort(resource.GetPort("6379/tcp")),
+			)
+		})
+	})
+}
+
+func TestIntegrationRedisHashCluster(t *testing.T) {
+	integration.CheckSkip(t)
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+	pool.MaxWait = time.Second * 30
+
+	networks, _ := pool.Client.ListNetworks()
+	hostIP := ""
+	for _, network := range networks {
+		if network.Name == "bridge" {
+			hostIP = network.IPAM.Config[0].Gateway
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		hostIP = "0.0.0.0"
+	}
+
+	// The ports differ from those of the cluster cache tests so that both
+	// clusters can run at the same time.
+	exposedPorts := make([]string, 12)
+	portBindings := make(map[docker.Port][]docker.PortBinding, 12)
+	for i := 0; i < 6; i++ {
+		p1 := fmt.Sprintf("%d/tcp", 7100+i)
+		p2 := fmt.Sprintf("%d/tcp", 17100+i)
+		exposedPorts[i] = p1
+		exposedPorts[i+6] = p2
+		portBindings[docker.Port(p1)] = []docker.PortBinding{{HostIP: "", HostPort: p1}}
+		portBindings[docker.Port(p2)] = []docker.PortBinding{{HostIP: "", HostPort: p2}}
+	}
+
+	cluster, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Name:         "redis-hash-cluster",
+		Repository:   "grokzen/redis-cluster",
+		Tag:          "6.0.7",
+		ExposedPorts: exposedPorts,
+		PortBindings: portBindings,
+		Env: []string{
+			"IP=" + hostIP,
+			"INITIAL_PORT=7100",
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, pool.Purge(cluster))
+	})
+
+	var addrs []string
+	clusterURL := ""
+	for i := 0; i < 6; i++ {
+		addr := fmt.Sprintf("%s:%d", hostIP, 7100+i)
+		addrs = append(addrs, addr)
+		clusterURL += fmt.Sprintf("redis://%s/0,", addr)
+	}
+	clusterURL = strings.TrimSuffix(clusterURL, ",")
+
+	newWriter := func(t testing.TB, fn func(conf *output.RedisHashConfig)) *redisHashWriter {
+		conf := output.NewRedisHashConfig()
+		conf.URL = clusterURL
+		conf.Kind = "cluster"
+		conf.Key = `${! json("id") }`
+		conf.WalkJSONObject = true
+		if fn != nil {
+			fn(&conf)
+		}
+
+		w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+		require.NoError(t, err)
+		return w
+	}
+
+	require.NoError(t, pool.Retry(func() error {
+		w := newWriter(t, nil)
+		if cErr := w.ConnectWithContext(context.Background()); cErr != nil {
+			return cErr
+		}
+		defer func() {
+			_ = w.disconnect()
+		}()
+		return w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{
+			[]byte(`{"id":"benthos_test_redis_connect"}`),
+		}))
+	}))
+
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: addrs,
+	})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	template := `
+output:
+  redis_hash:
+    url: $VAR1
+    kind: cluster
+    key: $ID-${! json("id") }
+    fields:
+      content: ${! content() }
+`
+	hashGetFn := func(ctx context.Context, testID string, id string) (string, []string, error) {
+		res, err := client.HGet(testID+"-"+id, "content").Result()
+		if err != nil {
+			return "", nil, err
+		}
+		return res, nil, nil
+	}
+	suite := integration.StreamTests(
+		integration.StreamTestOutputOnlySendSequential(10, hashGetFn),
+		integration.StreamTestOutputOnlySendBatch(10, hashGetFn),
+		integration.StreamTestOutputOnlyOverride(hashGetFn),
+	)
+	suite.Run(
+		t, template,
+		integration.StreamTestOptSleepAfterInput(100*time.Millisecond),
+		integration.StreamTestOptSleepAfterOutput(100*time.Millisecond),
+		integration.StreamTestOptVarOne(clusterURL),
+	)
+
+	t.Run("with diff", func(t *testing.T) {
+		t.Parallel()
+		suite.Run(
+			t, template+`
+    diff: true
+`,
+			integration.StreamTestOptSleepAfterInput(100*time.Millisecond),
+			integration.StreamTestOptSleepAfterOutput(100*time.Millisecond),
+			integration.StreamTestOptVarOne(clusterURL),
+		)
+	})
+
+	t.Run("batch across slots", func(t *testing.T) {
+		t.Parallel()
+		w := newWriter(t, func(conf *output.RedisHashConfig) {
+			conf.Key = `slots-${! json("id") }`
+			conf.Expiration = "1h"
+		})
+		require.NoError(t, w.ConnectWithContext(context.Background()))
+		t.Cleanup(func() {
+			_ = w.disconnect()
+		})
+
+		var parts [][]byte
+		slots := map[int64]struct{}{}
+		for i := 0; i < 100; i++ {
+			parts = append(parts, []byte(fmt.Sprintf(`{"id":"%v","value":"%v"}`, i, i*2)))
+
+			slot, err := client.ClusterKeySlot(fmt.Sprintf("slots-%v", i)).Result()
+			require.NoError(t, err)
+			slots[slot] = struct{}{}
+		}
+		require.Greater(t, len(slots), 1)
+		require.NoError(t, w.WriteWithContext(context.Background(), message.QuickBatch(parts)))
+
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("slots-%v", i)
+			res, err := client.HGetAll(key).Result()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{
+				"id":    fmt.Sprintf("%v", i),
+				"value": fmt.Sprintf("%v", i*2),
+			}, res, key)
+
+			ttl, err := client.PTTL(key).Result()
+			require.NoError(t, err)
+			assert.Greater(t, int64(ttl), int64(0), key)
+		}
 	})
 }
 
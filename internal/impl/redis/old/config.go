@@ -7,18 +7,26 @@ import (
 
 // Config is a config struct for a redis connection.
 type Config struct {
-	URL    string      `json:"url" yaml:"url"`
-	Kind   string      `json:"kind" yaml:"kind"`
-	Master string      `json:"master" yaml:"master"`
-	TLS    btls.Config `json:"tls" yaml:"tls"`
+	URL          string      `json:"url" yaml:"url"`
+	ReadURL      string      `json:"read_url" yaml:"read_url"`
+	Kind         string      `json:"kind" yaml:"kind"`
+	Master       string      `json:"master" yaml:"master"`
+	DialTimeout  string      `json:"dial_timeout" yaml:"dial_timeout"`
+	ReadTimeout  string      `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout string      `json:"write_timeout" yaml:"write_timeout"`
+	TLS          btls.Config `json:"tls" yaml:"tls"`
 }
 
 // NewConfig returns a Config with default values.
 func NewConfig() Config {
 	return Config{
-		URL:  "",
-		Kind: "simple",
-		TLS:  btls.NewConfig(),
+		URL:          "",
+		ReadURL:      "",
+		Kind:         "simple",
+		DialTimeout:  "5s",
+		ReadTimeout:  "3s",
+		WriteTimeout: "3s",
+		TLS:          btls.NewConfig(),
 	}
 }
 
@@ -32,7 +40,7 @@ func ConfigDocs() docs.FieldSpecs {
 Some cloud hosted instances of Redis (such as Azure Cache) might need some hand holding in order to establish stable connections. Unfortunately, it is often the case that TLS issues will manifest as generic error messages such as "i/o timeout". If you're using TLS and are seeing connectivity problems consider setting ` + "`enable_renegotiation` to `true`" + `, and ensuring that the server supports at least TLS version 1.2.`
 	return docs.FieldSpecs{
 		docs.FieldString(
-			"url", "The URL of the target Redis server. Database is optional and is supplied as the URL path. The scheme `tcp` is equivalent to `redis`.",
+			"url", "The URL of the target Redis server. Database is optional and is supplied as the URL path. The scheme `tcp` is equivalent to `redis`, and TLS is enabled for URLs with the scheme `rediss`.",
 			":6397",
 			"localhost:6397",
 			"redis://localhost:6379",
@@ -42,6 +50,18 @@ Some cloud hosted instances of Redis (such as Azure Cache) might need some hand
 		).HasDefault(""),
 		docs.FieldString("kind", "Specifies a simple, cluster-aware, or failover-aware redis client.", "simple", "cluster", "failover").HasDefault("simple").Advanced(),
 		docs.FieldString("master", "Name of the redis master when `kind` is `failover`", "mymaster").HasDefault("").Advanced(),
+		docs.FieldString("dial_timeout", "The maximum period to wait for a connection to be established.").HasDefault("5s").Advanced().AtVersion("4.2.0"),
+		docs.FieldString("read_timeout", "The maximum period to wait for a response to a command, after which the command fails. Commands that block, such as those used for consuming lists and streams, are given additional time by the client according to their own timeouts.").HasDefault("3s").Advanced().AtVersion("4.2.0"),
+		docs.FieldString("write_timeout", "The maximum period to wait for a command to be written to a connection.").HasDefault("3s").Advanced().AtVersion("4.2.0"),
 		tlsSpec,
 	}
 }
+
+// ReadURLDocs returns a documentation field spec for the read URL of a Config,
+// which is only relevant to components that perform reads.
+func ReadURLDocs() docs.FieldSpec {
+	return docs.FieldString(
+		"read_url", "An optional URL of a Redis server, such as a read replica, that read operations are sent to instead of `url`, whilst writes continue to be sent to `url`. The `kind`, `master` and `tls` settings apply to both. Reads from a replica are eventually consistent and may not reflect the most recent writes.",
+		"redis://replica:6379",
+	).HasDefault("").Advanced().AtVersion("4.2.0")
+}
@@ -2,18 +2,25 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v7"
 
+	ibatch "github.com/benthosdev/benthos/v4/internal/batch"
+	"github.com/benthosdev/benthos/v4/internal/batch/policy"
 	"github.com/benthosdev/benthos/v4/internal/bloblang/field"
 	"github.com/benthosdev/benthos/v4/internal/bundle"
 	"github.com/benthosdev/benthos/v4/internal/component"
 	"github.com/benthosdev/benthos/v4/internal/component/metrics"
 	"github.com/benthosdev/benthos/v4/internal/component/output"
+	"github.com/benthosdev/benthos/v4/internal/component/output/batcher"
 	"github.com/benthosdev/benthos/v4/internal/component/output/processors"
 	"github.com/benthosdev/benthos/v4/internal/docs"
 	"github.com/benthosdev/benthos/v4/internal/impl/redis/old"
@@ -26,8 +33,8 @@ func init() {
 		return newRedisHashOutput(c, nm, nm.Logger(), nm.Metrics())
 	}), docs.ComponentSpec{
 		Name:    "redis_hash",
-		Summary: `Sets Redis hash objects using the HMSET command.`,
-		Description: output.Description(true, false, `
+		Summary: `Sets Redis hash objects using the HMSET or HSET commands.`,
+		Description: output.Description(true, true, `
 The field `+"`key`"+` supports
 [interpolation functions](/docs/configuration/interpolation#bloblang-queries), allowing
 you to create a unique key for each message.
@@ -60,16 +67,195 @@ The order of hash field extraction is as follows:
 2. JSON object (if enabled)
 3. Explicit fields
 
-Where latter stages will overwrite matching field names of a former stage.`),
+Where latter stages will overwrite matching field names of a former stage.
+
+### Interpolation Errors
+
+When the interpolation of a field within `+"`fields`"+` fails, such as when it
+references data that a message does not contain, the field `+"`on_interp_error`"+`
+determines what happens. By default, with `+"`fail`"+`, the message fails to send
+with an error describing the failed interpolation, and none of its fields are
+written. With `+"`write`"+` the field is set to the result of the failed
+interpolation, which is the string a failed function resolves to and is usually
+not meaningful. With `+"`skip`"+` the field is not set by the message, and in diff
+mode is not deleted from the current hash either.
+
+A message always fails to send when the interpolation of `+"`key`"+` or
+`+"`expiration`"+` fails, or when its key resolves to an empty string, as
+writing to such a key would mix the data of unrelated messages.
+
+### Deleting Empty Fields
+
+When the field `+"`delete_empty`"+` is set to `+"`true`"+` the hash fields of a
+message with an empty value are deleted with the HDEL command, within the same
+pipeline as the fields that are set, rather than being set to an empty string.
+This includes `+"`null`"+` values of a walked JSON object. Empty values are
+determined after all stages of field extraction, and therefore an empty
+explicit field deletes a field of the same name that was walked from metadata
+or the JSON object.
+
+Keys that are absent from a walked JSON object are not deleted, as a message
+does not identify the fields that it lacks. In order to delete a field when a
+key is absent map it explicitly with a fallback to an empty string, such as
+`+"`${! json(\"doc.status\").or(\"\") }`"+`, or enable `+"`diff`"+`, which deletes all
+fields of the current hash that are not set by a message. In diff mode empty
+fields are only deleted when they exist within the current hash.
+
+### Diff Mode
+
+When the field `+"`diff`"+` is set to `+"`true`"+` the current hash of each key
+is read with the HGETALL command before writing, and only the fields that have
+changed are set. Fields of the current hash that are not present in the message
+are deleted with the HDEL command, and keys that do not exist yet have all of
+their fields set. This reduces the number of fields written, and allows
+consumers of keyspace notifications to observe minimal changes, at the cost of
+an extra round trip per message.
+
+When the field `+"`read_url`"+` is set the current hashes are read from that
+server instead, such as a read replica, whilst writes are still sent to
+`+"`url`"+`. Since replicas are eventually consistent the current hash read
+may be stale, in which case unchanged fields might be set again, or fields
+that were recently added might not be deleted until a subsequent write.
+
+### Skipping Unchanged Writes
+
+When the field `+"`only_if_changed`"+` is set to `+"`true`"+` the current values of
+the fields of each message are read with the HMGET command before writing, and
+the write is skipped when they all match, which avoids replicating identical
+writes at the cost of an extra round trip. Unlike diff mode all fields of a
+message are set when any of them differ, and fields of the current hash that
+are not set by the message are left intact. The reads of a batch are sent
+within a single pipeline, and are sent to `+"`read_url`"+` when it is set. When a
+write is skipped the expiration of its key is still renewed, but the expiry of
+its fields is not.
+
+### Sanitizing Field Names
+
+When the field `+"`sanitize_field_names`"+` is set to `+"`true`"+` each
+character of a hash field name that is listed in `+"`sanitize_characters`"+`
+is replaced with `+"`sanitize_replacement`"+` before the fields are set. This is
+useful when hashes are indexed by tools such as RediSearch, where certain
+characters within field names cause problems.
+
+When distinct field names become identical after sanitization the value of the
+field whose original name sorts last lexicographically is used, and a warning
+is logged.
+
+### Write Consistency
+
+When the field `+"`wait_replicas`"+` is greater than zero a WAIT command is
+issued after each write, which blocks until the write has been acknowledged by
+at least that many replicas, or until `+"`wait_timeout`"+` has elapsed. If the
+required number of replicas do not acknowledge the write in time then the
+message fails to send, even though the write may have been applied to the
+primary. The WAIT command is sent within the same pipeline as the write, except
+in diff mode where it follows the transaction that applies the changes. Waiting
+for replicas is not supported when `+"`kind`"+` is `+"`cluster`"+`, as the WAIT
+command cannot be routed to the nodes that hold the written keys.
+
+### Key Expiration
+
+The field `+"`expiration`"+` sets a duration after which the key of each message
+expires, which is applied with the PEXPIRE command within the same pipeline as
+the write and is therefore renewed by each message written to the key. When the
+expiration resolves to an empty string or zero the key does not expire, and its
+current expiry is left unchanged. When `+"`diff`"+` is `+"`true`"+` the expiration is
+renewed even when no fields of the hash have changed.
+
+### Field Expiry
+
+The field `+"`field_expiry`"+` allows you to specify a map of hash field names to
+durations, where each listed field that is set by a message expires
+independently of the key after its duration. The expiry is applied with the
+HPEXPIRE command within the same pipeline as the write, and is therefore renewed
+each time the field is set. In diff mode only the fields that have changed are
+set, and therefore the expiry of unchanged fields is not renewed. Field names
+refer to the names of fields as they are set, after sanitization.
+
+Field expiry requires Redis 7.4 or newer, and messages fail to send with an
+error explaining this when the server does not support it. Outside of diff mode
+the fields are set before their expiry is applied, and therefore such messages
+may have been partially written.
+
+### Counting New Fields
+
+When the field `+"`count_new_fields`"+` is set to `+"`true`"+` fields are set with
+the HSET command instead of HMSET, which returns the number of fields that were
+newly created rather than updated, and this number is added to the counter
+metric `+"`redis_hash_fields_created`"+`. This gives insight into the ratio of
+created to updated fields without reading hashes separately. Setting multiple
+fields with HSET requires Redis 4.0 or newer.
+
+Since outputs cannot modify messages once they are acknowledged, the number is
+not added to the metadata of messages, and is instead also logged at the debug
+level for each key written.
+
+### Set Command
+
+Fields are set with the HMSET command by default, which is deprecated since
+Redis 4.0 in favour of HSET with multiple fields and values. The field
+`+"`set_command`"+` selects HSET instead, which sets fields in exactly the same
+way, or `+"`auto`"+` in order to select HSET when the server reports a version
+of 4.0 or newer with the INFO command upon connecting. When the version cannot
+be determined, such as when the INFO command is disabled, `+"`auto`"+` falls back
+to HMSET and logs a warning. Fields are always set with HSET when
+`+"`count_new_fields`"+` is `+"`true`"+`.
+
+### Cluster and Failover
+
+When `+"`kind`"+` is `+"`cluster`"+` the field `+"`url`"+` lists the addresses of one or
+more nodes of a Redis Cluster, from which the remaining nodes are discovered.
+The commands of each key are routed to the node that holds its slot, and MOVED
+and ASK redirects are followed, including for the pipelines of batches that
+write keys of different slots. When `+"`kind`"+` is `+"`failover`"+` the field
+`+"`url`"+` lists the addresses of Redis Sentinel servers, which are asked for
+the address of the current primary named by `+"`master`"+`.
+
+### Batches
+
+The messages of a batch are written with a single pipeline, and therefore in
+one round trip. When the server rejects the commands of a message, such as when
+its key holds a value that is not a hash, only that message fails to send,
+whereas other errors fail the whole batch. When `+"`wait_replicas`"+` is greater
+than zero a single WAIT command follows the writes of the batch. In diff mode
+the messages of a batch are written individually, as the current hash of each
+key is read before writing.`),
 		Config: docs.FieldComponent().WithChildren(old.ConfigDocs()...).WithChildren(
+			old.ReadURLDocs(),
 			docs.FieldString(
 				"key", "The key for each message, function interpolations should be used to create a unique key per message.",
 				"${!meta(\"kafka_key\")}", "${!json(\"doc.id\")}", "${!count(\"msgs\")}",
-			).IsInterpolated(),
+			).IsInterpolated().LinterFunc(docs.LintRequiredInterpolatedField),
 			docs.FieldBool("walk_metadata", "Whether all metadata fields of messages should be walked and added to the list of hash fields to set."),
+			docs.FieldString("metadata_exclude", "A list of metadata keys to exclude when `walk_metadata` is `true`.").Array().Advanced().AtVersion("4.2.0"),
 			docs.FieldBool("walk_json_object", "Whether to walk each message as a JSON object and add each key/value pair to the list of hash fields to set."),
 			docs.FieldString("fields", "A map of key/value pairs to set as hash fields.").IsInterpolated().Map(),
+			docs.FieldString("on_interp_error", "What to do when the interpolation of a field within `fields` fails.").HasAnnotatedOptions(
+				"write", "Set the field to the result of the failed interpolation.",
+				"skip", "Do not set the field.",
+				"fail", "Fail to send the message.",
+			).Advanced().AtVersion("4.2.0"),
+			docs.FieldBool("delete_empty", "Whether to delete hash fields with an empty value, such as an interpolation that resolves to an empty string or a `null` value of a walked JSON object, rather than setting them to an empty string.").Advanced().AtVersion("4.2.0"),
+			docs.FieldBool("diff", "Whether to only set hash fields that differ from the current hash of the key, and delete fields of the current hash that are not set by the message.").Advanced().AtVersion("4.2.0"),
+			docs.FieldBool("only_if_changed", "Whether to read the current values of the fields of each message before writing, and skip the write when none of them differ. Cannot be combined with `diff`.").Advanced().AtVersion("4.2.0"),
+			docs.FieldBool("sanitize_field_names", "Whether to replace the characters of `sanitize_characters` within hash field names before they are set.").Advanced().AtVersion("4.2.0"),
+			docs.FieldString("sanitize_characters", "The characters to replace within hash field names when `sanitize_field_names` is `true`.").Advanced().AtVersion("4.2.0"),
+			docs.FieldString("sanitize_replacement", "The string that characters of `sanitize_characters` are replaced with.").Advanced().AtVersion("4.2.0"),
+			docs.FieldInt("wait_replicas", "The number of replicas that must acknowledge each write before it is considered successful, where zero disables waiting for replicas. Not supported when `kind` is `cluster`.").Advanced().AtVersion("4.2.0"),
+			docs.FieldString("wait_timeout", "The maximum period to wait for replicas to acknowledge a write when `wait_replicas` is greater than zero.", "500ms", "5s").Advanced().AtVersion("4.2.0"),
+			docs.FieldString("expiration", "An optional duration after which the key of each message expires, which is renewed by each write to the key. Leave empty or set to zero in order for keys not to expire.", "1h", "${! meta(\"ttl\") }").IsInterpolated().AtVersion("4.2.0"),
+			docs.FieldString("field_expiry", "A map of hash field names to durations after which those fields expire, independently of the key. Requires Redis 7.4 or newer.", map[string]string{
+				"session_token": "15m",
+				"last_seen":     "24h",
+			}).Map().Advanced().AtVersion("4.2.0"),
+			docs.FieldBool("count_new_fields", "Whether to set fields with the HSET command and count the number of fields that are newly created, which is added to the metric `redis_hash_fields_created`. Requires Redis 4.0 or newer.").Advanced().AtVersion("4.2.0"),
+			docs.FieldString("set_command", "The command used to set hash fields.").HasAnnotatedOptions(
+				"hmset", "Set fields with the HMSET command, which is deprecated since Redis 4.0.",
+				"hset", "Set fields with the HSET command, which requires Redis 4.0 or newer.",
+				"auto", "Set fields with the HSET command when the server is Redis 4.0 or newer, and the HMSET command otherwise.",
+			).Advanced().AtVersion("4.2.0"),
 			docs.FieldInt("max_in_flight", "The maximum number of messages to have in flight at a given time. Increase this to improve throughput."),
+			policy.FieldSpec(),
 		).ChildDefaultAndTypesFromStruct(output.NewRedisHashConfig()),
 		Categories: []string{
 			"Services",
@@ -89,7 +275,7 @@ func newRedisHashOutput(conf output.Config, mgr bundle.NewManagement, log log.Mo
 	if err != nil {
 		return nil, err
 	}
-	return output.OnlySinglePayloads(a), nil
+	return batcher.NewFromConfig(conf.RedisHash.Batching, a, mgr, log, stats)
 }
 
 type redisHashWriter struct {
@@ -97,18 +283,31 @@ type redisHashWriter struct {
 
 	conf output.RedisHashConfig
 
-	keyStr *field.Expression
-	fields map[string]*field.Expression
+	keyStr          *field.Expression
+	fields          map[string]*field.Expression
+	metadataExclude map[string]struct{}
+	onInterpError   string
+	setCommand      string
+	sanitizer       *hashFieldSanitizer
+
+	waitTimeout time.Duration
+	expiration  *field.Expression
+	fieldExpiry map[string]time.Duration
 
-	client  redis.UniversalClient
-	connMut sync.RWMutex
+	mFieldsCreated metrics.StatCounter
+
+	client     redis.UniversalClient
+	readClient redis.UniversalClient
+	useHSet    bool
+	connMut    sync.RWMutex
 }
 
 func newRedisHashWriter(conf output.RedisHashConfig, mgr bundle.NewManagement, log log.Modular) (*redisHashWriter, error) {
 	r := &redisHashWriter{
-		log:    log,
-		conf:   conf,
-		fields: map[string]*field.Expression{},
+		log:             log,
+		conf:            conf,
+		fields:          map[string]*field.Expression{},
+		metadataExclude: map[string]struct{}{},
 	}
 
 	var err error
@@ -122,13 +321,86 @@ func newRedisHashWriter(conf output.RedisHashConfig, mgr bundle.NewManagement, l
 		}
 	}
 
+	switch conf.OnInterpError {
+	case "write", "skip", "fail":
+		r.onInterpError = conf.OnInterpError
+	default:
+		return nil, fmt.Errorf("on_interp_error option '%v' not recognised", conf.OnInterpError)
+	}
+
+	switch conf.SetCommand {
+	case "hmset", "hset", "auto":
+		r.setCommand = conf.SetCommand
+	default:
+		return nil, fmt.Errorf("set_command option '%v' not recognised", conf.SetCommand)
+	}
+
+	for _, k := range conf.MetadataExclude {
+		r.metadataExclude[k] = struct{}{}
+	}
+
 	if !conf.WalkMetadata && !conf.WalkJSONObject && len(conf.Fields) == 0 {
 		return nil, errors.New("at least one mechanism for setting fields must be enabled")
 	}
 
+	if conf.SanitizeFieldNames {
+		if conf.SanitizeCharacters == "" {
+			return nil, errors.New("sanitize_characters must not be empty when sanitize_field_names is enabled")
+		}
+		r.sanitizer = newHashFieldSanitizer(conf.SanitizeCharacters, conf.SanitizeReplacement)
+	}
+
+	if conf.Diff && conf.OnlyIfChanged {
+		return nil, errors.New("only_if_changed cannot be combined with diff, which already skips unchanged fields")
+	}
+
+	if conf.WaitReplicas < 0 {
+		return nil, fmt.Errorf("wait_replicas must not be negative, got %v", conf.WaitReplicas)
+	}
+	if conf.WaitReplicas > 0 {
+		if conf.Kind == "cluster" {
+			return nil, errors.New("wait_replicas is not supported when kind is cluster")
+		}
+		if r.waitTimeout, err = time.ParseDuration(conf.WaitTimeout); err != nil {
+			return nil, fmt.Errorf("failed to parse wait_timeout: %v", err)
+		}
+	}
+
+	if conf.Expiration != "" {
+		if r.expiration, err = mgr.BloblEnvironment().NewField(conf.Expiration); err != nil {
+			return nil, fmt.Errorf("failed to parse expiration expression: %v", err)
+		}
+		if r.expiration.NumDynamicExpressions() == 0 {
+			if _, err := parseKeyExpiration(conf.Expiration); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for k, v := range conf.FieldExpiry {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse field_expiry of field '%v': %v", k, err)
+		}
+		if d < time.Millisecond {
+			return nil, fmt.Errorf("field_expiry of field '%v' must be at least 1ms, got %v", k, v)
+		}
+		if r.fieldExpiry == nil {
+			r.fieldExpiry = map[string]time.Duration{}
+		}
+		r.fieldExpiry[k] = d
+	}
+
+	if conf.CountNewFields {
+		r.mFieldsCreated = mgr.Metrics().GetCounter("redis_hash_fields_created")
+	}
+
 	if _, err := clientFromConfig(conf.Config); err != nil {
 		return nil, err
 	}
+	if _, err := readClientFromConfig(conf.Config); err != nil {
+		return nil, err
+	}
 
 	return r, nil
 }
@@ -145,12 +417,69 @@ func (r *redisHashWriter) ConnectWithContext(ctx context.Context) error {
 		return err
 	}
 
+	// Reads are only performed in diff mode or when only changed hashes are
+	// written.
+	var readClient redis.UniversalClient
+	if r.conf.Diff || r.conf.OnlyIfChanged {
+		if readClient, err = readClientFromConfig(r.conf.Config); err != nil {
+			_ = client.Close()
+			return err
+		}
+		if readClient != nil {
+			if _, err = readClient.Ping().Result(); err != nil {
+				_ = client.Close()
+				_ = readClient.Close()
+				return err
+			}
+		}
+	}
+
 	r.log.Infoln("Setting messages as hash objects to Redis")
 
 	r.client = client
+	r.readClient = readClient
+	r.useHSet = r.detectHSet(client)
 	return nil
 }
 
+// detectHSet returns whether fields should be set with the HSET command, which
+// for the set command auto depends on the version of the server.
+func (r *redisHashWriter) detectHSet(client redis.UniversalClient) bool {
+	switch r.setCommand {
+	case "hset":
+		return true
+	case "auto":
+		info, err := client.Info("server").Result()
+		if err == nil {
+			var supported bool
+			if supported, err = infoSupportsHSet(info); err == nil {
+				return supported
+			}
+		}
+		r.log.Warnf("Failed to determine the version of the Redis server, fields will be set with HMSET: %v\n", err)
+	}
+	return false
+}
+
+// infoSupportsHSet returns whether the server described by the output of the
+// INFO command supports setting multiple fields with HSET, which requires
+// Redis 4.0 or newer.
+func infoSupportsHSet(info string) (bool, error) {
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "redis_version:") {
+			continue
+		}
+		version := strings.TrimPrefix(line, "redis_version:")
+		major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+		if err != nil {
+			return false, fmt.Errorf("failed to parse redis_version '%v'", version)
+		}
+		return major >= 4, nil
+	}
+	return false, errors.New("redis_version not found within server info")
+}
+
 //------------------------------------------------------------------------------
 
 func walkForHashFields(
@@ -165,57 +494,712 @@ func walkForHashFields(
 		return fmt.Errorf("expected JSON object, found '%T'", jVal)
 	}
 	for k, v := range jObj {
+		if n, isNum := v.(json.Number); isNum {
+			// Numbers are parsed as json.Number, which the client cannot
+			// marshal, and are therefore set as their textual form.
+			v = n.String()
+		}
 		fields[k] = v
 	}
 	return nil
 }
 
+// hashFields returns the hash fields to set for a message of a batch, along
+// with the names of explicit fields that were skipped due to failed
+// interpolations.
+func (r *redisHashWriter) hashFields(msg *message.Batch, i int) (fields map[string]interface{}, skipped []string, err error) {
+	fields = map[string]interface{}{}
+	if r.conf.WalkMetadata {
+		_ = msg.Get(i).MetaIter(func(k, v string) error {
+			if _, exclude := r.metadataExclude[k]; !exclude {
+				fields[k] = v
+			}
+			return nil
+		})
+	}
+	if r.conf.WalkJSONObject {
+		if err := walkForHashFields(msg, i, fields); err != nil {
+			return nil, nil, fmt.Errorf("failed to walk JSON object: %v", err)
+		}
+	}
+	for k, v := range r.fields {
+		str, err := v.TryString(i, msg)
+		if err != nil {
+			switch r.onInterpError {
+			case "skip":
+				skipped = append(skipped, k)
+				continue
+			case "fail":
+				return nil, nil, fmt.Errorf("failed to interpolate field '%v': %v", k, err)
+			}
+		}
+		fields[k] = str
+	}
+	sort.Strings(skipped)
+	return fields, skipped, nil
+}
+
+// hashWrite is a write of the fields of a message to the hash of a key.
+type hashWrite struct {
+	key        string
+	fields     map[string]interface{}
+	deleted    []string
+	skipped    []string
+	expiration time.Duration
+
+	// unchanged is set when the fields of the write match the current hash,
+	// in which case only the expiration of the key is renewed.
+	unchanged bool
+}
+
+// noop returns whether a write has no commands to issue, which is only the case
+// when there are no fields to set or delete and no expiration to renew.
+func (w *hashWrite) noop() bool {
+	if w.unchanged || (len(w.fields) == 0 && len(w.deleted) == 0) {
+		return w.expiration == 0
+	}
+	return false
+}
+
+// readFields returns the names of the fields set or deleted by a write, in a
+// consistent order.
+func (w *hashWrite) readFields() []string {
+	names := make([]string, 0, len(w.fields)+len(w.deleted))
+	for k := range w.fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return append(names, w.deleted...)
+}
+
+// matches returns whether the current values of the fields of a write, read in
+// the order of readFields, match the write.
+func (w *hashWrite) matches(names []string, current []interface{}) bool {
+	for i, k := range names {
+		if i >= len(w.fields) {
+			// Deleted fields match when they do not exist.
+			if current[i] != nil {
+				return false
+			}
+			continue
+		}
+		currentStr, exists := current[i].(string)
+		if !exists {
+			return false
+		}
+		if str, ok := hashFieldString(w.fields[k]); !ok || str != currentStr {
+			return false
+		}
+	}
+	return true
+}
+
+// hashWrite resolves the key, fields and expiration of a message of a batch.
+func (r *redisHashWriter) hashWrite(msg *message.Batch, i int) (*hashWrite, error) {
+	key, err := r.keyStr.TryString(i, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to interpolate key: %v", err)
+	}
+	if key == "" {
+		return nil, errors.New("key resolved to an empty string")
+	}
+	fields, skipped, err := r.hashFields(msg, i)
+	if err != nil {
+		return nil, err
+	}
+	var expiration time.Duration
+	if r.expiration != nil {
+		expStr, err := r.expiration.TryString(i, msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to interpolate expiration: %v", err)
+		}
+		if expiration, err = parseKeyExpiration(expStr); err != nil {
+			return nil, err
+		}
+	}
+	if len(skipped) > 0 {
+		r.log.Debugf("Skipping hash fields %v of key '%v' due to failed interpolations\n", skipped, key)
+	}
+	if r.sanitizer != nil {
+		var collisions map[string][]string
+		fields, collisions = r.sanitizer.sanitize(fields)
+		for name, sources := range collisions {
+			r.log.Warnf("Hash fields %v of key '%v' collide as '%v' after sanitization, using the value of '%v'\n", sources, key, name, sources[len(sources)-1])
+		}
+		for j, k := range skipped {
+			skipped[j] = r.sanitizer.replacer.Replace(k)
+		}
+	}
+	var deleted []string
+	if r.conf.DeleteEmpty {
+		for k, v := range fields {
+			if str, ok := hashFieldString(v); ok && str == "" {
+				deleted = append(deleted, k)
+				delete(fields, k)
+			}
+		}
+		sort.Strings(deleted)
+	}
+	return &hashWrite{
+		key:        key,
+		fields:     fields,
+		deleted:    deleted,
+		skipped:    skipped,
+		expiration: expiration,
+	}, nil
+}
+
 func (r *redisHashWriter) WriteWithContext(ctx context.Context, msg *message.Batch) error {
 	r.connMut.RLock()
-	client := r.client
+	client, readClient, useHSet := r.client, r.readClient, r.useHSet
 	r.connMut.RUnlock()
 
 	if client == nil {
 		return component.ErrNotConnected
 	}
 
-	return output.IterateBatchedSend(msg, func(i int, p *message.Part) error {
-		key := r.keyStr.String(i, msg)
-		fields := map[string]interface{}{}
-		if r.conf.WalkMetadata {
-			_ = p.MetaIter(func(k, v string) error {
-				fields[k] = v
+	if readClient == nil {
+		readClient = client
+	}
+
+	// Diff mode reads the current hash of each key before writing, and
+	// therefore writes the messages of a batch individually.
+	if msg.Len() > 1 && !r.conf.Diff {
+		return r.writeBatch(client, readClient, useHSet, msg)
+	}
+
+	return output.IterateBatchedSend(msg, func(i int, _ *message.Part) error {
+		w, err := r.hashWrite(msg, i)
+		if err != nil {
+			r.log.Errorf("HMSET error: %v\n", err)
+			return err
+		}
+		if r.conf.Diff {
+			return r.writeDiff(client, readClient, useHSet, w.key, w.fields, w.skipped, w.expiration)
+		}
+		if w.noop() {
+			// HMSET rejects an empty set of fields, which is possible when all
+			// fields are skipped and there is no expiration to renew.
+			return nil
+		}
+		if r.conf.OnlyIfChanged {
+			if err := r.markUnchanged(readClient, []*hashWrite{w}); err != nil {
+				_ = r.disconnect()
+				r.log.Errorf("Error from redis: %v\n", err)
+				return component.ErrNotConnected
+			}
+			if w.noop() {
 				return nil
-			})
+			}
 		}
-		if r.conf.WalkJSONObject {
-			if err := walkForHashFields(msg, i, fields); err != nil {
-				err = fmt.Errorf("failed to walk JSON object: %v", err)
-				r.log.Errorf("HMSET error: %v\n", err)
+		if r.conf.WaitReplicas > 0 || len(r.fieldExpiry) > 0 || w.expiration > 0 || len(w.deleted) > 0 || w.unchanged {
+			pipe := client.Pipeline()
+			cmds := r.queueWrite(pipe, useHSet, w)
+			var waitCmd *redis.Cmd
+			if r.conf.WaitReplicas > 0 {
+				waitCmd = r.wait(pipe)
+			}
+			_, _ = pipe.Exec()
+			if err := cmds.connErr(); err != nil {
+				return r.writeErr(w.key, err)
+			}
+			r.countCreated(w.key, cmds.set)
+			if err := checkExpire(cmds.fieldExpire); err != nil {
 				return err
 			}
+			if waitCmd != nil {
+				return r.checkWait(waitCmd)
+			}
+			return nil
+		}
+		setCmd := r.setFields(client, useHSet, w.key, w.fields)
+		if err := setCmd.Err(); err != nil {
+			return r.writeErr(w.key, err)
 		}
-		for k, v := range r.fields {
-			fields[k] = v.String(i, msg)
+		r.countCreated(w.key, setCmd)
+		return nil
+	})
+}
+
+// writeErr handles an error from writing a single message to a key. Errors
+// returned by the server, such as when the key holds a value that is not a
+// hash, only fail the message, whereas any other error disconnects.
+func (r *redisHashWriter) writeErr(key string, err error) error {
+	if isRedisError(err) {
+		r.log.Errorf("Error from redis for key '%v': %v\n", key, err)
+		return err
+	}
+	_ = r.disconnect()
+	r.log.Errorf("Error from redis: %v\n", err)
+	return component.ErrNotConnected
+}
+
+// hashWriteCmds are the commands queued for a write, where the command that
+// sets fields is nil when the write has no fields to set.
+type hashWriteCmds struct {
+	set         redis.Cmder
+	del         *redis.IntCmd
+	keyExpire   *redis.BoolCmd
+	fieldExpire []*redis.Cmd
+}
+
+// queueWrite queues the commands of a write within a pipeline.
+func (r *redisHashWriter) queueWrite(pipe redis.Pipeliner, useHSet bool, w *hashWrite) hashWriteCmds {
+	var cmds hashWriteCmds
+	if w.unchanged {
+		if w.expiration > 0 {
+			cmds.keyExpire = pipe.PExpire(w.key, w.expiration)
 		}
-		if err := client.HMSet(key, fields).Err(); err != nil {
+		return cmds
+	}
+	if len(w.fields) > 0 {
+		cmds.set = r.setFields(pipe, useHSet, w.key, w.fields)
+	}
+	if len(w.deleted) > 0 {
+		cmds.del = pipe.HDel(w.key, w.deleted...)
+	}
+	if w.expiration > 0 {
+		cmds.keyExpire = pipe.PExpire(w.key, w.expiration)
+	}
+	cmds.fieldExpire = r.expireFields(pipe, w.key, w.fields)
+	return cmds
+}
+
+// markUnchanged reads the current values of the fields of writes with the HMGET
+// command within a single pipeline, and marks the writes that match them as
+// unchanged. Errors returned by the server for a key, such as when it holds a
+// value that is not a hash, leave its write to be attempted and report them.
+func (r *redisHashWriter) markUnchanged(readClient redis.UniversalClient, writes []*hashWrite) error {
+	pipe := readClient.Pipeline()
+	names := make([][]string, len(writes))
+	cmds := make([]*redis.SliceCmd, len(writes))
+	for i, w := range writes {
+		if w.noop() {
+			continue
+		}
+		if names[i] = w.readFields(); len(names[i]) == 0 {
+			// Writes that only renew the expiration have nothing to compare.
+			continue
+		}
+		cmds[i] = pipe.HMGet(w.key, names[i]...)
+	}
+	_, _ = pipe.Exec()
+
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		current, err := cmd.Result()
+		if err != nil {
+			if isRedisError(err) {
+				continue
+			}
+			return err
+		}
+		if writes[i].matches(names[i], current) {
+			writes[i].unchanged = true
+			r.log.Debugf("Skipping unchanged hash fields of key '%v'\n", writes[i].key)
+		}
+	}
+	return nil
+}
+
+// connErr returns the error of the commands that set and delete the fields and
+// the expiration of a key, which are handled as connection errors.
+func (c hashWriteCmds) connErr() error {
+	if c.set != nil {
+		if err := c.set.Err(); err != nil {
+			return err
+		}
+	}
+	if c.del != nil {
+		if err := c.del.Err(); err != nil {
+			return err
+		}
+	}
+	if c.keyExpire != nil {
+		return c.keyExpire.Err()
+	}
+	return nil
+}
+
+// writeBatch writes the messages of a batch with a single pipeline. Errors
+// returned by the server for the commands of a message, such as when a key
+// holds a value that is not a hash, only fail that message, whereas any other
+// error fails the batch and disconnects.
+func (r *redisHashWriter) writeBatch(client, readClient redis.UniversalClient, useHSet bool, msg *message.Batch) error {
+	var batchErr *ibatch.Error
+	fail := func(i int, err error) {
+		if batchErr == nil {
+			batchErr = ibatch.NewError(msg, err)
+		}
+		batchErr.Failed(i, err)
+	}
+
+	indexes := make([]int, 0, msg.Len())
+	writes := make([]*hashWrite, 0, msg.Len())
+	for i := 0; i < msg.Len(); i++ {
+		w, err := r.hashWrite(msg, i)
+		if err != nil {
+			r.log.Errorf("HMSET error: %v\n", err)
+			fail(i, err)
+			continue
+		}
+		if w.noop() {
+			continue
+		}
+		indexes = append(indexes, i)
+		writes = append(writes, w)
+	}
+
+	if r.conf.OnlyIfChanged && len(writes) > 0 {
+		if err := r.markUnchanged(readClient, writes); err != nil {
 			_ = r.disconnect()
 			r.log.Errorf("Error from redis: %v\n", err)
 			return component.ErrNotConnected
 		}
+		changedIndexes, changedWrites := indexes[:0], writes[:0]
+		for j, w := range writes {
+			if !w.noop() {
+				changedIndexes = append(changedIndexes, indexes[j])
+				changedWrites = append(changedWrites, w)
+			}
+		}
+		indexes, writes = changedIndexes, changedWrites
+	}
+
+	if len(writes) == 0 {
+		if batchErr != nil {
+			return batchErr
+		}
 		return nil
+	}
+
+	pipe := client.Pipeline()
+	queued := make([]hashWriteCmds, 0, len(writes))
+	for _, w := range writes {
+		queued = append(queued, r.queueWrite(pipe, useHSet, w))
+	}
+
+	// A single WAIT covers the writes of every message of the batch.
+	var waitCmd *redis.Cmd
+	if r.conf.WaitReplicas > 0 {
+		waitCmd = r.wait(pipe)
+	}
+	_, _ = pipe.Exec()
+
+	rejected := make([]bool, len(queued))
+	for j, cmds := range queued {
+		err := cmds.connErr()
+		if err == nil {
+			continue
+		}
+		if !isRedisError(err) {
+			_ = r.disconnect()
+			r.log.Errorf("Error from redis: %v\n", err)
+			return component.ErrNotConnected
+		}
+		r.log.Errorf("Error from redis for key '%v': %v\n", writes[j].key, err)
+		fail(indexes[j], err)
+		rejected[j] = true
+	}
+
+	var waitErr error
+	if waitCmd != nil {
+		waitErr = r.checkWait(waitCmd)
+	}
+	for j, cmds := range queued {
+		if rejected[j] {
+			continue
+		}
+		r.countCreated(writes[j].key, cmds.set)
+		if err := checkExpire(cmds.fieldExpire); err != nil {
+			fail(indexes[j], err)
+			continue
+		}
+		if waitErr != nil {
+			fail(indexes[j], waitErr)
+		}
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// setFields issues the command that sets the fields of a hash, which is HSET
+// when selected by the set command or when new fields are counted, since unlike
+// HMSET it returns the number of fields that were created.
+func (r *redisHashWriter) setFields(c redis.Cmdable, useHSet bool, key string, fields map[string]interface{}) redis.Cmder {
+	if useHSet || r.mFieldsCreated != nil {
+		return c.HSet(key, fields)
+	}
+	return c.HMSet(key, fields)
+}
+
+// countCreated adds the number of fields created by a successful HSET command
+// to the metric of created fields.
+func (r *redisHashWriter) countCreated(key string, cmd redis.Cmder) {
+	if r.mFieldsCreated == nil {
+		return
+	}
+	hsetCmd, ok := cmd.(*redis.IntCmd)
+	if !ok {
+		return
+	}
+	created := hsetCmd.Val()
+	r.mFieldsCreated.Incr(created)
+	r.log.Debugf("Created %v new hash fields of key '%v'\n", created, key)
+}
+
+// parseKeyExpiration parses the resolved expiration of a key, where an empty
+// string or zero means that the key does not expire.
+func parseKeyExpiration(str string) (time.Duration, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse expiration: %v", err)
+	}
+	if d < 0 || (d > 0 && d < time.Millisecond) {
+		return 0, fmt.Errorf("expiration must be zero or at least 1ms, got %v", str)
+	}
+	return d, nil
+}
+
+// wait issues a WAIT command for the configured number of replicas.
+func (r *redisHashWriter) wait(c interface {
+	Do(args ...interface{}) *redis.Cmd
+}) *redis.Cmd {
+	return c.Do("wait", r.conf.WaitReplicas, int64(r.waitTimeout/time.Millisecond))
+}
+
+// expireFields issues HPEXPIRE commands for the fields with an expiry that are
+// set by a write, with a command for each distinct duration.
+func (r *redisHashWriter) expireFields(c interface {
+	Do(args ...interface{}) *redis.Cmd
+}, key string, fields map[string]interface{}) []*redis.Cmd {
+	byDuration := map[time.Duration][]string{}
+	for k, d := range r.fieldExpiry {
+		if _, exists := fields[k]; exists {
+			byDuration[d] = append(byDuration[d], k)
+		}
+	}
+
+	durations := make([]time.Duration, 0, len(byDuration))
+	for d := range byDuration {
+		durations = append(durations, d)
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
 	})
+
+	cmds := make([]*redis.Cmd, 0, len(durations))
+	for _, d := range durations {
+		names := byDuration[d]
+		sort.Strings(names)
+
+		args := []interface{}{"hpexpire", key, int64(d / time.Millisecond), "fields", len(names)}
+		for _, n := range names {
+			args = append(args, n)
+		}
+		cmds = append(cmds, c.Do(args...))
+	}
+	return cmds
+}
+
+// checkExpire returns an error if any HPEXPIRE command was rejected by the
+// server, explaining when this is because the server does not support it.
+func checkExpire(cmds []*redis.Cmd) error {
+	for _, cmd := range cmds {
+		err := cmd.Err()
+		if !isRedisError(err) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+			return fmt.Errorf("failed to set field expiry, which requires Redis 7.4 or newer: %w", err)
+		}
+		return fmt.Errorf("failed to set field expiry: %w", err)
+	}
+	return nil
+}
+
+// checkWait returns an error if the result of a WAIT command shows that fewer
+// than the configured number of replicas acknowledged a write.
+func (r *redisHashWriter) checkWait(cmd *redis.Cmd) error {
+	acked, err := cmd.Int64()
+	if err != nil {
+		return fmt.Errorf("failed to wait for replicas: %w", err)
+	}
+	if acked < int64(r.conf.WaitReplicas) {
+		return fmt.Errorf("write acknowledged by %v of %v replicas within %v", acked, r.conf.WaitReplicas, r.waitTimeout)
+	}
+	return nil
+}
+
+// writeDiff compares the fields of a message with the current hash of a key,
+// read with readClient, and only sets the fields that have changed and deletes
+// the fields that are no longer present, except for skipped fields. The key
+// expiration is renewed when greater than zero, even when nothing has changed.
+func (r *redisHashWriter) writeDiff(client, readClient redis.UniversalClient, useHSet bool, key string, fields map[string]interface{}, skipped []string, expiration time.Duration) error {
+	current, err := readClient.HGetAll(key).Result()
+	if err != nil {
+		return r.writeErr(key, err)
+	}
+
+	changed := map[string]interface{}{}
+	for k, v := range fields {
+		if currentV, exists := current[k]; exists {
+			if str, ok := hashFieldString(v); ok && str == currentV {
+				continue
+			}
+		}
+		changed[k] = v
+	}
+
+	keep := make(map[string]struct{}, len(skipped))
+	for _, k := range skipped {
+		keep[k] = struct{}{}
+	}
+
+	var removed []string
+	for k := range current {
+		if _, exists := fields[k]; exists {
+			continue
+		}
+		if _, exists := keep[k]; !exists {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+
+	if len(changed) == 0 && len(removed) == 0 {
+		if expiration <= 0 || len(current) == 0 {
+			return nil
+		}
+		if err := client.PExpire(key, expiration).Err(); err != nil {
+			return r.writeErr(key, err)
+		}
+		return nil
+	}
+
+	pipe := client.TxPipeline()
+	var setCmd redis.Cmder
+	var expireCmds []*redis.Cmd
+	if len(changed) > 0 {
+		setCmd = r.setFields(pipe, useHSet, key, changed)
+		expireCmds = r.expireFields(pipe, key, changed)
+	}
+	if len(removed) > 0 {
+		pipe.HDel(key, removed...)
+	}
+	if expiration > 0 {
+		pipe.PExpire(key, expiration)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		// A rejected expiry aborts the transaction, in which case the
+		// connection is still healthy.
+		if expErr := checkExpire(expireCmds); expErr != nil {
+			return expErr
+		}
+		return r.writeErr(key, err)
+	}
+	if setCmd != nil {
+		r.countCreated(key, setCmd)
+	}
+	if r.conf.WaitReplicas > 0 {
+		// WAIT does not block within a transaction, and is therefore issued
+		// once the transaction has been executed.
+		return r.checkWait(r.wait(client))
+	}
+	return nil
+}
+
+// hashFieldString returns the string a hash field value is stored as by Redis,
+// or false if the value is of a type that cannot be compared.
+func hashFieldString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return t, true
+	case []byte:
+		return string(t), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", t), true
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 64), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case bool:
+		if t {
+			return "1", true
+		}
+		return "0", true
+	}
+	return "", false
+}
+
+// hashFieldSanitizer replaces a set of characters within hash field names.
+type hashFieldSanitizer struct {
+	replacer *strings.Replacer
+}
+
+func newHashFieldSanitizer(chars, replacement string) *hashFieldSanitizer {
+	var oldNew []string
+	for _, c := range chars {
+		oldNew = append(oldNew, string(c), replacement)
+	}
+	return &hashFieldSanitizer{replacer: strings.NewReplacer(oldNew...)}
+}
+
+// sanitize returns a copy of fields with sanitized names, along with a map of
+// sanitized names to the original names that collided as them. Original names
+// are applied in lexicographical order, and therefore the value of the last
+// name of each collision is used.
+func (h *hashFieldSanitizer) sanitize(fields map[string]interface{}) (sanitized map[string]interface{}, collisions map[string][]string) {
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	sanitized = make(map[string]interface{}, len(fields))
+	sources := make(map[string][]string, len(fields))
+	for _, k := range names {
+		sk := h.replacer.Replace(k)
+		sanitized[sk] = fields[k]
+		sources[sk] = append(sources[sk], k)
+	}
+
+	for sk, srcs := range sources {
+		if len(srcs) > 1 {
+			if collisions == nil {
+				collisions = map[string][]string{}
+			}
+			collisions[sk] = srcs
+		}
+	}
+	return
 }
 
 func (r *redisHashWriter) disconnect() error {
 	r.connMut.Lock()
 	defer r.connMut.Unlock()
+	var err error
+	if r.readClient != nil {
+		err = r.readClient.Close()
+		r.readClient = nil
+	}
 	if r.client != nil {
-		err := r.client.Close()
+		if cErr := r.client.Close(); cErr != nil {
+			err = cErr
+		}
 		r.client = nil
-		return err
 	}
-	return nil
+	return err
 }
 
 func (r *redisHashWriter) CloseAsync() {
@@ -0,0 +1,776 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ibatch "github.com/benthosdev/benthos/v4/internal/batch"
+	"github.com/benthosdev/benthos/v4/internal/component"
+	"github.com/benthosdev/benthos/v4/internal/component/metrics"
+	"github.com/benthosdev/benthos/v4/internal/component/output"
+	"github.com/benthosdev/benthos/v4/internal/log"
+	"github.com/benthosdev/benthos/v4/internal/manager/mock"
+	"github.com/benthosdev/benthos/v4/internal/message"
+)
+
+func TestHashFieldString(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		exp   string
+		ok    bool
+	}{
+		{name: "string", input: "foo", exp: "foo", ok: true},
+		{name: "bytes", input: []byte("foo"), exp: "foo", ok: true},
+		{name: "nil", input: nil, exp: "", ok: true},
+		{name: "int", input: int64(-10), exp: "-10", ok: true},
+		{name: "uint", input: uint8(10), exp: "10", ok: true},
+		{name: "float", input: 1.5, exp: "1.5", ok: true},
+		{name: "whole float", input: float64(100000000), exp: "100000000", ok: true},
+		{name: "true", input: true, exp: "1", ok: true},
+		{name: "false", input: false, exp: "0", ok: true},
+		{name: "object", input: map[string]interface{}{}, ok: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			str, ok := hashFieldString(test.input)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.exp, str)
+		})
+	}
+}
+
+func TestHashFieldSanitizer(t *testing.T) {
+	s := newHashFieldSanitizer(" .", "_")
+
+	tests := []struct {
+		name       string
+		input      map[string]interface{}
+		exp        map[string]interface{}
+		collisions map[string][]string
+	}{
+		{
+			name:  "no changes",
+			input: map[string]interface{}{"foo": "a", "bar_baz": "b"},
+			exp:   map[string]interface{}{"foo": "a", "bar_baz": "b"},
+		},
+		{
+			name:  "replaced characters",
+			input: map[string]interface{}{"foo bar": "a", "baz.buz": "b", "a. b": "c"},
+			exp:   map[string]interface{}{"foo_bar": "a", "baz_buz": "b", "a__b": "c"},
+		},
+		{
+			name:  "collisions",
+			input: map[string]interface{}{"foo bar": "a", "foo.bar": "b", "foo_bar": "c", "baz": "d"},
+			exp:   map[string]interface{}{"foo_bar": "c", "baz": "d"},
+			collisions: map[string][]string{
+				"foo_bar": {"foo bar", "foo.bar", "foo_bar"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, collisions := s.sanitize(test.input)
+			assert.Equal(t, test.exp, res)
+			assert.Equal(t, test.collisions, collisions)
+		})
+	}
+}
+
+func TestHashWaitConfig(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.WalkMetadata = true
+
+	conf.WaitReplicas = -1
+	_, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.EqualError(t, err, "wait_replicas must not be negative, got -1")
+
+	conf.WaitReplicas = 2
+	conf.WaitTimeout = "nope"
+	_, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse wait_timeout")
+
+	conf.WaitTimeout = "500ms"
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, w.waitTimeout)
+
+	assert.NoError(t, w.checkWait(redis.NewCmdResult(int64(2), nil)))
+	assert.NoError(t, w.checkWait(redis.NewCmdResult(int64(3), nil)))
+	assert.EqualError(t, w.checkWait(redis.NewCmdResult(int64(1), nil)), "write acknowledged by 1 of 2 replicas within 500ms")
+	assert.EqualError(t, w.checkWait(redis.NewCmdResult(nil, errors.New("nope"))), "failed to wait for replicas: nope")
+
+	conf.Kind = "cluster"
+	_, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.EqualError(t, err, "wait_replicas is not supported when kind is cluster")
+
+	conf.WaitReplicas = 0
+	_, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+}
+
+func TestHashFieldsMetadataExclude(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.WalkMetadata = true
+	conf.MetadataExclude = []string{"kafka_key", "trace_id"}
+	conf.Fields = map[string]string{"trace_id": "${! meta(\"trace_id\") }-explicit"}
+
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	msg := message.QuickBatch([][]byte{[]byte("hello world")})
+	part := msg.Get(0)
+	part.MetaSet("kafka_key", "foo")
+	part.MetaSet("kafka_topic", "bar")
+	part.MetaSet("trace_id", "baz")
+
+	fields, skipped, err := w.hashFields(msg, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"kafka_topic": "bar",
+		"trace_id":    "baz-explicit",
+	}, fields)
+	assert.Empty(t, skipped)
+}
+
+func TestHashFieldsInterpErrorFailsByDefault(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:1"
+	conf.Key = "foo"
+	conf.Fields = map[string]string{
+		"content": "${! content() }",
+		"name":    "name: ${! throw(\"nope\") }",
+	}
+
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	// The messages fail before any command is sent, and therefore the client
+	// is never connected.
+	client, err := clientFromConfig(conf.Config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	w.client = client
+
+	err = w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{[]byte("foo")}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to interpolate field 'name': nope")
+
+	err = w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{[]byte("foo"), []byte("bar")}))
+	var batchErr *ibatch.Error
+	require.True(t, errors.As(err, &batchErr), err)
+	assert.Equal(t, 2, batchErr.IndexedErrors())
+}
+
+func TestHashFieldsOnInterpError(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.Fields = map[string]string{
+		"content": "${! content() }",
+		"doc_id":  "${! json(\"id\") }",
+		"name":    "name: ${! throw(\"nope\") }",
+	}
+
+	conf.OnInterpError = "nope"
+	_, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.EqualError(t, err, "on_interp_error option 'nope' not recognised")
+
+	msg := message.QuickBatch([][]byte{[]byte("not json")})
+
+	conf.OnInterpError = "write"
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	fields, skipped, err := w.hashFields(msg, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"content": "not json",
+		"doc_id":  "null",
+		"name":    "name: ",
+	}, fields)
+	assert.Empty(t, skipped)
+
+	conf.OnInterpError = "skip"
+	w, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	fields, skipped, err = w.hashFields(msg, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"content": "not json",
+	}, fields)
+	assert.Equal(t, []string{"doc_id", "name"}, skipped)
+
+	conf.OnInterpError = "fail"
+	w, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	_, _, err = w.hashFields(msg, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to interpolate field '")
+
+	fields, skipped, err = w.hashFields(message.QuickBatch([][]byte{[]byte(`{"id":"foo"}`)}), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to interpolate field 'name': nope")
+	assert.Nil(t, fields)
+	assert.Nil(t, skipped)
+}
+
+type recordedCommands struct {
+	args [][]interface{}
+	err  error
+}
+
+func (r *recordedCommands) Do(args ...interface{}) *redis.Cmd {
+	r.args = append(r.args, args)
+	return redis.NewCmdResult(nil, r.err)
+}
+
+func TestHashFieldExpiry(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.WalkMetadata = true
+
+	conf.FieldExpiry = map[string]string{"foo": "nope"}
+	_, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse field_expiry of field 'foo'")
+
+	conf.FieldExpiry = map[string]string{"foo": "0s"}
+	_, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.EqualError(t, err, "field_expiry of field 'foo' must be at least 1ms, got 0s")
+
+	conf.FieldExpiry = map[string]string{
+		"a": "1m",
+		"b": "1s",
+		"c": "1m",
+		"d": "1h",
+	}
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	rec := &recordedCommands{}
+	cmds := w.expireFields(rec, "foo", map[string]interface{}{
+		"a": "1",
+		"b": "2",
+		"c": "3",
+		"e": "4",
+	})
+	assert.Len(t, cmds, 2)
+	assert.Equal(t, [][]interface{}{
+		{"hpexpire", "foo", int64(1000), "fields", 1, "b"},
+		{"hpexpire", "foo", int64(60000), "fields", 2, "a", "c"},
+	}, rec.args)
+
+	assert.Empty(t, w.expireFields(rec, "foo", map[string]interface{}{"e": "4"}))
+}
+
+func TestHashExpiration(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.WalkMetadata = true
+
+	conf.Expiration = "nope"
+	_, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse expiration")
+
+	conf.Expiration = "-1s"
+	_, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.EqualError(t, err, "expiration must be zero or at least 1ms, got -1s")
+
+	// Interpolated expirations are only parsed when writing.
+	conf.Expiration = `${! meta("ttl") }`
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+	require.NotNil(t, w.expiration)
+
+	conf.Expiration = ""
+	w, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+	assert.Nil(t, w.expiration)
+
+	tests := []struct {
+		input       string
+		expiration  time.Duration
+		errContains string
+	}{
+		{input: "", expiration: 0},
+		{input: " ", expiration: 0},
+		{input: "0", expiration: 0},
+		{input: "0s", expiration: 0},
+		{input: "1h", expiration: time.Hour},
+		{input: "1500ms", expiration: 1500 * time.Millisecond},
+		{input: "10us", errContains: "expiration must be zero or at least 1ms, got 10us"},
+		{input: "null", errContains: "failed to parse expiration"},
+	}
+
+	for _, test := range tests {
+		d, err := parseKeyExpiration(test.input)
+		if test.errContains != "" {
+			require.Error(t, err, test.input)
+			assert.Contains(t, err.Error(), test.errContains, test.input)
+			continue
+		}
+		require.NoError(t, err, test.input)
+		assert.Equal(t, test.expiration, d, test.input)
+	}
+}
+
+func TestHashExpirationWithoutFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiration string
+		onlyIfChg  bool
+		batchSize  int
+		expected   []string
+	}{
+		{name: "single", expiration: "1m", batchSize: 1, expected: []string{"pexpire"}},
+		{name: "batch", expiration: "1m", batchSize: 2, expected: []string{"pexpire", "pexpire"}},
+		{name: "only if changed", expiration: "1m", onlyIfChg: true, batchSize: 1, expected: []string{"pexpire"}},
+		{name: "no expiration", batchSize: 1},
+		{name: "no expiration batch", batchSize: 2},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			urlStr, received := recordingServer(t)
+
+			conf := output.NewRedisHashConfig()
+			conf.URL = urlStr
+			conf.Key = "foo"
+			conf.SetCommand = "hmset"
+			conf.OnInterpError = "skip"
+			conf.OnlyIfChanged = test.onlyIfChg
+			conf.Expiration = test.expiration
+			conf.Fields = map[string]string{
+				"content": `${! meta("nope").or(throw("no content")) }`,
+			}
+
+			w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+			require.NoError(t, err)
+			client, err := clientFromConfig(conf.Config)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = client.Close()
+			})
+			w.client = client
+
+			parts := make([][]byte, test.batchSize)
+			for i := range parts {
+				parts[i] = []byte("bar")
+			}
+
+			// All fields are skipped, but the expiration of the key is still
+			// renewed.
+			require.NoError(t, w.WriteWithContext(context.Background(), message.QuickBatch(parts)))
+			assert.Equal(t, test.expected, received())
+		})
+	}
+}
+
+func TestHashWrite(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.Key = `${! json("id") }`
+	conf.WalkJSONObject = true
+	conf.SanitizeFieldNames = true
+	conf.Expiration = `${! meta("ttl").or(throw("no ttl")) }`
+
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	msg := message.QuickBatch([][]byte{
+		[]byte(`{"id":"foo","a.b":"c","n":10.5}`),
+		[]byte(`{"id":"bar"}`),
+		[]byte(`not json`),
+		[]byte(`{"id":""}`),
+		[]byte(`{"id":"baz"}`),
+	})
+	msg.Get(0).MetaSet("ttl", "1m")
+	msg.Get(1).MetaSet("ttl", "nope")
+	msg.Get(2).MetaSet("ttl", "1m")
+	msg.Get(3).MetaSet("ttl", "1m")
+
+	hw, err := w.hashWrite(msg, 0)
+	require.NoError(t, err)
+	assert.Equal(t, &hashWrite{
+		key:        "foo",
+		fields:     map[string]interface{}{"id": "foo", "a_b": "c", "n": "10.5"},
+		expiration: time.Minute,
+	}, hw)
+
+	_, err = w.hashWrite(msg, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse expiration")
+
+	_, err = w.hashWrite(msg, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to interpolate key")
+
+	_, err = w.hashWrite(msg, 3)
+	require.EqualError(t, err, "key resolved to an empty string")
+
+	_, err = w.hashWrite(msg, 4)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to interpolate expiration")
+
+	conf.Key = "foo"
+	conf.Expiration = ""
+	w, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	_, err = w.hashWrite(msg, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to walk JSON object")
+}
+
+func TestHashDeleteEmpty(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.Key = "foo"
+	conf.WalkJSONObject = true
+	conf.Fields = map[string]string{
+		"status": `${! json("status").or("") }`,
+		"b":      "",
+	}
+
+	msg := message.QuickBatch([][]byte{
+		[]byte(`{"a":"1","b":"2","c":null,"d":"","e":false}`),
+	})
+
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	hw, err := w.hashWrite(msg, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a": "1", "b": "", "c": nil, "d": "", "e": false, "status": "",
+	}, hw.fields)
+	assert.Empty(t, hw.deleted)
+
+	conf.DeleteEmpty = true
+	w, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	hw, err = w.hashWrite(msg, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a": "1", "e": false,
+	}, hw.fields)
+	assert.Equal(t, []string{"b", "c", "d", "status"}, hw.deleted)
+}
+
+func TestHashOnlyIfChanged(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.WalkMetadata = true
+	conf.OnlyIfChanged = true
+
+	conf.Diff = true
+	_, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.EqualError(t, err, "only_if_changed cannot be combined with diff, which already skips unchanged fields")
+
+	conf.Diff = false
+	_, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+
+	w := &hashWrite{
+		key: "foo",
+		fields: map[string]interface{}{
+			"b": "2",
+			"a": int64(1),
+			"c": nil,
+		},
+		deleted: []string{"d"},
+	}
+	names := w.readFields()
+	assert.Equal(t, []string{"a", "b", "c", "d"}, names)
+
+	tests := []struct {
+		name    string
+		current []interface{}
+		matches bool
+	}{
+		{name: "all match", current: []interface{}{"1", "2", "", nil}, matches: true},
+		{name: "value differs", current: []interface{}{"1", "3", "", nil}},
+		{name: "field missing", current: []interface{}{"1", "2", nil, nil}},
+		{name: "deleted field exists", current: []interface{}{"1", "2", "", "4"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.matches, w.matches(names, test.current), test.name)
+	}
+
+	assert.False(t, w.noop())
+	w.unchanged = true
+	assert.True(t, w.noop())
+	w.expiration = time.Minute
+	assert.False(t, w.noop())
+}
+
+func TestHashCheckExpire(t *testing.T) {
+	assert.NoError(t, checkExpire(nil))
+	assert.NoError(t, checkExpire([]*redis.Cmd{redis.NewCmdResult(int64(1), nil)}))
+
+	// Errors that are not from the server are handled by the write itself.
+	assert.NoError(t, checkExpire([]*redis.Cmd{redis.NewCmdResult(nil, errors.New("connection reset"))}))
+
+	rec := &recordedCommands{err: redisError("ERR unknown command 'hpexpire', with args beginning with: 'foo'")}
+	err := checkExpire([]*redis.Cmd{rec.Do()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to set field expiry, which requires Redis 7.4 or newer")
+
+	rec = &recordedCommands{err: redisError("WRONGTYPE Operation against a key holding the wrong kind of value")}
+	err = checkExpire([]*redis.Cmd{rec.Do()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to set field expiry: WRONGTYPE")
+}
+
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func (redisError) RedisError() {}
+
+// fakeServer starts a server that speaks enough of the Redis protocol to
+// answer each command with the raw reply returned by handle, and returns its
+// URL.
+func fakeServer(t *testing.T, handle func(args []string) string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					// Each command is an array of bulk strings.
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+					args := make([]string, n)
+					for i := range args {
+						if line, err = r.ReadString('\n'); err != nil {
+							return
+						}
+						size, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+						arg := make([]byte, size+2)
+						if _, err := io.ReadFull(r, arg); err != nil {
+							return
+						}
+						args[i] = string(arg[:size])
+					}
+					if _, err := conn.Write([]byte(handle(args))); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+// rejectingServer starts a fake server that rejects every command with a
+// WRONGTYPE error, and returns its URL.
+func rejectingServer(t *testing.T) string {
+	t.Helper()
+	return fakeServer(t, func([]string) string {
+		return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	})
+}
+
+// recordingServer starts a fake server that accepts every command, replying
+// with an integer, and records the commands it receives. It returns its URL
+// and a function that returns the names of the commands received so far.
+func recordingServer(t *testing.T) (string, func() []string) {
+	t.Helper()
+
+	var mut sync.Mutex
+	var names []string
+	urlStr := fakeServer(t, func(args []string) string {
+		mut.Lock()
+		names = append(names, strings.ToLower(args[0]))
+		mut.Unlock()
+		return ":1\r\n"
+	})
+	return urlStr, func() []string {
+		mut.Lock()
+		defer mut.Unlock()
+		return append([]string(nil), names...)
+	}
+}
+
+func TestHashSingleMessageRejected(t *testing.T) {
+	tests := map[string]func(conf *output.RedisHashConfig){
+		"hmset": func(conf *output.RedisHashConfig) {},
+		"pipeline": func(conf *output.RedisHashConfig) {
+			conf.Expiration = "1m"
+		},
+		"diff": func(conf *output.RedisHashConfig) {
+			conf.Diff = true
+		},
+	}
+
+	for name, fn := range tests {
+		fn := fn
+		t.Run(name, func(t *testing.T) {
+			conf := output.NewRedisHashConfig()
+			conf.URL = rejectingServer(t)
+			conf.Key = "foo"
+			conf.SetCommand = "hmset"
+			conf.Fields = map[string]string{
+				"content": "${! content() }",
+			}
+			fn(&conf)
+
+			w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+			require.NoError(t, err)
+			client, err := clientFromConfig(conf.Config)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = client.Close()
+			})
+			w.client = client
+
+			// A rejection fails the message without disconnecting, as
+			// reconnecting would retry it indefinitely.
+			err = w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{[]byte("bar")}))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "WRONGTYPE")
+			assert.NotEqual(t, component.ErrNotConnected, err)
+
+			w.connMut.RLock()
+			assert.NotNil(t, w.client)
+			w.connMut.RUnlock()
+		})
+	}
+}
+
+func TestHashCountNewFields(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.WalkMetadata = true
+
+	stats := metrics.NewLocal()
+	mgr := mock.NewManager()
+	mgr.M = stats
+
+	w, err := newRedisHashWriter(conf, mgr, log.Noop())
+	require.NoError(t, err)
+	assert.Nil(t, w.mFieldsCreated)
+
+	// HMSET results are not counted.
+	w.countCreated("foo", redis.NewBoolResult(true, nil))
+	assert.Empty(t, stats.GetCounters())
+
+	conf.CountNewFields = true
+	w, err = newRedisHashWriter(conf, mgr, log.Noop())
+	require.NoError(t, err)
+
+	w.countCreated("foo", redis.NewIntResult(2, nil))
+	w.countCreated("bar", redis.NewIntResult(0, nil))
+	w.countCreated("baz", redis.NewIntResult(3, nil))
+	assert.Equal(t, int64(5), stats.GetCounters()["redis_hash_fields_created"])
+}
+
+func TestHashWriteHSetWithoutCounting(t *testing.T) {
+	urlStr, received := recordingServer(t)
+
+	conf := output.NewRedisHashConfig()
+	conf.URL = urlStr
+	conf.Key = "foo"
+	conf.SetCommand = "hset"
+	conf.CountNewFields = false
+	conf.Fields = map[string]string{
+		"content": "${! content() }",
+	}
+
+	w, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.NoError(t, err)
+	client, err := clientFromConfig(conf.Config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	w.client = client
+	w.useHSet = w.detectHSet(client)
+
+	require.NoError(t, w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{[]byte("bar")})))
+	require.NoError(t, w.WriteWithContext(context.Background(), message.QuickBatch([][]byte{[]byte("baz"), []byte("buz")})))
+	assert.Equal(t, []string{"hset", "hset", "hset"}, received())
+}
+
+func TestHashSetCommand(t *testing.T) {
+	conf := output.NewRedisHashConfig()
+	conf.URL = "tcp://localhost:6379"
+	conf.WalkMetadata = true
+
+	conf.SetCommand = "nope"
+	_, err := newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+	require.EqualError(t, err, "set_command option 'nope' not recognised")
+
+	for _, cmd := range []string{"hmset", "hset", "auto"} {
+		conf.SetCommand = cmd
+		_, err = newRedisHashWriter(conf, mock.NewManager(), log.Noop())
+		require.NoError(t, err, cmd)
+	}
+}
+
+func TestHashInfoSupportsHSet(t *testing.T) {
+	tests := []struct {
+		info        string
+		supported   bool
+		errContains string
+	}{
+		{info: "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n", supported: true},
+		{info: "# Server\r\nredis_version:4.0.0\r\n", supported: true},
+		{info: "# Server\r\nredis_version:3.2.12\r\n", supported: false},
+		{info: "# Server\r\nredis_version:nope\r\n", errContains: "failed to parse redis_version 'nope'"},
+		{info: "# Server\r\nredis_mode:standalone\r\n", errContains: "redis_version not found within server info"},
+	}
+
+	for _, test := range tests {
+		supported, err := infoSupportsHSet(test.info)
+		if test.errContains != "" {
+			require.Error(t, err, test.info)
+			assert.Contains(t, err.Error(), test.errContains, test.info)
+			continue
+		}
+		require.NoError(t, err, test.info)
+		assert.Equal(t, test.supported, supported, test.info)
+	}
+}
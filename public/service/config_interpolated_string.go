@@ -25,6 +25,15 @@ func NewInterpolatedStringMapField(name string) *ConfigField {
 	return &ConfigField{field: tf}
 }
 
+// NewInterpolatedStringListField describes a new config field consisting of a
+// list of interpolated string values. It is then possible to extract a slice of
+// *InterpolatedString from the resulting parsed config with the method
+// FieldInterpolatedStringList.
+func NewInterpolatedStringListField(name string) *ConfigField {
+	tf := docs.FieldString(name, "").IsInterpolated().Array()
+	return &ConfigField{field: tf}
+}
+
 // FieldInterpolatedString accesses a field from a parsed config that was
 // defined with NewInterpolatedStringField and returns either an
 // *InterpolatedString or an error if the string was invalid.
@@ -87,3 +96,39 @@ func (p *ParsedConfig) FieldInterpolatedStringMap(path ...string) (map[string]*I
 	}
 	return sMap, nil
 }
+
+// FieldInterpolatedStringList accesses a field that is a list of interpolated
+// string values from the parsed config by its name and returns the value.
+//
+// Returns an error if the field is not found, or is not a list of interpolated
+// strings.
+func (p *ParsedConfig) FieldInterpolatedStringList(path ...string) ([]*InterpolatedString, error) {
+	v, exists := p.field(path...)
+	if !exists {
+		return nil, fmt.Errorf("field '%v' was not found in the config", p.fullDotPath(path...))
+	}
+	iList, ok := v.([]interface{})
+	if !ok {
+		if sList, ok := v.([]string); ok {
+			iList = make([]interface{}, len(sList))
+			for i, sv := range sList {
+				iList[i] = sv
+			}
+		} else {
+			return nil, fmt.Errorf("expected field '%v' to be a string list, got %T", p.fullDotPath(path...), v)
+		}
+	}
+	sList := make([]*InterpolatedString, len(iList))
+	for i, ev := range iList {
+		str, ok := ev.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected field '%v' to be a string list, found an element of type %T", p.fullDotPath(path...), ev)
+		}
+		e, err := p.mgr.BloblEnvironment().NewField(str)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse interpolated field '%v': %v", strings.Join(path, "."), err)
+		}
+		sList[i] = &InterpolatedString{expr: e}
+	}
+	return sList, nil
+}
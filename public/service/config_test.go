@@ -62,7 +62,7 @@ c:
     e: evalue
 `,
 			lints: []docs.Lint{
-				docs.NewLintError(2, "field not_real not recognised"),
+				docs.NewLintError(2, docs.LintUnknown, "field not_real not recognised"),
 			},
 		},
 		{
@@ -79,7 +79,7 @@ c:
     e: evalue
 `,
 			lints: []docs.Lint{
-				docs.NewLintError(4, "field not_real not recognised"),
+				docs.NewLintError(4, docs.LintUnknown, "field not_real not recognised"),
 			},
 		},
 	}
@@ -387,3 +387,35 @@ b:
 	res = iConf["d"].String(NewMessage([]byte("hello world")))
 	assert.Equal(t, "xyzzy hello world baz", res)
 }
+
+func TestConfigInterpolatedStringList(t *testing.T) {
+	spec := NewConfigSpec().
+		Field(NewInterpolatedStringListField("a")).
+		Field(NewStringListField("b"))
+
+	parsedConfig, err := spec.ParseYAML(`
+a:
+  - foo ${! content() } bar
+  - xyzzy ${! content() } baz
+b:
+  - this is ${! json( } an invalid interp string
+  - this is another invalid interp string
+`, nil)
+	require.NoError(t, err)
+
+	_, err = parsedConfig.FieldInterpolatedStringList("b")
+	require.Error(t, err)
+
+	_, err = parsedConfig.FieldInterpolatedStringList("c")
+	require.Error(t, err)
+
+	iConf, err := parsedConfig.FieldInterpolatedStringList("a")
+	require.NoError(t, err)
+	require.Len(t, iConf, 2)
+
+	res := iConf[0].String(NewMessage([]byte("hello world")))
+	assert.Equal(t, "foo hello world bar", res)
+
+	res = iConf[1].String(NewMessage([]byte("hello world")))
+	assert.Equal(t, "xyzzy hello world baz", res)
+}
@@ -47,3 +47,26 @@ func TestInterpolatedString(t *testing.T) {
 		})
 	}
 }
+
+func TestInterpolatedStringStatic(t *testing.T) {
+	tests := []struct {
+		expr     string
+		static   string
+		isStatic bool
+	}{
+		{expr: ``, static: ``, isStatic: true},
+		{expr: `foo bar`, static: `foo bar`, isStatic: true},
+		{expr: `foo ${{! content() }} bar`, static: `foo ${! content() } bar`, isStatic: true},
+		{expr: `foo ${! content() } bar`, isStatic: false},
+		{expr: `${! meta("var1") }`, isStatic: false},
+	}
+
+	for _, test := range tests {
+		i, err := NewInterpolatedString(test.expr)
+		require.NoError(t, err, test.expr)
+
+		static, isStatic := i.Static()
+		assert.Equal(t, test.isStatic, isStatic, test.expr)
+		assert.Equal(t, test.static, static, test.expr)
+	}
+}
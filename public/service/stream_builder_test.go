@@ -759,6 +759,29 @@ output:
 	require.NoError(t, b.SetYAML(lintingErrorConfig))
 }
 
+func TestStreamBuilderEmptyInterpolatedField(t *testing.T) {
+	env := service.NewEnvironment()
+	require.NoError(t, env.RegisterOutput(
+		"interp_topic",
+		service.NewConfigSpec().Field(service.NewInterpolatedStringField("topic")),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (out service.Output, maxInFlight int, err error) {
+			return &noopOutput{}, 1, nil
+		},
+	))
+
+	// Empty interpolated fields without a default are only linted when they
+	// opt in, and therefore configs such as this continue to build.
+	b := env.NewStreamBuilder()
+	require.NoError(t, b.SetYAML(`
+output:
+  interp_topic:
+    topic: ""
+`))
+
+	_, err := b.Build()
+	require.NoError(t, err)
+}
+
 type disabledMux struct{}
 
 func (d disabledMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {